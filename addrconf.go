@@ -0,0 +1,75 @@
+package ndp
+
+import (
+	"fmt"
+)
+
+// slaacPrefixLength is the length of prefixes SLAAC can form addresses on,
+// leaving 64 bits for the interface identifier as described at
+// https://tools.ietf.org/html/rfc4291#section-2.5.1
+const slaacPrefixLength = 64
+
+// AddressConfiguration describes how a host should configure its addresses
+// based on a router advertisement
+type AddressConfiguration struct {
+	// SLAAC holds the prefixes to form addresses on as described at
+	// https://tools.ietf.org/html/rfc4862#section-5.5.3
+	SLAAC []*ICMPOptionPrefixInformation
+	// DHCPv6Address tells to request addresses using DHCPv6 IA_NA, as the
+	// router set the M flag
+	DHCPv6Address bool
+	// DHCPv6PrefixDelegation tells to request prefixes using DHCPv6-PD, as
+	// the router set the P flag on at least one prefix as described at
+	// https://tools.ietf.org/html/rfc9762#section-5
+	DHCPv6PrefixDelegation bool
+	// DHCPv6Information tells to only request other configuration using
+	// stateless DHCPv6, as the router set the O flag and no other DHCPv6
+	// exchange provides it
+	DHCPv6Information bool
+}
+
+func (c AddressConfiguration) String() string {
+	prefixes := []string{}
+	for _, p := range c.SLAAC {
+		prefixes = append(prefixes, fmt.Sprintf("%s/%d", p.Prefix, p.PrefixLength))
+	}
+
+	return fmt.Sprintf("slaac %s, dhcpv6 ia_na %t, dhcpv6-pd %t, dhcpv6 information %t", prefixes, c.DHCPv6Address, c.DHCPv6PrefixDelegation, c.DHCPv6Information)
+}
+
+// AddressConfiguration returns how a host should configure its addresses
+// based on the flags and prefix information options of this
+// ICMPRouterAdvertisement. Prefixes with the P flag set are left to
+// DHCPv6-PD and not used for SLAAC, even with the A flag set.
+func (p ICMPRouterAdvertisement) AddressConfiguration() AddressConfiguration {
+	c := AddressConfiguration{
+		SLAAC:         []*ICMPOptionPrefixInformation{},
+		DHCPv6Address: p.ManagedAddress,
+	}
+
+	for _, o := range p.Options {
+		pio, ok := o.(*ICMPOptionPrefixInformation)
+		if !ok {
+			continue
+		}
+
+		// ignore invalid prefixes
+		if pio.Prefix.To16() == nil || pio.ValidLifetime == 0 || pio.PreferredLifetime > pio.ValidLifetime {
+			continue
+		}
+
+		if pio.PrefixDelegation {
+			c.DHCPv6PrefixDelegation = true
+			continue
+		}
+
+		if pio.Auto && pio.PrefixLength == slaacPrefixLength && !pio.Prefix.IsLinkLocalUnicast() {
+			c.SLAAC = append(c.SLAAC, pio)
+		}
+	}
+
+	// other configuration comes along with addresses and prefixes
+	c.DHCPv6Information = p.OtherStateful && !c.DHCPv6Address && !c.DHCPv6PrefixDelegation
+
+	return c
+}
@@ -0,0 +1,86 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAddressConfiguration(t *testing.T) {
+	pio := func(prefix string, length uint8, auto, pd bool) *ICMPOptionPrefixInformation {
+		return &ICMPOptionPrefixInformation{
+			PrefixLength:      length,
+			OnLink:            true,
+			Auto:              auto,
+			PrefixDelegation:  pd,
+			ValidLifetime:     86400,
+			PreferredLifetime: 14400,
+			Prefix:            net.ParseIP(prefix),
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		ra      *ICMPRouterAdvertisement
+		descfix string
+	}{
+		{
+			"slaac only",
+			&ICMPRouterAdvertisement{
+				optionContainer: optionContainer{ICMPOptions{
+					pio("2001:db8:1::", 64, true, false),
+					// not autonomous
+					pio("2001:db8:2::", 64, false, false),
+					// interface identifier wouldn't fit
+					pio("2001:db8:3::", 48, true, false),
+					// link-local
+					pio("fe80::", 64, true, false),
+				}},
+			},
+			"slaac [2001:db8:1::/64], dhcpv6 ia_na false, dhcpv6-pd false, dhcpv6 information false",
+		},
+		{
+			"slaac and stateless dhcpv6",
+			&ICMPRouterAdvertisement{
+				OtherStateful:   true,
+				optionContainer: optionContainer{ICMPOptions{pio("2001:db8:1::", 64, true, false)}},
+			},
+			"slaac [2001:db8:1::/64], dhcpv6 ia_na false, dhcpv6-pd false, dhcpv6 information true",
+		},
+		{
+			"managed",
+			&ICMPRouterAdvertisement{
+				ManagedAddress: true,
+				OtherStateful:  true,
+			},
+			"slaac [], dhcpv6 ia_na true, dhcpv6-pd false, dhcpv6 information false",
+		},
+		{
+			"prefix delegation preferred",
+			&ICMPRouterAdvertisement{
+				OtherStateful: true,
+				optionContainer: optionContainer{ICMPOptions{
+					// P flag takes precedence over A flag
+					pio("2001:db8:1::", 64, true, true),
+					pio("2001:db8:2::", 64, true, false),
+				}},
+			},
+			"slaac [2001:db8:2::/64], dhcpv6 ia_na false, dhcpv6-pd true, dhcpv6 information false",
+		},
+		{
+			"invalid lifetimes",
+			&ICMPRouterAdvertisement{
+				optionContainer: optionContainer{ICMPOptions{
+					&ICMPOptionPrefixInformation{PrefixLength: 64, Auto: true, PrefixDelegation: true, Prefix: net.ParseIP("2001:db8:1::")},
+					&ICMPOptionPrefixInformation{PrefixLength: 64, Auto: true, ValidLifetime: 10, PreferredLifetime: 20, Prefix: net.ParseIP("2001:db8:2::")},
+				}},
+			},
+			"slaac [], dhcpv6 ia_na false, dhcpv6-pd false, dhcpv6 information false",
+		},
+	}
+
+	for _, test := range tests {
+		if desc := test.ra.AddressConfiguration().String(); desc != test.descfix {
+			t.Errorf("%s: fixture of '%s' did not match '%s'", test.desc, test.descfix, desc)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package ndp
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+// CertificationPathAllComponents is the Component of an
+// ICMPCertificationPathSolicitation requesting the whole certification path
+const CertificationPathAllComponents = 65535
+
+// ICMPCertificationPathSolicitation implements the Certification Path
+// Solicitation message as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.1
+type ICMPCertificationPathSolicitation struct {
+	optionContainer
+	Identifier uint16
+	Component  uint16
+}
+
+func (p ICMPCertificationPathSolicitation) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, component %d\n", p.Identifier, p.Component)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeCertificationPathSolicitation
+func (p ICMPCertificationPathSolicitation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeCertificationPathSolicitation
+}
+
+// Marshal returns byte slice representing this
+// ICMPCertificationPathSolicitation
+func (p ICMPCertificationPathSolicitation) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	binary.BigEndian.PutUint16(b[6:8], p.Component)
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+	return b, nil
+}
+
+// ICMPCertificationPathAdvertisement implements the Certification Path
+// Advertisement message as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.2
+type ICMPCertificationPathAdvertisement struct {
+	optionContainer
+	Identifier    uint16
+	AllComponents uint16
+	Component     uint16
+}
+
+func (p ICMPCertificationPathAdvertisement) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, component %d of %d\n", p.Identifier, p.Component, p.AllComponents)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeCertificationPathAdvertisement
+func (p ICMPCertificationPathAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeCertificationPathAdvertisement
+}
+
+// Marshal returns byte slice representing this
+// ICMPCertificationPathAdvertisement
+func (p ICMPCertificationPathAdvertisement) Marshal() ([]byte, error) {
+	b := make([]byte, 12)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	binary.BigEndian.PutUint16(b[6:8], p.AllComponents)
+	binary.BigEndian.PutUint16(b[8:10], p.Component)
+	// b[10:12] = reserved
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+	return b, nil
+}
+
+func parseCertificationPathSolicitation(b []byte) (ICMP, error) {
+	if len(b) < 8 {
+		return nil, errMessageTooShort
+	}
+
+	p := &ICMPCertificationPathSolicitation{
+		Identifier: binary.BigEndian.Uint16(b[4:6]),
+		Component:  binary.BigEndian.Uint16(b[6:8]),
+	}
+
+	if len(b) > 8 {
+		options, err := parseOptions(b[8:])
+		if err != nil {
+			return nil, err
+		}
+
+		p.Options = options
+	}
+
+	return p, nil
+}
+
+func parseCertificationPathAdvertisement(b []byte) (ICMP, error) {
+	if len(b) < 12 {
+		return nil, errMessageTooShort
+	}
+
+	p := &ICMPCertificationPathAdvertisement{
+		Identifier:    binary.BigEndian.Uint16(b[4:6]),
+		AllComponents: binary.BigEndian.Uint16(b[6:8]),
+		Component:     binary.BigEndian.Uint16(b[8:10]),
+	}
+
+	if len(b) > 12 {
+		options, err := parseOptions(b[12:])
+		if err != nil {
+			return nil, err
+		}
+
+		p.Options = options
+	}
+
+	return p, nil
+}
+
+// TrustAnchorNameType describes the type of name in an
+// ICMPOptionTrustAnchor
+type TrustAnchorNameType uint8
+
+// types currently defined in RFC3971 and RFC6495
+const (
+	TrustAnchorNameDER                        TrustAnchorNameType = 1
+	TrustAnchorNameFQDN                       TrustAnchorNameType = 2
+	TrustAnchorNameSHA1SubjectKeyIdentifier   TrustAnchorNameType = 3
+	TrustAnchorNameSHA224SubjectKeyIdentifier TrustAnchorNameType = 4
+	TrustAnchorNameSHA256SubjectKeyIdentifier TrustAnchorNameType = 5
+	TrustAnchorNameSHA384SubjectKeyIdentifier TrustAnchorNameType = 6
+	TrustAnchorNameSHA512SubjectKeyIdentifier TrustAnchorNameType = 7
+)
+
+func (t TrustAnchorNameType) String() string {
+	switch t {
+	case TrustAnchorNameDER:
+		return "der name"
+	case TrustAnchorNameFQDN:
+		return "fqdn"
+	case TrustAnchorNameSHA1SubjectKeyIdentifier:
+		return "sha-1 ski"
+	case TrustAnchorNameSHA224SubjectKeyIdentifier:
+		return "sha-224 ski"
+	case TrustAnchorNameSHA256SubjectKeyIdentifier:
+		return "sha-256 ski"
+	case TrustAnchorNameSHA384SubjectKeyIdentifier:
+		return "sha-384 ski"
+	case TrustAnchorNameSHA512SubjectKeyIdentifier:
+		return "sha-512 ski"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPOptionTrustAnchor implements the Trust Anchor option as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.3
+type ICMPOptionTrustAnchor struct {
+	NameType TrustAnchorNameType
+	// Name holds the DER-encoded X.501 name for TrustAnchorNameDER, the
+	// wire format domain name for TrustAnchorNameFQDN or the subject key
+	// identifier otherwise
+	Name []byte
+}
+
+// TrustAnchorOption returns the ICMPOptionTrustAnchor naming given
+// certificate by its subject
+func TrustAnchorOption(cert *x509.Certificate) *ICMPOptionTrustAnchor {
+	return &ICMPOptionTrustAnchor{
+		NameType: TrustAnchorNameDER,
+		Name:     cert.RawSubject,
+	}
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionTrustAnchor) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf(": %s ", o.NameType)
+
+	switch o.NameType {
+	case TrustAnchorNameDER:
+		var name pkix.RDNSequence
+		if _, err := asn1.Unmarshal(o.Name, &name); err == nil {
+			s += name.String()
+		} else {
+			s += fmt.Sprintf("%x", o.Name)
+		}
+	case TrustAnchorNameFQDN:
+		s += strings.Join(decDomainName(o.Name), ", ")
+	default:
+		s += fmt.Sprintf("%x", o.Name)
+	}
+
+	return s
+}
+
+// Type returns ICMPOptionTypeTrustAnchor
+func (o ICMPOptionTrustAnchor) Type() ICMPOptionType {
+	return ICMPOptionTypeTrustAnchor
+}
+
+// Len returns the length in bytes of ICMPOptionTrustAnchor
+func (o ICMPOptionTrustAnchor) Len() uint8 {
+	return uint8((4 + len(o.Name) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionTrustAnchor
+func (o ICMPOptionTrustAnchor) Marshal() ([]byte, error) {
+	l := (4 + len(o.Name) + 7) / 8
+	if l > 255 {
+		return nil, fmt.Errorf("trust anchor name of %d bytes too large", len(o.Name))
+	}
+
+	b := make([]byte, l*8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(l)
+	// option fields
+	b[2] = byte(o.NameType)
+	b[3] = byte(l*8 - 4 - len(o.Name))
+	copy(b[4:], o.Name)
+
+	return b, nil
+}
+
+// CertificateType describes the type of certificate in an
+// ICMPOptionCertificate
+type CertificateType uint8
+
+// types currently defined
+const (
+	CertificateTypeX509v3 CertificateType = 1
+)
+
+func (t CertificateType) String() string {
+	switch t {
+	case CertificateTypeX509v3:
+		return "x.509v3"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPOptionCertificate implements the Certificate option as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.4
+type ICMPOptionCertificate struct {
+	CertType CertificateType
+	// Certificate holds the DER-encoded certificate
+	Certificate []byte
+}
+
+// CertificateOption returns the ICMPOptionCertificate carrying given
+// certificate
+func CertificateOption(cert *x509.Certificate) *ICMPOptionCertificate {
+	return &ICMPOptionCertificate{
+		CertType:    CertificateTypeX509v3,
+		Certificate: cert.Raw,
+	}
+}
+
+// ParseCertificate returns the X.509 certificate in this
+// ICMPOptionCertificate
+func (o ICMPOptionCertificate) ParseCertificate() (*x509.Certificate, error) {
+	if o.CertType != CertificateTypeX509v3 {
+		return nil, fmt.Errorf("certificate type %d not supported", o.CertType)
+	}
+
+	return x509.ParseCertificate(o.Certificate)
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionCertificate) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf(": %s", o.CertType)
+	if cert, err := o.ParseCertificate(); err == nil {
+		s += fmt.Sprintf(" %s", cert.Subject)
+	}
+
+	return s
+}
+
+// Type returns ICMPOptionTypeCertificate
+func (o ICMPOptionCertificate) Type() ICMPOptionType {
+	return ICMPOptionTypeCertificate
+}
+
+// Len returns the length in bytes of ICMPOptionCertificate
+func (o ICMPOptionCertificate) Len() uint8 {
+	return uint8((4 + len(o.Certificate) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionCertificate
+func (o ICMPOptionCertificate) Marshal() ([]byte, error) {
+	l := (4 + len(o.Certificate) + 7) / 8
+	if l > 255 {
+		return nil, fmt.Errorf("certificate of %d bytes too large", len(o.Certificate))
+	}
+
+	b := make([]byte, l*8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(l)
+	// option fields
+	b[2] = byte(o.CertType)
+	// b[3] = reserved
+	copy(b[4:], o.Certificate)
+
+	return b, nil
+}
+
+func parseTrustAnchorOption(b []byte) (*ICMPOptionTrustAnchor, error) {
+	padLength := int(b[3])
+	if 4+padLength > len(b) {
+		return nil, fmt.Errorf("option %s (%d) has invalid pad length %d", ICMPOptionTypeTrustAnchor, ICMPOptionTypeTrustAnchor, padLength)
+	}
+
+	return &ICMPOptionTrustAnchor{
+		NameType: TrustAnchorNameType(b[2]),
+		Name:     b[4:(len(b) - padLength)],
+	}, nil
+}
+
+func parseCertificateOption(b []byte) (*ICMPOptionCertificate, error) {
+	o := &ICMPOptionCertificate{
+		CertType: CertificateType(b[2]),
+	}
+
+	// the certificate tells its own length, the rest is padding
+	var cert asn1.RawValue
+	if _, err := asn1.Unmarshal(b[4:], &cert); err != nil {
+		return nil, fmt.Errorf("invalid certificate in option %s (%d): %s", ICMPOptionTypeCertificate, ICMPOptionTypeCertificate, err)
+	}
+
+	o.Certificate = cert.FullBytes
+	return o, nil
+}
@@ -0,0 +1,189 @@
+package ndp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+func testSelfSignedCertificate(t *testing.T, cn string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Unix(1700000000, 0),
+		NotAfter:              time.Unix(1700000000, 0).Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestICMPCertificationPathSolicitation(t *testing.T) {
+	icmp := &ICMPCertificationPathSolicitation{
+		Identifier: 4660,
+		Component:  CertificationPathAllComponents,
+	}
+	icmp.AddOption(&ICMPOptionTrustAnchor{
+		NameType: TrustAnchorNameFQDN,
+		Name:     encDomainNameLabels([]string{"example.com."}),
+	})
+
+	if icmp.Type() != ipv6.ICMPTypeCertificationPathSolicitation {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeCertificationPathSolicitation)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{148, 0, 0, 0, 18, 52, 255, 255, 15, 3, 2, 7, 7, 101, 120, 97, 109, 112, 108, 101, 3, 99, 111, 109, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "certification path solicitation message, length 32, id 4660, component 65535\n    trust anchor option (15), length 24 (3): fqdn example.com."
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:6])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPCertificationPathAdvertisement(t *testing.T) {
+	anchor := testSelfSignedCertificate(t, "trust anchor")
+
+	icmp := &ICMPCertificationPathAdvertisement{
+		Identifier:    4660,
+		AllComponents: 1,
+		Component:     0,
+	}
+	icmp.AddOption(TrustAnchorOption(anchor))
+	icmp.AddOption(CertificateOption(anchor))
+
+	if icmp.Type() != ipv6.ICMPTypeCertificationPathAdvertisement {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeCertificationPathAdvertisement)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{149, 0, 0, 0, 18, 52, 0, 1, 0, 0, 0, 0}
+	if bytes.Compare(marshal[:12], fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal[:12])
+	}
+
+	if len(marshal)%8 != 4 {
+		t.Errorf("options of %d bytes not padded", len(marshal)-12)
+	}
+
+	ta, co := icmp.Options[0].Len(), icmp.Options[1].Len()
+	descfix := fmt.Sprintf("certification path advertisement message, length %d, id 4660, component 0 of 1\n", len(marshal))
+	descfix += fmt.Sprintf("    trust anchor option (15), length %d (%d): der name CN=trust anchor\n", int(ta)*8, ta)
+	descfix += fmt.Sprintf("    certificate option (16), length %d (%d): x.509v3 CN=trust anchor", int(co)*8, co)
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	o, err := parsedICMP.(*ICMPCertificationPathAdvertisement).GetOption(ICMPOptionTypeCertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := (*o).(*ICMPOptionCertificate).ParseCertificate()
+	if err != nil {
+		t.Error(err)
+	} else if !cert.Equal(anchor) {
+		t.Error("parsed certificate did not match")
+	}
+
+	_, err = ParseMessage(marshal[:10])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	// certificate should be valid DER
+	broken := []byte{16, 1, 1, 0, 0xff, 0xff, 0xff, 0xff}
+	if _, err := parseOptions(broken); err == nil {
+		t.Error("expected error for invalid certificate")
+	}
+}
+
+func TestTrustAnchorNameTypeString(t *testing.T) {
+	tests := []struct {
+		in  TrustAnchorNameType
+		out string
+	}{
+		{0, "<nil>"},
+		{TrustAnchorNameDER, "der name"},
+		{TrustAnchorNameFQDN, "fqdn"},
+		{TrustAnchorNameSHA256SubjectKeyIdentifier, "sha-256 ski"},
+	}
+
+	for _, test := range tests {
+		if strings.Compare(test.in.String(), test.out) != 0 {
+			t.Errorf("expected %s but got %s", test.out, test.in.String())
+		}
+	}
+}
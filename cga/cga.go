@@ -0,0 +1,254 @@
+// Package cga implements Cryptographically Generated Addresses as described
+// in https://tools.ietf.org/html/rfc3972, for use with SEcure Neighbor
+// Discovery.
+package cga
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+
+	"github.com/skoef/ndp"
+)
+
+// MaxSec is the highest security parameter that can be encoded in an
+// address, although anything above 2 or 3 is infeasible to generate
+const MaxSec = 7
+
+// MaxCollisionCount is the highest collision count allowed when an address
+// turned out to be in use already
+const MaxCollisionCount = 2
+
+var (
+	errInvalidSec            = errors.New("sec should be in range 0-7")
+	errInvalidCollisionCount = errors.New("collision count should be in range 0-2")
+	errPrefixMismatch        = errors.New("subnet prefix does not match address")
+	errHash1Mismatch         = errors.New("hash1 does not match interface identifier")
+	errHash2Mismatch         = errors.New("hash2 does not satisfy sec")
+)
+
+// Generate returns CGAParameters and the address derived from them for given
+// subnet prefix, public key and security parameter sec. For sec above 0 the
+// modifier is brute forced on all CPU cores, which is expensive: every
+// increment of sec multiplies the expected work by 2^16. The search stops
+// with the error of ctx when it's done.
+func Generate(ctx context.Context, prefix net.IP, pub crypto.PublicKey, sec uint8) (*ndp.CGAParameters, net.IP, error) {
+	if sec > MaxSec {
+		return nil, nil, errInvalidSec
+	}
+
+	if prefix.To16() == nil {
+		return nil, nil, fmt.Errorf("invalid subnet prefix %s", prefix)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modifier, err := findModifier(ctx, der, sec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := &ndp.CGAParameters{
+		Modifier:     modifier,
+		SubnetPrefix: make(net.IP, net.IPv6len),
+		PublicKey:    der,
+	}
+	copy(params.SubnetPrefix, prefix.To16()[:8])
+
+	addr, err := Address(params, sec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return params, addr, nil
+}
+
+// Address returns the address for given CGAParameters and sec. After a
+// collision during duplicate address detection, the CollisionCount of the
+// parameters can be incremented to get a new address without generating a
+// new modifier.
+func Address(params *ndp.CGAParameters, sec uint8) (net.IP, error) {
+	if sec > MaxSec {
+		return nil, errInvalidSec
+	}
+
+	if params.CollisionCount > MaxCollisionCount {
+		return nil, errInvalidCollisionCount
+	}
+
+	b, err := params.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	hash1 := sha1.Sum(b)
+
+	addr := make(net.IP, net.IPv6len)
+	copy(addr[0:8], params.SubnetPrefix.To16()[:8])
+	copy(addr[8:16], hash1[:8])
+	// sec goes in the leftmost 3 bits, u and g bits are cleared
+	addr[8] = (addr[8] & 0x1c) | (sec << 5)
+
+	return addr, nil
+}
+
+// Sec returns the security parameter encoded in given address
+func Sec(addr net.IP) uint8 {
+	return addr.To16()[8] >> 5
+}
+
+// Verify checks whether given address was generated from given
+// CGAParameters, as described at https://tools.ietf.org/html/rfc3972#section-5
+func Verify(addr net.IP, params *ndp.CGAParameters) error {
+	a := addr.To16()
+	if a == nil {
+		return fmt.Errorf("invalid address %s", addr)
+	}
+
+	if params.CollisionCount > MaxCollisionCount {
+		return errInvalidCollisionCount
+	}
+
+	prefix := params.SubnetPrefix.To16()
+	if prefix == nil || !bytes.Equal(prefix[:8], a[:8]) {
+		return errPrefixMismatch
+	}
+
+	b, err := params.Marshal()
+	if err != nil {
+		return err
+	}
+
+	// ignore sec, u and g bits
+	hash1 := sha1.Sum(b)
+	hash1[0] &= 0x1c
+	iid := make([]byte, 8)
+	copy(iid, a[8:16])
+	iid[0] &= 0x1c
+	if !bytes.Equal(hash1[:8], iid) {
+		return errHash1Mismatch
+	}
+
+	sec := Sec(a)
+	if sec == 0 {
+		return nil
+	}
+
+	hash2 := sha1.Sum(hash2Input(params.Modifier, params.PublicKey, params.ExtensionFields))
+	if !secSatisfied(hash2[:], sec) {
+		return errHash2Mismatch
+	}
+
+	return nil
+}
+
+// Option returns the ICMPOptionCGA carrying given CGAParameters, to be added
+// to for instance ICMPNeighborSolicitation and ICMPNeighborAdvertisement
+// before signing them with ndp.SignMessage
+func Option(params *ndp.CGAParameters) *ndp.ICMPOptionCGA {
+	return &ndp.ICMPOptionCGA{Parameters: *params}
+}
+
+// hash2Input returns the bytes Hash2 is calculated over: the modifier, 9
+// zero bytes in place of subnet prefix and collision count, the public key
+// and any extension fields
+func hash2Input(modifier [16]byte, pub, ext []byte) []byte {
+	b := make([]byte, 25, 25+len(pub)+len(ext))
+	copy(b[0:16], modifier[:])
+	b = append(b, pub...)
+	b = append(b, ext...)
+
+	return b
+}
+
+// secSatisfied returns true if the leftmost 16*sec bits of hash2 are zero
+func secSatisfied(hash2 []byte, sec uint8) bool {
+	for _, c := range hash2[:(2 * int(sec))] {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// increment adds 1 to given modifier as a big-endian 128 bit integer
+func increment(m *[16]byte) {
+	for i := len(m) - 1; i >= 0; i-- {
+		m[i]++
+		if m[i] != 0 {
+			return
+		}
+	}
+}
+
+// findModifier returns a modifier for which Hash2 satisfies sec. Each CPU
+// core increments its own random modifier until one of them finds one.
+func findModifier(ctx context.Context, pub []byte, sec uint8) ([16]byte, error) {
+	var modifier [16]byte
+	if _, err := rand.Read(modifier[:]); err != nil {
+		return modifier, err
+	}
+
+	// any modifier will do
+	if sec == 0 {
+		return modifier, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan [16]byte, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		var start [16]byte
+		if _, err := rand.Read(start[:]); err != nil {
+			return modifier, err
+		}
+
+		wg.Add(1)
+		go func(m [16]byte) {
+			defer wg.Done()
+
+			b := hash2Input(m, pub, nil)
+			for n := 0; ; n++ {
+				if n%1024 == 0 && ctx.Err() != nil {
+					return
+				}
+
+				hash2 := sha1.Sum(b)
+				if secSatisfied(hash2[:], sec) {
+					copy(m[:], b[0:16])
+					select {
+					case found <- m:
+						cancel()
+					default:
+					}
+					return
+				}
+
+				increment((*[16]byte)(b[0:16]))
+			}
+		}(start)
+	}
+
+	wg.Wait()
+
+	select {
+	case modifier = <-found:
+		return modifier, nil
+	default:
+		return modifier, ctx.Err()
+	}
+}
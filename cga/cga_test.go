@@ -0,0 +1,161 @@
+package cga
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestGenerateVerify(t *testing.T) {
+	key := testKey(t)
+	prefix := net.ParseIP("2001:db8:1:2::")
+
+	for _, sec := range []uint8{0, 1} {
+		params, addr, err := Generate(context.Background(), prefix, &key.PublicKey, sec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !addr.Mask(net.CIDRMask(64, 128)).Equal(prefix) {
+			t.Errorf("address %s not in prefix %s", addr, prefix)
+		}
+
+		if Sec(addr) != sec {
+			t.Errorf("unexpected sec %d in %s, expected %d", Sec(addr), addr, sec)
+		}
+
+		// u and g bits are cleared
+		if addr[8]&0x03 != 0 {
+			t.Errorf("u and g bits set in %s", addr)
+		}
+
+		if err := Verify(addr, params); err != nil {
+			t.Errorf("generated address %s did not verify: %s", addr, err)
+		}
+
+		// parameters survive the trip through the option
+		ns := &ndp.ICMPNeighborSolicitation{TargetAddress: addr}
+		ns.Options = append(ns.Options, Option(params))
+		m, err := ns.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := ndp.ParseMessage(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		o, err := parsed.(*ndp.ICMPNeighborSolicitation).GetOption(ndp.ICMPOptionTypeCGA)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := Verify(addr, &(*o).(*ndp.ICMPOptionCGA).Parameters); err != nil {
+			t.Errorf("address %s did not verify with parsed option: %s", addr, err)
+		}
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	key := testKey(t)
+	params, addr, err := Generate(context.Background(), net.ParseIP("fe80::"), &key.PublicKey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other prefix
+	other := make(net.IP, net.IPv6len)
+	copy(other, addr)
+	other[7] = 1
+	if err := Verify(other, params); err != errPrefixMismatch {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// other interface identifier
+	copy(other, addr)
+	other[15] ^= 0xff
+	if err := Verify(other, params); err != errHash1Mismatch {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// claiming a higher sec than generated for
+	copy(other, addr)
+	other[8] = (other[8] & 0x1f) | (3 << 5)
+	if err := Verify(other, params); err != errHash2Mismatch {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// collision count is limited
+	p := *params
+	p.CollisionCount = 3
+	if err := Verify(addr, &p); err != errInvalidCollisionCount {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Address(&p, 1); err != errInvalidCollisionCount {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// collisions lead to a new address, which verifies as well
+	p.CollisionCount = 1
+	next, err := Address(&p, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.Equal(addr) {
+		t.Errorf("collision count did not change address %s", addr)
+	}
+
+	if err := Verify(next, &p); err != nil {
+		t.Errorf("address %s did not verify: %s", next, err)
+	}
+
+	if err := Verify(addr, &p); err != errHash1Mismatch {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGenerateCancel(t *testing.T) {
+	key := testKey(t)
+
+	if _, _, err := Generate(context.Background(), net.ParseIP("fe80::"), &key.PublicKey, 8); err != errInvalidSec {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// sec 4 would take ages
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := Generate(ctx, net.ParseIP("fe80::"), &key.PublicKey, 4); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	m := [16]byte{15: 0xff}
+	increment(&m)
+	if m[14] != 1 || m[15] != 0 {
+		t.Errorf("unexpected modifier %x", m)
+	}
+
+	m = [16]byte{0: 0xff, 1: 0xff, 2: 0xff, 3: 0xff, 4: 0xff, 5: 0xff, 6: 0xff, 7: 0xff, 8: 0xff, 9: 0xff, 10: 0xff, 11: 0xff, 12: 0xff, 13: 0xff, 14: 0xff, 15: 0xff}
+	increment(&m)
+	if m != [16]byte{} {
+		t.Errorf("unexpected modifier %x", m)
+	}
+}
@@ -0,0 +1,261 @@
+package ndp
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// ndHopLimit is the hop limit neighbor discovery messages are sent and
+	// received with, so they can't come from off-link as described at
+	// https://tools.ietf.org/html/rfc4861#section-6.1.1
+	ndHopLimit = 255
+	// mldHopLimit is the hop limit MLD and multicast router discovery
+	// messages are sent with, so they stay on-link as described at
+	// https://tools.ietf.org/html/rfc3810#section-5
+	mldHopLimit = 1
+	// defaultHopLimit is the hop limit of other messages sent by pipes
+	defaultHopLimit = 64
+)
+
+var errRouterAlertUnsupported = errors.New("router alert option not supported on this platform")
+
+// routerAlertMLD is a Hop-by-Hop Options header holding the Router Alert
+// option for MLD as described at https://tools.ietf.org/html/rfc2711,
+// padded to 8 octets. The next header is filled in when sending.
+var routerAlertMLD = []byte{0, 0, 5, 2, 0, 0, 1, 0}
+
+// Conn implements an interface to exchange ICMP messages with other nodes
+type Conn interface {
+	// ReadFrom returns the next ICMP received, the address it was sent from
+	// and the hop limit it was received with. Neighbor discovery messages
+	// received with a hop limit other than 255 are dropped.
+	ReadFrom() (ICMP, net.IP, int, error)
+	// WriteTo sends given ICMP to given destination address. Neighbor
+	// discovery messages are sent with hop limit 255, MLD messages with hop
+	// limit 1 and a Router Alert option, as are multicast router discovery
+	// messages.
+	WriteTo(m ICMP, dst net.IP) error
+	// Close closes the Conn
+	Close() error
+}
+
+// packetConn implements Conn on top of an ICMPv6 socket
+type packetConn struct {
+	conn  *net.IPConn
+	pconn *ipv6.PacketConn
+	zone  string
+}
+
+// ListenICMP returns a Conn sending and receiving ICMPv6 messages on given
+// local address, which may carry a zone such as "fe80::1%eth0" to use for
+// link-local destinations. Messages that can't be parsed are skipped.
+func ListenICMP(address string) (Conn, error) {
+	c, err := net.ListenPacket("ip6:ipv6-icmp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	// the hop limit of received neighbor discovery messages is checked
+	p := ipv6.NewPacketConn(c)
+	if err = p.SetControlMessage(ipv6.FlagHopLimit, true); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	zone := ""
+	if i := strings.LastIndex(address, "%"); i >= 0 {
+		zone = address[i+1:]
+	}
+
+	return &packetConn{conn: c.(*net.IPConn), pconn: p, zone: zone}, nil
+}
+
+func (c *packetConn) ReadFrom() (ICMP, net.IP, int, error) {
+	buf := make([]byte, 65535)
+	for {
+		n, cm, addr, err := c.pconn.ReadFrom(buf)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		m, err := ParseMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		// without control message the hop limit is unknown, so neighbor
+		// discovery messages are dropped
+		hopLimit := 0
+		if cm != nil {
+			hopLimit = cm.HopLimit
+		}
+
+		if !validHopLimit(m, hopLimit) {
+			continue
+		}
+
+		var src net.IP
+		if ipAddr, ok := addr.(*net.IPAddr); ok {
+			src = ipAddr.IP
+		}
+
+		return m, src, hopLimit, nil
+	}
+}
+
+func (c *packetConn) WriteTo(m ICMP, dst net.IP) error {
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	// checksum is calculated by the kernel
+	addr := &net.IPAddr{IP: dst}
+	if dst.IsLinkLocalUnicast() || dst.IsLinkLocalMulticast() || dst.IsInterfaceLocalMulticast() {
+		addr.Zone = c.zone
+	}
+
+	// the hop limit is set per message, as others like echo requests need
+	// the defaults of the socket, for both unicast and multicast
+	var oob []byte
+	switch {
+	case neighborDiscovery(m.Type()):
+		oob = (&ipv6.ControlMessage{HopLimit: ndHopLimit}).Marshal()
+	case multicastDiscovery(m.Type()):
+		ra, err := routerAlert()
+		if err != nil {
+			return err
+		}
+
+		oob = append((&ipv6.ControlMessage{HopLimit: mldHopLimit}).Marshal(), ra...)
+	}
+
+	_, _, err = c.conn.WriteMsgIP(b, oob, addr)
+	return err
+}
+
+func (c *packetConn) Close() error {
+	return c.conn.Close()
+}
+
+// neighborDiscovery returns true for messages that are only valid when
+// received with hop limit 255
+func neighborDiscovery(t ipv6.ICMPType) bool {
+	switch t {
+	case ipv6.ICMPTypeRouterSolicitation, ipv6.ICMPTypeRouterAdvertisement,
+		ipv6.ICMPTypeNeighborSolicitation, ipv6.ICMPTypeNeighborAdvertisement,
+		ipv6.ICMPTypeRedirect,
+		ipv6.ICMPTypeInverseNeighborDiscoverySolicitation, ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement,
+		ipv6.ICMPTypeCertificationPathSolicitation, ipv6.ICMPTypeCertificationPathAdvertisement:
+		return true
+	}
+
+	return false
+}
+
+// multicastDiscovery returns true for MLD and multicast router discovery
+// messages, which are sent with hop limit 1 and a Router Alert option as
+// described at https://tools.ietf.org/html/rfc4286#section-2
+func multicastDiscovery(t ipv6.ICMPType) bool {
+	switch t {
+	case ipv6.ICMPTypeMulticastListenerQuery, ipv6.ICMPTypeMulticastListenerReport,
+		ipv6.ICMPTypeMulticastListenerDone, ipv6.ICMPTypeVersion2MulticastListenerReport,
+		ipv6.ICMPTypeMulticastRouterAdvertisement, ipv6.ICMPTypeMulticastRouterSolicitation,
+		ipv6.ICMPTypeMulticastRouterTermination:
+		return true
+	}
+
+	return false
+}
+
+// validHopLimit returns false for neighbor discovery messages received with
+// a hop limit other than 255, which must be dropped
+func validHopLimit(m ICMP, hopLimit int) bool {
+	return !neighborDiscovery(m.Type()) || hopLimit == ndHopLimit
+}
+
+// pipeMessage is a message on its way through a pipe
+type pipeMessage struct {
+	b        []byte
+	hopLimit int
+}
+
+// pipeConn implements one end of an in-memory Conn
+type pipeConn struct {
+	addr net.IP
+	peer net.IP
+	in   <-chan pipeMessage
+	out  chan<- pipeMessage
+	done chan struct{}
+	once *sync.Once
+}
+
+// NewPipe returns two Conns connected to each other in memory, using given
+// addresses as their respective source addresses. Messages are marshalled
+// and parsed again on their way through, so both ends never share memory.
+// Closing either end closes both.
+func NewPipe(a, b net.IP) (Conn, Conn) {
+	ab := make(chan pipeMessage, 64)
+	ba := make(chan pipeMessage, 64)
+	done := make(chan struct{})
+	once := &sync.Once{}
+
+	return &pipeConn{addr: a, peer: b, in: ba, out: ab, done: done, once: once},
+		&pipeConn{addr: b, peer: a, in: ab, out: ba, done: done, once: once}
+}
+
+func (c *pipeConn) ReadFrom() (ICMP, net.IP, int, error) {
+	for {
+		select {
+		case pm := <-c.in:
+			m, err := ParseMessage(pm.b)
+			if err != nil {
+				continue
+			}
+
+			return m, c.peer, pm.hopLimit, nil
+		case <-c.done:
+			return nil, nil, 0, net.ErrClosed
+		}
+	}
+}
+
+func (c *pipeConn) WriteTo(m ICMP, dst net.IP) error {
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.done:
+		return net.ErrClosed
+	default:
+	}
+
+	pm := pipeMessage{b: b, hopLimit: defaultHopLimit}
+	if neighborDiscovery(m.Type()) {
+		pm.hopLimit = ndHopLimit
+	} else if multicastDiscovery(m.Type()) {
+		pm.hopLimit = mldHopLimit
+	}
+
+	select {
+	case c.out <- pm:
+		return nil
+	case <-c.done:
+		return net.ErrClosed
+	}
+}
+
+func (c *pipeConn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+
+	return nil
+}
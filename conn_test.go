@@ -0,0 +1,143 @@
+package ndp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+type sentMessage struct {
+	message ICMP
+	dst     net.IP
+}
+
+// recordingConn implements Conn by keeping track of all messages written to
+// it, it never returns anything to read
+type recordingConn struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (c *recordingConn) ReadFrom() (ICMP, net.IP, int, error) {
+	return nil, nil, 0, errors.New("nothing to read")
+}
+
+func (c *recordingConn) WriteTo(m ICMP, dst net.IP) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sent = append(c.sent, sentMessage{message: m, dst: dst})
+	return nil
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+// flush returns all messages written so far and forgets about them
+func (c *recordingConn) flush() []sentMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sent := c.sent
+	c.sent = nil
+	return sent
+}
+
+func TestPipe(t *testing.T) {
+	a, b := NewPipe(net.ParseIP("fe80::1"), net.ParseIP("fe80::2"))
+
+	req := &ICMPEchoRequest{Identifier: 1, SequenceNumber: 1, Data: []byte{1, 2, 3}}
+	if err := a.WriteTo(req, net.ParseIP("fe80::2")); err != nil {
+		t.Fatal(err)
+	}
+
+	m, src, hopLimit, err := b.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !src.Equal(net.ParseIP("fe80::1")) || hopLimit != defaultHopLimit {
+		t.Errorf("unexpected source %s or hop limit %d", src, hopLimit)
+	}
+
+	// messages don't share memory with the original
+	req.Data[0] = 9
+	if m.(*ICMPEchoRequest).Data[0] != 1 {
+		t.Error("received message shares memory with sent message")
+	}
+
+	// neighbor discovery is sent with hop limit 255
+	if err = a.WriteTo(&ICMPRouterSolicitation{}, net.ParseIP("ff02::2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, hopLimit, err = b.ReadFrom(); err != nil || hopLimit != ndHopLimit {
+		t.Errorf("unexpected hop limit %d: %v", hopLimit, err)
+	}
+
+	// mld is sent with hop limit 1
+	if err = a.WriteTo(&ICMPMulticastListenerQuery{MulticastAddress: net.IPv6zero}, net.ParseIP("ff02::1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, hopLimit, err = b.ReadFrom(); err != nil || hopLimit != mldHopLimit {
+		t.Errorf("unexpected hop limit %d: %v", hopLimit, err)
+	}
+
+	// closing one end closes both
+	b.Close()
+	if _, _, _, err = a.ReadFrom(); err != net.ErrClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err = a.WriteTo(req, net.ParseIP("fe80::2")); err != net.ErrClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidHopLimit(t *testing.T) {
+	tests := []struct {
+		m        ICMP
+		hopLimit int
+		valid    bool
+	}{
+		{&ICMPNeighborSolicitation{}, 255, true},
+		{&ICMPNeighborAdvertisement{}, 64, false},
+		{&ICMPRouterAdvertisement{}, 1, false},
+		{&ICMPRouterSolicitation{}, 0, false},
+		{&ICMPRedirect{}, 254, false},
+		{&ICMPCertificationPathSolicitation{}, 64, false},
+		{&ICMPEchoRequest{}, 1, true},
+		{&ICMPEchoReply{}, 64, true},
+	}
+
+	for _, test := range tests {
+		if validHopLimit(test.m, test.hopLimit) != test.valid {
+			t.Errorf("unexpected validity of %s with hop limit %d", test.m.Type(), test.hopLimit)
+		}
+	}
+}
+
+func TestMulticastDiscovery(t *testing.T) {
+	tests := []struct {
+		m   ICMP
+		mld bool
+	}{
+		{&ICMPMulticastListenerQuery{}, true},
+		{&ICMPMulticastListenerReport{}, true},
+		{&ICMPMulticastListenerDone{}, true},
+		{&ICMPVersion2MulticastListenerReport{}, true},
+		{&ICMPMulticastRouterAdvertisement{}, true},
+		{&ICMPMulticastRouterSolicitation{}, true},
+		{&ICMPMulticastRouterTermination{}, true},
+		{&ICMPNeighborSolicitation{}, false},
+		{&ICMPEchoRequest{}, false},
+	}
+
+	for _, test := range tests {
+		if multicastDiscovery(test.m.Type()) != test.mld {
+			t.Errorf("unexpected mld classification of %s", test.m.Type())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPEchoRequest implements the Echo Request message as described at
+// https://tools.ietf.org/html/rfc4443#section-4.1
+type ICMPEchoRequest struct {
+	Identifier     uint16
+	SequenceNumber uint16
+	Data           []byte
+}
+
+func (p ICMPEchoRequest) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, seq %d", p.Identifier, p.SequenceNumber)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeEchoRequest
+func (p ICMPEchoRequest) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeEchoRequest
+}
+
+// Marshal returns byte slice representing this ICMPEchoRequest
+func (p ICMPEchoRequest) Marshal() ([]byte, error) {
+	return marshalEcho(p.Type(), p.Identifier, p.SequenceNumber, p.Data), nil
+}
+
+// ICMPEchoReply implements the Echo Reply message as described at
+// https://tools.ietf.org/html/rfc4443#section-4.2
+type ICMPEchoReply struct {
+	Identifier     uint16
+	SequenceNumber uint16
+	Data           []byte
+}
+
+func (p ICMPEchoReply) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, seq %d", p.Identifier, p.SequenceNumber)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeEchoReply
+func (p ICMPEchoReply) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeEchoReply
+}
+
+// Marshal returns byte slice representing this ICMPEchoReply
+func (p ICMPEchoReply) Marshal() ([]byte, error) {
+	return marshalEcho(p.Type(), p.Identifier, p.SequenceNumber, p.Data), nil
+}
+
+func marshalEcho(t ipv6.ICMPType, id, seq uint16, data []byte) []byte {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(t)
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], id)
+	binary.BigEndian.PutUint16(b[6:8], seq)
+	b = append(b, data...)
+
+	return b
+}
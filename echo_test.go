@@ -0,0 +1,70 @@
+package ndp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPEcho(t *testing.T) {
+	tests := []struct {
+		icmp    ICMP
+		typ     ipv6.ICMPType
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPEchoRequest{Identifier: 1, SequenceNumber: 2, Data: []byte{1, 2, 3, 4}},
+			ipv6.ICMPTypeEchoRequest,
+			[]byte{128, 0, 0, 0, 0, 1, 0, 2, 1, 2, 3, 4},
+			"echo request, length 12, id 1, seq 2",
+		},
+		{
+			&ICMPEchoReply{Identifier: 258, SequenceNumber: 65535},
+			ipv6.ICMPTypeEchoReply,
+			[]byte{129, 0, 0, 0, 1, 2, 255, 255},
+			"echo reply, length 8, id 258, seq 65535",
+		},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.icmp.Type(), test.typ)
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(test.fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		parsedMarshal, err := parsedICMP.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+
+		_, err = ParseMessage(test.fixture[:6])
+		if err != errMessageTooShort {
+			t.Errorf("unexpected error message: %s", err)
+		}
+	}
+}
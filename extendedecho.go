@@ -0,0 +1,359 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv6"
+)
+
+var errMalformedExtension = errors.New("malformed icmp extension structure")
+
+// InterfaceIdentificationType implements the C-Types of the Interface
+// Identification Object as described at
+// https://tools.ietf.org/html/rfc8335#section-2.1
+type InterfaceIdentificationType uint8
+
+// types currently defined
+const (
+	_ InterfaceIdentificationType = iota
+	InterfaceIdentificationByName
+	InterfaceIdentificationByIndex
+	InterfaceIdentificationByAddress
+)
+
+// address family numbers as used in the Interface Identification Object
+const (
+	afiIPv4 = 1
+	afiIPv6 = 2
+)
+
+// class number of the Interface Identification Object
+const interfaceIdentificationClass = 3
+
+// InterfaceIdentification implements the Interface Identification Object as
+// described at https://tools.ietf.org/html/rfc8335#section-2.1, identifying
+// an interface by either name, index or address depending on its CType
+type InterfaceIdentification struct {
+	CType   InterfaceIdentificationType
+	Name    string
+	Index   uint32
+	Address net.IP
+}
+
+func (i InterfaceIdentification) String() string {
+	switch i.CType {
+	case InterfaceIdentificationByName:
+		return fmt.Sprintf("interface name %s", i.Name)
+	case InterfaceIdentificationByIndex:
+		return fmt.Sprintf("interface index %d", i.Index)
+	case InterfaceIdentificationByAddress:
+		return fmt.Sprintf("interface address %s", i.Address)
+	default:
+		return "interface <nil>"
+	}
+}
+
+// marshal returns the ICMP Extension Structure as described at
+// https://tools.ietf.org/html/rfc4884#section-7 holding this object
+func (i InterfaceIdentification) marshal() ([]byte, error) {
+	var payload []byte
+
+	switch i.CType {
+	case InterfaceIdentificationByName:
+		payload = []byte(i.Name)
+		// pad with NULs to a 32-bit boundary
+		for len(payload)%4 != 0 {
+			payload = append(payload, 0)
+		}
+	case InterfaceIdentificationByIndex:
+		payload = make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, i.Index)
+	case InterfaceIdentificationByAddress:
+		payload = make([]byte, 4)
+		if ip4 := i.Address.To4(); ip4 != nil {
+			binary.BigEndian.PutUint16(payload[0:2], afiIPv4)
+			payload[2] = net.IPv4len
+			payload = append(payload, ip4...)
+		} else if ip6 := i.Address.To16(); ip6 != nil {
+			binary.BigEndian.PutUint16(payload[0:2], afiIPv6)
+			payload[2] = net.IPv6len
+			payload = append(payload, ip6...)
+		} else {
+			return nil, fmt.Errorf("invalid interface address %s", i.Address)
+		}
+	default:
+		return nil, fmt.Errorf("interface identification type %d not supported", i.CType)
+	}
+
+	b := make([]byte, 8)
+	// extension header, version 2
+	b[0] = 0x20
+	// object header
+	binary.BigEndian.PutUint16(b[4:6], uint16(4+len(payload)))
+	b[6] = interfaceIdentificationClass
+	b[7] = uint8(i.CType)
+	b = append(b, payload...)
+
+	// checksum covers the whole extension structure
+	binary.BigEndian.PutUint16(b[2:4], internetChecksum(b))
+
+	return b, nil
+}
+
+func parseInterfaceIdentification(b []byte) (*InterfaceIdentification, error) {
+	// extension header and object header
+	if len(b) < 8 || b[0]>>4 != 2 {
+		return nil, errMalformedExtension
+	}
+
+	if internetChecksum(b) != 0 {
+		return nil, errMalformedExtension
+	}
+
+	l := int(binary.BigEndian.Uint16(b[4:6]))
+	if l < 4 || len(b) < 4+l || b[6] != interfaceIdentificationClass {
+		return nil, errMalformedExtension
+	}
+
+	i := &InterfaceIdentification{
+		CType: InterfaceIdentificationType(b[7]),
+	}
+	payload := b[8:(4 + l)]
+
+	switch i.CType {
+	case InterfaceIdentificationByName:
+		n := len(payload)
+		for n > 0 && payload[n-1] == 0 {
+			n--
+		}
+		i.Name = string(payload[:n])
+	case InterfaceIdentificationByIndex:
+		if len(payload) != 4 {
+			return nil, errMalformedExtension
+		}
+		i.Index = binary.BigEndian.Uint32(payload)
+	case InterfaceIdentificationByAddress:
+		if len(payload) < 4 || len(payload) < 4+int(payload[2]) {
+			return nil, errMalformedExtension
+		}
+
+		afi := binary.BigEndian.Uint16(payload[0:2])
+		switch {
+		case afi == afiIPv4 && payload[2] == net.IPv4len, afi == afiIPv6 && payload[2] == net.IPv6len:
+			i.Address = net.IP(payload[4:(4 + int(payload[2]))])
+		default:
+			return nil, errMalformedExtension
+		}
+	default:
+		return nil, errMalformedExtension
+	}
+
+	return i, nil
+}
+
+// ExtendedEchoReplyCode implements the codes of the Extended Echo Reply
+// message as described at https://tools.ietf.org/html/rfc8335#section-3
+type ExtendedEchoReplyCode uint8
+
+// codes currently defined
+const (
+	ExtendedEchoNoError ExtendedEchoReplyCode = iota
+	ExtendedEchoMalformedQuery
+	ExtendedEchoNoSuchInterface
+	ExtendedEchoNoSuchTableEntry
+	ExtendedEchoMultipleInterfaces
+)
+
+func (c ExtendedEchoReplyCode) String() string {
+	switch c {
+	case ExtendedEchoNoError:
+		return "no error"
+	case ExtendedEchoMalformedQuery:
+		return "malformed query"
+	case ExtendedEchoNoSuchInterface:
+		return "no such interface"
+	case ExtendedEchoNoSuchTableEntry:
+		return "no such table entry"
+	case ExtendedEchoMultipleInterfaces:
+		return "multiple interfaces satisfy query"
+	default:
+		return "<nil>"
+	}
+}
+
+// ExtendedEchoState implements the neighbor states of the Extended Echo
+// Reply message as described at https://tools.ietf.org/html/rfc8335#section-3
+type ExtendedEchoState uint8
+
+// states currently defined
+const (
+	ExtendedEchoStateReserved ExtendedEchoState = iota
+	ExtendedEchoStateIncomplete
+	ExtendedEchoStateReachable
+	ExtendedEchoStateStale
+	ExtendedEchoStateDelay
+	ExtendedEchoStateProbe
+	ExtendedEchoStateFailed
+)
+
+func (s ExtendedEchoState) String() string {
+	switch s {
+	case ExtendedEchoStateReserved:
+		return "reserved"
+	case ExtendedEchoStateIncomplete:
+		return "incomplete"
+	case ExtendedEchoStateReachable:
+		return "reachable"
+	case ExtendedEchoStateStale:
+		return "stale"
+	case ExtendedEchoStateDelay:
+		return "delay"
+	case ExtendedEchoStateProbe:
+		return "probe"
+	case ExtendedEchoStateFailed:
+		return "failed"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPExtendedEchoRequest implements the Extended Echo Request message as
+// described at https://tools.ietf.org/html/rfc8335#section-2
+type ICMPExtendedEchoRequest struct {
+	Identifier     uint16
+	SequenceNumber uint8
+	Local          bool
+	// Interface is nil when the request carried no valid Interface
+	// Identification Object
+	Interface *InterfaceIdentification
+}
+
+func (p ICMPExtendedEchoRequest) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, seq %d, ", p.Identifier, p.SequenceNumber)
+	if p.Local {
+		s += "local, "
+	}
+	if p.Interface == nil {
+		s += "interface <nil>"
+	} else {
+		s += p.Interface.String()
+	}
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeExtendedEchoRequest
+func (p ICMPExtendedEchoRequest) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeExtendedEchoRequest
+}
+
+// Marshal returns byte slice representing this ICMPExtendedEchoRequest
+func (p ICMPExtendedEchoRequest) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	b[6] = p.SequenceNumber
+	if p.Local {
+		b[7] ^= 0x01
+	}
+
+	if p.Interface != nil {
+		ext, err := p.Interface.marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, ext...)
+	}
+
+	return b, nil
+}
+
+// ICMPExtendedEchoReply implements the Extended Echo Reply message as
+// described at https://tools.ietf.org/html/rfc8335#section-3
+type ICMPExtendedEchoReply struct {
+	Code           ExtendedEchoReplyCode
+	Identifier     uint16
+	SequenceNumber uint8
+	State          ExtendedEchoState
+	Active         bool
+	IPv4           bool
+	IPv6           bool
+}
+
+func (p ICMPExtendedEchoReply) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s, ", p.Code)
+	s += fmt.Sprintf("id %d, seq %d, ", p.Identifier, p.SequenceNumber)
+	s += fmt.Sprintf("state %s, ", p.State)
+	f := []string{}
+	if p.Active {
+		f = append(f, "active")
+	}
+	if p.IPv4 {
+		f = append(f, "ipv4")
+	}
+	if p.IPv6 {
+		f = append(f, "ipv6")
+	}
+	s += fmt.Sprintf("Flags %s", f)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeExtendedEchoReply
+func (p ICMPExtendedEchoReply) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeExtendedEchoReply
+}
+
+// Marshal returns byte slice representing this ICMPExtendedEchoReply
+func (p ICMPExtendedEchoReply) Marshal() ([]byte, error) {
+	if p.State > 7 {
+		return nil, fmt.Errorf("state %d too large to fit in boundaries", p.State)
+	}
+
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	b[1] = uint8(p.Code)
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	b[6] = p.SequenceNumber
+	b[7] = uint8(p.State) << 5
+	if p.Active {
+		b[7] ^= 0x04
+	}
+	if p.IPv4 {
+		b[7] ^= 0x02
+	}
+	if p.IPv6 {
+		b[7] ^= 0x01
+	}
+
+	return b, nil
+}
+
+// internet checksum as described at https://tools.ietf.org/html/rfc1071
+func internetChecksum(b []byte) uint16 {
+	var s uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		s += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		s += uint32(b[len(b)-1]) << 8
+	}
+	for s > 0xffff {
+		s = s>>16 + s&0xffff
+	}
+
+	return ^uint16(s)
+}
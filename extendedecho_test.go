@@ -0,0 +1,143 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPExtendedEchoRequest(t *testing.T) {
+	tests := []struct {
+		icmp    *ICMPExtendedEchoRequest
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPExtendedEchoRequest{Identifier: 1, SequenceNumber: 2, Local: true, Interface: &InterfaceIdentification{CType: InterfaceIdentificationByName, Name: "eth0"}},
+			[]byte{160, 0, 0, 0, 0, 1, 2, 1, 32, 0, 15, 82, 0, 8, 3, 1, 101, 116, 104, 48},
+			"extended echo request, length 20, id 1, seq 2, local, interface name eth0",
+		},
+		{
+			&ICMPExtendedEchoRequest{Identifier: 1, SequenceNumber: 3, Local: true, Interface: &InterfaceIdentification{CType: InterfaceIdentificationByName, Name: "eth10"}},
+			[]byte{160, 0, 0, 0, 0, 1, 3, 1, 32, 0, 223, 76, 0, 12, 3, 1, 101, 116, 104, 49, 48, 0, 0, 0},
+			"extended echo request, length 24, id 1, seq 3, local, interface name eth10",
+		},
+		{
+			&ICMPExtendedEchoRequest{Identifier: 1, SequenceNumber: 4, Local: true, Interface: &InterfaceIdentification{CType: InterfaceIdentificationByIndex, Index: 7}},
+			[]byte{160, 0, 0, 0, 0, 1, 4, 1, 32, 0, 220, 238, 0, 8, 3, 2, 0, 0, 0, 7},
+			"extended echo request, length 20, id 1, seq 4, local, interface index 7",
+		},
+		{
+			&ICMPExtendedEchoRequest{Identifier: 1, SequenceNumber: 5, Interface: &InterfaceIdentification{CType: InterfaceIdentificationByAddress, Address: net.ParseIP("fe80::1")}},
+			[]byte{160, 0, 0, 0, 0, 1, 5, 0, 32, 0, 206, 96, 0, 24, 3, 3, 0, 2, 16, 0, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			"extended echo request, length 36, id 1, seq 5, interface address fe80::1",
+		},
+		{
+			&ICMPExtendedEchoRequest{Identifier: 1, SequenceNumber: 6, Interface: &InterfaceIdentification{CType: InterfaceIdentificationByAddress, Address: net.ParseIP("192.0.2.1")}},
+			[]byte{160, 0, 0, 0, 0, 1, 6, 0, 32, 0, 22, 238, 0, 12, 3, 3, 0, 1, 4, 0, 192, 0, 2, 1},
+			"extended echo request, length 24, id 1, seq 6, interface address 192.0.2.1",
+		},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != ipv6.ICMPTypeExtendedEchoRequest {
+			t.Errorf("wrong type: %d instead of %d", test.icmp.Type(), ipv6.ICMPTypeExtendedEchoRequest)
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(test.fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		parsedMarshal, err := parsedICMP.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+	}
+
+	// broken checksum leaves request without interface
+	fixture := []byte{160, 0, 0, 0, 0, 1, 2, 1, 32, 0, 0, 0, 0, 8, 3, 1, 101, 116, 104, 48}
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if parsedICMP.(*ICMPExtendedEchoRequest).Interface != nil {
+		t.Error("expected no interface for malformed extension")
+	}
+
+	_, err = ParseMessage(fixture[:6])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPExtendedEchoReply(t *testing.T) {
+	icmp := &ICMPExtendedEchoReply{
+		Identifier:     1,
+		SequenceNumber: 2,
+		State:          ExtendedEchoStateReachable,
+		Active:         true,
+		IPv6:           true,
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeExtendedEchoReply {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeExtendedEchoReply)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{161, 0, 0, 0, 0, 1, 2, 69}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "extended echo reply, length 8, no error, id 1, seq 2, state reachable, Flags [active ipv6]"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	icmp.State = 8
+	if _, err = icmp.Marshal(); err == nil {
+		t.Error("expected error for state out of bounds")
+	}
+}
@@ -0,0 +1,159 @@
+package ndp
+
+import (
+	"net"
+	"sync"
+)
+
+// ExtendedEchoInterface describes a local interface as answered on by
+// ExtendedEchoResponder
+type ExtendedEchoInterface struct {
+	Name      string
+	Index     uint32
+	Addresses []net.IP
+	Active    bool
+	IPv4      bool
+	IPv6      bool
+}
+
+// matches returns true if given identification refers to this interface
+func (i ExtendedEchoInterface) matches(id *InterfaceIdentification) bool {
+	switch id.CType {
+	case InterfaceIdentificationByName:
+		return i.Name == id.Name
+	case InterfaceIdentificationByIndex:
+		return i.Index == id.Index
+	case InterfaceIdentificationByAddress:
+		for _, a := range i.Addresses {
+			if a.Equal(id.Address) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ExtendedEchoResponder answers Extended Echo Requests as a proxy node as
+// described at https://tools.ietf.org/html/rfc8335#section-4, either for its
+// own interfaces or for the neighbors it knows the state of
+type ExtendedEchoResponder struct {
+	conn Conn
+
+	mu         sync.Mutex
+	interfaces []ExtendedEchoInterface
+	neighbors  map[string]ExtendedEchoState
+}
+
+// NewExtendedEchoResponder returns an ExtendedEchoResponder answering
+// requests received on given Conn about given interfaces
+func NewExtendedEchoResponder(conn Conn, interfaces []ExtendedEchoInterface) *ExtendedEchoResponder {
+	return &ExtendedEchoResponder{
+		conn:       conn,
+		interfaces: interfaces,
+		neighbors:  make(map[string]ExtendedEchoState),
+	}
+}
+
+// SetInterfaces replaces the interfaces requests are answered about
+func (r *ExtendedEchoResponder) SetInterfaces(interfaces []ExtendedEchoInterface) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.interfaces = interfaces
+}
+
+// SetNeighborState sets the state answered for given neighbor address
+func (r *ExtendedEchoResponder) SetNeighborState(address net.IP, state ExtendedEchoState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.neighbors[address.String()] = state
+}
+
+// RemoveNeighbor forgets about given neighbor address
+func (r *ExtendedEchoResponder) RemoveNeighbor(address net.IP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.neighbors, address.String())
+}
+
+// Serve answers requests read from the Conn until reading fails
+func (r *ExtendedEchoResponder) Serve() error {
+	for {
+		m, src, _, err := r.conn.ReadFrom()
+		if err != nil {
+			return err
+		}
+
+		if err = r.HandleMessage(src, m); err != nil {
+			return err
+		}
+	}
+}
+
+// HandleMessage answers given message if it is an Extended Echo Request,
+// other messages are ignored
+func (r *ExtendedEchoResponder) HandleMessage(src net.IP, m ICMP) error {
+	req, ok := m.(*ICMPExtendedEchoRequest)
+	if !ok {
+		return nil
+	}
+
+	return r.conn.WriteTo(r.Reply(req), src)
+}
+
+// Reply returns the ICMPExtendedEchoReply for given request
+func (r *ExtendedEchoResponder) Reply(req *ICMPExtendedEchoRequest) *ICMPExtendedEchoReply {
+	reply := &ICMPExtendedEchoReply{
+		Identifier:     req.Identifier,
+		SequenceNumber: req.SequenceNumber,
+	}
+
+	if req.Interface == nil {
+		reply.Code = ExtendedEchoMalformedQuery
+		return reply
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// neighbors can only be probed by address
+	if !req.Local {
+		if req.Interface.CType != InterfaceIdentificationByAddress {
+			reply.Code = ExtendedEchoMalformedQuery
+			return reply
+		}
+
+		state, ok := r.neighbors[req.Interface.Address.String()]
+		if !ok {
+			reply.Code = ExtendedEchoNoSuchTableEntry
+			return reply
+		}
+
+		reply.State = state
+		return reply
+	}
+
+	var found []ExtendedEchoInterface
+	for _, i := range r.interfaces {
+		if i.matches(req.Interface) {
+			found = append(found, i)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		reply.Code = ExtendedEchoNoSuchInterface
+	case 1:
+		reply.Active = found[0].Active
+		// protocol bits are only meaningful for active interfaces
+		reply.IPv4 = found[0].Active && found[0].IPv4
+		reply.IPv6 = found[0].Active && found[0].IPv6
+	default:
+		reply.Code = ExtendedEchoMultipleInterfaces
+	}
+
+	return reply
+}
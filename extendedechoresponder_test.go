@@ -0,0 +1,67 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestExtendedEchoResponder(t *testing.T) {
+	local, remote := NewPipe(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"))
+	defer local.Close()
+
+	responder := NewExtendedEchoResponder(local, []ExtendedEchoInterface{
+		{Name: "eth0", Index: 1, Addresses: []net.IP{net.ParseIP("2001:db8::1")}, Active: true, IPv6: true},
+		{Name: "eth1", Index: 2, Active: false, IPv4: true, IPv6: true},
+		{Name: "eth2", Index: 2},
+	})
+	responder.SetNeighborState(net.ParseIP("fe80::10"), ExtendedEchoStateStale)
+	go responder.Serve()
+
+	// over the wire
+	req := &ICMPExtendedEchoRequest{Identifier: 9, SequenceNumber: 1, Local: true, Interface: &InterfaceIdentification{CType: InterfaceIdentificationByName, Name: "eth0"}}
+	if err := remote.WriteTo(req, net.ParseIP("2001:db8::1")); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _, _, err := remote.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := m.(*ICMPExtendedEchoReply)
+	if reply.Identifier != 9 || reply.SequenceNumber != 1 || reply.Code != ExtendedEchoNoError || !reply.Active || reply.IPv4 || !reply.IPv6 {
+		t.Errorf("unexpected reply %s", reply)
+	}
+
+	tests := []struct {
+		local bool
+		id    *InterfaceIdentification
+		code  ExtendedEchoReplyCode
+		state ExtendedEchoState
+		flags [3]bool
+	}{
+		{true, &InterfaceIdentification{CType: InterfaceIdentificationByAddress, Address: net.ParseIP("2001:db8::1")}, ExtendedEchoNoError, 0, [3]bool{true, false, true}},
+		// inactive interfaces don't report protocols
+		{true, &InterfaceIdentification{CType: InterfaceIdentificationByName, Name: "eth1"}, ExtendedEchoNoError, 0, [3]bool{}},
+		{true, &InterfaceIdentification{CType: InterfaceIdentificationByName, Name: "eth3"}, ExtendedEchoNoSuchInterface, 0, [3]bool{}},
+		{true, &InterfaceIdentification{CType: InterfaceIdentificationByIndex, Index: 2}, ExtendedEchoMultipleInterfaces, 0, [3]bool{}},
+		{true, nil, ExtendedEchoMalformedQuery, 0, [3]bool{}},
+		// neighbors
+		{false, &InterfaceIdentification{CType: InterfaceIdentificationByAddress, Address: net.ParseIP("fe80::10")}, ExtendedEchoNoError, ExtendedEchoStateStale, [3]bool{}},
+		{false, &InterfaceIdentification{CType: InterfaceIdentificationByAddress, Address: net.ParseIP("fe80::11")}, ExtendedEchoNoSuchTableEntry, 0, [3]bool{}},
+		{false, &InterfaceIdentification{CType: InterfaceIdentificationByName, Name: "eth0"}, ExtendedEchoMalformedQuery, 0, [3]bool{}},
+	}
+
+	for _, test := range tests {
+		reply := responder.Reply(&ICMPExtendedEchoRequest{Local: test.local, Interface: test.id})
+		if reply.Code != test.code || reply.State != test.state || [3]bool{reply.Active, reply.IPv4, reply.IPv6} != test.flags {
+			t.Errorf("unexpected reply for %v: %s", test.id, reply)
+		}
+	}
+
+	responder.RemoveNeighbor(net.ParseIP("fe80::10"))
+	reply = responder.Reply(&ICMPExtendedEchoRequest{Interface: &InterfaceIdentification{CType: InterfaceIdentificationByAddress, Address: net.ParseIP("fe80::10")}})
+	if reply.Code != ExtendedEchoNoSuchTableEntry {
+		t.Errorf("unexpected reply for removed neighbor: %s", reply)
+	}
+}
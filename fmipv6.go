@@ -0,0 +1,431 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+// FMIPv6Subtype describes the subtype of ICMPv6 experimental mobility
+// messages
+type FMIPv6Subtype uint8
+
+// subtypes currently defined
+const (
+	FMIPv6SubtypeRouterSolicitationForProxyAdvertisement FMIPv6Subtype = 2
+	FMIPv6SubtypeProxyRouterAdvertisement                FMIPv6Subtype = 3
+)
+
+func (s FMIPv6Subtype) String() string {
+	switch s {
+	case FMIPv6SubtypeRouterSolicitationForProxyAdvertisement:
+		return "RtSolPr"
+	case FMIPv6SubtypeProxyRouterAdvertisement:
+		return "PrRtAdv"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPRouterSolicitationForProxyAdvertisement implements the Router
+// Solicitation for Proxy Advertisement (RtSolPr) message as described at
+// https://tools.ietf.org/html/rfc5568#section-6.1.1
+type ICMPRouterSolicitationForProxyAdvertisement struct {
+	optionContainer
+	Identifier uint16
+}
+
+func (p ICMPRouterSolicitationForProxyAdvertisement) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s %s, length %d, ", p.Type(), p.Subtype(), len(m))
+	s += fmt.Sprintf("id %d\n", p.Identifier)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeFMIPv6
+func (p ICMPRouterSolicitationForProxyAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeFMIPv6
+}
+
+// Subtype returns FMIPv6SubtypeRouterSolicitationForProxyAdvertisement
+func (p ICMPRouterSolicitationForProxyAdvertisement) Subtype() FMIPv6Subtype {
+	return FMIPv6SubtypeRouterSolicitationForProxyAdvertisement
+}
+
+// Marshal returns byte slice representing this
+// ICMPRouterSolicitationForProxyAdvertisement
+func (p ICMPRouterSolicitationForProxyAdvertisement) Marshal() ([]byte, error) {
+	return marshalFMIPv6(p.Type(), 0, p.Subtype(), p.Identifier, p.Options)
+}
+
+// ICMPProxyRouterAdvertisement implements the Proxy Router Advertisement
+// (PrRtAdv) message as described at
+// https://tools.ietf.org/html/rfc5568#section-6.1.2
+type ICMPProxyRouterAdvertisement struct {
+	optionContainer
+	// Code tells the mobile node how to interpret the options, see the RFC
+	// for the meaning of the values 0 to 5
+	Code       uint8
+	Identifier uint16
+}
+
+func (p ICMPProxyRouterAdvertisement) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s %s, length %d, ", p.Type(), p.Subtype(), len(m))
+	s += fmt.Sprintf("code %d, id %d\n", p.Code, p.Identifier)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeFMIPv6
+func (p ICMPProxyRouterAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeFMIPv6
+}
+
+// Subtype returns FMIPv6SubtypeProxyRouterAdvertisement
+func (p ICMPProxyRouterAdvertisement) Subtype() FMIPv6Subtype {
+	return FMIPv6SubtypeProxyRouterAdvertisement
+}
+
+// Marshal returns byte slice representing this ICMPProxyRouterAdvertisement
+func (p ICMPProxyRouterAdvertisement) Marshal() ([]byte, error) {
+	return marshalFMIPv6(p.Type(), p.Code, p.Subtype(), p.Identifier, p.Options)
+}
+
+func marshalFMIPv6(t ipv6.ICMPType, code uint8, subtype FMIPv6Subtype, id uint16, options ICMPOptions) ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(t)
+	b[1] = code
+	// b[2:3] = checksum, calculated separately
+	b[4] = uint8(subtype)
+	// b[5] = reserved
+	binary.BigEndian.PutUint16(b[6:8], id)
+	// add options
+	om, err := options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+	return b, nil
+}
+
+func parseFMIPv6(b []byte) (ICMP, error) {
+	if len(b) < 8 {
+		return nil, errMessageTooShort
+	}
+
+	var options ICMPOptions
+	if len(b) > 8 {
+		var err error
+		options, err = parseOptions(b[8:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id := binary.BigEndian.Uint16(b[6:8])
+	switch FMIPv6Subtype(b[4]) {
+	case FMIPv6SubtypeRouterSolicitationForProxyAdvertisement:
+		p := &ICMPRouterSolicitationForProxyAdvertisement{Identifier: id}
+		p.Options = options
+		return p, nil
+
+	case FMIPv6SubtypeProxyRouterAdvertisement:
+		p := &ICMPProxyRouterAdvertisement{Code: uint8(b[1]), Identifier: id}
+		p.Options = options
+		return p, nil
+
+	default:
+		return nil, fmt.Errorf("fmipv6 message with subtype %d not supported", b[4])
+	}
+}
+
+// IPAddressPrefixOptionCode describes what address or prefix an
+// ICMPOptionIPAddressPrefix holds
+type IPAddressPrefixOptionCode uint8
+
+// codes currently defined
+const (
+	IPAddressPrefixOldCareOfAddress IPAddressPrefixOptionCode = 1
+	IPAddressPrefixNewCareOfAddress IPAddressPrefixOptionCode = 2
+	IPAddressPrefixNARAddress       IPAddressPrefixOptionCode = 3
+	IPAddressPrefixNARPrefix        IPAddressPrefixOptionCode = 4
+)
+
+func (c IPAddressPrefixOptionCode) String() string {
+	switch c {
+	case IPAddressPrefixOldCareOfAddress:
+		return "old care-of address"
+	case IPAddressPrefixNewCareOfAddress:
+		return "new care-of address"
+	case IPAddressPrefixNARAddress:
+		return "nar address"
+	case IPAddressPrefixNARPrefix:
+		return "nar prefix"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPOptionIPAddressPrefix implements the IP Address/Prefix option as
+// described at https://tools.ietf.org/html/rfc5568#section-6.4.1
+type ICMPOptionIPAddressPrefix struct {
+	OptionCode   IPAddressPrefixOptionCode
+	PrefixLength uint8
+	Address      net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionIPAddressPrefix) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": %s %s/%d", o.OptionCode, o.Address, o.PrefixLength)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeIPAddressPrefix
+func (o ICMPOptionIPAddressPrefix) Type() ICMPOptionType {
+	return ICMPOptionTypeIPAddressPrefix
+}
+
+// Len returns the length in bytes of ICMPOptionIPAddressPrefix
+func (o ICMPOptionIPAddressPrefix) Len() uint8 {
+	// IP Address/Prefix options are always 3
+	return 3
+}
+
+// Marshal returns byte slice representing this ICMPOptionIPAddressPrefix
+func (o ICMPOptionIPAddressPrefix) Marshal() ([]byte, error) {
+	if o.Address.To16() == nil {
+		return nil, fmt.Errorf("invalid address %s", o.Address)
+	}
+
+	if o.PrefixLength > 128 {
+		return nil, fmt.Errorf("invalid prefix length %d", o.PrefixLength)
+	}
+
+	// option header
+	b := make([]byte, 8)
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(o.OptionCode)
+	b[3] = o.PrefixLength
+	// b[4:8] = reserved
+	b = append(b, o.Address.To16()...)
+
+	return b, nil
+}
+
+// LinkLayerAddressOptionCode describes whose link-layer address an
+// ICMPOptionLinkLayerAddress holds
+type LinkLayerAddressOptionCode uint8
+
+// codes currently defined
+const (
+	LinkLayerAddressWildcard LinkLayerAddressOptionCode = iota
+	LinkLayerAddressNewAccessPoint
+	LinkLayerAddressMobileNode
+	LinkLayerAddressNAR
+	LinkLayerAddressSource
+	LinkLayerAddressCurrentInterface
+	LinkLayerAddressNoPrefixInformation
+	LinkLayerAddressNoFastHandover
+)
+
+func (c LinkLayerAddressOptionCode) String() string {
+	switch c {
+	case LinkLayerAddressWildcard:
+		return "wildcard"
+	case LinkLayerAddressNewAccessPoint:
+		return "new access point"
+	case LinkLayerAddressMobileNode:
+		return "mobile node"
+	case LinkLayerAddressNAR:
+		return "nar"
+	case LinkLayerAddressSource:
+		return "source"
+	case LinkLayerAddressCurrentInterface:
+		return "current interface"
+	case LinkLayerAddressNoPrefixInformation:
+		return "no prefix information"
+	case LinkLayerAddressNoFastHandover:
+		return "no fast handover"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPOptionLinkLayerAddress implements the Link-Layer Address option as
+// described at https://tools.ietf.org/html/rfc5568#section-6.4.3
+type ICMPOptionLinkLayerAddress struct {
+	OptionCode       LinkLayerAddressOptionCode
+	LinkLayerAddress net.HardwareAddr
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionLinkLayerAddress) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": %s", o.OptionCode)
+	if len(o.LinkLayerAddress) > 0 {
+		s += fmt.Sprintf(" %s", o.LinkLayerAddress)
+	}
+
+	return s
+}
+
+// Type returns ICMPOptionTypeLinkLayerAddress
+func (o ICMPOptionLinkLayerAddress) Type() ICMPOptionType {
+	return ICMPOptionTypeLinkLayerAddress
+}
+
+// Len returns the length in bytes of ICMPOptionLinkLayerAddress
+func (o ICMPOptionLinkLayerAddress) Len() uint8 {
+	// header and option code, padded to multiples of 8 bytes
+	return uint8((3 + len(o.LinkLayerAddress) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionLinkLayerAddress
+func (o ICMPOptionLinkLayerAddress) Marshal() ([]byte, error) {
+	if 3+len(o.LinkLayerAddress) > 255*8 {
+		return nil, fmt.Errorf("link-layer address of %d bytes too large", len(o.LinkLayerAddress))
+	}
+
+	b := make([]byte, int(o.Len())*8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(o.OptionCode)
+	copy(b[3:], o.LinkLayerAddress)
+
+	return b, nil
+}
+
+// trimLinkLayerAddress strips the padding off a link-layer address when it
+// looks like a 48 or 64 bit address followed by zeroes, the option itself
+// doesn't tell how long the address is. Only padding means no address at all.
+func trimLinkLayerAddress(b []byte) net.HardwareAddr {
+	for _, l := range []int{0, 6, 8} {
+		if len(b) < l {
+			continue
+		}
+
+		padded := true
+		for _, c := range b[l:] {
+			if c != 0 {
+				padded = false
+				break
+			}
+		}
+
+		if padded {
+			return net.HardwareAddr(b[:l])
+		}
+	}
+
+	return net.HardwareAddr(b)
+}
+
+// NeighborAdvertisementAcknowledgeStatus describes the status of an
+// ICMPOptionNeighborAdvertisementAcknowledge
+type NeighborAdvertisementAcknowledgeStatus uint8
+
+// statuses currently defined
+const (
+	NAACKStatusNewCareOfAddressInvalid       NeighborAdvertisementAcknowledgeStatus = 1
+	NAACKStatusUseSuppliedNewCareOfAddress   NeighborAdvertisementAcknowledgeStatus = 2
+	NAACKStatusUseNARAddress                 NeighborAdvertisementAcknowledgeStatus = 3
+	NAACKStatusPreviousCareOfAddressSupplied NeighborAdvertisementAcknowledgeStatus = 4
+	NAACKStatusLinkLayerAddressUnrecognized  NeighborAdvertisementAcknowledgeStatus = 128
+)
+
+func (s NeighborAdvertisementAcknowledgeStatus) String() string {
+	switch s {
+	case NAACKStatusNewCareOfAddressInvalid:
+		return "ncoa invalid, perform address configuration"
+	case NAACKStatusUseSuppliedNewCareOfAddress:
+		return "ncoa invalid, use supplied ncoa"
+	case NAACKStatusUseNARAddress:
+		return "ncoa invalid, use nar address"
+	case NAACKStatusPreviousCareOfAddressSupplied:
+		return "pcoa supplied, do not send fbu"
+	case NAACKStatusLinkLayerAddressUnrecognized:
+		return "lla unrecognized"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPOptionNeighborAdvertisementAcknowledge implements the Neighbor
+// Advertisement Acknowledgment (NAACK) option as described at
+// https://tools.ietf.org/html/rfc5568#section-6.4.5
+type ICMPOptionNeighborAdvertisementAcknowledge struct {
+	Status NeighborAdvertisementAcknowledgeStatus
+	// NewCareOfAddress is only sent along with
+	// NAACKStatusUseSuppliedNewCareOfAddress
+	NewCareOfAddress net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionNeighborAdvertisementAcknowledge) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": status %s (%d)", o.Status, o.Status)
+	if o.NewCareOfAddress != nil {
+		s += fmt.Sprintf(", ncoa %s", o.NewCareOfAddress)
+	}
+
+	return s
+}
+
+// Type returns ICMPOptionTypeNeighborAdvertisementAcknowledge
+func (o ICMPOptionNeighborAdvertisementAcknowledge) Type() ICMPOptionType {
+	return ICMPOptionTypeNeighborAdvertisementAcknowledge
+}
+
+// Len returns the length in bytes of
+// ICMPOptionNeighborAdvertisementAcknowledge
+func (o ICMPOptionNeighborAdvertisementAcknowledge) Len() uint8 {
+	if o.NewCareOfAddress != nil {
+		return 3
+	}
+
+	return 1
+}
+
+// Marshal returns byte slice representing this
+// ICMPOptionNeighborAdvertisementAcknowledge
+func (o ICMPOptionNeighborAdvertisementAcknowledge) Marshal() ([]byte, error) {
+	// option header
+	b := make([]byte, 8)
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	// b[2] = option code, always 0
+	b[3] = byte(o.Status)
+	// b[4:8] = reserved
+	if o.NewCareOfAddress != nil {
+		if o.NewCareOfAddress.To16() == nil {
+			return nil, fmt.Errorf("invalid new care-of address %s", o.NewCareOfAddress)
+		}
+
+		b = append(b, o.NewCareOfAddress.To16()...)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,301 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestFMIPv6SubtypeString(t *testing.T) {
+	tests := []struct {
+		in  FMIPv6Subtype
+		out string
+	}{
+		{0, "<nil>"},
+		{FMIPv6SubtypeRouterSolicitationForProxyAdvertisement, "RtSolPr"},
+		{FMIPv6SubtypeProxyRouterAdvertisement, "PrRtAdv"},
+	}
+
+	for _, test := range tests {
+		if strings.Compare(test.in.String(), test.out) != 0 {
+			t.Errorf("expected %s but got %s", test.out, test.in.String())
+		}
+	}
+}
+
+func TestICMPFMIPv6(t *testing.T) {
+	rtsolpr := &ICMPRouterSolicitationForProxyAdvertisement{Identifier: 4660}
+	rtsolpr.AddOption(&ICMPOptionLinkLayerAddress{
+		OptionCode:       LinkLayerAddressMobileNode,
+		LinkLayerAddress: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	})
+	rtsolpr.AddOption(&ICMPOptionLinkLayerAddress{
+		OptionCode:       LinkLayerAddressNewAccessPoint,
+		LinkLayerAddress: net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb},
+	})
+
+	prrtadv := &ICMPProxyRouterAdvertisement{Identifier: 4660}
+	prrtadv.AddOption(&ICMPOptionLinkLayerAddress{
+		OptionCode:       LinkLayerAddressNewAccessPoint,
+		LinkLayerAddress: net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb},
+	})
+	prrtadv.AddOption(&ICMPOptionIPAddressPrefix{
+		OptionCode:   IPAddressPrefixNARPrefix,
+		PrefixLength: 64,
+		Address:      net.ParseIP("2001:db8:2::"),
+	})
+
+	tests := []struct {
+		icmp    ICMP
+		fixture []byte
+		descfix string
+	}{
+		{
+			rtsolpr,
+			[]byte{154, 0, 0, 0, 2, 0, 18, 52, 19, 2, 2, 0, 17, 34, 51, 68, 85, 0, 0, 0, 0, 0, 0, 0, 19, 2, 1, 102, 119, 136, 153, 170, 187, 0, 0, 0, 0, 0, 0, 0},
+			"fmipv6 messages RtSolPr, length 40, id 4660\n    link-layer address option (19), length 16 (2): mobile node 00:11:22:33:44:55\n    link-layer address option (19), length 16 (2): new access point 66:77:88:99:aa:bb",
+		},
+		{
+			prrtadv,
+			[]byte{154, 0, 0, 0, 3, 0, 18, 52, 19, 2, 1, 102, 119, 136, 153, 170, 187, 0, 0, 0, 0, 0, 0, 0, 17, 3, 4, 64, 0, 0, 0, 0, 32, 1, 13, 184, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			"fmipv6 messages PrRtAdv, length 48, code 0, id 4660\n    link-layer address option (19), length 16 (2): new access point 66:77:88:99:aa:bb\n    ip address/prefix option (17), length 24 (3): nar prefix 2001:db8:2::/64",
+		},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != ipv6.ICMPTypeFMIPv6 {
+			t.Errorf("wrong type: %d instead of %d", test.icmp.Type(), ipv6.ICMPTypeFMIPv6)
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(test.fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		parsedMarshal, err := parsedICMP.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+
+		_, err = ParseMessage(test.fixture[:6])
+		if err != errMessageTooShort {
+			t.Errorf("unexpected error message: %s", err)
+		}
+	}
+
+	// handover initiate and acknowledge moved to the mobility header
+	if _, err := ParseMessage([]byte{154, 0, 0, 0, 4, 0, 18, 52}); err == nil {
+		t.Error("expected error for unsupported subtype")
+	}
+}
+
+func TestICMPOptionLinkLayerAddress(t *testing.T) {
+	tests := []struct {
+		option  *ICMPOptionLinkLayerAddress
+		length  uint8
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPOptionLinkLayerAddress{OptionCode: LinkLayerAddressWildcard},
+			1,
+			[]byte{19, 1, 0, 0, 0, 0, 0, 0},
+			"link-layer address option (19), length 8 (1): wildcard",
+		},
+		{
+			&ICMPOptionLinkLayerAddress{OptionCode: LinkLayerAddressNAR, LinkLayerAddress: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+			2,
+			[]byte{19, 2, 3, 0, 17, 34, 51, 68, 85, 0, 0, 0, 0, 0, 0, 0},
+			"link-layer address option (19), length 16 (2): nar 00:11:22:33:44:55",
+		},
+		{
+			&ICMPOptionLinkLayerAddress{OptionCode: LinkLayerAddressSource, LinkLayerAddress: net.HardwareAddr{0x02, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55}},
+			2,
+			[]byte{19, 2, 4, 2, 17, 34, 255, 254, 51, 68, 85, 0, 0, 0, 0, 0},
+			"link-layer address option (19), length 16 (2): source 02:11:22:ff:fe:33:44:55",
+		},
+	}
+
+	for _, test := range tests {
+		if test.option.Type() != ICMPOptionTypeLinkLayerAddress {
+			t.Errorf("wrong type: %d instead of %d", test.option.Type(), ICMPOptionTypeLinkLayerAddress)
+		}
+
+		if test.option.Len() != test.length {
+			t.Errorf("wrong length, %d != %d", test.option.Len(), test.length)
+		}
+
+		marshal, err := test.option.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.option.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		options, err := parseOptions(test.fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if len(options) != 1 {
+			t.Errorf("parsed %d options instead of 1", len(options))
+		}
+
+		parsed := options[0].(*ICMPOptionLinkLayerAddress)
+		if bytes.Compare(parsed.LinkLayerAddress, test.option.LinkLayerAddress) != 0 {
+			t.Errorf("parsed address %s did not match %s", parsed.LinkLayerAddress, test.option.LinkLayerAddress)
+		}
+	}
+}
+
+func TestICMPOptionIPAddressPrefix(t *testing.T) {
+	option := &ICMPOptionIPAddressPrefix{
+		OptionCode:   IPAddressPrefixNewCareOfAddress,
+		PrefixLength: 128,
+		Address:      net.ParseIP("2001:db8:2::10"),
+	}
+
+	if option.Type() != ICMPOptionTypeIPAddressPrefix {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeIPAddressPrefix)
+	}
+
+	if option.Len() != 3 {
+		t.Errorf("wrong length, %d != 3", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{17, 3, 2, 128, 0, 0, 0, 0, 32, 1, 13, 184, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 16}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "ip address/prefix option (17), length 24 (3): new care-of address 2001:db8:2::10/128"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsedMarshal, err := options[0].Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	option.PrefixLength = 129
+	if _, err := option.Marshal(); err == nil {
+		t.Error("expected error for invalid prefix length")
+	}
+}
+
+func TestICMPOptionNeighborAdvertisementAcknowledge(t *testing.T) {
+	tests := []struct {
+		option  *ICMPOptionNeighborAdvertisementAcknowledge
+		length  uint8
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPOptionNeighborAdvertisementAcknowledge{Status: NAACKStatusNewCareOfAddressInvalid},
+			1,
+			[]byte{20, 1, 0, 1, 0, 0, 0, 0},
+			"neighbor advertisement acknowledgment option (20), length 8 (1): status ncoa invalid, perform address configuration (1)",
+		},
+		{
+			&ICMPOptionNeighborAdvertisementAcknowledge{Status: NAACKStatusUseSuppliedNewCareOfAddress, NewCareOfAddress: net.ParseIP("2001:db8:2::10")},
+			3,
+			[]byte{20, 3, 0, 2, 0, 0, 0, 0, 32, 1, 13, 184, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 16},
+			"neighbor advertisement acknowledgment option (20), length 24 (3): status ncoa invalid, use supplied ncoa (2), ncoa 2001:db8:2::10",
+		},
+	}
+
+	for _, test := range tests {
+		if test.option.Type() != ICMPOptionTypeNeighborAdvertisementAcknowledge {
+			t.Errorf("wrong type: %d instead of %d", test.option.Type(), ICMPOptionTypeNeighborAdvertisementAcknowledge)
+		}
+
+		if test.option.Len() != test.length {
+			t.Errorf("wrong length, %d != %d", test.option.Len(), test.length)
+		}
+
+		marshal, err := test.option.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.option.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		options, err := parseOptions(test.fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if len(options) != 1 {
+			t.Errorf("parsed %d options instead of 1", len(options))
+		}
+
+		parsedMarshal, err := options[0].Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+	}
+
+	// NAACK options are either 1 or 3 long
+	if _, err := parseOptions([]byte{20, 2, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Error("expected error for invalid length")
+	}
+}
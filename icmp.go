@@ -65,6 +65,12 @@ func Checksum(body *[]byte, srcIP, dstIP net.IP) error {
 	return nil
 }
 
+// container gives access to the options of any message embedding
+// optionContainer
+func (oc *optionContainer) container() *optionContainer {
+	return oc
+}
+
 // AddOption adds given ICMPOption to options of ICMP
 func (oc *optionContainer) AddOption(o ICMPOption) {
 	oc.Options = append(oc.Options, o)
@@ -143,6 +149,10 @@ func ParseMessage(b []byte) (ICMP, error) {
 		} else if b[5]&0x08 > 0 {
 			message.(*ICMPRouterAdvertisement).RouterPreference = RouterPreferenceHigh
 		}
+		if b[5]&0x04 > 0 {
+			message.(*ICMPRouterAdvertisement).Proxy = true
+		}
+		message.(*ICMPRouterAdvertisement).UnknownFlags = b[5] & raUnknownFlags
 
 		if len(b) > 16 {
 			options, err := parseOptions(b[16:])
@@ -198,6 +208,241 @@ func ParseMessage(b []byte) (ICMP, error) {
 
 		return message, nil
 
+	case ipv6.ICMPTypeRedirect:
+		if len(b) < 40 {
+			return nil, errMessageTooShort
+		}
+
+		p := &ICMPRedirect{
+			TargetAddress:      net.IP(b[8:24]),
+			DestinationAddress: net.IP(b[24:40]),
+		}
+
+		if len(b) > 40 {
+			options, err := parseOptions(b[40:])
+			if err != nil {
+				return nil, err
+			}
+
+			p.Options = options
+		}
+
+		return p, nil
+
+	case ipv6.ICMPTypeDestinationUnreachable:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPDestinationUnreachable{
+			Code:           DestinationUnreachableCode(b[1]),
+			InvokingPacket: b[8:],
+		}, nil
+
+	case ipv6.ICMPTypePacketTooBig:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPPacketTooBig{
+			MTU:            binary.BigEndian.Uint32(b[4:8]),
+			InvokingPacket: b[8:],
+		}, nil
+
+	case ipv6.ICMPTypeTimeExceeded:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPTimeExceeded{
+			Code:           TimeExceededCode(b[1]),
+			InvokingPacket: b[8:],
+		}, nil
+
+	case ipv6.ICMPTypeParameterProblem:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPParameterProblem{
+			Code:           ParameterProblemCode(b[1]),
+			Pointer:        binary.BigEndian.Uint32(b[4:8]),
+			InvokingPacket: b[8:],
+		}, nil
+
+	case ipv6.ICMPTypeEchoRequest:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPEchoRequest{
+			Identifier:     binary.BigEndian.Uint16(b[4:6]),
+			SequenceNumber: binary.BigEndian.Uint16(b[6:8]),
+			Data:           b[8:],
+		}, nil
+
+	case ipv6.ICMPTypeEchoReply:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPEchoReply{
+			Identifier:     binary.BigEndian.Uint16(b[4:6]),
+			SequenceNumber: binary.BigEndian.Uint16(b[6:8]),
+			Data:           b[8:],
+		}, nil
+
+	case ipv6.ICMPTypeMulticastListenerQuery:
+		return parseMulticastListenerQuery(b)
+
+	case ipv6.ICMPTypeMulticastListenerReport:
+		if len(b) < 24 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPMulticastListenerReport{
+			MulticastAddress: b[8:24],
+		}, nil
+
+	case ipv6.ICMPTypeMulticastListenerDone:
+		if len(b) < 24 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPMulticastListenerDone{
+			MulticastAddress: b[8:24],
+		}, nil
+
+	case ipv6.ICMPTypeVersion2MulticastListenerReport:
+		return parseVersion2MulticastListenerReport(b)
+
+	case ipv6.ICMPTypeRouterRenumbering:
+		return parseRouterRenumbering(b)
+
+	case ipv6.ICMPTypeNodeInformationQuery:
+		return parseNodeInformationQuery(b)
+
+	case ipv6.ICMPTypeNodeInformationResponse:
+		return parseNodeInformationReply(b)
+
+	case ipv6.ICMPTypeInverseNeighborDiscoverySolicitation:
+		message = &ICMPInverseNeighborDiscoverySolicitation{}
+
+		if len(b) > 8 {
+			options, err := parseOptions(b[8:])
+			if err != nil {
+				return nil, err
+			}
+
+			message.(*ICMPInverseNeighborDiscoverySolicitation).Options = options
+		}
+
+		return message, nil
+
+	case ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement:
+		message = &ICMPInverseNeighborDiscoveryAdvertisement{}
+
+		if len(b) > 8 {
+			options, err := parseOptions(b[8:])
+			if err != nil {
+				return nil, err
+			}
+
+			message.(*ICMPInverseNeighborDiscoveryAdvertisement).Options = options
+		}
+
+		return message, nil
+
+	case ipv6.ICMPTypeHomeAgentAddressDiscoveryRequest:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPHomeAgentAddressDiscoveryRequest{
+			Identifier: binary.BigEndian.Uint16(b[4:6]),
+		}, nil
+
+	case ipv6.ICMPTypeHomeAgentAddressDiscoveryReply:
+		return parseHomeAgentAddressDiscoveryReply(b)
+
+	case ipv6.ICMPTypeMobilePrefixSolicitation:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPMobilePrefixSolicitation{
+			Identifier: binary.BigEndian.Uint16(b[4:6]),
+		}, nil
+
+	case ipv6.ICMPTypeMobilePrefixAdvertisement:
+		return parseMobilePrefixAdvertisement(b)
+
+	case ipv6.ICMPTypeCertificationPathSolicitation:
+		return parseCertificationPathSolicitation(b)
+
+	case ipv6.ICMPTypeCertificationPathAdvertisement:
+		return parseCertificationPathAdvertisement(b)
+
+	case ipv6.ICMPTypeMulticastRouterAdvertisement:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPMulticastRouterAdvertisement{
+			AdvertisementInterval: b[1],
+			QueryInterval:         binary.BigEndian.Uint16(b[4:6]),
+			RobustnessVariable:    binary.BigEndian.Uint16(b[6:8]),
+		}, nil
+
+	case ipv6.ICMPTypeMulticastRouterSolicitation:
+		return &ICMPMulticastRouterSolicitation{}, nil
+
+	case ipv6.ICMPTypeMulticastRouterTermination:
+		return &ICMPMulticastRouterTermination{}, nil
+
+	case ipv6.ICMPTypeFMIPv6:
+		return parseFMIPv6(b)
+
+	case ipv6.ICMPTypeRPLControl:
+		return parseRPL(b)
+
+	case ipv6.ICMPTypeDuplicateAddressRequest, ipv6.ICMPTypeDuplicateAddressConfirmation:
+		return parseDuplicateAddress(b)
+
+	case ipv6.ICMPTypeExtendedEchoRequest:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		message = &ICMPExtendedEchoRequest{
+			Identifier:     binary.BigEndian.Uint16(b[4:6]),
+			SequenceNumber: b[6],
+			Local:          (b[7]&0x01 > 0),
+		}
+
+		// requests without a valid object are kept, so they can be
+		// answered as malformed
+		if i, err := parseInterfaceIdentification(b[8:]); err == nil {
+			message.(*ICMPExtendedEchoRequest).Interface = i
+		}
+
+		return message, nil
+
+	case ipv6.ICMPTypeExtendedEchoReply:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		return &ICMPExtendedEchoReply{
+			Code:           ExtendedEchoReplyCode(b[1]),
+			Identifier:     binary.BigEndian.Uint16(b[4:6]),
+			SequenceNumber: b[6],
+			State:          ExtendedEchoState(b[7] >> 5),
+			Active:         (b[7]&0x04 > 0),
+			IPv4:           (b[7]&0x02 > 0),
+			IPv6:           (b[7]&0x01 > 0),
+		}, nil
+
 	default:
 		return nil, fmt.Errorf("message with type %d not supported", icmpType)
 	}
@@ -211,7 +456,7 @@ type ICMPRouterSolicitation struct {
 
 func (p ICMPRouterSolicitation) String() string {
 	m, _ := p.Marshal()
-	s := fmt.Sprintf("%s, length %d\n", p.Type(), uint8(len(m)))
+	s := fmt.Sprintf("%s, length %d\n", p.Type(), len(m))
 	for _, o := range p.Options {
 		s += fmt.Sprintf("    %s\n", o)
 	}
@@ -266,6 +511,31 @@ func (typ RouterPreferenceField) String() string {
 	}
 }
 
+// RAFlag is the position of a router advertisement flag as described at
+// https://tools.ietf.org/html/rfc5175#section-3, with flags 0 to 7 in the
+// flags byte of the message and 8 to 55 in the Flags Expansion option
+type RAFlag uint8
+
+// flags currently defined
+const (
+	RAFlagManagedAddress RAFlag = iota
+	RAFlagOtherStateful
+	RAFlagHomeAgent
+	// router preference takes 2 bits
+	RAFlagRouterPreference
+	_
+	RAFlagProxy
+)
+
+// Known returns whether the flag is registered and modelled by this package
+func (f RAFlag) Known() bool {
+	return f <= RAFlagProxy
+}
+
+// raUnknownFlags masks the bits of the flags byte not modelled by
+// ICMPRouterAdvertisement
+const raUnknownFlags = 0x03
+
 // ICMPRouterAdvertisement implements the Router Advertisement message as
 // described at https://tools.ietf.org/html/rfc4861#section-4.2
 type ICMPRouterAdvertisement struct {
@@ -275,14 +545,43 @@ type ICMPRouterAdvertisement struct {
 	OtherStateful    bool
 	HomeAgent        bool
 	RouterPreference RouterPreferenceField
-	RouterLifeTime   uint16
-	ReachableTime    uint32
-	RetransTimer     uint32
+	// Proxy is set by ND proxies as described at
+	// https://tools.ietf.org/html/rfc4389#section-4.1.3.3
+	Proxy          bool
+	RouterLifeTime uint16
+	ReachableTime  uint32
+	RetransTimer   uint32
+	// UnknownFlags holds the bits of the flags byte that are not known,
+	// keeping them when marshalling
+	UnknownFlags uint8
+}
+
+// UnknownFlagsSet returns the flags that are set in the flags byte and the
+// Flags Expansion option without being known
+func (p ICMPRouterAdvertisement) UnknownFlagsSet() []RAFlag {
+	flags := []RAFlag{}
+	for f := RAFlag(0); f < 8; f++ {
+		if !f.Known() && p.UnknownFlags&(0x80>>f) > 0 {
+			flags = append(flags, f)
+		}
+	}
+
+	for _, o := range p.Options {
+		if fe, ok := o.(*ICMPOptionFlagsExpansion); ok {
+			for _, f := range fe.FlagsSet() {
+				if !f.Known() {
+					flags = append(flags, f)
+				}
+			}
+		}
+	}
+
+	return flags
 }
 
 func (p ICMPRouterAdvertisement) String() string {
 	m, _ := p.Marshal()
-	s := fmt.Sprintf("%s, length %d\n ", p.Type(), uint8(len(m)))
+	s := fmt.Sprintf("%s, length %d\n ", p.Type(), len(m))
 	s += fmt.Sprintf("hop limit %d, ", p.HopLimit)
 	f := []string{}
 	if p.ManagedAddress {
@@ -294,7 +593,13 @@ func (p ICMPRouterAdvertisement) String() string {
 	if p.HomeAgent {
 		f = append(f, "home agent")
 	}
+	if p.Proxy {
+		f = append(f, "proxy")
+	}
 	s += fmt.Sprintf("Flags %s, ", f)
+	if p.UnknownFlags&raUnknownFlags > 0 {
+		s += fmt.Sprintf("unknown flags 0x%02x, ", p.UnknownFlags&raUnknownFlags)
+	}
 	s += fmt.Sprintf("pref %s, ", p.RouterPreference)
 	s += fmt.Sprintf("router lifetime %ds, ", p.RouterLifeTime)
 	s += fmt.Sprintf("reachable time %ds, ", p.ReachableTime)
@@ -335,6 +640,10 @@ func (p ICMPRouterAdvertisement) Marshal() ([]byte, error) {
 	case RouterPreferenceHigh:
 		b[5] ^= 0x08
 	}
+	if p.Proxy {
+		b[5] ^= 0x04
+	}
+	b[5] ^= p.UnknownFlags & raUnknownFlags
 	binary.BigEndian.PutUint16(b[6:8], uint16(p.RouterLifeTime))
 	binary.BigEndian.PutUint32(b[8:12], uint32(p.ReachableTime))
 	binary.BigEndian.PutUint32(b[12:16], uint32(p.RetransTimer))
@@ -357,7 +666,7 @@ type ICMPNeighborSolicitation struct {
 
 func (p ICMPNeighborSolicitation) String() string {
 	m, _ := p.Marshal()
-	s := fmt.Sprintf("%s, length %d, ", p.Type(), uint8(len(m)))
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
 	s += fmt.Sprintf("who has %s\n", p.TargetAddress)
 	for _, o := range p.Options {
 		s += fmt.Sprintf("    %s\n", o)
@@ -401,7 +710,7 @@ type ICMPNeighborAdvertisement struct {
 
 func (p ICMPNeighborAdvertisement) String() string {
 	m, _ := p.Marshal()
-	s := fmt.Sprintf("%s, length %d, ", p.Type(), uint8(len(m)))
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
 	s += fmt.Sprintf("tgt is %s, ", p.TargetAddress)
 	s += "Flags ["
 	if p.Router {
@@ -453,3 +762,54 @@ func (p ICMPNeighborAdvertisement) Marshal() ([]byte, error) {
 
 	return b, nil
 }
+
+// ICMPRedirect implements the Redirect message as described at
+// https://tools.ietf.org/html/rfc4861#section-4.5
+type ICMPRedirect struct {
+	optionContainer
+	// TargetAddress is the better first hop, or equal to DestinationAddress
+	// when the destination is on-link
+	TargetAddress      net.IP
+	DestinationAddress net.IP
+}
+
+func (p ICMPRedirect) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s to %s\n", p.DestinationAddress, p.TargetAddress)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeRedirect
+func (p ICMPRedirect) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRedirect
+}
+
+// Marshal returns byte slice representing this ICMPRedirect
+func (p ICMPRedirect) Marshal() ([]byte, error) {
+	if p.TargetAddress.To16() == nil || p.DestinationAddress.To16() == nil {
+		return nil, fmt.Errorf("invalid target %s or destination %s", p.TargetAddress, p.DestinationAddress)
+	}
+
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	// b[4:8] = reserved
+	b = append(b, p.TargetAddress.To16()...)
+	b = append(b, p.DestinationAddress.To16()...)
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+
+	return b, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -16,8 +17,8 @@ func TestParseMessage(t *testing.T) {
 		t.Errorf("unexpected error message: %s", err)
 	}
 
-	_, err = ParseMessage([]byte{128, 0, 0, 0})
-	fixture := "message with type 128 not supported"
+	_, err = ParseMessage([]byte{200, 0, 0, 0})
+	fixture := "message with type 200 not supported"
 	if strings.Compare(fmt.Sprintf("%s", err), fixture) != 0 {
 		t.Errorf("unexpected error message: %s", err)
 	}
@@ -484,6 +485,156 @@ func TestICMPRouterAdvertisement(t *testing.T) {
 	}
 }
 
+func TestICMPRouterAdvertisementFlags(t *testing.T) {
+	icmp := &ICMPRouterAdvertisement{
+		HopLimit: 64,
+		Proxy:    true,
+		// known bits are ignored
+		UnknownFlags: 0x81,
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{134, 0, 0, 0, 64, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "router advertisement, length 16\n hop limit 64, Flags [proxy], unknown flags 0x01, pref medium, router lifetime 0s, reachable time 0s, retrans time 0s"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	fe := &ICMPOptionFlagsExpansion{}
+	fe.Set(8)
+	fe.Set(40)
+	icmp.AddOption(fe)
+
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedICMP, err := ParseMessage(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := parsedICMP.(*ICMPRouterAdvertisement)
+	if !parsed.Proxy || parsed.UnknownFlags != 0x01 {
+		t.Errorf("unexpected flags in %s", parsed)
+	}
+
+	// unset flags are not reported, unknown flags are
+	flags := parsed.UnknownFlagsSet()
+	if !reflect.DeepEqual(flags, []RAFlag{7, 8, 40}) {
+		t.Errorf("unexpected unknown flags %v", flags)
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	if !RAFlagProxy.Known() || RAFlag(6).Known() {
+		t.Error("unexpected known flags")
+	}
+}
+
+func TestICMPRedirect(t *testing.T) {
+	icmp := &ICMPRedirect{
+		TargetAddress:      net.ParseIP("fe80::1"),
+		DestinationAddress: net.ParseIP("2001:db8::1"),
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeRedirect {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeRedirect)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{137, 0, 0, 0, 0, 0, 0, 0,
+		254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "redirect message, length 40, 2001:db8::1 to fe80::1"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// add option
+	option := &ICMPOptionTargetLinkLayerAddress{}
+	option.LinkLayerAddress, err = net.ParseMAC("a1:b2:c3:d4:e5:f6")
+	if err != nil {
+		t.Error(err)
+	}
+
+	icmp.AddOption(option)
+
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = append(fixture, 2, 1, 161, 178, 195, 212, 229, 246)
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix = "redirect message, length 48, 2001:db8::1 to fe80::1\n    target link-layer address option (2), length 8 (1): a1:b2:c3:d4:e5:f6"
+	desc = icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err = parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	if _, err = ParseMessage(fixture[:39]); err != errMessageTooShort {
+		t.Errorf("expected %s, got %v", errMessageTooShort, err)
+	}
+}
+
 func TestChecksum(t *testing.T) {
 	// prepare icmp message
 	msg := &ICMPRouterAdvertisement{
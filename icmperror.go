@@ -0,0 +1,412 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv6"
+)
+
+// maximum size of an ICMPv6 error message, invoking packets are truncated so
+// the message doesn't exceed the minimum IPv6 MTU as described at
+// https://tools.ietf.org/html/rfc4443#section-2.4
+const maxErrorMessageLen = 1280 - 40
+
+// DestinationUnreachableCode implements the codes of the Destination
+// Unreachable message as described at
+// https://tools.ietf.org/html/rfc4443#section-3.1
+type DestinationUnreachableCode uint8
+
+// codes currently defined
+const (
+	DestinationUnreachableNoRoute DestinationUnreachableCode = iota
+	DestinationUnreachableAdministrativelyProhibited
+	DestinationUnreachableBeyondScope
+	DestinationUnreachableAddress
+	DestinationUnreachablePort
+	DestinationUnreachableSourcePolicy
+	DestinationUnreachableRejectRoute
+	DestinationUnreachableSourceRoutingHeader
+)
+
+func (c DestinationUnreachableCode) String() string {
+	switch c {
+	case DestinationUnreachableNoRoute:
+		return "no route to destination"
+	case DestinationUnreachableAdministrativelyProhibited:
+		return "administratively prohibited"
+	case DestinationUnreachableBeyondScope:
+		return "beyond scope of source address"
+	case DestinationUnreachableAddress:
+		return "address unreachable"
+	case DestinationUnreachablePort:
+		return "port unreachable"
+	case DestinationUnreachableSourcePolicy:
+		return "source address failed ingress/egress policy"
+	case DestinationUnreachableRejectRoute:
+		return "reject route to destination"
+	case DestinationUnreachableSourceRoutingHeader:
+		return "error in source routing header"
+	default:
+		return "<nil>"
+	}
+}
+
+// TimeExceededCode implements the codes of the Time Exceeded message as
+// described at https://tools.ietf.org/html/rfc4443#section-3.3
+type TimeExceededCode uint8
+
+// codes currently defined
+const (
+	TimeExceededHopLimit TimeExceededCode = iota
+	TimeExceededFragmentReassembly
+)
+
+func (c TimeExceededCode) String() string {
+	switch c {
+	case TimeExceededHopLimit:
+		return "hop limit exceeded in transit"
+	case TimeExceededFragmentReassembly:
+		return "fragment reassembly time exceeded"
+	default:
+		return "<nil>"
+	}
+}
+
+// ParameterProblemCode implements the codes of the Parameter Problem message
+// as described at https://tools.ietf.org/html/rfc4443#section-3.4
+type ParameterProblemCode uint8
+
+// codes currently defined
+const (
+	ParameterProblemHeaderField ParameterProblemCode = iota
+	ParameterProblemNextHeader
+	ParameterProblemOption
+	ParameterProblemIncompleteHeaderChain
+)
+
+func (c ParameterProblemCode) String() string {
+	switch c {
+	case ParameterProblemHeaderField:
+		return "erroneous header field"
+	case ParameterProblemNextHeader:
+		return "unrecognized next header"
+	case ParameterProblemOption:
+		return "unrecognized ipv6 option"
+	case ParameterProblemIncompleteHeaderChain:
+		return "incomplete header chain"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPDestinationUnreachable implements the Destination Unreachable message
+// as described at https://tools.ietf.org/html/rfc4443#section-3.1
+type ICMPDestinationUnreachable struct {
+	Code           DestinationUnreachableCode
+	InvokingPacket []byte
+}
+
+// Invoking returns the decoded view of the packet that invoked this error
+func (p ICMPDestinationUnreachable) Invoking() *InvokingPacket {
+	return ParseInvokingPacket(p.InvokingPacket)
+}
+
+func (p ICMPDestinationUnreachable) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s (%d)", p.Code, p.Code)
+	s += fmt.Sprintf("\n    %s", p.Invoking())
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeDestinationUnreachable
+func (p ICMPDestinationUnreachable) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeDestinationUnreachable
+}
+
+// Marshal returns byte slice representing this ICMPDestinationUnreachable
+func (p ICMPDestinationUnreachable) Marshal() ([]byte, error) {
+	return marshalError(p.Type(), uint8(p.Code), 0, p.InvokingPacket), nil
+}
+
+// ICMPPacketTooBig implements the Packet Too Big message as described at
+// https://tools.ietf.org/html/rfc4443#section-3.2
+type ICMPPacketTooBig struct {
+	MTU            uint32
+	InvokingPacket []byte
+}
+
+// Invoking returns the decoded view of the packet that invoked this error
+func (p ICMPPacketTooBig) Invoking() *InvokingPacket {
+	return ParseInvokingPacket(p.InvokingPacket)
+}
+
+func (p ICMPPacketTooBig) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("mtu %d", p.MTU)
+	s += fmt.Sprintf("\n    %s", p.Invoking())
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypePacketTooBig
+func (p ICMPPacketTooBig) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypePacketTooBig
+}
+
+// Marshal returns byte slice representing this ICMPPacketTooBig
+func (p ICMPPacketTooBig) Marshal() ([]byte, error) {
+	return marshalError(p.Type(), 0, p.MTU, p.InvokingPacket), nil
+}
+
+// ICMPTimeExceeded implements the Time Exceeded message as described at
+// https://tools.ietf.org/html/rfc4443#section-3.3
+type ICMPTimeExceeded struct {
+	Code           TimeExceededCode
+	InvokingPacket []byte
+}
+
+// Invoking returns the decoded view of the packet that invoked this error
+func (p ICMPTimeExceeded) Invoking() *InvokingPacket {
+	return ParseInvokingPacket(p.InvokingPacket)
+}
+
+func (p ICMPTimeExceeded) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s (%d)", p.Code, p.Code)
+	s += fmt.Sprintf("\n    %s", p.Invoking())
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeTimeExceeded
+func (p ICMPTimeExceeded) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeTimeExceeded
+}
+
+// Marshal returns byte slice representing this ICMPTimeExceeded
+func (p ICMPTimeExceeded) Marshal() ([]byte, error) {
+	return marshalError(p.Type(), uint8(p.Code), 0, p.InvokingPacket), nil
+}
+
+// ICMPParameterProblem implements the Parameter Problem message as described
+// at https://tools.ietf.org/html/rfc4443#section-3.4
+type ICMPParameterProblem struct {
+	Code           ParameterProblemCode
+	Pointer        uint32
+	InvokingPacket []byte
+}
+
+// Invoking returns the decoded view of the packet that invoked this error
+func (p ICMPParameterProblem) Invoking() *InvokingPacket {
+	return ParseInvokingPacket(p.InvokingPacket)
+}
+
+func (p ICMPParameterProblem) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s (%d), ", p.Code, p.Code)
+	s += fmt.Sprintf("pointer %d", p.Pointer)
+	s += fmt.Sprintf("\n    %s", p.Invoking())
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeParameterProblem
+func (p ICMPParameterProblem) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeParameterProblem
+}
+
+// Marshal returns byte slice representing this ICMPParameterProblem
+func (p ICMPParameterProblem) Marshal() ([]byte, error) {
+	return marshalError(p.Type(), uint8(p.Code), p.Pointer, p.InvokingPacket), nil
+}
+
+func marshalError(t ipv6.ICMPType, code uint8, field uint32, invoking []byte) []byte {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(t)
+	b[1] = code
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint32(b[4:8], field)
+	b = append(b, invoking...)
+
+	// don't exceed the minimum IPv6 MTU
+	if len(b) > maxErrorMessageLen {
+		b = b[:maxErrorMessageLen]
+	}
+
+	return b
+}
+
+// ExtensionHeader describes an IPv6 extension header found in an invoking
+// packet
+type ExtensionHeader struct {
+	// Type is the protocol number of this header
+	Type uint8
+	// Length is the length of this header in octets
+	Length int
+}
+
+// InvokingPacket is a decoded view of the packet embedded in an ICMPv6 error
+// message, as far as it could be decoded from the bytes available
+type InvokingPacket struct {
+	Source           net.IP
+	Destination      net.IP
+	FlowLabel        uint32
+	HopLimit         uint8
+	ExtensionHeaders []ExtensionHeader
+	// Protocol is the upper-layer protocol number, only valid when
+	// Complete is true
+	Protocol uint8
+	// Complete is true when the whole extension header chain could be
+	// walked, up to the upper-layer header
+	Complete bool
+	// SourcePort and DestinationPort are set for TCP, UDP, SCTP and DCCP
+	// when enough of the upper-layer header is available
+	SourcePort      uint16
+	DestinationPort uint16
+	HasPorts        bool
+	// ICMPType, ICMPIdentifier and ICMPSequence are set when the invoking
+	// packet was an ICMPv6 echo message
+	ICMPType       ipv6.ICMPType
+	ICMPIdentifier uint16
+	ICMPSequence   uint16
+	Payload        []byte
+}
+
+// protocol numbers relevant to walking the extension header chain
+const (
+	protocolHopByHop    = 0
+	protocolTCP         = 6
+	protocolUDP         = 17
+	protocolRouting     = 43
+	protocolFragment    = 44
+	protocolAH          = 51
+	protocolICMPv6      = 58
+	protocolDestination = 60
+	protocolMobility    = 135
+	protocolHIP         = 139
+	protocolShim6       = 140
+	protocolDCCP        = 33
+	protocolSCTP        = 132
+)
+
+// ParseInvokingPacket decodes as much as possible of given (truncated) IPv6
+// packet, as found in ICMPv6 error messages. It returns nil when not even
+// the IPv6 header is available.
+func ParseInvokingPacket(b []byte) *InvokingPacket {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return nil
+	}
+
+	p := &InvokingPacket{
+		FlowLabel:   binary.BigEndian.Uint32(b[0:4]) & 0x000fffff,
+		HopLimit:    b[7],
+		Source:      net.IP(b[8:24]),
+		Destination: net.IP(b[24:40]),
+	}
+
+	next := b[6]
+	b = b[40:]
+	for {
+		switch next {
+		case protocolHopByHop, protocolRouting, protocolDestination, protocolMobility, protocolHIP, protocolShim6:
+			if len(b) < 8 {
+				return p
+			}
+			l := (int(b[1]) + 1) * 8
+			p.ExtensionHeaders = append(p.ExtensionHeaders, ExtensionHeader{Type: next, Length: l})
+			if len(b) < l {
+				return p
+			}
+			next = b[0]
+			b = b[l:]
+			continue
+
+		case protocolFragment:
+			if len(b) < 8 {
+				return p
+			}
+			p.ExtensionHeaders = append(p.ExtensionHeaders, ExtensionHeader{Type: next, Length: 8})
+			// only the first fragment holds the upper-layer header
+			if binary.BigEndian.Uint16(b[2:4])&0xfff8 != 0 {
+				return p
+			}
+			next = b[0]
+			b = b[8:]
+			continue
+
+		case protocolAH:
+			if len(b) < 8 {
+				return p
+			}
+			l := (int(b[1]) + 2) * 4
+			p.ExtensionHeaders = append(p.ExtensionHeaders, ExtensionHeader{Type: next, Length: l})
+			if len(b) < l {
+				return p
+			}
+			next = b[0]
+			b = b[l:]
+			continue
+		}
+
+		break
+	}
+
+	p.Protocol = next
+	p.Complete = true
+	p.Payload = b
+
+	switch next {
+	case protocolTCP, protocolUDP, protocolSCTP, protocolDCCP:
+		if len(b) >= 4 {
+			p.SourcePort = binary.BigEndian.Uint16(b[0:2])
+			p.DestinationPort = binary.BigEndian.Uint16(b[2:4])
+			p.HasPorts = true
+		}
+	case protocolICMPv6:
+		if len(b) >= 8 {
+			p.ICMPType = ipv6.ICMPType(b[0])
+			if p.ICMPType == ipv6.ICMPTypeEchoRequest || p.ICMPType == ipv6.ICMPTypeEchoReply {
+				p.ICMPIdentifier = binary.BigEndian.Uint16(b[4:6])
+				p.ICMPSequence = binary.BigEndian.Uint16(b[6:8])
+			}
+		}
+	}
+
+	return p
+}
+
+func (p *InvokingPacket) String() string {
+	if p == nil {
+		return "invoking packet unavailable"
+	}
+
+	s := fmt.Sprintf("%s > %s", p.Source, p.Destination)
+	for _, h := range p.ExtensionHeaders {
+		s += fmt.Sprintf(", ext %d (%d)", h.Type, h.Length)
+	}
+	if !p.Complete {
+		return s + ", truncated"
+	}
+
+	switch {
+	case p.HasPorts:
+		s += fmt.Sprintf(", proto %d, port %d > %d", p.Protocol, p.SourcePort, p.DestinationPort)
+	case p.Protocol == protocolICMPv6 && p.ICMPType != 0:
+		s += fmt.Sprintf(", %s", p.ICMPType)
+		if p.ICMPType == ipv6.ICMPTypeEchoRequest || p.ICMPType == ipv6.ICMPTypeEchoReply {
+			s += fmt.Sprintf(" id %d, seq %d", p.ICMPIdentifier, p.ICMPSequence)
+		}
+	default:
+		s += fmt.Sprintf(", proto %d", p.Protocol)
+	}
+
+	return s
+}
@@ -0,0 +1,187 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+// build an IPv6 header for given addresses, next header and payload length
+func testIPv6Header(src, dst string, next uint8, payloadLen int) []byte {
+	b := make([]byte, 40)
+	binary.BigEndian.PutUint32(b[0:4], 0x60000000|0x12345)
+	binary.BigEndian.PutUint16(b[4:6], uint16(payloadLen))
+	b[6] = next
+	b[7] = 64
+	copy(b[8:24], net.ParseIP(src))
+	copy(b[24:40], net.ParseIP(dst))
+
+	return b
+}
+
+func TestParseInvokingPacket(t *testing.T) {
+	// hop-by-hop header followed by UDP
+	pkt := testIPv6Header("2001:db8::1", "2001:db8::2", protocolHopByHop, 16)
+	pkt = append(pkt, protocolUDP, 0, 5, 2, 0, 0, 1, 0)
+	pkt = append(pkt, 0x30, 0x39, 0, 53, 0, 8, 0, 0)
+
+	p := ParseInvokingPacket(pkt)
+	if p == nil {
+		t.Fatal("failed to parse invoking packet")
+	}
+
+	if !p.Source.Equal(net.ParseIP("2001:db8::1")) || !p.Destination.Equal(net.ParseIP("2001:db8::2")) {
+		t.Errorf("unexpected addresses %s > %s", p.Source, p.Destination)
+	}
+
+	if p.FlowLabel != 0x12345 || p.HopLimit != 64 {
+		t.Errorf("unexpected flow label %#x or hop limit %d", p.FlowLabel, p.HopLimit)
+	}
+
+	if !p.Complete || p.Protocol != protocolUDP || !p.HasPorts || p.SourcePort != 12345 || p.DestinationPort != 53 {
+		t.Errorf("unexpected upper-layer header: %s", p)
+	}
+
+	descfix := "2001:db8::1 > 2001:db8::2, ext 0 (8), proto 17, port 12345 > 53"
+	if strings.Compare(p.String(), descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, p)
+	}
+
+	// truncated within extension header
+	p = ParseInvokingPacket(pkt[:44])
+	if p == nil || p.Complete {
+		t.Errorf("expected incomplete invoking packet: %s", p)
+	}
+
+	// non-first fragment has no upper-layer header
+	pkt = testIPv6Header("2001:db8::1", "2001:db8::2", protocolFragment, 16)
+	pkt = append(pkt, protocolTCP, 0, 0, 8, 0, 0, 0, 1)
+	pkt = append(pkt, 0, 80, 0, 80, 0, 0, 0, 0)
+	p = ParseInvokingPacket(pkt)
+	if p == nil || p.Complete || len(p.ExtensionHeaders) != 1 {
+		t.Errorf("expected incomplete invoking packet: %s", p)
+	}
+
+	descfix = "2001:db8::1 > 2001:db8::2, ext 44 (8), truncated"
+	if strings.Compare(p.String(), descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, p)
+	}
+
+	// echo request
+	pkt = testIPv6Header("2001:db8::1", "2001:db8::2", protocolICMPv6, 8)
+	pkt = append(pkt, 128, 0, 0, 0, 0, 1, 0, 2)
+	p = ParseInvokingPacket(pkt)
+	descfix = "2001:db8::1 > 2001:db8::2, echo request id 1, seq 2"
+	if strings.Compare(p.String(), descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, p)
+	}
+
+	// not even an IPv6 header
+	if ParseInvokingPacket(pkt[:39]) != nil {
+		t.Error("expected nil for short invoking packet")
+	}
+}
+
+func TestICMPErrors(t *testing.T) {
+	pkt := testIPv6Header("2001:db8::1", "2001:db8::2", protocolUDP, 8)
+	pkt = append(pkt, 0x30, 0x39, 0, 53, 0, 8, 0, 0)
+	flow := "\n    2001:db8::1 > 2001:db8::2, proto 17, port 12345 > 53"
+
+	tests := []struct {
+		icmp    ICMP
+		typ     ipv6.ICMPType
+		header  []byte
+		descfix string
+	}{
+		{
+			&ICMPDestinationUnreachable{Code: DestinationUnreachablePort, InvokingPacket: pkt},
+			ipv6.ICMPTypeDestinationUnreachable,
+			[]byte{1, 4, 0, 0, 0, 0, 0, 0},
+			"destination unreachable, length 56, port unreachable (4)" + flow,
+		},
+		{
+			&ICMPPacketTooBig{MTU: 1400, InvokingPacket: pkt},
+			ipv6.ICMPTypePacketTooBig,
+			[]byte{2, 0, 0, 0, 0, 0, 5, 120},
+			"packet too big, length 56, mtu 1400" + flow,
+		},
+		{
+			&ICMPTimeExceeded{Code: TimeExceededFragmentReassembly, InvokingPacket: pkt},
+			ipv6.ICMPTypeTimeExceeded,
+			[]byte{3, 1, 0, 0, 0, 0, 0, 0},
+			"time exceeded, length 56, fragment reassembly time exceeded (1)" + flow,
+		},
+		{
+			&ICMPParameterProblem{Code: ParameterProblemNextHeader, Pointer: 6, InvokingPacket: pkt},
+			ipv6.ICMPTypeParameterProblem,
+			[]byte{4, 1, 0, 0, 0, 0, 0, 6},
+			"parameter problem, length 56, unrecognized next header (1), pointer 6" + flow,
+		},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.icmp.Type(), test.typ)
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		fixture := append(test.header, pkt...)
+		if bytes.Compare(marshal, fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		parsedMarshal, err := parsedICMP.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+
+		_, err = ParseMessage(fixture[:6])
+		if err != errMessageTooShort {
+			t.Errorf("unexpected error message: %s", err)
+		}
+	}
+
+	// invoking packet is truncated to fit the minimum MTU
+	icmp := &ICMPPacketTooBig{MTU: 1280, InvokingPacket: make([]byte, 1500)}
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(marshal) != 1240 {
+		t.Errorf("expected truncated message of 1240, not %d", len(marshal))
+	}
+}
+
+func TestICMPErrorCodeString(t *testing.T) {
+	if DestinationUnreachableCode(8).String() != "<nil>" {
+		t.Errorf("unexpected description for unknown code: %s", DestinationUnreachableCode(8))
+	}
+	if TimeExceededCode(2).String() != "<nil>" {
+		t.Errorf("unexpected description for unknown code: %s", TimeExceededCode(2))
+	}
+	if ParameterProblemCode(3).String() != "incomplete header chain" {
+		t.Errorf("unexpected description: %s", ParameterProblemCode(3))
+	}
+}
@@ -29,20 +29,42 @@ func (opts ICMPOptions) Marshal() ([]byte, error) {
 // ICMPOptionType describes ICMPv6 types
 type ICMPOptionType int
 
-// ICMPv6 Neighbor discovery types as described in RFC4861, RFC3971, RFC6106
+// ICMPv6 Neighbor discovery types as described in RFC4861, RFC6275, RFC3122,
+// RFC3971, RFC5568, RFC6106, RFC5175, RFC6775
 const (
 	ICMPOptionTypeUnknown ICMPOptionType = iota
 	// RFC4861
 	ICMPOptionTypeSourceLinkLayerAddress
 	ICMPOptionTypeTargetLinkLayerAddress
 	ICMPOptionTypePrefixInformation
-	_
+	ICMPOptionTypeRedirectedHeader
 	ICMPOptionTypeMTU
+	// RFC6275
+	ICMPOptionTypeAdvertisementInterval ICMPOptionType = 7
+	ICMPOptionTypeHomeAgentInformation  ICMPOptionType = 8
+	// RFC3122
+	ICMPOptionTypeSourceAddressList ICMPOptionType = 9
+	ICMPOptionTypeTargetAddressList ICMPOptionType = 10
 	// RFC3971
-	ICMPOptionTypeNonce ICMPOptionType = 14
+	ICMPOptionTypeCGA          ICMPOptionType = 11
+	ICMPOptionTypeRSASignature ICMPOptionType = 12
+	ICMPOptionTypeTimestamp    ICMPOptionType = 13
+	ICMPOptionTypeNonce        ICMPOptionType = 14
+	ICMPOptionTypeTrustAnchor  ICMPOptionType = 15
+	ICMPOptionTypeCertificate  ICMPOptionType = 16
+	// RFC5568
+	ICMPOptionTypeIPAddressPrefix                  ICMPOptionType = 17
+	ICMPOptionTypeLinkLayerAddress                 ICMPOptionType = 19
+	ICMPOptionTypeNeighborAdvertisementAcknowledge ICMPOptionType = 20
 	// RFC6106
 	ICMPOptionTypeRecursiveDNSServer ICMPOptionType = 25
 	ICMPOptionTypeDNSSearchList      ICMPOptionType = 31
+	// RFC5175
+	ICMPOptionTypeFlagsExpansion ICMPOptionType = 26
+	// RFC6775
+	ICMPOptionTypeAddressRegistration       ICMPOptionType = 33
+	ICMPOptionType6LoWPANContext            ICMPOptionType = 34
+	ICMPOptionTypeAuthoritativeBorderRouter ICMPOptionType = 35
 )
 
 func (t ICMPOptionType) String() string {
@@ -53,14 +75,48 @@ func (t ICMPOptionType) String() string {
 		return "target link-layer address"
 	case ICMPOptionTypePrefixInformation:
 		return "prefix info"
+	case ICMPOptionTypeRedirectedHeader:
+		return "redirected header"
 	case ICMPOptionTypeMTU:
 		return "mtu"
+	case ICMPOptionTypeAdvertisementInterval:
+		return "advertisement interval"
+	case ICMPOptionTypeHomeAgentInformation:
+		return "homeagent information"
+	case ICMPOptionTypeSourceAddressList:
+		return "source address list"
+	case ICMPOptionTypeTargetAddressList:
+		return "target address list"
+	case ICMPOptionTypeCGA:
+		return "cga"
+	case ICMPOptionTypeRSASignature:
+		return "rsa signature"
+	case ICMPOptionTypeTimestamp:
+		return "timestamp"
 	case ICMPOptionTypeNonce:
 		return "nonce"
+	case ICMPOptionTypeTrustAnchor:
+		return "trust anchor"
+	case ICMPOptionTypeCertificate:
+		return "certificate"
+	case ICMPOptionTypeIPAddressPrefix:
+		return "ip address/prefix"
+	case ICMPOptionTypeLinkLayerAddress:
+		return "link-layer address"
+	case ICMPOptionTypeNeighborAdvertisementAcknowledge:
+		return "neighbor advertisement acknowledgment"
 	case ICMPOptionTypeRecursiveDNSServer:
 		return "rdnss"
 	case ICMPOptionTypeDNSSearchList:
 		return "dnssl"
+	case ICMPOptionTypeFlagsExpansion:
+		return "flags expansion"
+	case ICMPOptionTypeAddressRegistration:
+		return "address registration"
+	case ICMPOptionType6LoWPANContext:
+		return "6lowpan context"
+	case ICMPOptionTypeAuthoritativeBorderRouter:
+		return "authoritative border router"
 	default:
 		return "<nil>"
 	}
@@ -82,7 +138,7 @@ type ICMPOptionUnknown struct {
 }
 
 func (o ICMPOptionUnknown) String() string {
-	return fmt.Sprintf("unknown option (%d), length %d (%d)", o.optionType, (o.optionLength * 8), o.optionLength)
+	return fmt.Sprintf("unknown option (%d), length %d (%d)", o.optionType, (int(o.optionLength) * 8), o.optionLength)
 }
 
 // Type returns apparent type of this option
@@ -188,9 +244,15 @@ func (o ICMPOptionTargetLinkLayerAddress) Marshal() ([]byte, error) {
 // ICMPOptionPrefixInformation implements the Prefix Information option
 // as described at https://tools.ietf.org/html/rfc4861#section-4.6.2
 type ICMPOptionPrefixInformation struct {
-	PrefixLength      uint8
-	OnLink            bool
-	Auto              bool
+	PrefixLength uint8
+	OnLink       bool
+	Auto         bool
+	// RouterAddress tells Prefix holds the full address of the router as
+	// described at https://tools.ietf.org/html/rfc6275#section-7.2
+	RouterAddress bool
+	// PrefixDelegation asks hosts to request prefixes using DHCPv6 prefix
+	// delegation as described at https://tools.ietf.org/html/rfc9762
+	PrefixDelegation  bool
 	ValidLifetime     uint32
 	PreferredLifetime uint32
 	Prefix            net.IP
@@ -208,6 +270,12 @@ func (o ICMPOptionPrefixInformation) String() string {
 	if o.Auto {
 		f = append(f, "auto")
 	}
+	if o.RouterAddress {
+		f = append(f, "router")
+	}
+	if o.PrefixDelegation {
+		f = append(f, "pd")
+	}
 	s += fmt.Sprintf("Flags %s, ", f)
 	s += fmt.Sprintf("valid time %ds, ", o.ValidLifetime)
 	s += fmt.Sprintf("pref. time %ds", o.PreferredLifetime)
@@ -240,6 +308,12 @@ func (o ICMPOptionPrefixInformation) Marshal() ([]byte, error) {
 	if o.Auto {
 		b[3] ^= 0x40
 	}
+	if o.RouterAddress {
+		b[3] ^= 0x20
+	}
+	if o.PrefixDelegation {
+		b[3] ^= 0x10
+	}
 	binary.BigEndian.PutUint32(b[4:8], uint32(o.ValidLifetime))
 	binary.BigEndian.PutUint32(b[8:12], uint32(o.PreferredLifetime))
 	b = append(b, o.Prefix...)
@@ -247,6 +321,57 @@ func (o ICMPOptionPrefixInformation) Marshal() ([]byte, error) {
 	return b, nil
 }
 
+// ICMPOptionRedirectedHeader implements the Redirected Header option as
+// described at https://tools.ietf.org/html/rfc4861#section-4.6.3
+type ICMPOptionRedirectedHeader struct {
+	// InvokingPacket is the (truncated) packet that triggered the redirect,
+	// when parsed it includes the padding of the option
+	InvokingPacket []byte
+}
+
+// Invoking returns the decoded view of the packet that triggered the redirect
+func (o ICMPOptionRedirectedHeader) Invoking() *InvokingPacket {
+	return ParseInvokingPacket(o.InvokingPacket)
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionRedirectedHeader) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (int(o.Len()) * 8), o.Len())
+	if p := o.Invoking(); p != nil {
+		s += fmt.Sprintf(": %s > %s", p.Source, p.Destination)
+	}
+
+	return s
+}
+
+// Type returns ICMPOptionTypeRedirectedHeader
+func (o ICMPOptionRedirectedHeader) Type() ICMPOptionType {
+	return ICMPOptionTypeRedirectedHeader
+}
+
+// Len returns the length in bytes of ICMPOptionRedirectedHeader
+func (o ICMPOptionRedirectedHeader) Len() uint8 {
+	return uint8((8 + len(o.InvokingPacket) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionRedirectedHeader
+func (o ICMPOptionRedirectedHeader) Marshal() ([]byte, error) {
+	// redirects should not exceed the minimum MTU
+	if len(o.InvokingPacket) > maxErrorMessageLen-40-8 {
+		return nil, fmt.Errorf("invoking packet of %d bytes too long", len(o.InvokingPacket))
+	}
+
+	b := make([]byte, int(o.Len())*8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// b[2:8] = reserved
+	copy(b[8:], o.InvokingPacket)
+
+	return b, nil
+}
+
 // ICMPOptionMTU implements the MTU option as described at
 // https://tools.ietf.org/html/rfc4861#section-4.6.4
 type ICMPOptionMTU struct {
@@ -418,6 +543,76 @@ func (o ICMPOptionDNSSearchList) Marshal() ([]byte, error) {
 	return b, nil
 }
 
+// ICMPOptionFlagsExpansion implements the Flags Expansion option as
+// described at https://tools.ietf.org/html/rfc5175#section-4, carrying the
+// router advertisement flags 8 to 55
+type ICMPOptionFlagsExpansion struct {
+	Flags [6]byte
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionFlagsExpansion) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("Flags %v", o.FlagsSet())
+
+	return s
+}
+
+// Type returns ICMPOptionTypeFlagsExpansion
+func (o ICMPOptionFlagsExpansion) Type() ICMPOptionType {
+	return ICMPOptionTypeFlagsExpansion
+}
+
+// Len returns the length in bytes of ICMPOptionFlagsExpansion
+func (o ICMPOptionFlagsExpansion) Len() uint8 {
+	// Flags expansion options are always 1
+	return 1
+}
+
+// IsSet returns whether given flag is set, flags outside of the option are
+// never set
+func (o ICMPOptionFlagsExpansion) IsSet(f RAFlag) bool {
+	if f < 8 || f > 55 {
+		return false
+	}
+
+	return o.Flags[(f-8)/8]&(0x80>>((f-8)%8)) > 0
+}
+
+// Set sets given flag, flags outside of the option are ignored
+func (o *ICMPOptionFlagsExpansion) Set(f RAFlag) {
+	if f < 8 || f > 55 {
+		return
+	}
+
+	o.Flags[(f-8)/8] |= 0x80 >> ((f - 8) % 8)
+}
+
+// FlagsSet returns all flags that are set
+func (o ICMPOptionFlagsExpansion) FlagsSet() []RAFlag {
+	flags := []RAFlag{}
+	for f := RAFlag(8); f <= 55; f++ {
+		if o.IsSet(f) {
+			flags = append(flags, f)
+		}
+	}
+
+	return flags
+}
+
+// Marshal returns byte slice representing this ICMPOptionFlagsExpansion
+func (o ICMPOptionFlagsExpansion) Marshal() ([]byte, error) {
+	b := make([]byte, 2)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b = append(b, o.Flags[:]...)
+
+	return b, nil
+}
+
 func parseOptions(b []byte) ([]ICMPOption, error) {
 	// empty container
 	var icmpOptions = []ICMPOption{}
@@ -431,9 +626,15 @@ func parseOptions(b []byte) ([]ICMPOption, error) {
 		// beginning of header specifies type and length
 		optionType := ICMPOptionType(b[0])
 		optionLength := uint8(b[1])
+		// options without length would make us loop forever
+		if optionLength == 0 {
+			return nil, fmt.Errorf("option %s (%d) has invalid length 0", optionType, optionType)
+		}
+
 		// check if we got enought data for at least as long as optionLength specifies
-		if uint8(len(b)) < (optionLength * 8) {
-			return nil, fmt.Errorf("too few bytes received: %d while at least %d expected", len(b), (optionLength * 8))
+		// lengths are calculated as int, options may well exceed 255 bytes
+		if len(b) < (int(optionLength) * 8) {
+			return nil, fmt.Errorf("too few bytes received: %d while at least %d expected", len(b), (int(optionLength) * 8))
 		}
 
 		var currentOption ICMPOption
@@ -468,11 +669,18 @@ func parseOptions(b []byte) ([]ICMPOption, error) {
 				PrefixLength:      uint8(b[2]),
 				OnLink:            (b[3]&0x80 > 0),
 				Auto:              (b[3]&0x40 > 0),
+				RouterAddress:     (b[3]&0x20 > 0),
+				PrefixDelegation:  (b[3]&0x10 > 0),
 				ValidLifetime:     binary.BigEndian.Uint32(b[4:8]),
 				PreferredLifetime: binary.BigEndian.Uint32(b[8:12]),
 				Prefix:            net.IP(b[16:32]),
 			}
 
+		case ICMPOptionTypeRedirectedHeader:
+			currentOption = &ICMPOptionRedirectedHeader{
+				InvokingPacket: b[8:(int(optionLength) * 8)],
+			}
+
 		case ICMPOptionTypeMTU:
 			if optionLength != 1 {
 				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
@@ -483,6 +691,68 @@ func parseOptions(b []byte) ([]ICMPOption, error) {
 				MTU: binary.BigEndian.Uint32(b[4:8]),
 			}
 
+		case ICMPOptionTypeAdvertisementInterval:
+			if optionLength != 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionAdvertisementInterval{
+				AdvertisementInterval: binary.BigEndian.Uint32(b[4:8]),
+			}
+
+		case ICMPOptionTypeHomeAgentInformation:
+			if optionLength != 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionHomeAgentInformation{
+				Preference: int16(binary.BigEndian.Uint16(b[4:6])),
+				Lifetime:   binary.BigEndian.Uint16(b[6:8]),
+			}
+
+		case ICMPOptionTypeSourceAddressList, ICMPOptionTypeTargetAddressList:
+			if optionLength < 3 || optionLength%2 != 1 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d", optionType, optionType, optionLength)
+			}
+
+			var addresses []net.IP
+			for i := 8; i < (int(optionLength) * 8); i += 16 {
+				addresses = append(addresses, net.IP(b[i:(i+16)]))
+			}
+
+			if optionType == ICMPOptionTypeSourceAddressList {
+				currentOption = &ICMPOptionSourceAddressList{Addresses: addresses}
+			} else {
+				currentOption = &ICMPOptionTargetAddressList{Addresses: addresses}
+			}
+
+		case ICMPOptionTypeCGA:
+			var err error
+			currentOption, err = parseCGAOption(b[:(int(optionLength) * 8)])
+			if err != nil {
+				return nil, err
+			}
+
+		case ICMPOptionTypeRSASignature:
+			if optionLength < 3 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 3", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionRSASignature{
+				Signature: b[20:(int(optionLength) * 8)],
+			}
+
+			copy(currentOption.(*ICMPOptionRSASignature).KeyHash[:], b[4:20])
+
+		case ICMPOptionTypeTimestamp:
+			if optionLength != 2 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 2", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionTimestamp{
+				Timestamp: decTimestamp(binary.BigEndian.Uint64(b[8:16])),
+			}
+
 		case ICMPOptionTypeNonce:
 			if optionLength != 1 {
 				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
@@ -494,6 +764,54 @@ func parseOptions(b []byte) ([]ICMPOption, error) {
 			n = append(n, b[2:8]...)
 			currentOption.(*ICMPOptionNonce).Nonce = binary.BigEndian.Uint64(n)
 
+		case ICMPOptionTypeTrustAnchor:
+			var err error
+			currentOption, err = parseTrustAnchorOption(b[:(int(optionLength) * 8)])
+			if err != nil {
+				return nil, err
+			}
+
+		case ICMPOptionTypeCertificate:
+			var err error
+			currentOption, err = parseCertificateOption(b[:(int(optionLength) * 8)])
+			if err != nil {
+				return nil, err
+			}
+
+		case ICMPOptionTypeIPAddressPrefix:
+			if optionLength != 3 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 3", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionIPAddressPrefix{
+				OptionCode:   IPAddressPrefixOptionCode(b[2]),
+				PrefixLength: uint8(b[3]),
+				Address:      net.IP(b[8:24]),
+			}
+
+		case ICMPOptionTypeLinkLayerAddress:
+			if optionLength < 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 1", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionLinkLayerAddress{
+				OptionCode:       LinkLayerAddressOptionCode(b[2]),
+				LinkLayerAddress: trimLinkLayerAddress(b[3:(int(optionLength) * 8)]),
+			}
+
+		case ICMPOptionTypeNeighborAdvertisementAcknowledge:
+			if optionLength != 1 && optionLength != 3 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionNeighborAdvertisementAcknowledge{
+				Status: NeighborAdvertisementAcknowledgeStatus(b[3]),
+			}
+
+			if optionLength == 3 {
+				currentOption.(*ICMPOptionNeighborAdvertisementAcknowledge).NewCareOfAddress = net.IP(b[8:24])
+			}
+
 		case ICMPOptionTypeRecursiveDNSServer:
 			if optionLength < 3 {
 				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 3", optionType, optionType, optionLength)
@@ -521,13 +839,68 @@ func parseOptions(b []byte) ([]ICMPOption, error) {
 				Lifetime: binary.BigEndian.Uint32(b[4:8]),
 			}
 
-			currentOption.(*ICMPOptionDNSSearchList).DomainNames = decDomainName(b[8:(optionLength * 8)])
+			currentOption.(*ICMPOptionDNSSearchList).DomainNames = decDomainName(b[8:(int(optionLength) * 8)])
+
+		case ICMPOptionTypeFlagsExpansion:
+			if optionLength != 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
+			}
+
+			o := &ICMPOptionFlagsExpansion{}
+			copy(o.Flags[:], b[2:8])
+			currentOption = o
+
+		case ICMPOptionTypeAddressRegistration:
+			if optionLength < 2 || optionLength > 5 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionAddressRegistration{
+				Status:     AddressRegistrationStatus(b[2]),
+				Opaque:     b[3],
+				I:          (b[4] >> 2) & 0x03,
+				Routing:    (b[4]&0x02 > 0),
+				TIDPresent: (b[4]&0x01 > 0),
+				TID:        b[5],
+				Lifetime:   binary.BigEndian.Uint16(b[6:8]),
+				ROVR:       b[8:(int(optionLength) * 8)],
+			}
+
+		case ICMPOptionType6LoWPANContext:
+			if optionLength != 2 && optionLength != 3 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d", optionType, optionType, optionLength)
+			}
+
+			if b[2] > 128 || (b[2] > 64) != (optionLength == 3) {
+				return nil, fmt.Errorf("option %s (%d) has invalid context length %d", optionType, optionType, b[2])
+			}
+
+			prefix := make(net.IP, net.IPv6len)
+			copy(prefix, b[8:(int(optionLength)*8)])
+			currentOption = &ICMPOption6LoWPANContext{
+				ContextLength: b[2],
+				Compression:   (b[3]&0x10 > 0),
+				CID:           b[3] & 0x0f,
+				ValidLifetime: binary.BigEndian.Uint16(b[6:8]),
+				Prefix:        prefix,
+			}
+
+		case ICMPOptionTypeAuthoritativeBorderRouter:
+			if optionLength != 3 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 3", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionAuthoritativeBorderRouter{
+				Version:       uint32(binary.BigEndian.Uint16(b[4:6]))<<16 | uint32(binary.BigEndian.Uint16(b[2:4])),
+				ValidLifetime: binary.BigEndian.Uint16(b[6:8]),
+				Address:       net.IP(b[8:24]),
+			}
 
 		default:
 			currentOption = &ICMPOptionUnknown{
 				optionLength: optionLength,
 				optionType:   optionType,
-				body:         b[2:(optionLength * 8)],
+				body:         b[2:(int(optionLength) * 8)],
 			}
 		}
 
@@ -539,12 +912,12 @@ func parseOptions(b []byte) ([]ICMPOption, error) {
 		icmpOptions = append(icmpOptions, currentOption)
 
 		// are we at the end of the byte slice
-		if len(b) <= int(optionLength*8) {
+		if len(b) <= (int(optionLength) * 8) {
 			break
 		}
 
 		// chop off bytes for this option
-		b = b[(optionLength * 8):]
+		b = b[(int(optionLength) * 8):]
 	}
 
 	return icmpOptions, nil
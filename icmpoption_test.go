@@ -16,10 +16,24 @@ func TestICMPOptionTypeString(t *testing.T) {
 		{ICMPOptionTypeSourceLinkLayerAddress, "source link-layer address"},
 		{ICMPOptionTypeTargetLinkLayerAddress, "target link-layer address"},
 		{ICMPOptionTypePrefixInformation, "prefix info"},
+		{ICMPOptionTypeRedirectedHeader, "redirected header"},
 		{ICMPOptionTypeMTU, "mtu"},
+		{ICMPOptionTypeAdvertisementInterval, "advertisement interval"},
+		{ICMPOptionTypeHomeAgentInformation, "homeagent information"},
+		{ICMPOptionTypeSourceAddressList, "source address list"},
+		{ICMPOptionTypeTargetAddressList, "target address list"},
 		{ICMPOptionTypeNonce, "nonce"},
+		{ICMPOptionTypeTrustAnchor, "trust anchor"},
+		{ICMPOptionTypeCertificate, "certificate"},
+		{ICMPOptionTypeIPAddressPrefix, "ip address/prefix"},
+		{ICMPOptionTypeLinkLayerAddress, "link-layer address"},
+		{ICMPOptionTypeNeighborAdvertisementAcknowledge, "neighbor advertisement acknowledgment"},
 		{ICMPOptionTypeRecursiveDNSServer, "rdnss"},
 		{ICMPOptionTypeDNSSearchList, "dnssl"},
+		{ICMPOptionTypeFlagsExpansion, "flags expansion"},
+		{ICMPOptionTypeAddressRegistration, "address registration"},
+		{ICMPOptionType6LoWPANContext, "6lowpan context"},
+		{ICMPOptionTypeAuthoritativeBorderRouter, "authoritative border router"},
 	}
 
 	for _, test := range tests {
@@ -397,6 +411,60 @@ func TestICMPOptionPrefixInformation(t *testing.T) {
 	if bytes.Compare(parsedMarshal, marshal) != 0 {
 		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
 	}
+
+	// router address flag as described in RFC6275
+	option.RouterAddress = true
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if marshal[3] != 0xe0 {
+		t.Errorf("unexpected flags %#x, expected 0xe0", marshal[3])
+	}
+
+	descfix = "prefix info option (3), length 32 (4): 2a00:1450:400e:802::/64, Flags [onlink auto router], valid time 2592000s, pref. time 604800s"
+	desc = option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !options[0].(*ICMPOptionPrefixInformation).RouterAddress {
+		t.Error("router address flag was not parsed")
+	}
+
+	// prefix delegation flag as described in RFC9762
+	option.RouterAddress = false
+	option.PrefixDelegation = true
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if marshal[3] != 0xd0 {
+		t.Errorf("unexpected flags %#x, expected 0xd0", marshal[3])
+	}
+
+	descfix = "prefix info option (3), length 32 (4): 2a00:1450:400e:802::/64, Flags [onlink auto pd], valid time 2592000s, pref. time 604800s"
+	desc = option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsed = options[0].(*ICMPOptionPrefixInformation)
+	if !parsed.PrefixDelegation || parsed.RouterAddress {
+		t.Error("prefix delegation flag was not parsed")
+	}
 }
 
 func TestICMPOptionRecursiveDNSServer(t *testing.T) {
@@ -496,6 +564,41 @@ func TestICMPOptionRecursiveDNSServer(t *testing.T) {
 	}
 }
 
+func TestParseOptionsLength(t *testing.T) {
+	// options may exceed 255 bytes
+	fixture := make([]byte, 320)
+	fixture[0] = 100
+	fixture[1] = 40
+	fixture = append(fixture, 5, 1, 0, 0, 0, 0, 5, 220)
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 2 {
+		t.Fatalf("parsed %d options instead of 2", len(options))
+	}
+
+	if options[0].Len() != 40 {
+		t.Errorf("wrong length, %d != 40", options[0].Len())
+	}
+
+	if options[1].Type() != ICMPOptionTypeMTU {
+		t.Errorf("wrong type: %d instead of %d", options[1].Type(), ICMPOptionTypeMTU)
+	}
+
+	// truncated large option
+	if _, err := parseOptions(fixture[:300]); err == nil {
+		t.Error("expected error for truncated option")
+	}
+
+	// options of length 0 are invalid
+	if _, err := parseOptions([]byte{100, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Error("expected error for option of length 0")
+	}
+}
+
 func TestICMPOptionUnknown(t *testing.T) {
 	option := &ICMPOptionUnknown{
 		optionType:   100,
@@ -549,3 +652,104 @@ func TestICMPOptionUnknown(t *testing.T) {
 		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
 	}
 }
+
+func TestICMPOptionFlagsExpansion(t *testing.T) {
+	option := &ICMPOptionFlagsExpansion{}
+	option.Set(8)
+	option.Set(17)
+	option.Set(55)
+	// flags outside of the option are ignored
+	option.Set(5)
+	option.Set(56)
+
+	if option.Type() != ICMPOptionTypeFlagsExpansion {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeFlagsExpansion)
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{26, 1, 128, 64, 0, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "flags expansion option (26), length 8 (1): Flags [8 17 55]"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := options[0].(*ICMPOptionFlagsExpansion)
+	if !parsed.IsSet(17) || parsed.IsSet(16) || parsed.IsSet(5) {
+		t.Errorf("unexpected flags %v", parsed.FlagsSet())
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = parseOptions([]byte{26, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	if err == nil {
+		t.Error("expected error parsing invalid length")
+	}
+}
+
+func TestICMPOptionRedirectedHeader(t *testing.T) {
+	invoking := make([]byte, 40)
+	invoking[0] = 0x60
+	copy(invoking[8:24], net.ParseIP("2001:db8::a"))
+	copy(invoking[24:40], net.ParseIP("2001:db8::1"))
+	option := &ICMPOptionRedirectedHeader{InvokingPacket: invoking}
+
+	if option.Type() != ICMPOptionTypeRedirectedHeader {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeRedirectedHeader)
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := append([]byte{4, 6, 0, 0, 0, 0, 0, 0}, invoking...)
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "redirected header option (4), length 48 (6): 2001:db8::a > 2001:db8::1"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedMarshal, err := options[0].Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	option.InvokingPacket = make([]byte, maxErrorMessageLen)
+	if _, err = option.Marshal(); err == nil {
+		t.Error("expected error marshalling oversized invoking packet")
+	}
+}
@@ -0,0 +1,185 @@
+package ndp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPInverseNeighborDiscoverySolicitation implements the Inverse Neighbor
+// Discovery Solicitation message as described at
+// https://tools.ietf.org/html/rfc3122#section-2.1
+type ICMPInverseNeighborDiscoverySolicitation struct {
+	optionContainer
+}
+
+func (p ICMPInverseNeighborDiscoverySolicitation) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d\n", p.Type(), len(m))
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeInverseNeighborDiscoverySolicitation
+func (p ICMPInverseNeighborDiscoverySolicitation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeInverseNeighborDiscoverySolicitation
+}
+
+// Marshal returns byte slice representing this
+// ICMPInverseNeighborDiscoverySolicitation
+func (p ICMPInverseNeighborDiscoverySolicitation) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+	return b, nil
+}
+
+// ICMPInverseNeighborDiscoveryAdvertisement implements the Inverse Neighbor
+// Discovery Advertisement message as described at
+// https://tools.ietf.org/html/rfc3122#section-2.2
+type ICMPInverseNeighborDiscoveryAdvertisement struct {
+	optionContainer
+}
+
+func (p ICMPInverseNeighborDiscoveryAdvertisement) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d\n", p.Type(), len(m))
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement
+func (p ICMPInverseNeighborDiscoveryAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement
+}
+
+// Marshal returns byte slice representing this
+// ICMPInverseNeighborDiscoveryAdvertisement
+func (p ICMPInverseNeighborDiscoveryAdvertisement) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+	return b, nil
+}
+
+// ICMPOptionSourceAddressList implements the Source Address List option as
+// described at https://tools.ietf.org/html/rfc3122#section-3.1
+type ICMPOptionSourceAddressList struct {
+	Addresses []net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionSourceAddressList) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += joinIPs(o.Addresses)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeSourceAddressList
+func (o ICMPOptionSourceAddressList) Type() ICMPOptionType {
+	return ICMPOptionTypeSourceAddressList
+}
+
+// Len returns the length in bytes of ICMPOptionSourceAddressList
+func (o ICMPOptionSourceAddressList) Len() uint8 {
+	return 1 + uint8(len(o.Addresses)*2)
+}
+
+// Marshal returns byte slice representing this ICMPOptionSourceAddressList
+func (o ICMPOptionSourceAddressList) Marshal() ([]byte, error) {
+	return marshalAddressList(o.Type(), o.Addresses)
+}
+
+// ICMPOptionTargetAddressList implements the Target Address List option as
+// described at https://tools.ietf.org/html/rfc3122#section-3.2
+type ICMPOptionTargetAddressList struct {
+	Addresses []net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionTargetAddressList) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += joinIPs(o.Addresses)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeTargetAddressList
+func (o ICMPOptionTargetAddressList) Type() ICMPOptionType {
+	return ICMPOptionTypeTargetAddressList
+}
+
+// Len returns the length in bytes of ICMPOptionTargetAddressList
+func (o ICMPOptionTargetAddressList) Len() uint8 {
+	return 1 + uint8(len(o.Addresses)*2)
+}
+
+// Marshal returns byte slice representing this ICMPOptionTargetAddressList
+func (o ICMPOptionTargetAddressList) Marshal() ([]byte, error) {
+	return marshalAddressList(o.Type(), o.Addresses)
+}
+
+// maxAddressListAddresses is the number of addresses fitting in an address
+// list option of at most 255 units of 8 octets
+const maxAddressListAddresses = (255 - 1) / 2
+
+func marshalAddressList(t ICMPOptionType, addresses []net.IP) ([]byte, error) {
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%s should hold at least one address", t)
+	}
+
+	// the length is calculated in units of 8 octets and would wrap
+	if len(addresses) > maxAddressListAddresses {
+		return nil, fmt.Errorf("%s of %d addresses exceeds %d addresses", t, len(addresses), maxAddressListAddresses)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(t)
+	b[1] = byte(1 + len(addresses)*2)
+	// b[2:8] = reserved
+	for _, a := range addresses {
+		b = append(b, a.To16()...)
+	}
+
+	return b, nil
+}
+
+// join addresses separated by comma
+func joinIPs(ips []net.IP) string {
+	s := []string{}
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+
+	return strings.Join(s, ", ")
+}
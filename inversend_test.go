@@ -0,0 +1,182 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPInverseNeighborDiscoverySolicitation(t *testing.T) {
+	icmp := &ICMPInverseNeighborDiscoverySolicitation{}
+	icmp.AddOption(&ICMPOptionSourceLinkLayerAddress{
+		LinkLayerAddress: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	})
+	icmp.AddOption(&ICMPOptionTargetLinkLayerAddress{
+		LinkLayerAddress: net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb},
+	})
+
+	if icmp.Type() != ipv6.ICMPTypeInverseNeighborDiscoverySolicitation {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeInverseNeighborDiscoverySolicitation)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{141, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 17, 34, 51, 68, 85, 2, 1, 102, 119, 136, 153, 170, 187}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "inverse neighbor discovery solicitation message, length 24\n    source link-layer address option (1), length 8 (1): 00:11:22:33:44:55\n    target link-layer address option (2), length 8 (1): 66:77:88:99:aa:bb"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPInverseNeighborDiscoveryAdvertisement(t *testing.T) {
+	icmp := &ICMPInverseNeighborDiscoveryAdvertisement{}
+	icmp.AddOption(&ICMPOptionTargetLinkLayerAddress{
+		LinkLayerAddress: net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb},
+	})
+	icmp.AddOption(&ICMPOptionTargetAddressList{
+		Addresses: []net.IP{net.ParseIP("2001:db8::1")},
+	})
+
+	if icmp.Type() != ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{142, 0, 0, 0, 0, 0, 0, 0, 2, 1, 102, 119, 136, 153, 170, 187, 10, 3, 0, 0, 0, 0, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "inverse neighbor discovery advertisement message, length 40\n    target link-layer address option (2), length 8 (1): 66:77:88:99:aa:bb\n    target address list option (10), length 24 (3): 2001:db8::1"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPOptionAddressList(t *testing.T) {
+	addresses := []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("fe80::1")}
+	tests := []struct {
+		option  ICMPOption
+		typ     ICMPOptionType
+		descfix string
+	}{
+		{&ICMPOptionSourceAddressList{Addresses: addresses}, ICMPOptionTypeSourceAddressList, "source address list option (9), length 40 (5): 2001:db8::1, fe80::1"},
+		{&ICMPOptionTargetAddressList{Addresses: addresses}, ICMPOptionTypeTargetAddressList, "target address list option (10), length 40 (5): 2001:db8::1, fe80::1"},
+	}
+
+	for _, test := range tests {
+		if test.option.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.option.Type(), test.typ)
+		}
+
+		if test.option.Len() != 5 {
+			t.Errorf("wrong length, %d != 5", test.option.Len())
+		}
+
+		marshal, err := test.option.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		fixture := []byte{uint8(test.typ), 5, 0, 0, 0, 0, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+		if bytes.Compare(marshal, fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", fixture, marshal)
+		}
+
+		desc := test.option.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		options, err := parseOptions(fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if len(options) != 1 {
+			t.Errorf("parsed %d options instead of 1", len(options))
+		}
+
+		parsedMarshal, err := options[0].Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+
+		// even lengths can't hold whole addresses
+		fixture[1] = 4
+		if _, err := parseOptions(fixture[:32]); err == nil {
+			t.Error("expected error for invalid length")
+		}
+	}
+
+	if _, err := (&ICMPOptionSourceAddressList{}).Marshal(); err == nil {
+		t.Error("expected error for empty address list")
+	}
+
+	// at most 127 addresses fit in an option
+	many := make([]net.IP, 128)
+	for i := range many {
+		many[i] = net.ParseIP("2001:db8::1")
+	}
+
+	if _, err := (&ICMPOptionTargetAddressList{Addresses: many}).Marshal(); err == nil {
+		t.Error("expected error for too many addresses")
+	}
+
+	marshal, err := (&ICMPOptionTargetAddressList{Addresses: many[:127]}).Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(marshal) != 255*8 || marshal[1] != 255 {
+		t.Errorf("wrong length, %d != %d", len(marshal), 255*8)
+	}
+}
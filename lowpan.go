@@ -0,0 +1,428 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// lowpanLifetimeUnit is the unit of the lifetimes in 6LoWPAN options
+const lowpanLifetimeUnit = 60 * time.Second
+
+// checkROVR returns an error when given registration ownership verifier has
+// an invalid size
+func checkROVR(rovr []byte) error {
+	switch len(rovr) {
+	case 8, 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("rovr of %d bytes should be 8, 16, 24 or 32 bytes", len(rovr))
+	}
+}
+
+// AddressRegistrationStatus describes the status of an
+// ICMPOptionAddressRegistration
+type AddressRegistrationStatus uint8
+
+// statuses currently defined in RFC6775 and RFC8505
+const (
+	AddressRegistrationSuccess AddressRegistrationStatus = iota
+	AddressRegistrationDuplicateAddress
+	AddressRegistrationNeighborCacheFull
+	AddressRegistrationMoved
+	AddressRegistrationRemoved
+	AddressRegistrationValidationRequested
+	AddressRegistrationDuplicateSourceAddress
+	AddressRegistrationInvalidSourceAddress
+	AddressRegistrationTopologicallyIncorrect
+	AddressRegistration6LBRRegistrySaturated
+	AddressRegistrationValidationFailed
+)
+
+func (s AddressRegistrationStatus) String() string {
+	switch s {
+	case AddressRegistrationSuccess:
+		return "success"
+	case AddressRegistrationDuplicateAddress:
+		return "duplicate address"
+	case AddressRegistrationNeighborCacheFull:
+		return "neighbor cache full"
+	case AddressRegistrationMoved:
+		return "moved"
+	case AddressRegistrationRemoved:
+		return "removed"
+	case AddressRegistrationValidationRequested:
+		return "validation requested"
+	case AddressRegistrationDuplicateSourceAddress:
+		return "duplicate source address"
+	case AddressRegistrationInvalidSourceAddress:
+		return "invalid source address"
+	case AddressRegistrationTopologicallyIncorrect:
+		return "registered address topologically incorrect"
+	case AddressRegistration6LBRRegistrySaturated:
+		return "6lbr registry saturated"
+	case AddressRegistrationValidationFailed:
+		return "validation failed"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPOptionAddressRegistration implements the Address Registration option
+// as described at https://tools.ietf.org/html/rfc6775#section-4.1 and its
+// extended form as described at https://tools.ietf.org/html/rfc8505#section-4.1
+type ICMPOptionAddressRegistration struct {
+	Status AddressRegistrationStatus
+	// Opaque is passed on to the routing protocol, its meaning is given by I
+	Opaque uint8
+	I      uint8
+	// Routing registration, asking the 6LR to redistribute the address
+	Routing bool
+	// TIDPresent tells whether TID is set, it's never set in options as
+	// described in RFC6775
+	TIDPresent bool
+	// TID is the transaction ID, a sequence number of registrations
+	TID uint8
+	// Lifetime is the registration lifetime in units of 60 seconds
+	Lifetime uint16
+	// ROVR is the registration ownership verifier of 8, 16, 24 or 32 bytes,
+	// or the EUI-64 of the registering node in RFC6775
+	ROVR []byte
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionAddressRegistration) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": status %s (%d), ", o.Status, o.Status)
+	f := []string{}
+	if o.Routing {
+		f = append(f, "routing")
+	}
+	if o.TIDPresent {
+		f = append(f, "tid")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	if o.TIDPresent {
+		s += fmt.Sprintf("tid %d, ", o.TID)
+	}
+	s += fmt.Sprintf("lifetime %s, ", o.RegistrationLifetime())
+	s += fmt.Sprintf("rovr %x", o.ROVR)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeAddressRegistration
+func (o ICMPOptionAddressRegistration) Type() ICMPOptionType {
+	return ICMPOptionTypeAddressRegistration
+}
+
+// Len returns the length in bytes of ICMPOptionAddressRegistration
+func (o ICMPOptionAddressRegistration) Len() uint8 {
+	return 1 + uint8((len(o.ROVR)+7)/8)
+}
+
+// RegistrationLifetime returns Lifetime as time.Duration
+func (o ICMPOptionAddressRegistration) RegistrationLifetime() time.Duration {
+	return time.Duration(o.Lifetime) * lowpanLifetimeUnit
+}
+
+// Marshal returns byte slice representing this ICMPOptionAddressRegistration
+func (o ICMPOptionAddressRegistration) Marshal() ([]byte, error) {
+	if err := checkROVR(o.ROVR); err != nil {
+		return nil, err
+	}
+
+	if o.I > 3 {
+		return nil, fmt.Errorf("invalid I field %d", o.I)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(o.Status)
+	b[3] = o.Opaque
+	b[4] = o.I << 2
+	if o.Routing {
+		b[4] ^= 0x02
+	}
+	if o.TIDPresent {
+		b[4] ^= 0x01
+	}
+	b[5] = o.TID
+	binary.BigEndian.PutUint16(b[6:8], o.Lifetime)
+	b = append(b, o.ROVR...)
+
+	return b, nil
+}
+
+// ICMPOption6LoWPANContext implements the 6LoWPAN Context option as
+// described at https://tools.ietf.org/html/rfc6775#section-4.2
+type ICMPOption6LoWPANContext struct {
+	ContextLength uint8
+	// Compression tells whether the context may be used for compression
+	Compression bool
+	// CID is the context identifier of 4 bits
+	CID uint8
+	// ValidLifetime is the lifetime of the context in units of 60 seconds
+	ValidLifetime uint16
+	Prefix        net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOption6LoWPANContext) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": %s/%d, cid %d, ", o.Prefix, o.ContextLength, o.CID)
+	f := []string{}
+	if o.Compression {
+		f = append(f, "compression")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("valid time %s", time.Duration(o.ValidLifetime)*lowpanLifetimeUnit)
+
+	return s
+}
+
+// Type returns ICMPOptionType6LoWPANContext
+func (o ICMPOption6LoWPANContext) Type() ICMPOptionType {
+	return ICMPOptionType6LoWPANContext
+}
+
+// Len returns the length in bytes of ICMPOption6LoWPANContext
+func (o ICMPOption6LoWPANContext) Len() uint8 {
+	// contexts up to 64 bits fit in 2
+	if o.ContextLength > 64 {
+		return 3
+	}
+
+	return 2
+}
+
+// Marshal returns byte slice representing this ICMPOption6LoWPANContext
+func (o ICMPOption6LoWPANContext) Marshal() ([]byte, error) {
+	if o.ContextLength > 128 {
+		return nil, fmt.Errorf("invalid context length %d", o.ContextLength)
+	}
+
+	if o.CID > 15 {
+		return nil, fmt.Errorf("invalid context identifier %d", o.CID)
+	}
+
+	prefix := o.Prefix.To16()
+	if prefix == nil {
+		return nil, fmt.Errorf("invalid prefix %s", o.Prefix)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = o.ContextLength
+	b[3] = o.CID
+	if o.Compression {
+		b[3] ^= 0x10
+	}
+	// b[4:6] = reserved
+	binary.BigEndian.PutUint16(b[6:8], o.ValidLifetime)
+	b = append(b, prefix.Mask(net.CIDRMask(int(o.ContextLength), 128))[:((int(o.Len())-1)*8)]...)
+
+	return b, nil
+}
+
+// ICMPOptionAuthoritativeBorderRouter implements the Authoritative Border
+// Router option as described at https://tools.ietf.org/html/rfc6775#section-4.3
+type ICMPOptionAuthoritativeBorderRouter struct {
+	// Version is increased by the 6LBR when its information changes
+	Version uint32
+	// ValidLifetime is the lifetime of the information in units of 60
+	// seconds, 0 means the default of 10000
+	ValidLifetime uint16
+	Address       net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionAuthoritativeBorderRouter) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": %s, version %d, ", o.Address, o.Version)
+	s += fmt.Sprintf("valid time %s", time.Duration(o.ValidLifetime)*lowpanLifetimeUnit)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeAuthoritativeBorderRouter
+func (o ICMPOptionAuthoritativeBorderRouter) Type() ICMPOptionType {
+	return ICMPOptionTypeAuthoritativeBorderRouter
+}
+
+// Len returns the length in bytes of ICMPOptionAuthoritativeBorderRouter
+func (o ICMPOptionAuthoritativeBorderRouter) Len() uint8 {
+	// Authoritative Border Router options are always 3
+	return 3
+}
+
+// Marshal returns byte slice representing this
+// ICMPOptionAuthoritativeBorderRouter
+func (o ICMPOptionAuthoritativeBorderRouter) Marshal() ([]byte, error) {
+	if o.Address.To16() == nil {
+		return nil, fmt.Errorf("invalid address %s", o.Address)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	binary.BigEndian.PutUint16(b[2:4], uint16(o.Version))
+	binary.BigEndian.PutUint16(b[4:6], uint16(o.Version>>16))
+	binary.BigEndian.PutUint16(b[6:8], o.ValidLifetime)
+	b = append(b, o.Address.To16()...)
+
+	return b, nil
+}
+
+// ICMPDuplicateAddressRequest implements the Duplicate Address Request
+// message as described at https://tools.ietf.org/html/rfc6775#section-4.4
+// and extended in https://tools.ietf.org/html/rfc8505#section-6
+type ICMPDuplicateAddressRequest struct {
+	TID uint8
+	// Lifetime is the registration lifetime in units of 60 seconds
+	Lifetime uint16
+	// ROVR is the registration ownership verifier of 8, 16, 24 or 32 bytes,
+	// or the EUI-64 of the registering node in RFC6775
+	ROVR              []byte
+	RegisteredAddress net.IP
+}
+
+func (p ICMPDuplicateAddressRequest) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("tid %d, ", p.TID)
+	s += fmt.Sprintf("lifetime %s, ", time.Duration(p.Lifetime)*lowpanLifetimeUnit)
+	s += fmt.Sprintf("rovr %x, ", p.ROVR)
+	s += fmt.Sprintf("address %s", p.RegisteredAddress)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeDuplicateAddressRequest
+func (p ICMPDuplicateAddressRequest) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeDuplicateAddressRequest
+}
+
+// Marshal returns byte slice representing this
+// ICMPDuplicateAddressRequest
+func (p ICMPDuplicateAddressRequest) Marshal() ([]byte, error) {
+	// status is always 0 in requests
+	return marshalDuplicateAddress(p.Type(), 0, p.TID, p.Lifetime, p.ROVR, p.RegisteredAddress)
+}
+
+// ICMPDuplicateAddressConfirmation implements the Duplicate Address
+// Confirmation message as described at
+// https://tools.ietf.org/html/rfc6775#section-4.4 and extended in
+// https://tools.ietf.org/html/rfc8505#section-6
+type ICMPDuplicateAddressConfirmation struct {
+	Status AddressRegistrationStatus
+	TID    uint8
+	// Lifetime is the registration lifetime in units of 60 seconds
+	Lifetime uint16
+	// ROVR is the registration ownership verifier of 8, 16, 24 or 32 bytes,
+	// or the EUI-64 of the registering node in RFC6775
+	ROVR              []byte
+	RegisteredAddress net.IP
+}
+
+func (p ICMPDuplicateAddressConfirmation) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("status %s (%d), ", p.Status, p.Status)
+	s += fmt.Sprintf("tid %d, ", p.TID)
+	s += fmt.Sprintf("lifetime %s, ", time.Duration(p.Lifetime)*lowpanLifetimeUnit)
+	s += fmt.Sprintf("rovr %x, ", p.ROVR)
+	s += fmt.Sprintf("address %s", p.RegisteredAddress)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeDuplicateAddressConfirmation
+func (p ICMPDuplicateAddressConfirmation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeDuplicateAddressConfirmation
+}
+
+// Marshal returns byte slice representing this
+// ICMPDuplicateAddressConfirmation
+func (p ICMPDuplicateAddressConfirmation) Marshal() ([]byte, error) {
+	return marshalDuplicateAddress(p.Type(), p.Status, p.TID, p.Lifetime, p.ROVR, p.RegisteredAddress)
+}
+
+func marshalDuplicateAddress(typ ipv6.ICMPType, status AddressRegistrationStatus, tid uint8, lifetime uint16, rovr []byte, addr net.IP) ([]byte, error) {
+	if err := checkROVR(rovr); err != nil {
+		return nil, err
+	}
+
+	if addr.To16() == nil {
+		return nil, fmt.Errorf("invalid registered address %s", addr)
+	}
+
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(typ)
+	// b[1] = code prefix, always 0, and code suffix, the size of the ROVR
+	// in units of 64 bits
+	b[1] = uint8(len(rovr) / 8)
+	// b[2:3] = checksum, calculated separately
+	b[4] = uint8(status)
+	b[5] = tid
+	binary.BigEndian.PutUint16(b[6:8], lifetime)
+	b = append(b, rovr...)
+	b = append(b, addr.To16()...)
+
+	return b, nil
+}
+
+func parseDuplicateAddress(b []byte) (ICMP, error) {
+	if len(b) < 32 {
+		return nil, errMessageTooShort
+	}
+
+	// the size of the ROVR follows from the size of the message
+	rovr := b[8:(len(b) - net.IPv6len)]
+	if err := checkROVR(rovr); err != nil {
+		return nil, err
+	}
+
+	// the code suffix should agree with that size, the code prefix is
+	// ignored. RFC6775 messages use code 0 with the 64-bit EUI-64.
+	suffix := int(b[1] & 0x0f)
+	if suffix != len(rovr)/8 && (suffix != 0 || len(rovr) != 8) {
+		return nil, fmt.Errorf("code suffix %d does not match rovr of %d bytes", suffix, len(rovr))
+	}
+
+	addr := net.IP(b[(len(b) - net.IPv6len):])
+	tid := b[5]
+	lifetime := binary.BigEndian.Uint16(b[6:8])
+
+	if ipv6.ICMPType(b[0]) == ipv6.ICMPTypeDuplicateAddressRequest {
+		return &ICMPDuplicateAddressRequest{
+			TID:               tid,
+			Lifetime:          lifetime,
+			ROVR:              rovr,
+			RegisteredAddress: addr,
+		}, nil
+	}
+
+	return &ICMPDuplicateAddressConfirmation{
+		Status:            AddressRegistrationStatus(b[4]),
+		TID:               tid,
+		Lifetime:          lifetime,
+		ROVR:              rovr,
+		RegisteredAddress: addr,
+	}, nil
+}
@@ -0,0 +1,330 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPOption6LoWPAN(t *testing.T) {
+	tests := []struct {
+		option  ICMPOption
+		typ     ICMPOptionType
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPOptionAddressRegistration{
+				Routing:    true,
+				TIDPresent: true,
+				TID:        7,
+				Lifetime:   5,
+				ROVR:       []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			},
+			ICMPOptionTypeAddressRegistration,
+			[]byte{33, 2, 0, 0, 3, 7, 0, 5, 1, 2, 3, 4, 5, 6, 7, 8},
+			"address registration option (33), length 16 (2): status success (0), Flags [routing tid], tid 7, lifetime 5m0s, rovr 0102030405060708",
+		},
+		{
+			&ICMPOptionAddressRegistration{
+				Status:   AddressRegistrationDuplicateAddress,
+				Lifetime: 60,
+				ROVR:     []byte{2, 0, 94, 255, 254, 0, 83, 1},
+			},
+			ICMPOptionTypeAddressRegistration,
+			[]byte{33, 2, 1, 0, 0, 0, 0, 60, 2, 0, 94, 255, 254, 0, 83, 1},
+			"address registration option (33), length 16 (2): status duplicate address (1), Flags [], lifetime 1h0m0s, rovr 02005efffe005301",
+		},
+		{
+			&ICMPOptionAddressRegistration{
+				Opaque:     9,
+				I:          1,
+				TIDPresent: true,
+				TID:        255,
+				Lifetime:   1,
+				ROVR:       bytes.Repeat([]byte{170}, 16),
+			},
+			ICMPOptionTypeAddressRegistration,
+			append([]byte{33, 3, 0, 9, 5, 255, 0, 1}, bytes.Repeat([]byte{170}, 16)...),
+			"address registration option (33), length 24 (3): status success (0), Flags [tid], tid 255, lifetime 1m0s, rovr aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+		{
+			&ICMPOption6LoWPANContext{
+				ContextLength: 64,
+				Compression:   true,
+				CID:           1,
+				ValidLifetime: 10,
+				Prefix:        net.ParseIP("2001:db8::"),
+			},
+			ICMPOptionType6LoWPANContext,
+			[]byte{34, 2, 64, 17, 0, 0, 0, 10, 32, 1, 13, 184, 0, 0, 0, 0},
+			"6lowpan context option (34), length 16 (2): 2001:db8::/64, cid 1, Flags [compression], valid time 10m0s",
+		},
+		{
+			&ICMPOption6LoWPANContext{
+				ContextLength: 80,
+				CID:           2,
+				ValidLifetime: 10,
+				Prefix:        net.ParseIP("2001:db8:0:0:1234::"),
+			},
+			ICMPOptionType6LoWPANContext,
+			[]byte{34, 3, 80, 2, 0, 0, 0, 10, 32, 1, 13, 184, 0, 0, 0, 0, 18, 52, 0, 0, 0, 0, 0, 0},
+			"6lowpan context option (34), length 24 (3): 2001:db8:0:0:1234::/80, cid 2, Flags [], valid time 10m0s",
+		},
+		{
+			&ICMPOptionAuthoritativeBorderRouter{
+				Version: 65538,
+				Address: net.ParseIP("2001:db8::1"),
+			},
+			ICMPOptionTypeAuthoritativeBorderRouter,
+			[]byte{35, 3, 0, 2, 0, 1, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			"authoritative border router option (35), length 24 (3): 2001:db8::1, version 65538, valid time 0s",
+		},
+	}
+
+	for _, test := range tests {
+		if test.option.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.option.Type(), test.typ)
+		}
+
+		marshal, err := test.option.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.option.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		options, err := parseOptions(test.fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(options) != 1 {
+			t.Fatalf("expected 1 option, got %d", len(options))
+		}
+
+		parsedMarshal, err := options[0].Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+	}
+}
+
+func TestICMPOption6LoWPANInvalid(t *testing.T) {
+	options := []ICMPOption{
+		&ICMPOptionAddressRegistration{ROVR: []byte{1, 2, 3}},
+		&ICMPOptionAddressRegistration{I: 4, ROVR: make([]byte, 8)},
+		&ICMPOption6LoWPANContext{ContextLength: 129, Prefix: net.ParseIP("2001:db8::")},
+		&ICMPOption6LoWPANContext{ContextLength: 64, CID: 16, Prefix: net.ParseIP("2001:db8::")},
+		&ICMPOption6LoWPANContext{ContextLength: 64},
+		&ICMPOptionAuthoritativeBorderRouter{},
+	}
+
+	for _, option := range options {
+		if _, err := option.Marshal(); err == nil {
+			t.Errorf("expected error marshalling %T", option)
+		}
+	}
+
+	fixtures := [][]byte{
+		append([]byte{33, 6}, make([]byte, 46)...),
+		{34, 2, 80, 0, 0, 0, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0},
+		append([]byte{34, 3, 64}, make([]byte, 21)...),
+		{35, 2, 0, 0, 0, 0, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0},
+	}
+
+	for _, fixture := range fixtures {
+		if _, err := parseOptions(fixture); err == nil {
+			t.Errorf("expected error parsing %v", fixture)
+		}
+	}
+}
+
+func TestICMPOption6LoWPANMessages(t *testing.T) {
+	ns := &ICMPNeighborSolicitation{
+		TargetAddress: net.ParseIP("2001:db8::2"),
+	}
+	ns.AddOption(&ICMPOptionAddressRegistration{
+		TIDPresent: true,
+		TID:        1,
+		Lifetime:   10,
+		ROVR:       []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	})
+
+	ra := &ICMPRouterAdvertisement{
+		HopLimit:       64,
+		RouterLifeTime: 1800,
+	}
+	ra.AddOption(&ICMPOption6LoWPANContext{
+		ContextLength: 64,
+		Compression:   true,
+		ValidLifetime: 60,
+		Prefix:        net.ParseIP("2001:db8::"),
+	})
+	ra.AddOption(&ICMPOptionAuthoritativeBorderRouter{
+		Version:       1,
+		ValidLifetime: 60,
+		Address:       net.ParseIP("2001:db8::1"),
+	})
+
+	for _, icmp := range []ICMP{ns, ra} {
+		marshal, err := icmp.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := ParseMessage(marshal)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsedMarshal, err := parsed.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+	}
+
+	marshal, err := ns.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseMessage(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	option := parsed.(*ICMPNeighborSolicitation).Options[0]
+	if _, ok := option.(*ICMPOptionAddressRegistration); !ok {
+		t.Errorf("expected address registration option, got %T", option)
+	}
+}
+
+func TestICMPDuplicateAddress(t *testing.T) {
+	tests := []struct {
+		icmp    ICMP
+		typ     ipv6.ICMPType
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPDuplicateAddressRequest{
+				TID:               5,
+				Lifetime:          10,
+				ROVR:              []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				RegisteredAddress: net.ParseIP("2001:db8::2"),
+			},
+			ipv6.ICMPTypeDuplicateAddressRequest,
+			[]byte{157, 1, 0, 0, 0, 5, 0, 10, 1, 2, 3, 4, 5, 6, 7, 8, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2},
+			"duplicate address request, length 32, tid 5, lifetime 10m0s, rovr 0102030405060708, address 2001:db8::2",
+		},
+		{
+			&ICMPDuplicateAddressConfirmation{
+				Status:            AddressRegistrationDuplicateAddress,
+				TID:               5,
+				Lifetime:          10,
+				ROVR:              bytes.Repeat([]byte{170}, 16),
+				RegisteredAddress: net.ParseIP("2001:db8::2"),
+			},
+			ipv6.ICMPTypeDuplicateAddressConfirmation,
+			append(append([]byte{158, 2, 0, 0, 1, 5, 0, 10}, bytes.Repeat([]byte{170}, 16)...), 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2),
+			"duplicate address confirmation, length 40, status duplicate address (1), tid 5, lifetime 10m0s, rovr aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa, address 2001:db8::2",
+		},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.icmp.Type(), test.typ)
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(test.fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsedMarshal, err := parsedICMP.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+
+		_, err = ParseMessage(test.fixture[:30])
+		if err != errMessageTooShort {
+			t.Errorf("unexpected error message: %s", err)
+		}
+
+		// ROVR of 12 bytes
+		if _, err = ParseMessage(append(test.fixture[:8:8], make([]byte, 28)...)); err == nil {
+			t.Error("expected error parsing invalid rovr")
+		}
+
+		// code suffix not matching the size of the ROVR
+		invalid := append([]byte(nil), test.fixture...)
+		invalid[1] = 4
+		if _, err = ParseMessage(invalid); err == nil {
+			t.Error("expected error parsing mismatching code suffix")
+		}
+	}
+
+	// RFC6775 messages use code 0 with the EUI-64 of the registering node
+	legacy := []byte{157, 0, 0, 0, 0, 5, 0, 10, 2, 0, 0, 255, 254, 0, 0, 1, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+	parsed, err := ParseMessage(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dar, ok := parsed.(*ICMPDuplicateAddressRequest); !ok || !bytes.Equal(dar.ROVR, []byte{2, 0, 0, 255, 254, 0, 0, 1}) || !dar.RegisteredAddress.Equal(net.ParseIP("2001:db8::2")) {
+		t.Errorf("unexpected message %v", parsed)
+	}
+
+	// but only with a 64-bit ROVR
+	legacy = append(append([]byte{158, 0, 0, 0, 0, 5, 0, 10}, bytes.Repeat([]byte{170}, 16)...), legacy[16:]...)
+	if _, err := ParseMessage(legacy); err == nil {
+		t.Error("expected error parsing code 0 with 128-bit rovr")
+	}
+
+	dar := &ICMPDuplicateAddressRequest{ROVR: []byte{1}, RegisteredAddress: net.ParseIP("2001:db8::2")}
+	if _, err := dar.Marshal(); err == nil {
+		t.Error("expected error marshalling invalid rovr")
+	}
+
+	dar = &ICMPDuplicateAddressRequest{ROVR: make([]byte, 8)}
+	if _, err := dar.Marshal(); err == nil {
+		t.Error("expected error marshalling without address")
+	}
+}
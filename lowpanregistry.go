@@ -0,0 +1,203 @@
+package ndp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// tidSequenceWindow is the window in which transaction IDs are comparable
+// as described at https://tools.ietf.org/html/rfc6550#section-7.2
+const tidSequenceWindow = 16
+
+// Registration describes an address registered at a 6LBR
+type Registration struct {
+	Address net.IP
+	ROVR    []byte
+	TID     uint8
+	Expires time.Time
+}
+
+// RegistrationTable is the registry of a 6LoWPAN Border Router, which
+// performs duplicate address detection across the whole 6LoWPAN on behalf
+// of its 6LRs by processing Duplicate Address Requests, as described at
+// https://tools.ietf.org/html/rfc6775#section-8.2 and
+// https://tools.ietf.org/html/rfc8505#section-6
+type RegistrationTable struct {
+	maxRegistrations int
+
+	mu            sync.Mutex
+	registrations map[string]*Registration
+}
+
+// NewRegistrationTable returns an empty RegistrationTable, holding at most
+// given number of registrations or an unlimited number when 0
+func NewRegistrationTable(maxRegistrations int) *RegistrationTable {
+	return &RegistrationTable{
+		maxRegistrations: maxRegistrations,
+		registrations:    make(map[string]*Registration),
+	}
+}
+
+// HandleDAR processes given Duplicate Address Request and returns the
+// Duplicate Address Confirmation to answer it with
+func (t *RegistrationTable) HandleDAR(dar *ICMPDuplicateAddressRequest, now time.Time) (*ICMPDuplicateAddressConfirmation, error) {
+	if err := checkROVR(dar.ROVR); err != nil {
+		return nil, err
+	}
+
+	addr := dar.RegisteredAddress.To16()
+	if addr == nil || addr.IsMulticast() || addr.IsUnspecified() {
+		return nil, fmt.Errorf("invalid registered address %s", dar.RegisteredAddress)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dac := &ICMPDuplicateAddressConfirmation{
+		Status:            t.register(addr, dar, now),
+		TID:               dar.TID,
+		Lifetime:          dar.Lifetime,
+		ROVR:              dar.ROVR,
+		RegisteredAddress: dar.RegisteredAddress,
+	}
+
+	return dac, nil
+}
+
+// Lookup returns the registration of given address
+func (t *RegistrationTable) Lookup(addr net.IP, now time.Time) (Registration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.registrations[addr.String()]
+	if !ok || !now.Before(r.Expires) {
+		return Registration{}, false
+	}
+
+	return *r, true
+}
+
+// Registrations returns all registrations that didn't expire at given time,
+// ordered by address
+func (t *RegistrationTable) Registrations(now time.Time) []Registration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	addrs := []net.IP{}
+	for _, r := range t.registrations {
+		if now.Before(r.Expires) {
+			addrs = append(addrs, r.Address)
+		}
+	}
+	sortIPs(addrs)
+
+	registrations := make([]Registration, 0, len(addrs))
+	for _, addr := range addrs {
+		registrations = append(registrations, *t.registrations[addr.String()])
+	}
+
+	return registrations
+}
+
+// Expire removes all registrations whose lifetime ended at given time
+func (t *RegistrationTable) Expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, r := range t.registrations {
+		if !now.Before(r.Expires) {
+			delete(t.registrations, key)
+		}
+	}
+}
+
+func (t *RegistrationTable) register(addr net.IP, dar *ICMPDuplicateAddressRequest, now time.Time) AddressRegistrationStatus {
+	key := addr.String()
+	r, ok := t.registrations[key]
+	if ok && !now.Before(r.Expires) {
+		delete(t.registrations, key)
+		ok = false
+	}
+
+	if ok {
+		// the address is owned by another node
+		if !sameROVR(r.ROVR, dar.ROVR) {
+			return AddressRegistrationDuplicateAddress
+		}
+
+		// the registration was updated through another 6LR since
+		if dar.TID != r.TID && !tidNewer(dar.TID, r.TID) {
+			return AddressRegistrationMoved
+		}
+	}
+
+	// a lifetime of 0 removes the registration
+	if dar.Lifetime == 0 {
+		delete(t.registrations, key)
+		return AddressRegistrationSuccess
+	}
+
+	if !ok && t.maxRegistrations > 0 && len(t.registrations) >= t.maxRegistrations {
+		// make room by dropping registrations that expired
+		for k, o := range t.registrations {
+			if !now.Before(o.Expires) {
+				delete(t.registrations, k)
+			}
+		}
+
+		if len(t.registrations) >= t.maxRegistrations {
+			return AddressRegistration6LBRRegistrySaturated
+		}
+	}
+
+	t.registrations[key] = &Registration{
+		Address: addr,
+		ROVR:    append([]byte(nil), dar.ROVR...),
+		TID:     dar.TID,
+		Expires: now.Add(time.Duration(dar.Lifetime) * lowpanLifetimeUnit),
+	}
+
+	return AddressRegistrationSuccess
+}
+
+// sameROVR compares registration ownership verifiers of possibly different
+// sizes over the size of the shortest as described at
+// https://tools.ietf.org/html/rfc8505#section-5.3
+func sameROVR(a, b []byte) bool {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	return bytes.Equal(a[:n], b[:n])
+}
+
+// tidNewer returns whether transaction ID a is newer than b, using the
+// lollipop counters described at https://tools.ietf.org/html/rfc6550#section-7.2
+// When both are too far apart to be compared, a is considered newer.
+func tidNewer(a, b uint8) bool {
+	switch {
+	case a > 127 && b <= 127:
+		return 256+int(b)-int(a) > tidSequenceWindow
+	case a <= 127 && b > 127:
+		return 256+int(a)-int(b) <= tidSequenceWindow
+	}
+
+	d := int(a) - int(b)
+	if a <= 127 {
+		// the circular region wraps around
+		d = int((a - b) & 0x7f)
+		if d >= 64 {
+			d -= 128
+		}
+	}
+
+	if d > tidSequenceWindow || d < -tidSequenceWindow {
+		return true
+	}
+
+	return d > 0
+}
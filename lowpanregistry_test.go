@@ -0,0 +1,135 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRegistrationTable(t *testing.T) {
+	table := NewRegistrationTable(2)
+	now := time.Unix(0, 0)
+	addr := net.ParseIP("2001:db8::2")
+	rovr := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	tests := []struct {
+		desc   string
+		dar    *ICMPDuplicateAddressRequest
+		status AddressRegistrationStatus
+	}{
+		{
+			"new registration",
+			&ICMPDuplicateAddressRequest{TID: 10, Lifetime: 5, ROVR: rovr, RegisteredAddress: addr},
+			AddressRegistrationSuccess,
+		},
+		{
+			"other owner",
+			&ICMPDuplicateAddressRequest{TID: 10, Lifetime: 5, ROVR: []byte{8, 7, 6, 5, 4, 3, 2, 1}, RegisteredAddress: addr},
+			AddressRegistrationDuplicateAddress,
+		},
+		{
+			"refresh by longer rovr",
+			&ICMPDuplicateAddressRequest{TID: 11, Lifetime: 5, ROVR: append(rovr, rovr...), RegisteredAddress: addr},
+			AddressRegistrationSuccess,
+		},
+		{
+			"stale registration",
+			&ICMPDuplicateAddressRequest{TID: 10, Lifetime: 5, ROVR: rovr, RegisteredAddress: addr},
+			AddressRegistrationMoved,
+		},
+		{
+			"second address",
+			&ICMPDuplicateAddressRequest{Lifetime: 5, ROVR: rovr, RegisteredAddress: net.ParseIP("2001:db8::3")},
+			AddressRegistrationSuccess,
+		},
+		{
+			"registry full",
+			&ICMPDuplicateAddressRequest{Lifetime: 5, ROVR: rovr, RegisteredAddress: net.ParseIP("2001:db8::4")},
+			AddressRegistration6LBRRegistrySaturated,
+		},
+	}
+
+	for _, test := range tests {
+		dac, err := table.HandleDAR(test.dar, now)
+		if err != nil {
+			t.Fatalf("%s: %s", test.desc, err)
+		}
+
+		if dac.Status != test.status {
+			t.Errorf("%s: unexpected status %s", test.desc, dac.Status)
+		}
+
+		if dac.TID != test.dar.TID || dac.Lifetime != test.dar.Lifetime || !dac.RegisteredAddress.Equal(test.dar.RegisteredAddress) {
+			t.Errorf("%s: unexpected confirmation %s", test.desc, dac)
+		}
+	}
+
+	r, ok := table.Lookup(addr, now)
+	if !ok || r.TID != 11 || len(r.ROVR) != 16 || !r.Expires.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("unexpected registration %+v", r)
+	}
+
+	if registrations := table.Registrations(now); len(registrations) != 2 || !registrations[0].Address.Equal(addr) {
+		t.Errorf("unexpected registrations %+v", registrations)
+	}
+
+	// a lifetime of 0 removes the registration
+	dac, _ := table.HandleDAR(&ICMPDuplicateAddressRequest{TID: 12, ROVR: rovr, RegisteredAddress: addr}, now)
+	if dac.Status != AddressRegistrationSuccess {
+		t.Errorf("unexpected status %s", dac.Status)
+	}
+
+	if _, ok = table.Lookup(addr, now); ok {
+		t.Error("expected registration to be removed")
+	}
+
+	// expired registrations make room and are free to be taken
+	later := now.Add(5 * time.Minute)
+	if _, ok = table.Lookup(net.ParseIP("2001:db8::3"), later); ok {
+		t.Error("expected registration to be expired")
+	}
+
+	dac, _ = table.HandleDAR(&ICMPDuplicateAddressRequest{Lifetime: 1, ROVR: []byte{8, 7, 6, 5, 4, 3, 2, 1}, RegisteredAddress: net.ParseIP("2001:db8::3")}, later)
+	if dac.Status != AddressRegistrationSuccess {
+		t.Errorf("unexpected status %s", dac.Status)
+	}
+
+	table.Expire(later.Add(time.Minute))
+	if registrations := table.Registrations(later); len(registrations) != 0 {
+		t.Errorf("unexpected registrations %+v", registrations)
+	}
+
+	// invalid requests
+	if _, err := table.HandleDAR(&ICMPDuplicateAddressRequest{ROVR: []byte{1}, RegisteredAddress: addr}, now); err == nil {
+		t.Error("expected error for invalid rovr")
+	}
+
+	if _, err := table.HandleDAR(&ICMPDuplicateAddressRequest{ROVR: rovr, RegisteredAddress: net.ParseIP("ff02::1")}, now); err == nil {
+		t.Error("expected error for multicast address")
+	}
+}
+
+func TestTIDNewer(t *testing.T) {
+	tests := []struct {
+		a, b  uint8
+		newer bool
+	}{
+		{11, 10, true},
+		{10, 11, false},
+		{10, 10, false},
+		{0, 127, true},
+		{127, 0, false},
+		{0, 255, true},
+		{255, 0, false},
+		{129, 128, true},
+		{128, 5, true},
+		{5, 128, false},
+		{10, 100, true},
+	}
+
+	for _, test := range tests {
+		if tidNewer(test.a, test.b) != test.newer {
+			t.Errorf("tidNewer(%d, %d) should be %t", test.a, test.b, test.newer)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// decode the Maximum Response Code as described at
+// https://tools.ietf.org/html/rfc3810#section-5.1.3
+func decMLDMaxRespCode(c uint16) time.Duration {
+	if c < 32768 {
+		return time.Duration(c) * time.Millisecond
+	}
+
+	mant := uint32(c & 0x0fff)
+	exp := uint32((c >> 12) & 0x07)
+	return time.Duration((mant|0x1000)<<(exp+3)) * time.Millisecond
+}
+
+// encode given delay in a Maximum Response Code, rounding down to the closest
+// value that can be represented
+func encMLDMaxRespCode(d time.Duration) uint16 {
+	ms := d.Milliseconds()
+	if ms < 32768 {
+		return uint16(ms)
+	}
+
+	for exp := uint32(0); exp < 8; exp++ {
+		mant := (ms >> (exp + 3)) - 0x1000
+		if mant < 0x1000 {
+			return 0x8000 | uint16(exp<<12) | uint16(mant)
+		}
+	}
+
+	return 0xffff
+}
+
+// decode the Querier's Query Interval Code as described at
+// https://tools.ietf.org/html/rfc3810#section-5.1.9
+func decMLDQQIC(c uint8) time.Duration {
+	if c < 128 {
+		return time.Duration(c) * time.Second
+	}
+
+	mant := uint32(c & 0x0f)
+	exp := uint32((c >> 4) & 0x07)
+	return time.Duration((mant|0x10)<<(exp+3)) * time.Second
+}
+
+// encode given interval in a Querier's Query Interval Code, rounding down to
+// the closest value that can be represented
+func encMLDQQIC(d time.Duration) uint8 {
+	s := int64(d / time.Second)
+	if s < 128 {
+		return uint8(s)
+	}
+
+	for exp := uint32(0); exp < 8; exp++ {
+		mant := (s >> (exp + 3)) - 0x10
+		if mant < 0x10 {
+			return 0x80 | uint8(exp<<4) | uint8(mant)
+		}
+	}
+
+	return 0xff
+}
+
+// ICMPMulticastListenerQuery implements the Multicast Listener Query message
+// as described at https://tools.ietf.org/html/rfc2710#section-3 and, unless
+// MLDv1 is set, https://tools.ietf.org/html/rfc3810#section-5.1
+type ICMPMulticastListenerQuery struct {
+	MLDv1                    bool
+	MaximumResponseCode      uint16
+	MulticastAddress         net.IP
+	SuppressRouterProcessing bool
+	RobustnessVariable       uint8
+	QQIC                     uint8
+	Sources                  []net.IP
+}
+
+// MaximumResponseDelay returns the delay encoded in MaximumResponseCode
+func (p ICMPMulticastListenerQuery) MaximumResponseDelay() time.Duration {
+	if p.MLDv1 {
+		return time.Duration(p.MaximumResponseCode) * time.Millisecond
+	}
+
+	return decMLDMaxRespCode(p.MaximumResponseCode)
+}
+
+// QueryInterval returns the interval encoded in QQIC
+func (p ICMPMulticastListenerQuery) QueryInterval() time.Duration {
+	return decMLDQQIC(p.QQIC)
+}
+
+// IsGeneral returns true if this is a General Query
+func (p ICMPMulticastListenerQuery) IsGeneral() bool {
+	return p.MulticastAddress == nil || p.MulticastAddress.IsUnspecified()
+}
+
+func (p ICMPMulticastListenerQuery) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	if p.MLDv1 {
+		s += "v1, "
+	} else {
+		s += "v2, "
+	}
+	s += fmt.Sprintf("max resp delay %s, ", p.MaximumResponseDelay())
+	s += fmt.Sprintf("group %s", p.MulticastAddress)
+	if p.MLDv1 {
+		return s
+	}
+
+	f := []string{}
+	if p.SuppressRouterProcessing {
+		f = append(f, "suppress")
+	}
+	s += fmt.Sprintf(", Flags %s, ", f)
+	s += fmt.Sprintf("robustness %d, ", p.RobustnessVariable)
+	s += fmt.Sprintf("qqi %s", p.QueryInterval())
+	for _, a := range p.Sources {
+		s += fmt.Sprintf(", source %s", a)
+	}
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeMulticastListenerQuery
+func (p ICMPMulticastListenerQuery) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMulticastListenerQuery
+}
+
+// Marshal returns byte slice representing this ICMPMulticastListenerQuery
+func (p ICMPMulticastListenerQuery) Marshal() ([]byte, error) {
+	if p.RobustnessVariable > 7 {
+		return nil, fmt.Errorf("robustness variable %d too large to fit in boundaries", p.RobustnessVariable)
+	}
+
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.MaximumResponseCode)
+	if p.MulticastAddress == nil {
+		b = append(b, net.IPv6unspecified...)
+	} else {
+		b = append(b, p.MulticastAddress.To16()...)
+	}
+
+	if p.MLDv1 {
+		return b, nil
+	}
+
+	v2 := make([]byte, 4)
+	if p.SuppressRouterProcessing {
+		v2[0] ^= 0x08
+	}
+	v2[0] ^= p.RobustnessVariable
+	v2[1] = p.QQIC
+	binary.BigEndian.PutUint16(v2[2:4], uint16(len(p.Sources)))
+	b = append(b, v2...)
+	for _, a := range p.Sources {
+		b = append(b, a.To16()...)
+	}
+
+	return b, nil
+}
+
+// ICMPMulticastListenerReport implements the Multicast Listener Report message
+// as described at https://tools.ietf.org/html/rfc2710#section-3
+type ICMPMulticastListenerReport struct {
+	MulticastAddress net.IP
+}
+
+func (p ICMPMulticastListenerReport) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("group %s", p.MulticastAddress)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeMulticastListenerReport
+func (p ICMPMulticastListenerReport) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMulticastListenerReport
+}
+
+// Marshal returns byte slice representing this ICMPMulticastListenerReport
+func (p ICMPMulticastListenerReport) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	b = append(b, p.MulticastAddress.To16()...)
+
+	return b, nil
+}
+
+// ICMPMulticastListenerDone implements the Multicast Listener Done message
+// as described at https://tools.ietf.org/html/rfc2710#section-3
+type ICMPMulticastListenerDone struct {
+	MulticastAddress net.IP
+}
+
+func (p ICMPMulticastListenerDone) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("group %s", p.MulticastAddress)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeMulticastListenerDone
+func (p ICMPMulticastListenerDone) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMulticastListenerDone
+}
+
+// Marshal returns byte slice representing this ICMPMulticastListenerDone
+func (p ICMPMulticastListenerDone) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	b = append(b, p.MulticastAddress.To16()...)
+
+	return b, nil
+}
+
+// MulticastAddressRecordType implements the Record Types as described at
+// https://tools.ietf.org/html/rfc3810#section-5.2.12
+type MulticastAddressRecordType uint8
+
+// types currently defined
+const (
+	_ MulticastAddressRecordType = iota
+	MulticastAddressRecordModeIsInclude
+	MulticastAddressRecordModeIsExclude
+	MulticastAddressRecordChangeToInclude
+	MulticastAddressRecordChangeToExclude
+	MulticastAddressRecordAllowNewSources
+	MulticastAddressRecordBlockOldSources
+)
+
+func (typ MulticastAddressRecordType) String() string {
+	switch typ {
+	case MulticastAddressRecordModeIsInclude:
+		return "is_in"
+	case MulticastAddressRecordModeIsExclude:
+		return "is_ex"
+	case MulticastAddressRecordChangeToInclude:
+		return "to_in"
+	case MulticastAddressRecordChangeToExclude:
+		return "to_ex"
+	case MulticastAddressRecordAllowNewSources:
+		return "allow"
+	case MulticastAddressRecordBlockOldSources:
+		return "block"
+	default:
+		return "<nil>"
+	}
+}
+
+// MulticastAddressRecord implements the Multicast Address Record as described
+// at https://tools.ietf.org/html/rfc3810#section-5.2.4
+type MulticastAddressRecord struct {
+	RecordType       MulticastAddressRecordType
+	MulticastAddress net.IP
+	Sources          []net.IP
+	AuxData          []byte
+}
+
+// IsJoin returns true if this record states there is interest in traffic for
+// its multicast address
+func (r MulticastAddressRecord) IsJoin() bool {
+	switch r.RecordType {
+	case MulticastAddressRecordModeIsExclude, MulticastAddressRecordChangeToExclude:
+		return true
+	case MulticastAddressRecordModeIsInclude, MulticastAddressRecordChangeToInclude, MulticastAddressRecordAllowNewSources:
+		return len(r.Sources) > 0
+	default:
+		return false
+	}
+}
+
+// IsLeave returns true if this record states there is no more interest in
+// traffic for its multicast address
+func (r MulticastAddressRecord) IsLeave() bool {
+	return r.RecordType == MulticastAddressRecordChangeToInclude && len(r.Sources) == 0
+}
+
+func (r MulticastAddressRecord) String() string {
+	s := fmt.Sprintf("%s %s", r.RecordType, r.MulticastAddress)
+	if len(r.Sources) > 0 {
+		src := []string{}
+		for _, a := range r.Sources {
+			src = append(src, a.String())
+		}
+		s += fmt.Sprintf(" { %s }", strings.Join(src, " "))
+	}
+
+	return s
+}
+
+// ICMPVersion2MulticastListenerReport implements the Version 2 Multicast
+// Listener Report message as described at
+// https://tools.ietf.org/html/rfc3810#section-5.2
+type ICMPVersion2MulticastListenerReport struct {
+	Records []MulticastAddressRecord
+}
+
+func (p ICMPVersion2MulticastListenerReport) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%d group record(s)\n", len(p.Records))
+	for _, r := range p.Records {
+		s += fmt.Sprintf("    %s\n", r)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeVersion2MulticastListenerReport
+func (p ICMPVersion2MulticastListenerReport) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeVersion2MulticastListenerReport
+}
+
+// Marshal returns byte slice representing this
+// ICMPVersion2MulticastListenerReport
+func (p ICMPVersion2MulticastListenerReport) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[6:8], uint16(len(p.Records)))
+	for _, r := range p.Records {
+		if len(r.AuxData)%4 != 0 {
+			return nil, fmt.Errorf("aux data of %s should be a multiple of 4 octets", r.MulticastAddress)
+		}
+
+		h := make([]byte, 4)
+		h[0] = uint8(r.RecordType)
+		h[1] = uint8(len(r.AuxData) / 4)
+		binary.BigEndian.PutUint16(h[2:4], uint16(len(r.Sources)))
+		b = append(b, h...)
+		b = append(b, r.MulticastAddress.To16()...)
+		for _, a := range r.Sources {
+			b = append(b, a.To16()...)
+		}
+		b = append(b, r.AuxData...)
+	}
+
+	return b, nil
+}
+
+func parseMulticastListenerQuery(b []byte) (*ICMPMulticastListenerQuery, error) {
+	if len(b) < 24 {
+		return nil, errMessageTooShort
+	}
+
+	message := &ICMPMulticastListenerQuery{
+		MaximumResponseCode: binary.BigEndian.Uint16(b[4:6]),
+		MulticastAddress:    net.IP(b[8:24]),
+	}
+
+	// MLDv1 queries are exactly 24 octets
+	if len(b) < 28 {
+		message.MLDv1 = true
+		return message, nil
+	}
+
+	message.SuppressRouterProcessing = (b[24]&0x08 > 0)
+	message.RobustnessVariable = b[24] & 0x07
+	message.QQIC = b[25]
+
+	n := int(binary.BigEndian.Uint16(b[26:28]))
+	if len(b) < 28+(n*16) {
+		return nil, errMessageTooShort
+	}
+
+	for i := 0; i < n; i++ {
+		message.Sources = append(message.Sources, net.IP(b[28+(i*16):44+(i*16)]))
+	}
+
+	return message, nil
+}
+
+func parseVersion2MulticastListenerReport(b []byte) (*ICMPVersion2MulticastListenerReport, error) {
+	if len(b) < 8 {
+		return nil, errMessageTooShort
+	}
+
+	message := &ICMPVersion2MulticastListenerReport{}
+
+	n := int(binary.BigEndian.Uint16(b[6:8]))
+	b = b[8:]
+	for i := 0; i < n; i++ {
+		if len(b) < 20 {
+			return nil, errMessageTooShort
+		}
+
+		auxLen := int(b[1]) * 4
+		srcLen := int(binary.BigEndian.Uint16(b[2:4])) * 16
+		if len(b) < 20+srcLen+auxLen {
+			return nil, errMessageTooShort
+		}
+
+		r := MulticastAddressRecord{
+			RecordType:       MulticastAddressRecordType(b[0]),
+			MulticastAddress: net.IP(b[4:20]),
+		}
+		for j := 20; j < 20+srcLen; j += 16 {
+			r.Sources = append(r.Sources, net.IP(b[j:(j+16)]))
+		}
+		if auxLen > 0 {
+			r.AuxData = b[20+srcLen : 20+srcLen+auxLen]
+		}
+
+		message.Records = append(message.Records, r)
+		b = b[20+srcLen+auxLen:]
+	}
+
+	return message, nil
+}
@@ -0,0 +1,348 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestMLDCodes(t *testing.T) {
+	respTests := []struct {
+		in   time.Duration
+		code uint16
+	}{
+		{10 * time.Second, 10000},
+		{32767 * time.Millisecond, 32767},
+		{32768 * time.Millisecond, 0x8000},
+		{65536 * time.Millisecond, 0x9000},
+	}
+
+	for _, test := range respTests {
+		code := encMLDMaxRespCode(test.in)
+		if code != test.code {
+			t.Errorf("expected code %#x for %s but got %#x", test.code, test.in, code)
+		}
+		if decMLDMaxRespCode(code) != test.in {
+			t.Errorf("expected %s for code %#x but got %s", test.in, code, decMLDMaxRespCode(code))
+		}
+	}
+
+	qqiTests := []struct {
+		in   time.Duration
+		code uint8
+	}{
+		{125 * time.Second, 125},
+		{128 * time.Second, 0x80},
+		{256 * time.Second, 0x90},
+	}
+
+	for _, test := range qqiTests {
+		code := encMLDQQIC(test.in)
+		if code != test.code {
+			t.Errorf("expected code %#x for %s but got %#x", test.code, test.in, code)
+		}
+		if decMLDQQIC(code) != test.in {
+			t.Errorf("expected %s for code %#x but got %s", test.in, code, decMLDQQIC(code))
+		}
+	}
+}
+
+func TestICMPMulticastListenerQuery(t *testing.T) {
+	icmp := &ICMPMulticastListenerQuery{
+		MaximumResponseCode: 10000,
+		MulticastAddress:    net.IPv6unspecified,
+		RobustnessVariable:  2,
+		QQIC:                125,
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeMulticastListenerQuery {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeMulticastListenerQuery)
+	}
+
+	if !icmp.IsGeneral() {
+		t.Error("expected general query")
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{130, 0, 0, 0, 39, 16, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 125, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "multicast listener query, length 28, v2, max resp delay 10s, group ::, Flags [], robustness 2, qqi 2m5s"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// address specific query with sources
+	icmp.MulticastAddress = net.ParseIP("ff02::1:ff00:1")
+	icmp.SuppressRouterProcessing = true
+	icmp.Sources = []net.IP{net.ParseIP("fe80::1")}
+
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{130, 0, 0, 0, 39, 16, 0, 0, 255, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 255, 0, 0, 1, 10, 125, 0, 1, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix = "multicast listener query, length 44, v2, max resp delay 10s, group ff02::1:ff00:1, Flags [suppress], robustness 2, qqi 2m5s, source fe80::1"
+	desc = icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err = parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// MLDv1 query
+	icmp = &ICMPMulticastListenerQuery{
+		MLDv1:               true,
+		MaximumResponseCode: 1000,
+		MulticastAddress:    net.ParseIP("ff02::1:ff00:1"),
+	}
+
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{130, 0, 0, 0, 3, 232, 0, 0, 255, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 255, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix = "multicast listener query, length 24, v1, max resp delay 1s, group ff02::1:ff00:1"
+	desc = icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !parsedICMP.(*ICMPMulticastListenerQuery).MLDv1 {
+		t.Error("expected MLDv1 query")
+	}
+
+	// sources exceeding message
+	_, err = ParseMessage(append(fixture, 0, 0, 0, 1))
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPMulticastListenerReportDone(t *testing.T) {
+	report := &ICMPMulticastListenerReport{
+		MulticastAddress: net.ParseIP("ff02::1:ff00:1"),
+	}
+
+	if report.Type() != ipv6.ICMPTypeMulticastListenerReport {
+		t.Errorf("wrong type: %d instead of %d", report.Type(), ipv6.ICMPTypeMulticastListenerReport)
+	}
+
+	marshal, err := report.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{131, 0, 0, 0, 0, 0, 0, 0, 255, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 255, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "multicast listener report, length 24, group ff02::1:ff00:1"
+	desc := report.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	done := &ICMPMulticastListenerDone{
+		MulticastAddress: net.ParseIP("ff02::1:ff00:1"),
+	}
+
+	if done.Type() != ipv6.ICMPTypeMulticastListenerDone {
+		t.Errorf("wrong type: %d instead of %d", done.Type(), ipv6.ICMPTypeMulticastListenerDone)
+	}
+
+	marshal, err = done.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{132, 0, 0, 0, 0, 0, 0, 0, 255, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 255, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix = "multicast listener done, length 24, group ff02::1:ff00:1"
+	desc = done.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err = parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:20])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPVersion2MulticastListenerReport(t *testing.T) {
+	icmp := &ICMPVersion2MulticastListenerReport{
+		Records: []MulticastAddressRecord{
+			{
+				RecordType:       MulticastAddressRecordChangeToExclude,
+				MulticastAddress: net.ParseIP("ff02::1:ff00:1"),
+			},
+			{
+				RecordType:       MulticastAddressRecordModeIsInclude,
+				MulticastAddress: net.ParseIP("ff05::2"),
+				Sources:          []net.IP{net.ParseIP("2001:db8::1")},
+				AuxData:          []byte{1, 2, 3, 4},
+			},
+		},
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeVersion2MulticastListenerReport {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeVersion2MulticastListenerReport)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{143, 0, 0, 0, 0, 0, 0, 2,
+		4, 0, 0, 0, 255, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 255, 0, 0, 1,
+		1, 1, 0, 1, 255, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+		32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 3, 4}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "version 2 multicast listener report, length 68, 2 group record(s)\n    to_ex ff02::1:ff00:1\n    is_in ff05::2 { 2001:db8::1 }"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:60])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	// aux data should align on 32 bits
+	icmp.Records[1].AuxData = []byte{1}
+	if _, err = icmp.Marshal(); err == nil {
+		t.Error("expected error for misaligned aux data")
+	}
+}
+
+func TestMulticastAddressRecord(t *testing.T) {
+	group := net.ParseIP("ff02::1:ff00:1")
+	source := []net.IP{net.ParseIP("fe80::1")}
+
+	tests := []struct {
+		record MulticastAddressRecord
+		join   bool
+		leave  bool
+	}{
+		{MulticastAddressRecord{MulticastAddressRecordModeIsExclude, group, nil, nil}, true, false},
+		{MulticastAddressRecord{MulticastAddressRecordChangeToExclude, group, nil, nil}, true, false},
+		{MulticastAddressRecord{MulticastAddressRecordModeIsInclude, group, source, nil}, true, false},
+		{MulticastAddressRecord{MulticastAddressRecordModeIsInclude, group, nil, nil}, false, false},
+		{MulticastAddressRecord{MulticastAddressRecordChangeToInclude, group, nil, nil}, false, true},
+		{MulticastAddressRecord{MulticastAddressRecordAllowNewSources, group, source, nil}, true, false},
+		{MulticastAddressRecord{MulticastAddressRecordBlockOldSources, group, source, nil}, false, false},
+	}
+
+	for _, test := range tests {
+		if test.record.IsJoin() != test.join {
+			t.Errorf("expected join %t for %s", test.join, test.record)
+		}
+		if test.record.IsLeave() != test.leave {
+			t.Errorf("expected leave %t for %s", test.leave, test.record)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+)
+
+// MLD protocol defaults as described at
+// https://tools.ietf.org/html/rfc3810#section-9
+const (
+	MLDDefaultRobustnessVariable        = 2
+	MLDDefaultQueryInterval             = 125 * time.Second
+	MLDDefaultQueryResponseInterval     = 10 * time.Second
+	MLDDefaultLastListenerQueryInterval = time.Second
+)
+
+var allNodesMulticast = net.ParseIP("ff02::1")
+
+// MLDConfig holds the MLD protocol variables used by MLDQuerier and
+// MLDSnoopingTable, zero values are replaced by their defaults
+type MLDConfig struct {
+	RobustnessVariable        uint8
+	QueryInterval             time.Duration
+	QueryResponseInterval     time.Duration
+	LastListenerQueryInterval time.Duration
+}
+
+func (c MLDConfig) withDefaults() MLDConfig {
+	if c.RobustnessVariable == 0 {
+		c.RobustnessVariable = MLDDefaultRobustnessVariable
+	}
+	if c.QueryInterval == 0 {
+		c.QueryInterval = MLDDefaultQueryInterval
+	}
+	if c.QueryResponseInterval == 0 {
+		c.QueryResponseInterval = MLDDefaultQueryResponseInterval
+	}
+	if c.LastListenerQueryInterval == 0 {
+		c.LastListenerQueryInterval = MLDDefaultLastListenerQueryInterval
+	}
+
+	return c
+}
+
+// MulticastAddressListeningInterval returns the time after which a group is
+// considered to have no more listeners
+func (c MLDConfig) MulticastAddressListeningInterval() time.Duration {
+	return time.Duration(c.RobustnessVariable)*c.QueryInterval + c.QueryResponseInterval
+}
+
+// OtherQuerierPresentTimeout returns the time after which a non-querier takes
+// over when it stops hearing queries from the querier
+func (c MLDConfig) OtherQuerierPresentTimeout() time.Duration {
+	return time.Duration(c.RobustnessVariable)*c.QueryInterval + c.QueryResponseInterval/2
+}
+
+// LastListenerQueryTime returns the time it takes before a group for which a
+// leave was heard is removed, when no listeners report in the meantime
+func (c MLDConfig) LastListenerQueryTime() time.Duration {
+	return time.Duration(c.RobustnessVariable) * c.LastListenerQueryInterval
+}
+
+// StartupQueryInterval returns the interval between General Queries sent
+// while starting up
+func (c MLDConfig) StartupQueryInterval() time.Duration {
+	return c.QueryInterval / 4
+}
+
+type mldGroup struct {
+	address     net.IP
+	expires     time.Time
+	queriesLeft int
+	nextQuery   time.Time
+}
+
+type mldQuery struct {
+	message *ICMPMulticastListenerQuery
+	dst     net.IP
+}
+
+// MLDQuerier implements the multicast router side of MLDv2 as described at
+// https://tools.ietf.org/html/rfc3810#section-7
+//
+// It keeps no timers of its own: received messages are passed in with
+// HandleMessage and Tick should be called regularly to send due queries and
+// expire groups.
+type MLDQuerier struct {
+	conn    Conn
+	address net.IP
+	config  MLDConfig
+
+	mu                 sync.Mutex
+	querier            bool
+	startupQueriesLeft int
+	nextGeneralQuery   time.Time
+	otherQuerierExpiry time.Time
+	groups             map[string]*mldGroup
+}
+
+// NewMLDQuerier returns an MLDQuerier sending queries over given Conn, using
+// given link-local address for querier election. Conns returned by ListenICMP
+// send the queries with hop limit 1 and a Router Alert option.
+func NewMLDQuerier(conn Conn, address net.IP, config MLDConfig) *MLDQuerier {
+	return &MLDQuerier{
+		conn:    conn,
+		address: address.To16(),
+		config:  config.withDefaults(),
+		groups:  make(map[string]*mldGroup),
+	}
+}
+
+// Start makes the MLDQuerier assume it is the querier and sends the first of
+// its startup queries
+func (q *MLDQuerier) Start(now time.Time) error {
+	q.mu.Lock()
+	q.querier = true
+	q.startupQueriesLeft = int(q.config.RobustnessVariable)
+	q.nextGeneralQuery = now
+	queries := q.tick(now)
+	q.mu.Unlock()
+
+	return q.send(queries)
+}
+
+// Tick sends all queries that are due at given time and expires groups for
+// which no listeners reported in time
+func (q *MLDQuerier) Tick(now time.Time) error {
+	q.mu.Lock()
+	queries := q.tick(now)
+	q.mu.Unlock()
+
+	return q.send(queries)
+}
+
+// HandleMessage processes an MLD message received from given source address,
+// other messages are ignored
+func (q *MLDQuerier) HandleMessage(src net.IP, m ICMP, now time.Time) error {
+	q.mu.Lock()
+	var queries []mldQuery
+
+	switch p := m.(type) {
+	case *ICMPMulticastListenerQuery:
+		q.handleQuery(src, p, now)
+	case *ICMPMulticastListenerReport:
+		q.join(p.MulticastAddress, now)
+	case *ICMPMulticastListenerDone:
+		queries = q.leave(p.MulticastAddress, now)
+	case *ICMPVersion2MulticastListenerReport:
+		for _, r := range p.Records {
+			if r.IsJoin() {
+				q.join(r.MulticastAddress, now)
+			} else if r.IsLeave() {
+				queries = append(queries, q.leave(r.MulticastAddress, now)...)
+			}
+		}
+	}
+
+	q.mu.Unlock()
+
+	return q.send(queries)
+}
+
+// IsQuerier returns true if this MLDQuerier currently is the querier for its
+// link
+func (q *MLDQuerier) IsQuerier() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.querier
+}
+
+// HasListeners returns true if given multicast address has listeners
+func (q *MLDQuerier) HasListeners(group net.IP) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, ok := q.groups[group.String()]
+	return ok
+}
+
+// Groups returns all multicast addresses that have listeners
+func (q *MLDQuerier) Groups() []net.IP {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	groups := []net.IP{}
+	for _, g := range q.groups {
+		groups = append(groups, g.address)
+	}
+	sortIPs(groups)
+
+	return groups
+}
+
+func (q *MLDQuerier) tick(now time.Time) []mldQuery {
+	var queries []mldQuery
+
+	// take over when the other querier went silent
+	if !q.querier && !q.otherQuerierExpiry.IsZero() && !now.Before(q.otherQuerierExpiry) {
+		q.querier = true
+		q.nextGeneralQuery = now
+	}
+
+	if q.querier && !q.nextGeneralQuery.IsZero() && !now.Before(q.nextGeneralQuery) {
+		queries = append(queries, q.generalQuery())
+		q.nextGeneralQuery = now.Add(q.config.QueryInterval)
+		if q.startupQueriesLeft > 0 {
+			q.startupQueriesLeft--
+			if q.startupQueriesLeft > 0 {
+				q.nextGeneralQuery = now.Add(q.config.StartupQueryInterval())
+			}
+		}
+	}
+
+	for k, g := range q.groups {
+		if !now.Before(g.expires) {
+			delete(q.groups, k)
+			continue
+		}
+
+		if g.queriesLeft > 0 && !now.Before(g.nextQuery) {
+			g.queriesLeft--
+			g.nextQuery = now.Add(q.config.LastListenerQueryInterval)
+			if q.querier {
+				queries = append(queries, q.specificQuery(g.address))
+			}
+		}
+	}
+
+	return queries
+}
+
+func (q *MLDQuerier) handleQuery(src net.IP, p *ICMPMulticastListenerQuery, now time.Time) {
+	src = src.To16()
+	if src == nil || src.Equal(q.address) {
+		return
+	}
+
+	// querier election: lowest address wins
+	if bytes.Compare(src, q.address) < 0 {
+		q.querier = false
+		q.startupQueriesLeft = 0
+		q.otherQuerierExpiry = now.Add(q.config.OtherQuerierPresentTimeout())
+	}
+
+	// non-queriers lower their group timers on address specific queries
+	if q.querier || p.IsGeneral() || p.SuppressRouterProcessing {
+		return
+	}
+
+	if g, ok := q.groups[p.MulticastAddress.String()]; ok {
+		expires := now.Add(q.config.LastListenerQueryTime())
+		if expires.Before(g.expires) {
+			g.expires = expires
+		}
+	}
+}
+
+func (q *MLDQuerier) join(group net.IP, now time.Time) {
+	if !group.IsMulticast() {
+		return
+	}
+
+	g, ok := q.groups[group.String()]
+	if !ok {
+		g = &mldGroup{
+			address: copyIP(group),
+		}
+		q.groups[group.String()] = g
+	}
+
+	g.expires = now.Add(q.config.MulticastAddressListeningInterval())
+	g.queriesLeft = 0
+}
+
+func (q *MLDQuerier) leave(group net.IP, now time.Time) []mldQuery {
+	g, ok := q.groups[group.String()]
+	if !ok || !q.querier || g.queriesLeft > 0 {
+		return nil
+	}
+
+	// send Last Listener Queries as described at
+	// https://tools.ietf.org/html/rfc3810#section-7.6.3.2
+	g.expires = now.Add(q.config.LastListenerQueryTime())
+	g.queriesLeft = int(q.config.RobustnessVariable) - 1
+	g.nextQuery = now.Add(q.config.LastListenerQueryInterval)
+
+	return []mldQuery{q.specificQuery(g.address)}
+}
+
+func (q *MLDQuerier) generalQuery() mldQuery {
+	return mldQuery{
+		message: &ICMPMulticastListenerQuery{
+			MaximumResponseCode: encMLDMaxRespCode(q.config.QueryResponseInterval),
+			MulticastAddress:    net.IPv6unspecified,
+			RobustnessVariable:  q.config.RobustnessVariable,
+			QQIC:                encMLDQQIC(q.config.QueryInterval),
+		},
+		dst: allNodesMulticast,
+	}
+}
+
+func (q *MLDQuerier) specificQuery(group net.IP) mldQuery {
+	return mldQuery{
+		message: &ICMPMulticastListenerQuery{
+			MaximumResponseCode: encMLDMaxRespCode(q.config.LastListenerQueryInterval),
+			MulticastAddress:    group,
+			RobustnessVariable:  q.config.RobustnessVariable,
+			QQIC:                encMLDQQIC(q.config.QueryInterval),
+		},
+		dst: group,
+	}
+}
+
+func (q *MLDQuerier) send(queries []mldQuery) error {
+	for _, m := range queries {
+		if err := q.conn.WriteTo(m.message, m.dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
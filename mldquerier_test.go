@@ -0,0 +1,203 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMLDConfig(t *testing.T) {
+	config := MLDConfig{}.withDefaults()
+
+	if config.MulticastAddressListeningInterval() != 260*time.Second {
+		t.Errorf("unexpected listening interval %s", config.MulticastAddressListeningInterval())
+	}
+
+	if config.OtherQuerierPresentTimeout() != 255*time.Second {
+		t.Errorf("unexpected other querier present timeout %s", config.OtherQuerierPresentTimeout())
+	}
+
+	if config.LastListenerQueryTime() != 2*time.Second {
+		t.Errorf("unexpected last listener query time %s", config.LastListenerQueryTime())
+	}
+}
+
+func TestMLDQuerierStartup(t *testing.T) {
+	conn := &recordingConn{}
+	q := NewMLDQuerier(conn, net.ParseIP("fe80::2"), MLDConfig{})
+	now := time.Unix(0, 0)
+
+	if err := q.Start(now); err != nil {
+		t.Fatal(err)
+	}
+
+	if !q.IsQuerier() {
+		t.Error("expected querier after start")
+	}
+
+	// startup query count equals robustness variable
+	for i := 0; i < 3; i++ {
+		sent := conn.flush()
+		if len(sent) != 1 {
+			t.Fatalf("expected 1 startup query, got %d", len(sent))
+		}
+
+		query := sent[0].message.(*ICMPMulticastListenerQuery)
+		if !query.IsGeneral() {
+			t.Errorf("expected general query, got %s", query)
+		}
+		if !sent[0].dst.Equal(allNodesMulticast) {
+			t.Errorf("unexpected destination %s", sent[0].dst)
+		}
+
+		if i < 1 {
+			now = now.Add(MLDDefaultQueryInterval / 4)
+		} else {
+			now = now.Add(MLDDefaultQueryInterval)
+		}
+
+		// nothing is sent before the next query is due
+		if err := q.Tick(now.Add(-time.Millisecond)); err != nil {
+			t.Error(err)
+		}
+		if len(conn.flush()) != 0 {
+			t.Fatal("unexpected query before interval")
+		}
+
+		if err := q.Tick(now); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMLDQuerierElection(t *testing.T) {
+	conn := &recordingConn{}
+	q := NewMLDQuerier(conn, net.ParseIP("fe80::2"), MLDConfig{})
+	now := time.Unix(0, 0)
+
+	if err := q.Start(now); err != nil {
+		t.Fatal(err)
+	}
+	conn.flush()
+
+	// higher address doesn't win
+	query := &ICMPMulticastListenerQuery{MulticastAddress: net.IPv6unspecified}
+	if err := q.HandleMessage(net.ParseIP("fe80::3"), query, now); err != nil {
+		t.Error(err)
+	}
+	if !q.IsQuerier() {
+		t.Error("lost election to higher address")
+	}
+
+	// lower address does
+	if err := q.HandleMessage(net.ParseIP("fe80::1"), query, now); err != nil {
+		t.Error(err)
+	}
+	if q.IsQuerier() {
+		t.Error("won election from lower address")
+	}
+
+	// no queries while not querier
+	now = now.Add(MLDDefaultQueryInterval)
+	if err := q.Tick(now); err != nil {
+		t.Error(err)
+	}
+	if len(conn.flush()) != 0 {
+		t.Error("unexpected query while not querier")
+	}
+
+	// take over when the other querier goes silent
+	now = time.Unix(0, 0).Add(MLDConfig{}.withDefaults().OtherQuerierPresentTimeout())
+	if err := q.Tick(now); err != nil {
+		t.Error(err)
+	}
+	if !q.IsQuerier() {
+		t.Error("expected to take over as querier")
+	}
+	if len(conn.flush()) != 1 {
+		t.Error("expected general query after taking over")
+	}
+}
+
+func TestMLDQuerierListeners(t *testing.T) {
+	conn := &recordingConn{}
+	q := NewMLDQuerier(conn, net.ParseIP("fe80::2"), MLDConfig{})
+	now := time.Unix(0, 0)
+
+	if err := q.Start(now); err != nil {
+		t.Fatal(err)
+	}
+	conn.flush()
+
+	group := net.ParseIP("ff02::1:ff00:1")
+	report := &ICMPVersion2MulticastListenerReport{
+		Records: []MulticastAddressRecord{
+			{RecordType: MulticastAddressRecordChangeToExclude, MulticastAddress: group},
+		},
+	}
+	if err := q.HandleMessage(net.ParseIP("fe80::10"), report, now); err != nil {
+		t.Error(err)
+	}
+
+	if !q.HasListeners(group) {
+		t.Error("expected listeners after report")
+	}
+
+	groups := q.Groups()
+	if len(groups) != 1 || !groups[0].Equal(group) {
+		t.Errorf("unexpected groups %v", groups)
+	}
+
+	// leave triggers last listener queries
+	done := &ICMPMulticastListenerDone{MulticastAddress: group}
+	if err := q.HandleMessage(net.ParseIP("fe80::10"), done, now); err != nil {
+		t.Error(err)
+	}
+
+	sent := conn.flush()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 last listener query, got %d", len(sent))
+	}
+	if !sent[0].dst.Equal(group) || !sent[0].message.(*ICMPMulticastListenerQuery).MulticastAddress.Equal(group) {
+		t.Errorf("unexpected last listener query %s to %s", sent[0].message, sent[0].dst)
+	}
+
+	now = now.Add(MLDDefaultLastListenerQueryInterval)
+	if err := q.Tick(now); err != nil {
+		t.Error(err)
+	}
+	if len(conn.flush()) != 1 {
+		t.Error("expected second last listener query")
+	}
+
+	// no listeners reported, so group is gone
+	now = now.Add(MLDDefaultLastListenerQueryInterval)
+	if err := q.Tick(now); err != nil {
+		t.Error(err)
+	}
+	if len(conn.flush()) != 0 {
+		t.Error("unexpected third last listener query")
+	}
+	if q.HasListeners(group) {
+		t.Error("expected group to be removed")
+	}
+
+	// a report during last listener queries keeps the group
+	if err := q.HandleMessage(net.ParseIP("fe80::10"), &ICMPMulticastListenerReport{MulticastAddress: group}, now); err != nil {
+		t.Error(err)
+	}
+	if err := q.HandleMessage(net.ParseIP("fe80::10"), done, now); err != nil {
+		t.Error(err)
+	}
+	if err := q.HandleMessage(net.ParseIP("fe80::11"), &ICMPMulticastListenerReport{MulticastAddress: group}, now); err != nil {
+		t.Error(err)
+	}
+
+	now = now.Add(10 * MLDDefaultLastListenerQueryInterval)
+	if err := q.Tick(now); err != nil {
+		t.Error(err)
+	}
+	if !q.HasListeners(group) {
+		t.Error("expected group to be kept")
+	}
+}
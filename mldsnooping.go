@@ -0,0 +1,174 @@
+package ndp
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MLDSnoopingTable keeps track of which ports have listeners for which
+// multicast addresses, based on the MLD messages seen on those ports, as
+// described at https://tools.ietf.org/html/rfc4541#section-3
+//
+// This allows for instance Neighbor Solicitations, which are sent to
+// solicited-node multicast addresses, to only be forwarded to the ports of
+// the nodes they are meant for.
+type MLDSnoopingTable struct {
+	config MLDConfig
+
+	mu        sync.Mutex
+	listeners map[string]map[string]time.Time
+	routers   map[string]time.Time
+}
+
+// NewMLDSnoopingTable returns an empty MLDSnoopingTable, timing out listeners
+// and routers according to given MLDConfig
+func NewMLDSnoopingTable(config MLDConfig) *MLDSnoopingTable {
+	return &MLDSnoopingTable{
+		config:    config.withDefaults(),
+		listeners: make(map[string]map[string]time.Time),
+		routers:   make(map[string]time.Time),
+	}
+}
+
+// HandleMessage processes an MLD message received on given port, other
+// messages are ignored
+func (t *MLDSnoopingTable) HandleMessage(port string, m ICMP, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch p := m.(type) {
+	case *ICMPMulticastListenerQuery:
+		// queries are sent by multicast routers, which want all traffic
+		t.routers[port] = now.Add(t.config.OtherQuerierPresentTimeout())
+	case *ICMPMulticastListenerReport:
+		t.join(port, p.MulticastAddress, now)
+	case *ICMPMulticastListenerDone:
+		t.leave(port, p.MulticastAddress, now)
+	case *ICMPVersion2MulticastListenerReport:
+		for _, r := range p.Records {
+			if r.IsJoin() {
+				t.join(port, r.MulticastAddress, now)
+			} else if r.IsLeave() {
+				t.leave(port, r.MulticastAddress, now)
+			}
+		}
+	}
+}
+
+// Ports returns the ports traffic for given multicast address should be
+// forwarded to: all ports with listeners and all ports with multicast
+// routers. When flood is true, the address can't be snooped and traffic
+// should be sent to all ports instead.
+func (t *MLDSnoopingTable) Ports(group net.IP, now time.Time) (ports []string, flood bool) {
+	// all-nodes traffic is never reported on
+	if group.Equal(allNodesMulticast) {
+		return nil, true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for port, expires := range t.listeners[group.String()] {
+		if now.Before(expires) {
+			seen[port] = true
+		}
+	}
+	for port, expires := range t.routers {
+		if now.Before(expires) {
+			seen[port] = true
+		}
+	}
+
+	ports = []string{}
+	for port := range seen {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+
+	return ports, false
+}
+
+// Groups returns the multicast addresses that have listeners on given port
+func (t *MLDSnoopingTable) Groups(port string, now time.Time) []net.IP {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	groups := []net.IP{}
+	for group, ports := range t.listeners {
+		if expires, ok := ports[port]; ok && now.Before(expires) {
+			groups = append(groups, net.ParseIP(group))
+		}
+	}
+	sortIPs(groups)
+
+	return groups
+}
+
+// Expire removes all listeners and routers that timed out at given time
+func (t *MLDSnoopingTable) Expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for group, ports := range t.listeners {
+		for port, expires := range ports {
+			if !now.Before(expires) {
+				delete(ports, port)
+			}
+		}
+		if len(ports) == 0 {
+			delete(t.listeners, group)
+		}
+	}
+
+	for port, expires := range t.routers {
+		if !now.Before(expires) {
+			delete(t.routers, port)
+		}
+	}
+}
+
+// RemovePort forgets everything learned on given port, e.g. when it goes
+// down
+func (t *MLDSnoopingTable) RemovePort(port string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for group, ports := range t.listeners {
+		delete(ports, port)
+		if len(ports) == 0 {
+			delete(t.listeners, group)
+		}
+	}
+
+	delete(t.routers, port)
+}
+
+func (t *MLDSnoopingTable) join(port string, group net.IP, now time.Time) {
+	if !group.IsMulticast() {
+		return
+	}
+
+	ports, ok := t.listeners[group.String()]
+	if !ok {
+		ports = make(map[string]time.Time)
+		t.listeners[group.String()] = ports
+	}
+
+	ports[port] = now.Add(t.config.MulticastAddressListeningInterval())
+}
+
+func (t *MLDSnoopingTable) leave(port string, group net.IP, now time.Time) {
+	ports, ok := t.listeners[group.String()]
+	if !ok {
+		return
+	}
+
+	// keep forwarding while the querier checks for other listeners
+	expires, ok := ports[port]
+	if ok && now.Add(t.config.LastListenerQueryTime()).Before(expires) {
+		ports[port] = now.Add(t.config.LastListenerQueryTime())
+	}
+}
@@ -0,0 +1,71 @@
+package ndp
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMLDSnoopingTable(t *testing.T) {
+	table := NewMLDSnoopingTable(MLDConfig{})
+	now := time.Unix(0, 0)
+	group := SolicitedNodeMulticast(net.ParseIP("2001:db8::1"))
+
+	table.HandleMessage("eth1", &ICMPMulticastListenerReport{MulticastAddress: group}, now)
+	table.HandleMessage("eth2", &ICMPVersion2MulticastListenerReport{
+		Records: []MulticastAddressRecord{
+			{RecordType: MulticastAddressRecordModeIsExclude, MulticastAddress: group},
+			{RecordType: MulticastAddressRecordModeIsExclude, MulticastAddress: net.ParseIP("ff05::2")},
+		},
+	}, now)
+
+	ports, flood := table.Ports(group, now)
+	if flood || !reflect.DeepEqual(ports, []string{"eth1", "eth2"}) {
+		t.Errorf("unexpected ports %v (flood %t)", ports, flood)
+	}
+
+	// other solicited-node addresses are not forwarded
+	ports, _ = table.Ports(SolicitedNodeMulticast(net.ParseIP("2001:db8::2")), now)
+	if len(ports) != 0 {
+		t.Errorf("unexpected ports %v", ports)
+	}
+
+	// all-nodes traffic is flooded
+	if _, flood = table.Ports(allNodesMulticast, now); !flood {
+		t.Error("expected all-nodes traffic to be flooded")
+	}
+
+	groups := table.Groups("eth2", now)
+	if len(groups) != 2 || !groups[0].Equal(group) {
+		t.Errorf("unexpected groups %v", groups)
+	}
+
+	// queries reveal router ports
+	table.HandleMessage("uplink", &ICMPMulticastListenerQuery{MulticastAddress: net.IPv6unspecified}, now)
+	ports, _ = table.Ports(group, now)
+	if !reflect.DeepEqual(ports, []string{"eth1", "eth2", "uplink"}) {
+		t.Errorf("unexpected ports %v", ports)
+	}
+
+	// leave times out after last listener query time
+	table.HandleMessage("eth1", &ICMPMulticastListenerDone{MulticastAddress: group}, now)
+	ports, _ = table.Ports(group, now.Add(3*time.Second))
+	if !reflect.DeepEqual(ports, []string{"eth2", "uplink"}) {
+		t.Errorf("unexpected ports %v", ports)
+	}
+
+	// everything times out eventually
+	table.Expire(now.Add(300 * time.Second))
+	ports, _ = table.Ports(group, now)
+	if len(ports) != 0 {
+		t.Errorf("unexpected ports %v after expiry", ports)
+	}
+
+	// removing port forgets about it
+	table.HandleMessage("eth1", &ICMPMulticastListenerReport{MulticastAddress: group}, now)
+	table.RemovePort("eth1")
+	if len(table.Groups("eth1", now)) != 0 {
+		t.Error("unexpected groups for removed port")
+	}
+}
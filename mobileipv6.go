@@ -0,0 +1,307 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPHomeAgentAddressDiscoveryRequest implements the Home Agent Address
+// Discovery Request message as described at
+// https://tools.ietf.org/html/rfc6275#section-6.5
+type ICMPHomeAgentAddressDiscoveryRequest struct {
+	Identifier uint16
+}
+
+func (p ICMPHomeAgentAddressDiscoveryRequest) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d", p.Identifier)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeHomeAgentAddressDiscoveryRequest
+func (p ICMPHomeAgentAddressDiscoveryRequest) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeHomeAgentAddressDiscoveryRequest
+}
+
+// Marshal returns byte slice representing this
+// ICMPHomeAgentAddressDiscoveryRequest
+func (p ICMPHomeAgentAddressDiscoveryRequest) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	// b[6:8] = reserved
+
+	return b, nil
+}
+
+// ICMPHomeAgentAddressDiscoveryReply implements the Home Agent Address
+// Discovery Reply message as described at
+// https://tools.ietf.org/html/rfc6275#section-6.6
+type ICMPHomeAgentAddressDiscoveryReply struct {
+	Identifier uint16
+	// HomeAgentAddresses are listed in order of preference
+	HomeAgentAddresses []net.IP
+}
+
+func (p ICMPHomeAgentAddressDiscoveryReply) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d", p.Identifier)
+	if len(p.HomeAgentAddresses) > 0 {
+		s += fmt.Sprintf(", home agents %s", joinIPs(p.HomeAgentAddresses))
+	}
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeHomeAgentAddressDiscoveryReply
+func (p ICMPHomeAgentAddressDiscoveryReply) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeHomeAgentAddressDiscoveryReply
+}
+
+// Marshal returns byte slice representing this
+// ICMPHomeAgentAddressDiscoveryReply
+func (p ICMPHomeAgentAddressDiscoveryReply) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	// b[6:8] = reserved
+	for _, a := range p.HomeAgentAddresses {
+		if a.To16() == nil {
+			return nil, fmt.Errorf("invalid home agent address %s", a)
+		}
+
+		b = append(b, a.To16()...)
+	}
+
+	return b, nil
+}
+
+// ICMPMobilePrefixSolicitation implements the Mobile Prefix Solicitation
+// message as described at https://tools.ietf.org/html/rfc6275#section-6.7
+type ICMPMobilePrefixSolicitation struct {
+	Identifier uint16
+}
+
+func (p ICMPMobilePrefixSolicitation) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d", p.Identifier)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeMobilePrefixSolicitation
+func (p ICMPMobilePrefixSolicitation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMobilePrefixSolicitation
+}
+
+// Marshal returns byte slice representing this ICMPMobilePrefixSolicitation
+func (p ICMPMobilePrefixSolicitation) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	// b[6:8] = reserved
+
+	return b, nil
+}
+
+// ICMPMobilePrefixAdvertisement implements the Mobile Prefix Advertisement
+// message as described at https://tools.ietf.org/html/rfc6275#section-6.8
+type ICMPMobilePrefixAdvertisement struct {
+	optionContainer
+	Identifier     uint16
+	ManagedAddress bool
+	OtherStateful  bool
+}
+
+func (p ICMPMobilePrefixAdvertisement) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, ", p.Identifier)
+	f := []string{}
+	if p.ManagedAddress {
+		f = append(f, "managed")
+	}
+	if p.OtherStateful {
+		f = append(f, "other stateful")
+	}
+	s += fmt.Sprintf("Flags %s\n", f)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeMobilePrefixAdvertisement
+func (p ICMPMobilePrefixAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMobilePrefixAdvertisement
+}
+
+// Marshal returns byte slice representing this ICMPMobilePrefixAdvertisement
+func (p ICMPMobilePrefixAdvertisement) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	if p.ManagedAddress {
+		b[6] ^= 0x80
+	}
+	if p.OtherStateful {
+		b[6] ^= 0x40
+	}
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+	return b, nil
+}
+
+// ICMPOptionAdvertisementInterval implements the Advertisement Interval
+// option as described at https://tools.ietf.org/html/rfc6275#section-7.3
+type ICMPOptionAdvertisementInterval struct {
+	// AdvertisementInterval is the maximum time in milliseconds between
+	// unsolicited Router Advertisements
+	AdvertisementInterval uint32
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionAdvertisementInterval) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": %dms", o.AdvertisementInterval)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeAdvertisementInterval
+func (o ICMPOptionAdvertisementInterval) Type() ICMPOptionType {
+	return ICMPOptionTypeAdvertisementInterval
+}
+
+// Len returns the length in bytes of ICMPOptionAdvertisementInterval
+func (o ICMPOptionAdvertisementInterval) Len() uint8 {
+	// Advertisement Interval options are always 1
+	return 1
+}
+
+// Marshal returns byte slice representing this
+// ICMPOptionAdvertisementInterval
+func (o ICMPOptionAdvertisementInterval) Marshal() ([]byte, error) {
+	// option header
+	b := make([]byte, 8)
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	binary.BigEndian.PutUint32(b[4:8], o.AdvertisementInterval)
+
+	return b, nil
+}
+
+// ICMPOptionHomeAgentInformation implements the Home Agent Information
+// option as described at https://tools.ietf.org/html/rfc6275#section-7.4
+type ICMPOptionHomeAgentInformation struct {
+	// Preference of this home agent, higher values are more preferable
+	Preference int16
+	// Lifetime in seconds of this home agent, 0 means the Router Lifetime
+	// of the Router Advertisement is used instead
+	Lifetime uint16
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionHomeAgentInformation) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": preference %d, lifetime %ds", o.Preference, o.Lifetime)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeHomeAgentInformation
+func (o ICMPOptionHomeAgentInformation) Type() ICMPOptionType {
+	return ICMPOptionTypeHomeAgentInformation
+}
+
+// Len returns the length in bytes of ICMPOptionHomeAgentInformation
+func (o ICMPOptionHomeAgentInformation) Len() uint8 {
+	// Home Agent Information options are always 1
+	return 1
+}
+
+// Marshal returns byte slice representing this
+// ICMPOptionHomeAgentInformation
+func (o ICMPOptionHomeAgentInformation) Marshal() ([]byte, error) {
+	// option header
+	b := make([]byte, 8)
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	binary.BigEndian.PutUint16(b[4:6], uint16(o.Preference))
+	binary.BigEndian.PutUint16(b[6:8], o.Lifetime)
+
+	return b, nil
+}
+
+func parseHomeAgentAddressDiscoveryReply(b []byte) (ICMP, error) {
+	if len(b) < 8 {
+		return nil, errMessageTooShort
+	}
+
+	if (len(b)-8)%16 != 0 {
+		return nil, fmt.Errorf("home agent address list of %d bytes is not a multiple of 16", len(b)-8)
+	}
+
+	p := &ICMPHomeAgentAddressDiscoveryReply{
+		Identifier: binary.BigEndian.Uint16(b[4:6]),
+	}
+
+	for i := 8; i < len(b); i += 16 {
+		p.HomeAgentAddresses = append(p.HomeAgentAddresses, net.IP(b[i:(i+16)]))
+	}
+
+	return p, nil
+}
+
+func parseMobilePrefixAdvertisement(b []byte) (ICMP, error) {
+	if len(b) < 8 {
+		return nil, errMessageTooShort
+	}
+
+	p := &ICMPMobilePrefixAdvertisement{
+		Identifier:     binary.BigEndian.Uint16(b[4:6]),
+		ManagedAddress: (b[6]&0x80 > 0),
+		OtherStateful:  (b[6]&0x40 > 0),
+	}
+
+	if len(b) > 8 {
+		options, err := parseOptions(b[8:])
+		if err != nil {
+			return nil, err
+		}
+
+		p.Options = options
+	}
+
+	return p, nil
+}
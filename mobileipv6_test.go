@@ -0,0 +1,242 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPHomeAgentAddressDiscovery(t *testing.T) {
+	tests := []struct {
+		icmp    ICMP
+		typ     ipv6.ICMPType
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPHomeAgentAddressDiscoveryRequest{Identifier: 4660},
+			ipv6.ICMPTypeHomeAgentAddressDiscoveryRequest,
+			[]byte{144, 0, 0, 0, 18, 52, 0, 0},
+			"home agent address discovery request message, length 8, id 4660",
+		},
+		{
+			&ICMPHomeAgentAddressDiscoveryReply{
+				Identifier:         4660,
+				HomeAgentAddresses: []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")},
+			},
+			ipv6.ICMPTypeHomeAgentAddressDiscoveryReply,
+			[]byte{145, 0, 0, 0, 18, 52, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2},
+			"home agent address discovery reply message, length 40, id 4660, home agents 2001:db8::1, 2001:db8::2",
+		},
+		{
+			&ICMPMobilePrefixSolicitation{Identifier: 4660},
+			ipv6.ICMPTypeMobilePrefixSolicitation,
+			[]byte{146, 0, 0, 0, 18, 52, 0, 0},
+			"mobile prefix solicitation, length 8, id 4660",
+		},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.icmp.Type(), test.typ)
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(test.fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		parsedMarshal, err := parsedICMP.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+
+		_, err = ParseMessage(test.fixture[:6])
+		if err != errMessageTooShort {
+			t.Errorf("unexpected error message: %s", err)
+		}
+	}
+
+	// addresses should be complete
+	if _, err := ParseMessage([]byte{145, 0, 0, 0, 18, 52, 0, 0, 32, 1, 13, 184}); err == nil {
+		t.Error("expected error for truncated home agent address")
+	}
+}
+
+func TestICMPMobilePrefixAdvertisement(t *testing.T) {
+	icmp := &ICMPMobilePrefixAdvertisement{
+		Identifier:     4660,
+		ManagedAddress: true,
+	}
+	icmp.AddOption(&ICMPOptionPrefixInformation{
+		PrefixLength:      64,
+		OnLink:            true,
+		Auto:              true,
+		RouterAddress:     true,
+		ValidLifetime:     86400,
+		PreferredLifetime: 14400,
+		Prefix:            net.ParseIP("2001:db8:1::"),
+	})
+
+	if icmp.Type() != ipv6.ICMPTypeMobilePrefixAdvertisement {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeMobilePrefixAdvertisement)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{147, 0, 0, 0, 18, 52, 128, 0, 3, 4, 64, 224, 0, 1, 81, 128, 0, 0, 56, 64, 0, 0, 0, 0, 32, 1, 13, 184, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "mobile prefix advertisement, length 40, id 4660, Flags [managed]\n    prefix info option (3), length 32 (4): 2001:db8:1::/64, Flags [onlink auto router], valid time 86400s, pref. time 14400s"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:6])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPOptionAdvertisementInterval(t *testing.T) {
+	option := &ICMPOptionAdvertisementInterval{
+		AdvertisementInterval: 1500,
+	}
+
+	if option.Type() != ICMPOptionTypeAdvertisementInterval {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeAdvertisementInterval)
+	}
+
+	if option.Len() != 1 {
+		t.Errorf("wrong length, %d != 1", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{7, 1, 0, 0, 0, 0, 5, 220}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "advertisement interval option (7), length 8 (1): 1500ms"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsedMarshal, err := options[0].Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPOptionHomeAgentInformation(t *testing.T) {
+	option := &ICMPOptionHomeAgentInformation{
+		Preference: -1,
+		Lifetime:   1800,
+	}
+
+	if option.Type() != ICMPOptionTypeHomeAgentInformation {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeHomeAgentInformation)
+	}
+
+	if option.Len() != 1 {
+		t.Errorf("wrong length, %d != 1", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{8, 1, 0, 0, 255, 255, 7, 8}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "homeagent information option (8), length 8 (1): preference -1, lifetime 1800s"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionHomeAgentInformation)
+	if parsed.Preference != -1 {
+		t.Errorf("unexpected preference %d", parsed.Preference)
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
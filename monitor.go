@@ -0,0 +1,614 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// monitorDADTimeout is how long an advertisement is taken as the answer to
+// a duplicate address detection solicitation, RetransTimer as described at
+// https://tools.ietf.org/html/rfc4862#section-5.4
+const monitorDADTimeout = time.Second
+
+var (
+	// monitorUnicastPrefixes are the ranges prefixes are advertised from,
+	// global unicast and unique local
+	monitorUnicastPrefixes = []*net.IPNet{mustParseCIDR("2000::/3"), mustParseCIDR("fc00::/7")}
+	// monitorBogonPrefixes are the ranges within those that are never
+	// advertised, documentation and the former 6bone
+	monitorBogonPrefixes = []*net.IPNet{mustParseCIDR("2001:db8::/32"), mustParseCIDR("3fff::/20"), mustParseCIDR("3ffe::/16")}
+)
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+// MonitorAlertType is the kind of suspicious behavior a Monitor found
+type MonitorAlertType uint8
+
+// alert types currently defined
+const (
+	// AlertNewRouter is raised for router advertisements from a router that
+	// is not in the database
+	AlertNewRouter MonitorAlertType = iota
+	// AlertRouterChanged is raised when a known router advertises different
+	// parameters than in the database
+	AlertRouterChanged
+	// AlertNewPrefix is raised for prefixes that are not in the database
+	AlertNewPrefix
+	// AlertBogonPrefix is raised for prefixes that are never advertised
+	AlertBogonPrefix
+	// AlertChangedLinkLayerAddress is raised when an address moves to a
+	// link-layer address it never used before
+	AlertChangedLinkLayerAddress
+	// AlertFlipFlop is raised when an address moves back to a link-layer
+	// address it used before
+	AlertFlipFlop
+	// AlertRouterSpoofing is raised for neighbor advertisements claiming
+	// the address of a router with another link-layer address
+	AlertRouterSpoofing
+	// AlertDADDenial is raised for neighbor advertisements answering
+	// duplicate address detection for an address not in use
+	AlertDADDenial
+)
+
+func (t MonitorAlertType) String() string {
+	switch t {
+	case AlertNewRouter:
+		return "new router"
+	case AlertRouterChanged:
+		return "router changed"
+	case AlertNewPrefix:
+		return "new prefix"
+	case AlertBogonPrefix:
+		return "bogon prefix"
+	case AlertChangedLinkLayerAddress:
+		return "changed link-layer address"
+	case AlertFlipFlop:
+		return "flip flop"
+	case AlertRouterSpoofing:
+		return "router spoofing"
+	case AlertDADDenial:
+		return "dad denial"
+	}
+
+	return "<nil>"
+}
+
+// MonitorAlert is an event about suspicious behavior seen by a Monitor
+type MonitorAlert struct {
+	Type MonitorAlertType
+	Time time.Time
+	// Address is the address of the node the alert is about
+	Address          net.IP
+	LinkLayerAddress net.HardwareAddr
+	// PreviousLinkLayerAddress is the link-layer address in the database,
+	// if any
+	PreviousLinkLayerAddress net.HardwareAddr
+	// Prefix is set for alerts about prefixes
+	Prefix *net.IPNet
+	// Detail describes the alert for humans
+	Detail string
+}
+
+func (a MonitorAlert) String() string {
+	s := fmt.Sprintf("%s: %s %s", a.Type, a.Address, a.LinkLayerAddress)
+	if a.Detail != "" {
+		s += fmt.Sprintf(": %s", a.Detail)
+	}
+
+	return s
+}
+
+// MarshalJSON implements json.Marshaler, writing addresses in their text
+// form for consumption by log processors
+func (a MonitorAlert) MarshalJSON() ([]byte, error) {
+	alert := struct {
+		Type                     string    `json:"type"`
+		Time                     time.Time `json:"time"`
+		Address                  string    `json:"address,omitempty"`
+		LinkLayerAddress         string    `json:"lladdr,omitempty"`
+		PreviousLinkLayerAddress string    `json:"previous_lladdr,omitempty"`
+		Prefix                   string    `json:"prefix,omitempty"`
+		Detail                   string    `json:"detail,omitempty"`
+	}{
+		Type:                     a.Type.String(),
+		Time:                     a.Time,
+		LinkLayerAddress:         a.LinkLayerAddress.String(),
+		PreviousLinkLayerAddress: a.PreviousLinkLayerAddress.String(),
+		Detail:                   a.Detail,
+	}
+
+	if a.Address != nil {
+		alert.Address = a.Address.String()
+	}
+
+	if a.Prefix != nil {
+		alert.Prefix = a.Prefix.String()
+	}
+
+	return json.Marshal(alert)
+}
+
+// MonitorRouter is a legitimate router with the parameters it advertises
+type MonitorRouter struct {
+	Address          net.IP
+	LinkLayerAddress net.HardwareAddr
+	HopLimit         uint8
+	ManagedAddress   bool
+	OtherStateful    bool
+	RouterPreference RouterPreferenceField
+	RouterLifeTime   uint16
+	ReachableTime    uint32
+	RetransTimer     uint32
+	// MTU is 0 when not advertised
+	MTU uint32
+}
+
+// changes returns the differences between this router and given one
+func (r MonitorRouter) changes(o MonitorRouter) []string {
+	changes := []string{}
+	if !bytes.Equal(r.LinkLayerAddress, o.LinkLayerAddress) {
+		changes = append(changes, fmt.Sprintf("link-layer address %s -> %s", r.LinkLayerAddress, o.LinkLayerAddress))
+	}
+
+	if r.HopLimit != o.HopLimit {
+		changes = append(changes, fmt.Sprintf("hop limit %d -> %d", r.HopLimit, o.HopLimit))
+	}
+
+	if r.ManagedAddress != o.ManagedAddress {
+		changes = append(changes, fmt.Sprintf("managed %t -> %t", r.ManagedAddress, o.ManagedAddress))
+	}
+
+	if r.OtherStateful != o.OtherStateful {
+		changes = append(changes, fmt.Sprintf("other %t -> %t", r.OtherStateful, o.OtherStateful))
+	}
+
+	if r.RouterPreference != o.RouterPreference {
+		changes = append(changes, fmt.Sprintf("pref %s -> %s", r.RouterPreference, o.RouterPreference))
+	}
+
+	if r.RouterLifeTime != o.RouterLifeTime {
+		changes = append(changes, fmt.Sprintf("router lifetime %ds -> %ds", r.RouterLifeTime, o.RouterLifeTime))
+	}
+
+	if r.ReachableTime != o.ReachableTime {
+		changes = append(changes, fmt.Sprintf("reachable time %ds -> %ds", r.ReachableTime, o.ReachableTime))
+	}
+
+	if r.RetransTimer != o.RetransTimer {
+		changes = append(changes, fmt.Sprintf("retrans time %ds -> %ds", r.RetransTimer, o.RetransTimer))
+	}
+
+	if r.MTU != o.MTU {
+		changes = append(changes, fmt.Sprintf("mtu %d -> %d", r.MTU, o.MTU))
+	}
+
+	return changes
+}
+
+// MonitorPrefix is a legitimate prefix and the router advertising it
+type MonitorPrefix struct {
+	Prefix *net.IPNet
+	Router net.IP
+}
+
+// MonitorBinding is the link-layer address an address was last seen with
+type MonitorBinding struct {
+	Address          net.IP
+	LinkLayerAddress net.HardwareAddr
+	// Previous are the link-layer addresses the address used before
+	Previous  []net.HardwareAddr
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// monitorDatabase is what a Monitor persists
+type monitorDatabase struct {
+	Routers  []MonitorRouter
+	Prefixes []MonitorPrefix
+	Bindings []MonitorBinding
+}
+
+// Monitor passively watches neighbor discovery on a link, like NDPMon does,
+// and raises alerts for rogue routers and spoofing. It keeps a database of
+// legitimate routers and prefixes, filled while learning, and of the
+// link-layer addresses addresses are bound to.
+type Monitor struct {
+	mu       sync.Mutex
+	learning bool
+	routers  map[string]*MonitorRouter
+	prefixes map[string]*MonitorPrefix
+	bindings map[string]*MonitorBinding
+	// dad holds the targets of duplicate address detection in progress
+	dad map[string]time.Time
+}
+
+// NewMonitor returns a Monitor with an empty database, learning makes all
+// routers and prefixes seen legitimate
+func NewMonitor(learning bool) *Monitor {
+	return &Monitor{
+		learning: learning,
+		routers:  make(map[string]*MonitorRouter),
+		prefixes: make(map[string]*MonitorPrefix),
+		bindings: make(map[string]*MonitorBinding),
+		dad:      make(map[string]time.Time),
+	}
+}
+
+// SetLearning sets whether routers and prefixes seen are legitimate
+func (m *Monitor) SetLearning(learning bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.learning = learning
+}
+
+// HandleMessage checks given message received from given source address and
+// link-layer address, which is nil when not known, and returns the alerts it
+// raised. Messages other than neighbor discovery are ignored.
+func (m *Monitor) HandleMessage(src net.IP, lla net.HardwareAddr, msg ICMP, now time.Time) []MonitorAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alerts := []MonitorAlert{}
+	switch p := msg.(type) {
+	case *ICMPRouterSolicitation:
+		alerts = m.bind(alerts, src, linkLayerAddress(p.Options, lla), now)
+	case *ICMPRouterAdvertisement:
+		alerts = m.handleRouterAdvertisement(alerts, src, linkLayerAddress(p.Options, lla), p, now)
+	case *ICMPNeighborSolicitation:
+		if src.IsUnspecified() {
+			m.expireDAD(now)
+			m.dad[p.TargetAddress.String()] = now.Add(monitorDADTimeout)
+			break
+		}
+
+		alerts = m.bind(alerts, src, linkLayerAddress(p.Options, lla), now)
+	case *ICMPNeighborAdvertisement:
+		alerts = m.handleNeighborAdvertisement(alerts, linkLayerAddress(p.Options, lla), p, now)
+	}
+
+	return alerts
+}
+
+func (m *Monitor) handleRouterAdvertisement(alerts []MonitorAlert, src net.IP, lla net.HardwareAddr, ra *ICMPRouterAdvertisement, now time.Time) []MonitorAlert {
+	router := MonitorRouter{
+		Address:          src,
+		LinkLayerAddress: lla,
+		HopLimit:         ra.HopLimit,
+		ManagedAddress:   ra.ManagedAddress,
+		OtherStateful:    ra.OtherStateful,
+		RouterPreference: ra.RouterPreference,
+		RouterLifeTime:   ra.RouterLifeTime,
+		ReachableTime:    ra.ReachableTime,
+		RetransTimer:     ra.RetransTimer,
+	}
+
+	for _, o := range ra.Options {
+		if mtu, ok := o.(*ICMPOptionMTU); ok {
+			router.MTU = mtu.MTU
+		}
+	}
+
+	known, ok := m.routers[src.String()]
+	switch {
+	case m.learning:
+		m.routers[src.String()] = &router
+	case !ok:
+		alerts = append(alerts, MonitorAlert{
+			Type:             AlertNewRouter,
+			Time:             now,
+			Address:          src,
+			LinkLayerAddress: lla,
+		})
+	default:
+		if changes := known.changes(router); len(changes) > 0 {
+			alerts = append(alerts, MonitorAlert{
+				Type:                     AlertRouterChanged,
+				Time:                     now,
+				Address:                  src,
+				LinkLayerAddress:         lla,
+				PreviousLinkLayerAddress: known.LinkLayerAddress,
+				Detail:                   fmt.Sprintf("%v", changes),
+			})
+		}
+	}
+
+	for _, o := range ra.Options {
+		pio, ok := o.(*ICMPOptionPrefixInformation)
+		if !ok || pio.Prefix.To16() == nil || pio.PrefixLength > 128 {
+			continue
+		}
+
+		prefix := &net.IPNet{
+			IP:   pio.Prefix.Mask(net.CIDRMask(int(pio.PrefixLength), 128)),
+			Mask: net.CIDRMask(int(pio.PrefixLength), 128),
+		}
+
+		alert := MonitorAlert{
+			Time:             now,
+			Address:          src,
+			LinkLayerAddress: lla,
+			Prefix:           prefix,
+		}
+
+		if bogonPrefix(prefix) {
+			alert.Type = AlertBogonPrefix
+			alerts = append(alerts, alert)
+			continue
+		}
+
+		if m.learning {
+			m.prefixes[prefix.String()] = &MonitorPrefix{Prefix: prefix, Router: src}
+			continue
+		}
+
+		if _, ok := m.prefixes[prefix.String()]; !ok {
+			alert.Type = AlertNewPrefix
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return m.bind(alerts, src, lla, now)
+}
+
+func (m *Monitor) handleNeighborAdvertisement(alerts []MonitorAlert, lla net.HardwareAddr, na *ICMPNeighborAdvertisement, now time.Time) []MonitorAlert {
+	key := na.TargetAddress.String()
+	if router, ok := m.routers[key]; ok && lla != nil && !bytes.Equal(router.LinkLayerAddress, lla) {
+		return append(alerts, MonitorAlert{
+			Type:                     AlertRouterSpoofing,
+			Time:                     now,
+			Address:                  na.TargetAddress,
+			LinkLayerAddress:         lla,
+			PreviousLinkLayerAddress: router.LinkLayerAddress,
+		})
+	}
+
+	// only the node using the address should answer duplicate address
+	// detection for it
+	if expires, ok := m.dad[key]; ok {
+		delete(m.dad, key)
+		binding := m.bindings[key]
+		if now.Before(expires) && lla != nil && (binding == nil || !bytes.Equal(binding.LinkLayerAddress, lla)) {
+			alert := MonitorAlert{
+				Type:             AlertDADDenial,
+				Time:             now,
+				Address:          na.TargetAddress,
+				LinkLayerAddress: lla,
+			}
+
+			if binding != nil {
+				alert.PreviousLinkLayerAddress = binding.LinkLayerAddress
+			}
+
+			return append(alerts, alert)
+		}
+	}
+
+	return m.bind(alerts, na.TargetAddress, lla, now)
+}
+
+// expireDAD forgets duplicate address detection no advertisement was seen
+// for in time, so targets nobody defended do not pile up
+func (m *Monitor) expireDAD(now time.Time) {
+	for target, expires := range m.dad {
+		if !now.Before(expires) {
+			delete(m.dad, target)
+		}
+	}
+}
+
+// bind remembers given address uses given link-layer address
+func (m *Monitor) bind(alerts []MonitorAlert, addr net.IP, lla net.HardwareAddr, now time.Time) []MonitorAlert {
+	if addr == nil || addr.IsUnspecified() || addr.IsMulticast() || lla == nil {
+		return alerts
+	}
+
+	binding, ok := m.bindings[addr.String()]
+	if !ok {
+		m.bindings[addr.String()] = &MonitorBinding{
+			Address:          addr,
+			LinkLayerAddress: lla,
+			FirstSeen:        now,
+			LastSeen:         now,
+		}
+
+		return alerts
+	}
+
+	binding.LastSeen = now
+	if bytes.Equal(binding.LinkLayerAddress, lla) {
+		return alerts
+	}
+
+	alert := MonitorAlert{
+		Type:                     AlertChangedLinkLayerAddress,
+		Time:                     now,
+		Address:                  addr,
+		LinkLayerAddress:         lla,
+		PreviousLinkLayerAddress: binding.LinkLayerAddress,
+	}
+
+	previous := []net.HardwareAddr{binding.LinkLayerAddress}
+	for _, p := range binding.Previous {
+		if bytes.Equal(p, lla) {
+			alert.Type = AlertFlipFlop
+			continue
+		}
+
+		previous = append(previous, p)
+	}
+
+	binding.LinkLayerAddress = lla
+	binding.Previous = previous
+
+	return append(alerts, alert)
+}
+
+// Routers returns the legitimate routers, sorted by address
+func (m *Monitor) Routers() []MonitorRouter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.database().Routers
+}
+
+// Prefixes returns the legitimate prefixes, sorted by prefix
+func (m *Monitor) Prefixes() []MonitorPrefix {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.database().Prefixes
+}
+
+// Binding returns the binding of given address
+func (m *Monitor) Binding(addr net.IP) (MonitorBinding, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	binding, ok := m.bindings[addr.String()]
+	if !ok {
+		return MonitorBinding{}, false
+	}
+
+	return *binding, true
+}
+
+// Save writes the database to given writer, so it can be loaded after a
+// restart
+func (m *Monitor) Save(w io.Writer) error {
+	m.mu.Lock()
+	db := m.database()
+	m.mu.Unlock()
+
+	return json.NewEncoder(w).Encode(db)
+}
+
+// Load replaces the database with the one read from given reader. The
+// database is left untouched when the one read is invalid.
+func (m *Monitor) Load(r io.Reader) error {
+	var db monitorDatabase
+	if err := json.NewDecoder(r).Decode(&db); err != nil {
+		return err
+	}
+
+	for _, r := range db.Routers {
+		if r.Address.To16() == nil {
+			return fmt.Errorf("address of router missing")
+		}
+	}
+
+	for _, p := range db.Prefixes {
+		if p.Prefix == nil {
+			return fmt.Errorf("prefix of router %s missing", p.Router)
+		}
+	}
+
+	for _, b := range db.Bindings {
+		if b.Address.To16() == nil {
+			return fmt.Errorf("address of binding to %s missing", b.LinkLayerAddress)
+		}
+	}
+
+	routers := make(map[string]*MonitorRouter)
+	for i := range db.Routers {
+		routers[db.Routers[i].Address.String()] = &db.Routers[i]
+	}
+
+	prefixes := make(map[string]*MonitorPrefix)
+	for i := range db.Prefixes {
+		prefixes[db.Prefixes[i].Prefix.String()] = &db.Prefixes[i]
+	}
+
+	bindings := make(map[string]*MonitorBinding)
+	for i := range db.Bindings {
+		bindings[db.Bindings[i].Address.String()] = &db.Bindings[i]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.routers = routers
+	m.prefixes = prefixes
+	m.bindings = bindings
+
+	return nil
+}
+
+// database returns a sorted copy of the database
+func (m *Monitor) database() monitorDatabase {
+	db := monitorDatabase{
+		Routers:  []MonitorRouter{},
+		Prefixes: []MonitorPrefix{},
+		Bindings: []MonitorBinding{},
+	}
+
+	for _, r := range m.routers {
+		db.Routers = append(db.Routers, *r)
+	}
+
+	for _, p := range m.prefixes {
+		db.Prefixes = append(db.Prefixes, *p)
+	}
+
+	for _, b := range m.bindings {
+		db.Bindings = append(db.Bindings, *b)
+	}
+
+	sort.Slice(db.Routers, func(i, j int) bool {
+		return bytes.Compare(db.Routers[i].Address.To16(), db.Routers[j].Address.To16()) < 0
+	})
+	sort.Slice(db.Prefixes, func(i, j int) bool {
+		return db.Prefixes[i].Prefix.String() < db.Prefixes[j].Prefix.String()
+	})
+	sort.Slice(db.Bindings, func(i, j int) bool {
+		return bytes.Compare(db.Bindings[i].Address.To16(), db.Bindings[j].Address.To16()) < 0
+	})
+
+	return db
+}
+
+// linkLayerAddress returns the address in the source or target link-layer
+// address option, or given address when there is none
+func linkLayerAddress(options ICMPOptions, lla net.HardwareAddr) net.HardwareAddr {
+	for _, o := range options {
+		switch l := o.(type) {
+		case *ICMPOptionSourceLinkLayerAddress:
+			return l.LinkLayerAddress
+		case *ICMPOptionTargetLinkLayerAddress:
+			return l.LinkLayerAddress
+		}
+	}
+
+	return lla
+}
+
+// bogonPrefix returns true for prefixes that are never advertised
+func bogonPrefix(prefix *net.IPNet) bool {
+	ones, _ := prefix.Mask.Size()
+	for _, b := range monitorBogonPrefixes {
+		if b.Contains(prefix.IP) {
+			return true
+		}
+	}
+
+	for _, u := range monitorUnicastPrefixes {
+		if uOnes, _ := u.Mask.Size(); ones >= uOnes && u.Contains(prefix.IP) {
+			return false
+		}
+	}
+
+	return true
+}
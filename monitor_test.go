@@ -0,0 +1,246 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitorAlertTypeString(t *testing.T) {
+	tests := []struct {
+		a MonitorAlertType
+		s string
+	}{
+		{AlertNewRouter, "new router"},
+		{AlertRouterChanged, "router changed"},
+		{AlertNewPrefix, "new prefix"},
+		{AlertBogonPrefix, "bogon prefix"},
+		{AlertChangedLinkLayerAddress, "changed link-layer address"},
+		{AlertFlipFlop, "flip flop"},
+		{AlertRouterSpoofing, "router spoofing"},
+		{AlertDADDenial, "dad denial"},
+		{MonitorAlertType(8), "<nil>"},
+	}
+
+	for _, test := range tests {
+		if test.a.String() != test.s {
+			t.Errorf("unexpected string for alert type %d: %s", test.a, test.a)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	monitor := NewMonitor(true)
+	now := time.Unix(0, 0)
+
+	router := net.ParseIP("fe80::1")
+	routerMAC := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	advertisement := func(prefix string) *ICMPRouterAdvertisement {
+		ra := &ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}
+		ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: routerMAC})
+		ra.AddOption(&ICMPOptionMTU{MTU: 1500})
+		ra.AddOption(&ICMPOptionPrefixInformation{Prefix: net.ParseIP(prefix), PrefixLength: 64, ValidLifetime: 3600})
+		return ra
+	}
+
+	types := func(alerts []MonitorAlert) []MonitorAlertType {
+		t := []MonitorAlertType{}
+		for _, a := range alerts {
+			t = append(t, a.Type)
+		}
+
+		return t
+	}
+
+	// learn the router and its prefix, bogons are never legitimate
+	alerts := monitor.HandleMessage(router, nil, advertisement("2001:db8:1::"), now)
+	if len(alerts) != 1 || alerts[0].Type != AlertBogonPrefix || alerts[0].Prefix.String() != "2001:db8:1::/64" {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	if alerts = monitor.HandleMessage(router, nil, advertisement("2a00:1:2:3::"), now); len(alerts) != 0 {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	monitor.SetLearning(false)
+	routers := monitor.Routers()
+	if len(routers) != 1 || !routers[0].Address.Equal(router) || routers[0].MTU != 1500 || !bytes.Equal(routers[0].LinkLayerAddress, routerMAC) {
+		t.Errorf("unexpected routers %v", routers)
+	}
+
+	prefixes := monitor.Prefixes()
+	if len(prefixes) != 1 || prefixes[0].Prefix.String() != "2a00:1:2:3::/64" {
+		t.Errorf("unexpected prefixes %v", prefixes)
+	}
+
+	if alerts = monitor.HandleMessage(router, nil, advertisement("2a00:1:2:3::"), now); len(alerts) != 0 {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	// rogue routers
+	rogue := advertisement("2a00:1:2:4::")
+	alerts = monitor.HandleMessage(net.ParseIP("fe80::666"), nil, rogue, now)
+	if got := types(alerts); len(got) != 2 || got[0] != AlertNewRouter || got[1] != AlertNewPrefix {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	changed := advertisement("2a00:1:2:3::")
+	changed.HopLimit = 255
+	changed.ManagedAddress = true
+	alerts = monitor.HandleMessage(router, nil, changed, now)
+	if len(alerts) != 1 || alerts[0].Type != AlertRouterChanged || alerts[0].Detail != "[hop limit 64 -> 255 managed false -> true]" {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	// bindings
+	host := net.ParseIP("2a00:1:2:3::10")
+	mac1 := net.HardwareAddr{2, 0, 0, 0, 0, 10}
+	mac2 := net.HardwareAddr{2, 0, 0, 0, 0, 11}
+	if alerts = monitor.HandleMessage(host, mac1, &ICMPNeighborSolicitation{TargetAddress: router}, now); len(alerts) != 0 {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	now = now.Add(time.Second)
+	alerts = monitor.HandleMessage(net.ParseIP("fe80::2"), mac2, &ICMPNeighborAdvertisement{TargetAddress: host}, now)
+	if len(alerts) != 1 || alerts[0].Type != AlertChangedLinkLayerAddress || !bytes.Equal(alerts[0].PreviousLinkLayerAddress, mac1) {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	alerts = monitor.HandleMessage(host, mac1, &ICMPRouterSolicitation{}, now)
+	if len(alerts) != 1 || alerts[0].Type != AlertFlipFlop || !bytes.Equal(alerts[0].LinkLayerAddress, mac1) {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	binding, ok := monitor.Binding(host)
+	if !ok || !bytes.Equal(binding.LinkLayerAddress, mac1) || len(binding.Previous) != 1 || !bytes.Equal(binding.Previous[0], mac2) || binding.FirstSeen != time.Unix(0, 0) || binding.LastSeen != now {
+		t.Errorf("unexpected binding %v", binding)
+	}
+
+	// spoofing the router
+	na := &ICMPNeighborAdvertisement{Router: true, Override: true, TargetAddress: router}
+	na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: mac2})
+	alerts = monitor.HandleMessage(router, nil, na, now)
+	if len(alerts) != 1 || alerts[0].Type != AlertRouterSpoofing || !bytes.Equal(alerts[0].PreviousLinkLayerAddress, routerMAC) {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	// denying duplicate address detection
+	fresh := net.ParseIP("2a00:1:2:3::20")
+	monitor.HandleMessage(net.IPv6unspecified, mac1, &ICMPNeighborSolicitation{TargetAddress: fresh}, now)
+	alerts = monitor.HandleMessage(fresh, mac2, &ICMPNeighborAdvertisement{TargetAddress: fresh}, now.Add(100*time.Millisecond))
+	if len(alerts) != 1 || alerts[0].Type != AlertDADDenial {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	// but the node using the address does
+	monitor.HandleMessage(net.IPv6unspecified, mac2, &ICMPNeighborSolicitation{TargetAddress: host}, now)
+	if alerts = monitor.HandleMessage(host, mac1, &ICMPNeighborAdvertisement{TargetAddress: host}, now.Add(100*time.Millisecond)); len(alerts) != 0 {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	// duplicate address detection nobody answered is forgotten
+	for i := 0; i < 10; i++ {
+		target := net.ParseIP(fmt.Sprintf("2a00:1:2:3::%d", 100+i))
+		monitor.HandleMessage(net.IPv6unspecified, mac1, &ICMPNeighborSolicitation{TargetAddress: target}, now.Add(time.Duration(i)*time.Second))
+	}
+
+	if len(monitor.dad) != 1 {
+		t.Errorf("expected 1 duplicate address detection in progress, got %d", len(monitor.dad))
+	}
+
+	// other messages are ignored
+	if alerts = monitor.HandleMessage(host, mac2, &ICMPEchoRequest{}, now); len(alerts) != 0 {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	// the database survives a restart
+	var db bytes.Buffer
+	if err := monitor.Save(&db); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewMonitor(false)
+	if err := restarted.Load(&db); err != nil {
+		t.Fatal(err)
+	}
+
+	if alerts = restarted.HandleMessage(router, nil, advertisement("2a00:1:2:3::"), now); len(alerts) != 0 {
+		t.Errorf("unexpected alerts %v", alerts)
+	}
+
+	if binding, ok := restarted.Binding(host); !ok || !bytes.Equal(binding.LinkLayerAddress, mac1) || !binding.LastSeen.Equal(now.Add(100*time.Millisecond)) {
+		t.Errorf("unexpected binding %v", binding)
+	}
+
+	if err := restarted.Load(strings.NewReader("{")); err == nil {
+		t.Error("expected error loading invalid database")
+	}
+
+	// an invalid database does not replace any part of the loaded one
+	if err := restarted.Load(strings.NewReader(`{"Routers":[],"Prefixes":[{}],"Bindings":[]}`)); err == nil {
+		t.Error("expected error loading database with prefix missing")
+	}
+
+	if err := restarted.Load(strings.NewReader(`{"Routers":[],"Prefixes":[],"Bindings":[{}]}`)); err == nil {
+		t.Error("expected error loading database with address missing")
+	}
+
+	if routers := restarted.Routers(); len(routers) != 1 || !routers[0].Address.Equal(router) {
+		t.Errorf("unexpected routers %v", routers)
+	}
+
+	if _, ok := restarted.Binding(host); !ok {
+		t.Error("expected binding to survive loading invalid database")
+	}
+}
+
+func TestMonitorAlertJSON(t *testing.T) {
+	alert := MonitorAlert{
+		Type:             AlertBogonPrefix,
+		Time:             time.Unix(0, 0).UTC(),
+		Address:          net.ParseIP("fe80::1"),
+		LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		Prefix:           mustParseCIDR("2001:db8::/64"),
+	}
+
+	b, err := json.Marshal(alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture := `{"type":"bogon prefix","time":"1970-01-01T00:00:00Z","address":"fe80::1","lladdr":"02:00:00:00:00:01","prefix":"2001:db8::/64"}`
+	if strings.Compare(string(b), fixture) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", fixture, b)
+	}
+
+	descfix := "bogon prefix: fe80::1 02:00:00:00:00:01"
+	if strings.Compare(alert.String(), descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, alert)
+	}
+}
+
+func TestBogonPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		bogon  bool
+	}{
+		{"2a00:1::/64", false},
+		{"fd00:1::/64", false},
+		{"2001:db8::/64", true},
+		{"3ffe::/64", true},
+		{"fe80::/64", true},
+		{"ff02::/64", true},
+		{"::/0", true},
+		{"2000::/2", true},
+	}
+
+	for _, test := range tests {
+		if bogonPrefix(mustParseCIDR(test.prefix)) != test.bogon {
+			t.Errorf("unexpected bogon check for %s", test.prefix)
+		}
+	}
+}
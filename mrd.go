@@ -0,0 +1,100 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPMulticastRouterAdvertisement implements the Multicast Router
+// Advertisement message as described at
+// https://tools.ietf.org/html/rfc4286#section-2
+type ICMPMulticastRouterAdvertisement struct {
+	AdvertisementInterval uint8
+	QueryInterval         uint16
+	RobustnessVariable    uint16
+}
+
+func (p ICMPMulticastRouterAdvertisement) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d\n ", p.Type(), len(m))
+	s += fmt.Sprintf("ad interval %ds, ", p.AdvertisementInterval)
+	s += fmt.Sprintf("query interval %ds, ", p.QueryInterval)
+	s += fmt.Sprintf("robustness %d", p.RobustnessVariable)
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeMulticastRouterAdvertisement
+func (p ICMPMulticastRouterAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMulticastRouterAdvertisement
+}
+
+// Marshal returns byte slice representing this
+// ICMPMulticastRouterAdvertisement
+func (p ICMPMulticastRouterAdvertisement) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// code field holds the advertisement interval
+	b[1] = p.AdvertisementInterval
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.QueryInterval)
+	binary.BigEndian.PutUint16(b[6:8], p.RobustnessVariable)
+
+	return b, nil
+}
+
+// ICMPMulticastRouterSolicitation implements the Multicast Router
+// Solicitation message as described at
+// https://tools.ietf.org/html/rfc4286#section-5.1
+type ICMPMulticastRouterSolicitation struct{}
+
+func (p ICMPMulticastRouterSolicitation) String() string {
+	m, _ := p.Marshal()
+	return fmt.Sprintf("%s, length %d", p.Type(), len(m))
+}
+
+// Type returns ipv6.ICMPTypeMulticastRouterSolicitation
+func (p ICMPMulticastRouterSolicitation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMulticastRouterSolicitation
+}
+
+// Marshal returns byte slice representing this
+// ICMPMulticastRouterSolicitation
+func (p ICMPMulticastRouterSolicitation) Marshal() ([]byte, error) {
+	b := make([]byte, 4)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = reserved
+	// b[2:3] = checksum, calculated separately
+
+	return b, nil
+}
+
+// ICMPMulticastRouterTermination implements the Multicast Router Termination
+// message as described at https://tools.ietf.org/html/rfc4286#section-6.1
+type ICMPMulticastRouterTermination struct{}
+
+func (p ICMPMulticastRouterTermination) String() string {
+	m, _ := p.Marshal()
+	return fmt.Sprintf("%s, length %d", p.Type(), len(m))
+}
+
+// Type returns ipv6.ICMPTypeMulticastRouterTermination
+func (p ICMPMulticastRouterTermination) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeMulticastRouterTermination
+}
+
+// Marshal returns byte slice representing this
+// ICMPMulticastRouterTermination
+func (p ICMPMulticastRouterTermination) Marshal() ([]byte, error) {
+	b := make([]byte, 4)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = reserved
+	// b[2:3] = checksum, calculated separately
+
+	return b, nil
+}
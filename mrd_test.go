@@ -0,0 +1,97 @@
+package ndp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPMulticastRouterAdvertisement(t *testing.T) {
+	icmp := &ICMPMulticastRouterAdvertisement{
+		AdvertisementInterval: 20,
+		QueryInterval:         125,
+		RobustnessVariable:    2,
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeMulticastRouterAdvertisement {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeMulticastRouterAdvertisement)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{151, 20, 0, 0, 0, 125, 0, 2}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "multicast router advertisement, length 8\n ad interval 20s, query interval 125s, robustness 2"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:6])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPMulticastRouterSolicitationTermination(t *testing.T) {
+	tests := []struct {
+		icmp    ICMP
+		typ     ipv6.ICMPType
+		fixture []byte
+		descfix string
+	}{
+		{&ICMPMulticastRouterSolicitation{}, ipv6.ICMPTypeMulticastRouterSolicitation, []byte{152, 0, 0, 0}, "multicast router solicitation, length 4"},
+		{&ICMPMulticastRouterTermination{}, ipv6.ICMPTypeMulticastRouterTermination, []byte{153, 0, 0, 0}, "multicast router termination, length 4"},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.icmp.Type(), test.typ)
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(test.fixture)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if parsedICMP.Type() != test.typ {
+			t.Errorf("parsed type %d instead of %d", parsedICMP.Type(), test.typ)
+		}
+	}
+}
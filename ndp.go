@@ -1,6 +1,11 @@
 package ndp
 
-import "strings"
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+)
 
 // inspired by golang.org/net/dnsclient.go's absDomainName
 func decDomainName(b []byte) []string {
@@ -13,6 +18,10 @@ func decDomainName(b []byte) []string {
 	for {
 		// go over each label
 		length := int(b[0])
+		// stop at labels running past the end of the data
+		if length+1 > len(b) {
+			break
+		}
 		// extract new label
 		if length > 0 {
 			labels = append(labels, string(b[1:(length+1)]))
@@ -32,6 +41,24 @@ func decDomainName(b []byte) []string {
 
 // encode domain names as defined in RFC 1035 Section 3.1
 func encDomainName(dn []string) []byte {
+	b := encDomainNameLabels(dn)
+
+	// pad encoding until it's a multiple of octets
+	pad := (8 - (len(b) % 8))
+	for i := 0; i < pad; i++ {
+		b = append(b, 0)
+	}
+
+	// cap encoding on 255 octets
+	if len(b) > 255 {
+		return b[:255]
+	}
+
+	return b
+}
+
+// encode the labels of domain names without any padding
+func encDomainNameLabels(dn []string) []byte {
 	b := make([]byte, 0)
 	// loop over given domain names
 	for _, n := range dn {
@@ -52,16 +79,38 @@ func encDomainName(dn []string) []byte {
 		}
 	}
 
-	// pad encoding until it's a multiple of octets
-	pad := (8 - (len(b) % 8))
-	for i := 0; i < pad; i++ {
-		b = append(b, 0)
+	return b
+}
+
+// SolicitedNodeMulticast returns the solicited-node multicast address for given
+// address as described at https://tools.ietf.org/html/rfc4291#section-2.7.1
+func SolicitedNodeMulticast(ip net.IP) net.IP {
+	ip = ip.To16()
+	if ip == nil {
+		return nil
 	}
 
-	// cap encoding on 255 octets
-	if len(b) > 255 {
-		return b[:255]
+	snm := net.ParseIP("ff02::1:ff00:0")
+	copy(snm[13:], ip[13:])
+
+	return snm
+}
+
+// copy an address so it no longer refers to the buffer it was parsed from
+func copyIP(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
 	}
 
-	return b
+	c := make(net.IP, len(ip))
+	copy(c, ip)
+
+	return c
+}
+
+// sort addresses in place by their byte representation
+func sortIPs(ips []net.IP) {
+	sort.Slice(ips, func(i, j int) bool {
+		return bytes.Compare(ips[i].To16(), ips[j].To16()) < 0
+	})
 }
@@ -2,6 +2,7 @@ package ndp
 
 import (
 	"bytes"
+	"net"
 	"reflect"
 	"testing"
 )
@@ -66,3 +67,24 @@ func TestEncDecDomainName(t *testing.T) {
 		t.Errorf("expected truncated encoding of 72, not %d", len(encoded))
 	}
 }
+
+func TestSolicitedNodeMulticast(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"fe80::1", "ff02::1:ff00:1"},
+		{"2001:db8::abcd:ef12", "ff02::1:ffcd:ef12"},
+	}
+
+	for _, test := range tests {
+		snm := SolicitedNodeMulticast(net.ParseIP(test.in))
+		if !snm.Equal(net.ParseIP(test.out)) {
+			t.Errorf("expected %s for %s but got %s", test.out, test.in, snm)
+		}
+	}
+
+	if SolicitedNodeMulticast(nil) != nil {
+		t.Error("expected nil for invalid address")
+	}
+}
@@ -0,0 +1,492 @@
+package ndp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyCacheTimeout is how long the interface of an address is
+	// remembered, as long as REACHABLE_TIME described at
+	// https://tools.ietf.org/html/rfc4861#section-10
+	proxyCacheTimeout = 30 * time.Second
+	// proxyPendingTimeout is how long a relayed solicitation waits for its
+	// advertisement, MAX_MULTICAST_SOLICIT times RETRANS_TIMER
+	proxyPendingTimeout = 3 * time.Second
+	// proxyLoopTimeout is how long relayed messages are remembered to
+	// recognize them coming back, shorter than RETRANS_TIMER so
+	// retransmissions are still relayed
+	proxyLoopTimeout = 500 * time.Millisecond
+)
+
+var allRoutersMulticast = net.ParseIP("ff02::2")
+
+// ProxyInterface describes an interface NDProxy relays messages between
+type ProxyInterface struct {
+	Name string
+	// Conn needs to send neighbor discovery with hop limit 255, like the
+	// ones returned by ListenICMP, or relayed messages are dropped
+	Conn Conn
+	// LinkLayerAddress of the interface replaces the link-layer addresses
+	// in messages relayed onto it
+	LinkLayerAddress net.HardwareAddr
+	// Upstream marks the interface towards the router, when no interface is
+	// marked the first to receive a router advertisement without P flag is
+	// taken
+	Upstream bool
+}
+
+// NDProxy bridges neighbor discovery between interfaces sharing a single
+// prefix, as described at https://tools.ietf.org/html/rfc4389 and used for
+// extending a mobile /64 at https://tools.ietf.org/html/rfc7278
+//
+// Relayed messages get the link-layer address of the proxy on the outgoing
+// interface, so traffic between the links flows through the proxy. A proxy
+// cache remembers on which interface addresses were seen, so solicitations
+// are only relayed where their target lives, and relayed messages are
+// remembered for a short while to drop them when they loop back.
+// Messages protected by SEND can't be proxied, as rewriting them breaks their
+// signature.
+type NDProxy struct {
+	interfaces []ProxyInterface
+
+	mu       sync.Mutex
+	upstream string
+	cache    map[string]proxyCacheEntry
+	pending  map[string][]proxyPending
+	relayed  map[[sha256.Size]byte]time.Time
+}
+
+type proxyCacheEntry struct {
+	iface   string
+	expires time.Time
+}
+
+// proxyPending is a relayed solicitation waiting for its advertisement
+type proxyPending struct {
+	iface   string
+	src     net.IP
+	expires time.Time
+}
+
+// proxyRelay is a message to send on an interface
+type proxyRelay struct {
+	iface int
+	dst   net.IP
+	m     ICMP
+}
+
+// NewNDProxy returns an NDProxy relaying between given interfaces
+func NewNDProxy(interfaces ...ProxyInterface) (*NDProxy, error) {
+	if len(interfaces) < 2 {
+		return nil, errors.New("at least 2 interfaces are needed to proxy between")
+	}
+
+	p := &NDProxy{
+		cache:   make(map[string]proxyCacheEntry),
+		pending: make(map[string][]proxyPending),
+		relayed: make(map[[sha256.Size]byte]time.Time),
+	}
+
+	seen := make(map[string]bool)
+	for _, iface := range interfaces {
+		if seen[iface.Name] {
+			return nil, fmt.Errorf("duplicate interface %s", iface.Name)
+		}
+		seen[iface.Name] = true
+
+		if iface.Conn == nil || len(iface.LinkLayerAddress) == 0 {
+			return nil, fmt.Errorf("interface %s needs a conn and link-layer address", iface.Name)
+		}
+
+		if iface.Upstream {
+			if p.upstream != "" {
+				return nil, errors.New("only one interface can be upstream")
+			}
+
+			p.upstream = iface.Name
+		}
+	}
+
+	p.interfaces = interfaces
+
+	return p, nil
+}
+
+// Serve relays messages read from all interfaces until reading or writing
+// fails on any of them. Closing the Conns stops it.
+func (p *NDProxy) Serve() error {
+	errs := make(chan error, len(p.interfaces))
+	for _, iface := range p.interfaces {
+		go func(iface ProxyInterface) {
+			for {
+				m, src, hopLimit, err := iface.Conn.ReadFrom()
+				if err == nil {
+					err = p.HandleMessage(iface.Name, src, m, hopLimit, time.Now())
+				}
+
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(iface)
+	}
+
+	return <-errs
+}
+
+// HandleMessage relays given message received on given interface from given
+// source address with given hop limit to the other interfaces. Messages not
+// received with hop limit 255 could come from off-link and are dropped, as
+// are messages other than neighbor discovery.
+func (p *NDProxy) HandleMessage(iface string, src net.IP, m ICMP, hopLimit int, now time.Time) error {
+	if !validHopLimit(m, hopLimit) {
+		return nil
+	}
+
+	p.mu.Lock()
+	relays := p.handle(iface, src, m, now)
+	p.mu.Unlock()
+
+	for _, r := range relays {
+		if err := p.interfaces[r.iface].Conn.WriteTo(r.m, r.dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the interface given address was last seen on
+func (p *NDProxy) Lookup(addr net.IP, now time.Time) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.lookup(addr, now)
+}
+
+// Upstream returns the interface towards the router, if known yet
+func (p *NDProxy) Upstream() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.upstream
+}
+
+// Expire removes everything that timed out at given time
+func (p *NDProxy) Expire(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for addr, e := range p.cache {
+		if !now.Before(e.expires) {
+			delete(p.cache, addr)
+		}
+	}
+
+	for target, pending := range p.pending {
+		p.pending[target] = p.expirePending(pending, now)
+		if len(p.pending[target]) == 0 {
+			delete(p.pending, target)
+		}
+	}
+
+	for digest, expires := range p.relayed {
+		if !now.Before(expires) {
+			delete(p.relayed, digest)
+		}
+	}
+}
+
+func (p *NDProxy) handle(iface string, src net.IP, m ICMP, now time.Time) []proxyRelay {
+	in := p.index(iface)
+	if in < 0 || p.looped(m, now) {
+		return nil
+	}
+
+	var relays []proxyRelay
+	switch msg := m.(type) {
+	case *ICMPNeighborSolicitation:
+		relays = p.handleSolicitation(in, src, msg, now)
+	case *ICMPNeighborAdvertisement:
+		relays = p.handleAdvertisement(in, msg, now)
+	case *ICMPRouterSolicitation:
+		p.learn(src, in, now)
+		for _, out := range p.others(in) {
+			if p.upstream == "" || p.interfaces[out].Name == p.upstream {
+				relays = append(relays, proxyRelay{out, allRoutersMulticast, p.rewrite(msg, out)})
+			}
+		}
+	case *ICMPRouterAdvertisement:
+		relays = p.handleRouterAdvertisement(in, src, msg, now)
+	case *ICMPRedirect:
+		relays = p.handleRedirect(in, msg, now)
+	default:
+		return nil
+	}
+
+	// remember what is relayed, to recognize it coming back
+	p.remember(m, now)
+	for _, r := range relays {
+		p.remember(r.m, now)
+	}
+
+	return relays
+}
+
+func (p *NDProxy) handleSolicitation(in int, src net.IP, ns *ICMPNeighborSolicitation, now time.Time) []proxyRelay {
+	// solicitations for duplicate address detection come from the
+	// unspecified address
+	if !src.IsUnspecified() {
+		p.learn(src, in, now)
+	}
+
+	outs := p.others(in)
+	if iface, ok := p.lookup(ns.TargetAddress, now); ok {
+		// the target answers itself
+		if iface == p.interfaces[in].Name {
+			return nil
+		}
+
+		outs = []int{p.index(iface)}
+	}
+
+	key := ns.TargetAddress.String()
+	p.pending[key] = append(p.expirePending(p.pending[key], now), proxyPending{
+		iface:   p.interfaces[in].Name,
+		src:     src,
+		expires: now.Add(proxyPendingTimeout),
+	})
+
+	relays := []proxyRelay{}
+	for _, out := range outs {
+		relays = append(relays, proxyRelay{out, SolicitedNodeMulticast(ns.TargetAddress), p.rewrite(ns, out)})
+	}
+
+	return relays
+}
+
+func (p *NDProxy) handleAdvertisement(in int, na *ICMPNeighborAdvertisement, now time.Time) []proxyRelay {
+	p.learn(na.TargetAddress, in, now)
+
+	key := na.TargetAddress.String()
+	pending := p.expirePending(p.pending[key], now)
+	delete(p.pending, key)
+
+	relays := []proxyRelay{}
+	for _, s := range pending {
+		out := p.index(s.iface)
+		if out == in {
+			continue
+		}
+
+		dst := s.src
+		if dst.IsUnspecified() {
+			// answers to duplicate address detection go to all nodes
+			dst = allNodesMulticast
+		}
+
+		relays = append(relays, proxyRelay{out, dst, p.rewrite(na, out)})
+	}
+
+	// unsolicited advertisements are announced everywhere
+	if len(pending) == 0 && !na.Solicited {
+		for _, out := range p.others(in) {
+			relays = append(relays, proxyRelay{out, allNodesMulticast, p.rewrite(na, out)})
+		}
+	}
+
+	return relays
+}
+
+func (p *NDProxy) handleRouterAdvertisement(in int, src net.IP, ra *ICMPRouterAdvertisement, now time.Time) []proxyRelay {
+	name := p.interfaces[in].Name
+	if p.upstream == "" && !ra.Proxy {
+		p.upstream = name
+	}
+
+	// advertisements only flow downstream, those with P flag on another
+	// interface come from a proxy facing us
+	if name != p.upstream {
+		return nil
+	}
+
+	p.learn(src, in, now)
+
+	relays := []proxyRelay{}
+	for _, out := range p.others(in) {
+		relays = append(relays, proxyRelay{out, allNodesMulticast, p.rewrite(ra, out)})
+	}
+
+	return relays
+}
+
+func (p *NDProxy) handleRedirect(in int, r *ICMPRedirect, now time.Time) []proxyRelay {
+	// the redirected node sent the packet in the redirected header
+	var node net.IP
+	for _, o := range r.Options {
+		if rh, ok := o.(*ICMPOptionRedirectedHeader); ok {
+			if invoking := rh.Invoking(); invoking != nil {
+				node = invoking.Source
+			}
+		}
+	}
+
+	if node == nil {
+		return nil
+	}
+
+	iface, ok := p.lookup(node, now)
+	if !ok || iface == p.interfaces[in].Name {
+		return nil
+	}
+
+	out := p.index(iface)
+
+	return []proxyRelay{{out, node, p.rewrite(r, out)}}
+}
+
+// rewrite returns a copy of given message to relay on given interface
+func (p *NDProxy) rewrite(m ICMP, out int) ICMP {
+	lla := p.interfaces[out].LinkLayerAddress
+
+	switch msg := m.(type) {
+	case *ICMPNeighborSolicitation:
+		c := *msg
+		c.Options = rewriteLinkLayerOptions(msg.Options, lla)
+		return &c
+	case *ICMPNeighborAdvertisement:
+		c := *msg
+		c.Options = rewriteLinkLayerOptions(msg.Options, lla)
+		return &c
+	case *ICMPRouterSolicitation:
+		c := *msg
+		c.Options = rewriteLinkLayerOptions(msg.Options, lla)
+		return &c
+	case *ICMPRouterAdvertisement:
+		c := *msg
+		c.Proxy = true
+		c.Options = rewriteLinkLayerOptions(msg.Options, lla)
+		return &c
+	case *ICMPRedirect:
+		c := *msg
+		c.Options = rewriteLinkLayerOptions(msg.Options, lla)
+		return &c
+	}
+
+	return m
+}
+
+// rewriteLinkLayerOptions returns given options with the link-layer
+// addresses replaced by given address
+func rewriteLinkLayerOptions(options ICMPOptions, lla net.HardwareAddr) ICMPOptions {
+	rewritten := ICMPOptions{}
+	for _, o := range options {
+		switch o.(type) {
+		case *ICMPOptionSourceLinkLayerAddress:
+			o = &ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla}
+		case *ICMPOptionTargetLinkLayerAddress:
+			o = &ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla}
+		}
+
+		rewritten = append(rewritten, o)
+	}
+
+	return rewritten
+}
+
+// looped returns whether given message was relayed by this proxy, either
+// directly or through another path
+func (p *NDProxy) looped(m ICMP, now time.Time) bool {
+	if oc, ok := m.(optionMessage); ok {
+		for _, o := range oc.container().Options {
+			var lla net.HardwareAddr
+			switch l := o.(type) {
+			case *ICMPOptionSourceLinkLayerAddress:
+				lla = l.LinkLayerAddress
+			case *ICMPOptionTargetLinkLayerAddress:
+				lla = l.LinkLayerAddress
+			}
+
+			for _, iface := range p.interfaces {
+				if lla != nil && bytes.Equal(lla, iface.LinkLayerAddress) {
+					return true
+				}
+			}
+		}
+	}
+
+	expires, ok := p.relayed[proxyDigest(m)]
+
+	return ok && now.Before(expires)
+}
+
+func (p *NDProxy) remember(m ICMP, now time.Time) {
+	p.relayed[proxyDigest(m)] = now.Add(proxyLoopTimeout)
+}
+
+func proxyDigest(m ICMP) [sha256.Size]byte {
+	b, _ := m.Marshal()
+
+	return sha256.Sum256(b)
+}
+
+func (p *NDProxy) learn(addr net.IP, in int, now time.Time) {
+	if addr == nil || addr.IsUnspecified() || addr.IsMulticast() {
+		return
+	}
+
+	p.cache[addr.String()] = proxyCacheEntry{
+		iface:   p.interfaces[in].Name,
+		expires: now.Add(proxyCacheTimeout),
+	}
+}
+
+func (p *NDProxy) lookup(addr net.IP, now time.Time) (string, bool) {
+	e, ok := p.cache[addr.String()]
+	if !ok || !now.Before(e.expires) {
+		return "", false
+	}
+
+	return e.iface, true
+}
+
+func (p *NDProxy) expirePending(pending []proxyPending, now time.Time) []proxyPending {
+	current := []proxyPending{}
+	for _, s := range pending {
+		if now.Before(s.expires) {
+			current = append(current, s)
+		}
+	}
+
+	return current
+}
+
+// index returns the index of the interface with given name or -1
+func (p *NDProxy) index(name string) int {
+	for i, iface := range p.interfaces {
+		if iface.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// others returns the indexes of all interfaces but given one
+func (p *NDProxy) others(in int) []int {
+	outs := []int{}
+	for i := range p.interfaces {
+		if i != in {
+			outs = append(outs, i)
+		}
+	}
+
+	return outs
+}
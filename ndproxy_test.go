@@ -0,0 +1,251 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNDProxy(t *testing.T) {
+	uplink, eth1, eth2 := &recordingConn{}, &recordingConn{}, &recordingConn{}
+	conns := map[string]*recordingConn{"uplink": uplink, "eth1": eth1, "eth2": eth2}
+	proxy, err := NewNDProxy(
+		ProxyInterface{Name: "uplink", Conn: uplink, LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 1}, Upstream: true},
+		ProxyInterface{Name: "eth1", Conn: eth1, LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 2}},
+		ProxyInterface{Name: "eth2", Conn: eth2, LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 3}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := net.ParseIP("fe80::1")
+	hostA := net.ParseIP("2001:db8::a")
+	hostB := net.ParseIP("2001:db8::b")
+	macA := net.HardwareAddr{2, 0, 0, 0, 0, 10}
+	macB := net.HardwareAddr{2, 0, 0, 0, 0, 11}
+
+	now := time.Unix(0, 0)
+	// handle passes given message to the proxy a second after the previous
+	// one, and returns what was sent on each interface
+	handle := func(iface string, src net.IP, m ICMP) map[string][]sentMessage {
+		now = now.Add(time.Second)
+		if err := proxy.HandleMessage(iface, src, m, ndHopLimit, now); err != nil {
+			t.Fatal(err)
+		}
+
+		sent := make(map[string][]sentMessage)
+		for name, conn := range conns {
+			if s := conn.flush(); len(s) > 0 {
+				sent[name] = s
+			}
+		}
+
+		return sent
+	}
+
+	lla := func(m ICMP) net.HardwareAddr {
+		for _, o := range m.(optionMessage).container().Options {
+			switch l := o.(type) {
+			case *ICMPOptionSourceLinkLayerAddress:
+				return l.LinkLayerAddress
+			case *ICMPOptionTargetLinkLayerAddress:
+				return l.LinkLayerAddress
+			}
+		}
+
+		return nil
+	}
+
+	// router advertisements are relayed downstream with P flag
+	ra := &ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}
+	ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 99}})
+	sent := handle("uplink", router, ra)
+	if len(sent) != 2 || len(sent["eth1"]) != 1 || len(sent["eth2"]) != 1 {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	relayed := sent["eth1"][0]
+	if !relayed.dst.Equal(allNodesMulticast) || !relayed.message.(*ICMPRouterAdvertisement).Proxy || !bytes.Equal(lla(relayed.message), net.HardwareAddr{2, 0, 0, 0, 0, 2}) {
+		t.Errorf("unexpected relay %s to %s", relayed.message, relayed.dst)
+	}
+
+	if ra.Proxy || !bytes.Equal(lla(ra), net.HardwareAddr{2, 0, 0, 0, 0, 99}) {
+		t.Error("original message was modified")
+	}
+
+	// router advertisements don't flow upstream
+	if sent = handle("eth1", router, &ICMPRouterAdvertisement{Proxy: true}); len(sent) != 0 {
+		t.Errorf("unexpected relays %v", sent)
+	}
+
+	// solicitations for unknown targets are relayed everywhere
+	ns := &ICMPNeighborSolicitation{TargetAddress: hostB}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: macA})
+	sent = handle("eth1", hostA, ns)
+	if len(sent) != 2 || len(sent["uplink"]) != 1 || len(sent["eth2"]) != 1 {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	relayed = sent["eth2"][0]
+	if !relayed.dst.Equal(SolicitedNodeMulticast(hostB)) || !bytes.Equal(lla(relayed.message), net.HardwareAddr{2, 0, 0, 0, 0, 3}) {
+		t.Errorf("unexpected relay %s to %s", relayed.message, relayed.dst)
+	}
+	loopedNS := relayed.message
+
+	// advertisements are relayed to the soliciting node only
+	na := &ICMPNeighborAdvertisement{TargetAddress: hostB, Solicited: true}
+	na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: macB})
+	sent = handle("eth2", hostB, na)
+	if len(sent) != 1 || len(sent["eth1"]) != 1 {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	relayed = sent["eth1"][0]
+	if !relayed.dst.Equal(hostA) || !bytes.Equal(lla(relayed.message), net.HardwareAddr{2, 0, 0, 0, 0, 2}) {
+		t.Errorf("unexpected relay %s to %s", relayed.message, relayed.dst)
+	}
+
+	// both hosts are in the proxy cache now
+	if iface, ok := proxy.Lookup(hostA, now); !ok || iface != "eth1" {
+		t.Errorf("unexpected interface %s for %s", iface, hostA)
+	}
+
+	if iface, ok := proxy.Lookup(hostB, now); !ok || iface != "eth2" {
+		t.Errorf("unexpected interface %s for %s", iface, hostB)
+	}
+
+	// solicitations for known targets only go where the target is
+	sent = handle("eth1", hostA, ns)
+	if len(sent) != 1 || len(sent["eth2"]) != 1 {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	// the same message right after is a loop
+	if err = proxy.HandleMessage("uplink", hostA, ns, ndHopLimit, now.Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := uplink.flush(); len(s) != 0 || len(eth2.flush()) != 0 {
+		t.Errorf("unexpected relays %v", s)
+	}
+
+	// as are messages with the link-layer address of the proxy
+	if sent = handle("uplink", hostA, loopedNS); len(sent) != 0 {
+		t.Errorf("unexpected relays %v", sent)
+	}
+
+	// solicitations for targets on the same link are answered there
+	ns = &ICMPNeighborSolicitation{TargetAddress: hostA}
+	if sent = handle("eth1", net.ParseIP("2001:db8::d"), ns); len(sent) != 0 {
+		t.Errorf("unexpected relays %v", sent)
+	}
+
+	// duplicate address detection is answered to all nodes
+	dad := net.ParseIP("2001:db8::c")
+	sent = handle("eth1", net.IPv6unspecified, &ICMPNeighborSolicitation{TargetAddress: dad})
+	if len(sent) != 2 {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	sent = handle("eth2", dad, &ICMPNeighborAdvertisement{TargetAddress: dad, Override: true})
+	if len(sent) != 1 || len(sent["eth1"]) != 1 || !sent["eth1"][0].dst.Equal(allNodesMulticast) {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	// unsolicited advertisements go everywhere
+	sent = handle("eth2", hostB, &ICMPNeighborAdvertisement{TargetAddress: hostB, Override: true})
+	if len(sent) != 2 || !sent["uplink"][0].dst.Equal(allNodesMulticast) {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	// router solicitations only go upstream
+	sent = handle("eth1", hostA, &ICMPRouterSolicitation{})
+	if len(sent) != 1 || len(sent["uplink"]) != 1 || !sent["uplink"][0].dst.Equal(allRoutersMulticast) {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	// redirects go to the node that was redirected
+	invoking := make([]byte, 40)
+	invoking[0] = 0x60
+	copy(invoking[8:24], hostA)
+	copy(invoking[24:40], net.ParseIP("2001:db8:1::1"))
+	redirect := &ICMPRedirect{TargetAddress: router, DestinationAddress: net.ParseIP("2001:db8:1::1")}
+	redirect.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 99}})
+	redirect.AddOption(&ICMPOptionRedirectedHeader{InvokingPacket: invoking})
+	sent = handle("uplink", router, redirect)
+	if len(sent) != 1 || len(sent["eth1"]) != 1 {
+		t.Fatalf("unexpected relays %v", sent)
+	}
+
+	relayed = sent["eth1"][0]
+	if !relayed.dst.Equal(hostA) || !bytes.Equal(lla(relayed.message), net.HardwareAddr{2, 0, 0, 0, 0, 2}) {
+		t.Errorf("unexpected relay %s to %s", relayed.message, relayed.dst)
+	}
+
+	// other messages are ignored
+	if sent = handle("eth1", hostA, &ICMPEchoRequest{Identifier: 1}); len(sent) != 0 {
+		t.Errorf("unexpected relays %v", sent)
+	}
+
+	// everything times out eventually
+	proxy.Expire(now.Add(proxyCacheTimeout))
+	if _, ok := proxy.Lookup(hostA, now); ok {
+		t.Error("expected proxy cache to be expired")
+	}
+}
+
+func TestNDProxyUpstream(t *testing.T) {
+	eth0, eth1 := &recordingConn{}, &recordingConn{}
+	proxy, err := NewNDProxy(
+		ProxyInterface{Name: "eth0", Conn: eth0, LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 1}},
+		ProxyInterface{Name: "eth1", Conn: eth1, LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 2}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(0, 0)
+	// advertisements from off-link are dropped
+	if err = proxy.HandleMessage("eth1", net.ParseIP("2001:db8::1"), &ICMPRouterAdvertisement{}, 64, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if proxy.Upstream() != "" || len(eth0.flush()) != 0 {
+		t.Error("unexpected relay of off-link router advertisement")
+	}
+
+	// proxied advertisements don't make an interface upstream
+	if err = proxy.HandleMessage("eth1", net.ParseIP("fe80::2"), &ICMPRouterAdvertisement{Proxy: true}, ndHopLimit, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if proxy.Upstream() != "" || len(eth0.flush()) != 0 {
+		t.Error("unexpected relay of proxied router advertisement")
+	}
+
+	if err = proxy.HandleMessage("eth0", net.ParseIP("fe80::1"), &ICMPRouterAdvertisement{}, ndHopLimit, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if proxy.Upstream() != "eth0" || len(eth1.flush()) != 1 {
+		t.Error("expected eth0 to become upstream")
+	}
+}
+
+func TestNewNDProxy(t *testing.T) {
+	conn := &recordingConn{}
+	lla := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	tests := [][]ProxyInterface{
+		{{Name: "eth0", Conn: conn, LinkLayerAddress: lla}},
+		{{Name: "eth0", Conn: conn, LinkLayerAddress: lla}, {Name: "eth0", Conn: conn, LinkLayerAddress: lla}},
+		{{Name: "eth0", Conn: conn, LinkLayerAddress: lla}, {Name: "eth1", Conn: conn}},
+		{{Name: "eth0", Conn: conn, LinkLayerAddress: lla, Upstream: true}, {Name: "eth1", Conn: conn, LinkLayerAddress: lla, Upstream: true}},
+	}
+
+	for _, test := range tests {
+		if _, err := NewNDProxy(test...); err == nil {
+			t.Errorf("expected error for %v", test)
+		}
+	}
+}
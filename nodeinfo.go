@@ -0,0 +1,426 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+// NodeInformationQtype implements the Qtypes of Node Information messages as
+// described at https://tools.ietf.org/html/rfc4620#section-4
+type NodeInformationQtype uint16
+
+// qtypes currently defined
+const (
+	NodeInformationQtypeNOOP          NodeInformationQtype = 0
+	NodeInformationQtypeNodeName      NodeInformationQtype = 2
+	NodeInformationQtypeNodeAddresses NodeInformationQtype = 3
+	NodeInformationQtypeIPv4Addresses NodeInformationQtype = 4
+)
+
+func (q NodeInformationQtype) String() string {
+	switch q {
+	case NodeInformationQtypeNOOP:
+		return "noop"
+	case NodeInformationQtypeNodeName:
+		return "node name"
+	case NodeInformationQtypeNodeAddresses:
+		return "node addresses"
+	case NodeInformationQtypeIPv4Addresses:
+		return "ipv4 addresses"
+	default:
+		return "<nil>"
+	}
+}
+
+// NodeInformationSubjectType implements the codes of the Node Information
+// Query as described at https://tools.ietf.org/html/rfc4620#section-4
+type NodeInformationSubjectType uint8
+
+// codes currently defined
+const (
+	NodeInformationSubjectIPv6 NodeInformationSubjectType = iota
+	NodeInformationSubjectName
+	NodeInformationSubjectIPv4
+)
+
+// NodeInformationReplyCode implements the codes of the Node Information
+// Reply as described at https://tools.ietf.org/html/rfc4620#section-4
+type NodeInformationReplyCode uint8
+
+// codes currently defined
+const (
+	NodeInformationReplySuccessful NodeInformationReplyCode = iota
+	NodeInformationReplyRefused
+	NodeInformationReplyUnknownQtype
+)
+
+func (c NodeInformationReplyCode) String() string {
+	switch c {
+	case NodeInformationReplySuccessful:
+		return "successful"
+	case NodeInformationReplyRefused:
+		return "refused"
+	case NodeInformationReplyUnknownQtype:
+		return "unknown qtype"
+	default:
+		return "<nil>"
+	}
+}
+
+// NodeInformationFlags implements the flags of the Node Addresses and IPv4
+// Addresses qtypes as described at
+// https://tools.ietf.org/html/rfc4620#section-6.3
+type NodeInformationFlags struct {
+	Global     bool
+	SiteLocal  bool
+	LinkLocal  bool
+	Compatible bool
+	All        bool
+	Truncated  bool
+}
+
+func (f NodeInformationFlags) String() string {
+	s := []string{}
+	if f.Global {
+		s = append(s, "global")
+	}
+	if f.SiteLocal {
+		s = append(s, "site-local")
+	}
+	if f.LinkLocal {
+		s = append(s, "link-local")
+	}
+	if f.Compatible {
+		s = append(s, "compatible")
+	}
+	if f.All {
+		s = append(s, "all")
+	}
+	if f.Truncated {
+		s = append(s, "truncated")
+	}
+
+	return fmt.Sprintf("Flags %s", s)
+}
+
+func (f NodeInformationFlags) marshal() uint16 {
+	var b uint16
+	if f.Global {
+		b ^= 0x20
+	}
+	if f.SiteLocal {
+		b ^= 0x10
+	}
+	if f.LinkLocal {
+		b ^= 0x08
+	}
+	if f.Compatible {
+		b ^= 0x04
+	}
+	if f.All {
+		b ^= 0x02
+	}
+	if f.Truncated {
+		b ^= 0x01
+	}
+
+	return b
+}
+
+func parseNodeInformationFlags(b uint16) NodeInformationFlags {
+	return NodeInformationFlags{
+		Global:     (b&0x20 > 0),
+		SiteLocal:  (b&0x10 > 0),
+		LinkLocal:  (b&0x08 > 0),
+		Compatible: (b&0x04 > 0),
+		All:        (b&0x02 > 0),
+		Truncated:  (b&0x01 > 0),
+	}
+}
+
+// NodeAddress is an address with its TTL as returned in Node Information
+// Replies
+type NodeAddress struct {
+	TTL     uint32
+	Address net.IP
+}
+
+func (a NodeAddress) String() string {
+	return fmt.Sprintf("%s ttl %ds", a.Address, a.TTL)
+}
+
+// ICMPNodeInformationQuery implements the Node Information Query message as
+// described at https://tools.ietf.org/html/rfc4620#section-4
+type ICMPNodeInformationQuery struct {
+	NodeInformationFlags
+	SubjectType NodeInformationSubjectType
+	Qtype       NodeInformationQtype
+	Nonce       uint64
+	// SubjectAddress holds the subject for SubjectType IPv6 and IPv4
+	SubjectAddress net.IP
+	// SubjectName holds the subject for SubjectType Name
+	SubjectName string
+}
+
+func (p ICMPNodeInformationQuery) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s, %s, ", p.Qtype, p.NodeInformationFlags)
+	s += fmt.Sprintf("nonce %#016x, ", p.Nonce)
+	switch p.SubjectType {
+	case NodeInformationSubjectName:
+		s += fmt.Sprintf("subject name '%s'", p.SubjectName)
+	default:
+		s += fmt.Sprintf("subject %s", p.SubjectAddress)
+	}
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeNodeInformationQuery
+func (p ICMPNodeInformationQuery) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeNodeInformationQuery
+}
+
+// Marshal returns byte slice representing this ICMPNodeInformationQuery
+func (p ICMPNodeInformationQuery) Marshal() ([]byte, error) {
+	b := marshalNodeInformation(p.Type(), uint8(p.SubjectType), p.Qtype, p.NodeInformationFlags, p.Nonce)
+
+	switch p.SubjectType {
+	case NodeInformationSubjectIPv6:
+		if p.SubjectAddress.To16() == nil {
+			return nil, fmt.Errorf("invalid ipv6 subject %s", p.SubjectAddress)
+		}
+		b = append(b, p.SubjectAddress.To16()...)
+	case NodeInformationSubjectIPv4:
+		if p.SubjectAddress.To4() == nil {
+			return nil, fmt.Errorf("invalid ipv4 subject %s", p.SubjectAddress)
+		}
+		b = append(b, p.SubjectAddress.To4()...)
+	case NodeInformationSubjectName:
+		// NOOP queries may have an empty subject
+		if p.SubjectName != "" {
+			b = append(b, encNodeName(p.SubjectName)...)
+		}
+	default:
+		return nil, fmt.Errorf("subject type %d not supported", p.SubjectType)
+	}
+
+	return b, nil
+}
+
+// ICMPNodeInformationReply implements the Node Information Reply message as
+// described at https://tools.ietf.org/html/rfc4620#section-4
+type ICMPNodeInformationReply struct {
+	NodeInformationFlags
+	Code  NodeInformationReplyCode
+	Qtype NodeInformationQtype
+	Nonce uint64
+	// TTL and Names hold the data for qtype Node Name
+	TTL   uint32
+	Names []string
+	// Addresses holds the data for qtypes Node Addresses and IPv4 Addresses
+	Addresses []NodeAddress
+}
+
+func (p ICMPNodeInformationReply) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s, %s, %s, ", p.Code, p.Qtype, p.NodeInformationFlags)
+	s += fmt.Sprintf("nonce %#016x", p.Nonce)
+	if p.Code != NodeInformationReplySuccessful {
+		return s
+	}
+
+	switch p.Qtype {
+	case NodeInformationQtypeNodeName:
+		s += fmt.Sprintf(", ttl %ds, name(s) %s", p.TTL, strings.Join(p.Names, ", "))
+	case NodeInformationQtypeNodeAddresses, NodeInformationQtypeIPv4Addresses:
+		for _, a := range p.Addresses {
+			s += fmt.Sprintf(", %s", a)
+		}
+	}
+
+	return s
+}
+
+// Type returns ipv6.ICMPTypeNodeInformationResponse
+func (p ICMPNodeInformationReply) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeNodeInformationResponse
+}
+
+// Marshal returns byte slice representing this ICMPNodeInformationReply
+func (p ICMPNodeInformationReply) Marshal() ([]byte, error) {
+	b := marshalNodeInformation(p.Type(), uint8(p.Code), p.Qtype, p.NodeInformationFlags, p.Nonce)
+	if p.Code != NodeInformationReplySuccessful {
+		return b, nil
+	}
+
+	switch p.Qtype {
+	case NodeInformationQtypeNodeName:
+		ttl := make([]byte, 4)
+		binary.BigEndian.PutUint32(ttl, p.TTL)
+		b = append(b, ttl...)
+		for _, n := range p.Names {
+			b = append(b, encNodeName(n)...)
+		}
+	case NodeInformationQtypeNodeAddresses, NodeInformationQtypeIPv4Addresses:
+		for _, a := range p.Addresses {
+			ttl := make([]byte, 4)
+			binary.BigEndian.PutUint32(ttl, a.TTL)
+			b = append(b, ttl...)
+
+			if p.Qtype == NodeInformationQtypeIPv4Addresses {
+				if a.Address.To4() == nil {
+					return nil, fmt.Errorf("invalid ipv4 address %s", a.Address)
+				}
+				b = append(b, a.Address.To4()...)
+			} else {
+				b = append(b, a.Address.To16()...)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+func marshalNodeInformation(t ipv6.ICMPType, code uint8, qtype NodeInformationQtype, flags NodeInformationFlags, nonce uint64) []byte {
+	b := make([]byte, 16)
+	// message header
+	b[0] = uint8(t)
+	b[1] = code
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], uint16(qtype))
+	binary.BigEndian.PutUint16(b[6:8], flags.marshal())
+	binary.BigEndian.PutUint64(b[8:16], nonce)
+
+	return b
+}
+
+// encode a node name as described at
+// https://tools.ietf.org/html/rfc4620#section-3.3, names without a trailing
+// dot are not fully qualified and end with two zero-length labels
+func encNodeName(n string) []byte {
+	b := encDomainNameLabels([]string{n})
+	if !strings.HasSuffix(n, ".") {
+		b = append(b, 0, 0)
+	}
+
+	return b
+}
+
+// decode node names as described at
+// https://tools.ietf.org/html/rfc4620#section-3.3, names ending with two
+// zero-length labels are not fully qualified and get no trailing dot
+func decNodeNames(b []byte) []string {
+	names := []string{}
+	labels := []string{}
+	for len(b) > 0 {
+		length := int(b[0])
+		// stop at labels running past the end of the data
+		if length+1 > len(b) {
+			break
+		}
+
+		if length > 0 {
+			labels = append(labels, string(b[1:(length+1)]))
+		} else if len(labels) > 0 {
+			name := strings.Join(labels, ".")
+			if len(b) > 1 && b[1] == 0 {
+				// skip the second zero-length label
+				b = b[1:]
+			} else {
+				name += "."
+			}
+
+			names = append(names, name)
+			labels = []string{}
+		}
+		b = b[(length + 1):]
+	}
+
+	return names
+}
+
+func parseNodeInformationQuery(b []byte) (*ICMPNodeInformationQuery, error) {
+	if len(b) < 16 {
+		return nil, errMessageTooShort
+	}
+
+	message := &ICMPNodeInformationQuery{
+		NodeInformationFlags: parseNodeInformationFlags(binary.BigEndian.Uint16(b[6:8])),
+		SubjectType:          NodeInformationSubjectType(b[1]),
+		Qtype:                NodeInformationQtype(binary.BigEndian.Uint16(b[4:6])),
+		Nonce:                binary.BigEndian.Uint64(b[8:16]),
+	}
+
+	data := b[16:]
+	switch message.SubjectType {
+	case NodeInformationSubjectIPv6:
+		if len(data) < 16 {
+			return nil, errMessageTooShort
+		}
+		message.SubjectAddress = net.IP(data[:16])
+	case NodeInformationSubjectIPv4:
+		if len(data) < 4 {
+			return nil, errMessageTooShort
+		}
+		message.SubjectAddress = net.IP(data[:4])
+	case NodeInformationSubjectName:
+		if names := decNodeNames(data); len(names) > 0 {
+			message.SubjectName = names[0]
+		}
+	default:
+		return nil, fmt.Errorf("node information query with code %d not supported", b[1])
+	}
+
+	return message, nil
+}
+
+func parseNodeInformationReply(b []byte) (*ICMPNodeInformationReply, error) {
+	if len(b) < 16 {
+		return nil, errMessageTooShort
+	}
+
+	message := &ICMPNodeInformationReply{
+		NodeInformationFlags: parseNodeInformationFlags(binary.BigEndian.Uint16(b[6:8])),
+		Code:                 NodeInformationReplyCode(b[1]),
+		Qtype:                NodeInformationQtype(binary.BigEndian.Uint16(b[4:6])),
+		Nonce:                binary.BigEndian.Uint64(b[8:16]),
+	}
+
+	if message.Code != NodeInformationReplySuccessful {
+		return message, nil
+	}
+
+	data := b[16:]
+	switch message.Qtype {
+	case NodeInformationQtypeNodeName:
+		if len(data) < 4 {
+			return nil, errMessageTooShort
+		}
+		message.TTL = binary.BigEndian.Uint32(data[0:4])
+		message.Names = decNodeNames(data[4:])
+	case NodeInformationQtypeNodeAddresses, NodeInformationQtypeIPv4Addresses:
+		l := 20
+		if message.Qtype == NodeInformationQtypeIPv4Addresses {
+			l = 8
+		}
+		if len(data)%l != 0 {
+			return nil, errMessageTooShort
+		}
+
+		for i := 0; i < len(data); i += l {
+			message.Addresses = append(message.Addresses, NodeAddress{
+				TTL:     binary.BigEndian.Uint32(data[i:(i + 4)]),
+				Address: net.IP(data[(i + 4):(i + l)]),
+			})
+		}
+	}
+
+	return message, nil
+}
@@ -0,0 +1,274 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPNodeInformationQuery(t *testing.T) {
+	icmp := &ICMPNodeInformationQuery{
+		NodeInformationFlags: NodeInformationFlags{Global: true, LinkLocal: true},
+		SubjectType:          NodeInformationSubjectIPv6,
+		Qtype:                NodeInformationQtypeNodeAddresses,
+		Nonce:                0x0102030405060708,
+		SubjectAddress:       net.ParseIP("fe80::1"),
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeNodeInformationQuery {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeNodeInformationQuery)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{139, 0, 0, 0, 0, 3, 0, 40, 1, 2, 3, 4, 5, 6, 7, 8, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "icmp node information query, length 32, node addresses, Flags [global link-local], nonce 0x0102030405060708, subject fe80::1"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:24])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	// name subject
+	icmp = &ICMPNodeInformationQuery{
+		SubjectType: NodeInformationSubjectName,
+		Qtype:       NodeInformationQtypeNodeName,
+		Nonce:       1,
+		SubjectName: "host.example.",
+	}
+
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{139, 1, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 4, 104, 111, 115, 116, 7, 101, 120, 97, 109, 112, 108, 101, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if parsedICMP.(*ICMPNodeInformationQuery).SubjectName != "host.example." {
+		t.Errorf("unexpected subject name '%s'", parsedICMP.(*ICMPNodeInformationQuery).SubjectName)
+	}
+
+	// single label subject not fully qualified
+	icmp.SubjectName = "host"
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{139, 1, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 4, 104, 111, 115, 116, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if parsedICMP.(*ICMPNodeInformationQuery).SubjectName != "host" {
+		t.Errorf("unexpected subject name '%s'", parsedICMP.(*ICMPNodeInformationQuery).SubjectName)
+	}
+
+	// single label subject fully qualified
+	parsedICMP, err = ParseMessage(fixture[:22])
+	if err != nil {
+		t.Error(err)
+	}
+
+	if parsedICMP.(*ICMPNodeInformationQuery).SubjectName != "host." {
+		t.Errorf("unexpected subject name '%s'", parsedICMP.(*ICMPNodeInformationQuery).SubjectName)
+	}
+
+	// ipv4 subject
+	icmp = &ICMPNodeInformationQuery{
+		SubjectType:    NodeInformationSubjectIPv4,
+		Qtype:          NodeInformationQtypeIPv4Addresses,
+		SubjectAddress: net.ParseIP("192.0.2.1"),
+	}
+
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{139, 2, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 192, 0, 2, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	icmp.SubjectAddress = net.ParseIP("fe80::1")
+	if _, err = icmp.Marshal(); err == nil {
+		t.Error("expected error for ipv6 address as ipv4 subject")
+	}
+}
+
+func TestICMPNodeInformationReply(t *testing.T) {
+	icmp := &ICMPNodeInformationReply{
+		Qtype: NodeInformationQtypeNodeName,
+		Nonce: 1,
+		TTL:   300,
+		Names: []string{"host.example."},
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeNodeInformationResponse {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeNodeInformationResponse)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{140, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 44, 4, 104, 111, 115, 116, 7, 101, 120, 97, 109, 112, 108, 101, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "icmp node information response, length 34, successful, node name, Flags [], nonce 0x0000000000000001, ttl 300s, name(s) host.example."
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// names fully qualified or not survive parsing
+	icmp.Names = []string{"host", "host.example."}
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedICMP, err = ParseMessage(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if names := parsedICMP.(*ICMPNodeInformationReply).Names; !reflect.DeepEqual(names, icmp.Names) {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	// addresses
+	icmp = &ICMPNodeInformationReply{
+		NodeInformationFlags: NodeInformationFlags{Truncated: true},
+		Qtype:                NodeInformationQtypeNodeAddresses,
+		Addresses:            []NodeAddress{{TTL: 60, Address: net.ParseIP("2001:db8::1")}},
+	}
+
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{140, 0, 0, 0, 0, 3, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 60, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix = "icmp node information response, length 36, successful, node addresses, Flags [truncated], nonce 0x0000000000000000, 2001:db8::1 ttl 60s"
+	desc = icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err = parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:30])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	// ipv4 addresses
+	icmp.Qtype = NodeInformationQtypeIPv4Addresses
+	icmp.Addresses = []NodeAddress{{TTL: 60, Address: net.ParseIP("192.0.2.1")}}
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{140, 0, 0, 0, 0, 4, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 60, 192, 0, 2, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !parsedICMP.(*ICMPNodeInformationReply).Addresses[0].Address.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("unexpected address %s", parsedICMP.(*ICMPNodeInformationReply).Addresses[0])
+	}
+
+	// refused replies carry no data
+	icmp.Code = NodeInformationReplyRefused
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(marshal) != 16 {
+		t.Errorf("expected length 16 for refused reply, got %d", len(marshal))
+	}
+}
@@ -0,0 +1,213 @@
+package ndp
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// maximum number of addresses in a Node Information Reply, so it fits in the
+// minimum IPv6 MTU
+const maxNodeInformationAddresses = (MinimumMTU - 40 - 8 - 16) / 20
+
+// NodeInformation describes the local node as answered by
+// NodeInformationResponder
+type NodeInformation struct {
+	// Name is the fully qualified domain name of the node
+	Name string
+	// TTL is the TTL returned with Name
+	TTL uint32
+	// Addresses are the IPv6 addresses of the node
+	Addresses []NodeAddress
+	// IPv4Addresses are the IPv4 addresses of the node
+	IPv4Addresses []NodeAddress
+}
+
+// NodeInformationResponder answers Node Information Queries about the local
+// node as described at https://tools.ietf.org/html/rfc4620#section-5
+type NodeInformationResponder struct {
+	conn Conn
+
+	mu   sync.Mutex
+	info NodeInformation
+}
+
+// NewNodeInformationResponder returns a NodeInformationResponder answering
+// queries received on given Conn from given NodeInformation
+func NewNodeInformationResponder(conn Conn, info NodeInformation) *NodeInformationResponder {
+	return &NodeInformationResponder{
+		conn: conn,
+		info: info,
+	}
+}
+
+// SetNodeInformation replaces the NodeInformation queries are answered from
+func (r *NodeInformationResponder) SetNodeInformation(info NodeInformation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.info = info
+}
+
+// Serve answers queries read from the Conn until reading fails
+func (r *NodeInformationResponder) Serve() error {
+	for {
+		m, src, _, err := r.conn.ReadFrom()
+		if err != nil {
+			return err
+		}
+
+		if err = r.HandleMessage(src, m); err != nil {
+			return err
+		}
+	}
+}
+
+// HandleMessage answers given message if it is a Node Information Query
+// about the local node, other messages are ignored
+func (r *NodeInformationResponder) HandleMessage(src net.IP, m ICMP) error {
+	q, ok := m.(*ICMPNodeInformationQuery)
+	if !ok {
+		return nil
+	}
+
+	reply := r.Reply(q)
+	if reply == nil {
+		return nil
+	}
+
+	return r.conn.WriteTo(reply, src)
+}
+
+// Reply returns the ICMPNodeInformationReply for given query or nil when
+// the query is not about the local node
+func (r *NodeInformationResponder) Reply(q *ICMPNodeInformationQuery) *ICMPNodeInformationReply {
+	r.mu.Lock()
+	info := r.info
+	r.mu.Unlock()
+
+	if !info.isSubject(q) {
+		return nil
+	}
+
+	reply := &ICMPNodeInformationReply{
+		Qtype: q.Qtype,
+		Nonce: q.Nonce,
+	}
+
+	switch q.Qtype {
+	case NodeInformationQtypeNOOP:
+	case NodeInformationQtypeNodeName:
+		reply.TTL = info.TTL
+		if info.Name != "" {
+			reply.Names = []string{info.Name}
+		}
+	case NodeInformationQtypeNodeAddresses:
+		reply.Addresses, reply.Truncated = info.selectAddresses(q.NodeInformationFlags)
+	case NodeInformationQtypeIPv4Addresses:
+		reply.Addresses = info.IPv4Addresses
+		if len(reply.Addresses) > maxNodeInformationAddresses {
+			reply.Addresses = reply.Addresses[:maxNodeInformationAddresses]
+			reply.Truncated = true
+		}
+	default:
+		reply.Code = NodeInformationReplyUnknownQtype
+	}
+
+	return reply
+}
+
+// isSubject returns true when given query is about the node described
+func (info NodeInformation) isSubject(q *ICMPNodeInformationQuery) bool {
+	switch q.SubjectType {
+	case NodeInformationSubjectIPv6:
+		for _, a := range info.Addresses {
+			if a.Address.Equal(q.SubjectAddress) {
+				return true
+			}
+		}
+	case NodeInformationSubjectIPv4:
+		for _, a := range info.IPv4Addresses {
+			if a.Address.Equal(q.SubjectAddress) {
+				return true
+			}
+		}
+	case NodeInformationSubjectName:
+		// NOOP queries may have an empty subject
+		if q.SubjectName == "" {
+			return q.Qtype == NodeInformationQtypeNOOP
+		}
+
+		name := strings.ToLower(strings.TrimSuffix(info.Name, "."))
+		subject := strings.ToLower(strings.TrimSuffix(q.SubjectName, "."))
+		if name == subject {
+			return true
+		}
+
+		// single label subjects not fully qualified match the first label
+		// of our name
+		fqdn := strings.HasSuffix(q.SubjectName, ".")
+		if !fqdn && !strings.Contains(subject, ".") && strings.SplitN(name, ".", 2)[0] == subject {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectAddresses returns the IPv6 addresses with a scope requested by given
+// flags, when no scope is requested all addresses are returned
+func (info NodeInformation) selectAddresses(flags NodeInformationFlags) ([]NodeAddress, bool) {
+	all := !flags.Global && !flags.SiteLocal && !flags.LinkLocal
+
+	addrs := []NodeAddress{}
+	for _, a := range info.Addresses {
+		ip := a.Address.To16()
+		switch {
+		case ip.To4() != nil || isIPv4Compatible(ip):
+			if !flags.Compatible {
+				continue
+			}
+		case ip.IsLinkLocalUnicast():
+			if !all && !flags.LinkLocal {
+				continue
+			}
+		case isSiteLocal(ip):
+			if !all && !flags.SiteLocal {
+				continue
+			}
+		default:
+			if !all && !flags.Global {
+				continue
+			}
+		}
+
+		addrs = append(addrs, a)
+	}
+
+	if len(addrs) > maxNodeInformationAddresses {
+		return addrs[:maxNodeInformationAddresses], true
+	}
+
+	return addrs, false
+}
+
+// return true for deprecated site-local addresses in fec0::/10
+func isSiteLocal(ip net.IP) bool {
+	return len(ip) == net.IPv6len && ip[0] == 0xfe && ip[1]&0xc0 == 0xc0
+}
+
+// return true for deprecated IPv4-compatible addresses in ::/96
+func isIPv4Compatible(ip net.IP) bool {
+	if len(ip) != net.IPv6len || ip.IsUnspecified() || ip.IsLoopback() {
+		return false
+	}
+
+	for _, b := range ip[:12] {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
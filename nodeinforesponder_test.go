@@ -0,0 +1,100 @@
+package ndp
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestNodeInformationResponder(t *testing.T) {
+	info := NodeInformation{
+		Name: "host.example.",
+		TTL:  300,
+		Addresses: []NodeAddress{
+			{TTL: 60, Address: net.ParseIP("fe80::1")},
+			{TTL: 60, Address: net.ParseIP("2001:db8::1")},
+			{TTL: 60, Address: net.ParseIP("::ffff:192.0.2.1")},
+		},
+		IPv4Addresses: []NodeAddress{
+			{TTL: 60, Address: net.ParseIP("192.0.2.1")},
+		},
+	}
+
+	local, remote := NewPipe(net.ParseIP("fe80::1"), net.ParseIP("fe80::2"))
+	defer local.Close()
+	responder := NewNodeInformationResponder(local, info)
+	go responder.Serve()
+
+	// node name by address
+	query := &ICMPNodeInformationQuery{
+		SubjectType:    NodeInformationSubjectIPv6,
+		Qtype:          NodeInformationQtypeNodeName,
+		Nonce:          42,
+		SubjectAddress: net.ParseIP("2001:db8::1"),
+	}
+	if err := remote.WriteTo(query, net.ParseIP("fe80::1")); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _, _, err := remote.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := m.(*ICMPNodeInformationReply)
+	if reply.Nonce != 42 || reply.TTL != 300 || !reflect.DeepEqual(reply.Names, []string{"host.example."}) {
+		t.Errorf("unexpected reply %s", reply)
+	}
+
+	tests := []struct {
+		query     *ICMPNodeInformationQuery
+		code      NodeInformationReplyCode
+		addresses []string
+	}{
+		// link-local addresses only
+		{&ICMPNodeInformationQuery{SubjectType: NodeInformationSubjectName, SubjectName: "host", Qtype: NodeInformationQtypeNodeAddresses, NodeInformationFlags: NodeInformationFlags{LinkLocal: true}}, NodeInformationReplySuccessful, []string{"fe80::1"}},
+		// all scopes without compatible addresses
+		{&ICMPNodeInformationQuery{SubjectType: NodeInformationSubjectName, SubjectName: "HOST.example", Qtype: NodeInformationQtypeNodeAddresses}, NodeInformationReplySuccessful, []string{"fe80::1", "2001:db8::1"}},
+		// global and compatible addresses
+		{&ICMPNodeInformationQuery{SubjectType: NodeInformationSubjectIPv6, SubjectAddress: net.ParseIP("fe80::1"), Qtype: NodeInformationQtypeNodeAddresses, NodeInformationFlags: NodeInformationFlags{Global: true, Compatible: true}}, NodeInformationReplySuccessful, []string{"2001:db8::1", "192.0.2.1"}},
+		// ipv4 addresses
+		{&ICMPNodeInformationQuery{SubjectType: NodeInformationSubjectIPv4, SubjectAddress: net.ParseIP("192.0.2.1"), Qtype: NodeInformationQtypeIPv4Addresses}, NodeInformationReplySuccessful, []string{"192.0.2.1"}},
+		// noop with empty subject
+		{&ICMPNodeInformationQuery{SubjectType: NodeInformationSubjectName, Qtype: NodeInformationQtypeNOOP}, NodeInformationReplySuccessful, []string{}},
+		// unknown qtype
+		{&ICMPNodeInformationQuery{SubjectType: NodeInformationSubjectIPv6, SubjectAddress: net.ParseIP("fe80::1"), Qtype: 1}, NodeInformationReplyUnknownQtype, []string{}},
+	}
+
+	for _, test := range tests {
+		reply := responder.Reply(test.query)
+		if reply == nil {
+			t.Errorf("no reply for %s", test.query)
+			continue
+		}
+
+		if reply.Code != test.code {
+			t.Errorf("unexpected code %s for %s", reply.Code, test.query)
+		}
+
+		addresses := []string{}
+		for _, a := range reply.Addresses {
+			addresses = append(addresses, a.Address.String())
+		}
+		if !reflect.DeepEqual(addresses, test.addresses) {
+			t.Errorf("expected addresses %v for %s, got %v", test.addresses, test.query, addresses)
+		}
+	}
+
+	// queries about other nodes are not answered
+	others := []*ICMPNodeInformationQuery{
+		{SubjectType: NodeInformationSubjectIPv6, SubjectAddress: net.ParseIP("fe80::3"), Qtype: NodeInformationQtypeNodeName},
+		{SubjectType: NodeInformationSubjectName, SubjectName: "other.example.", Qtype: NodeInformationQtypeNodeName},
+		{SubjectType: NodeInformationSubjectName, SubjectName: "host.", Qtype: NodeInformationQtypeNodeName},
+		{SubjectType: NodeInformationSubjectName, Qtype: NodeInformationQtypeNodeName},
+	}
+	for _, q := range others {
+		if reply := responder.Reply(q); reply != nil {
+			t.Errorf("unexpected reply %s to %s", reply, q)
+		}
+	}
+}
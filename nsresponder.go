@@ -0,0 +1,382 @@
+package ndp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// nsResponderValidTimeout is how long a target that answered a probe is
+	// answered for without probing it again, as long as REACHABLE_TIME
+	// described at https://tools.ietf.org/html/rfc4861#section-10
+	nsResponderValidTimeout = 30 * time.Second
+	// nsResponderProbeTimeout is how long a probe waits for the target to
+	// answer, MAX_MULTICAST_SOLICIT times RETRANS_TIMER, and how long an
+	// unreachable target is not probed again
+	nsResponderProbeTimeout = 3 * time.Second
+)
+
+// NSResponderMode tells when an NSResponderRule answers solicitations
+type NSResponderMode uint8
+
+// modes currently defined
+const (
+	// NSResponderAlways answers for every target in the prefix
+	NSResponderAlways NSResponderMode = iota
+	// NSResponderReachable answers for targets that answer a solicitation
+	// of our own on the downstream interface
+	NSResponderReachable
+	// NSResponderStatic answers for the configured addresses only
+	NSResponderStatic
+)
+
+func (m NSResponderMode) String() string {
+	switch m {
+	case NSResponderAlways:
+		return "always"
+	case NSResponderReachable:
+		return "reachable"
+	case NSResponderStatic:
+		return "static"
+	}
+
+	return "<nil>"
+}
+
+// NSResponderRule describes for which targets NSResponder answers
+type NSResponderRule struct {
+	Prefix *net.IPNet
+	Mode   NSResponderMode
+	// Router sets the R flag in answers, for when we forward the traffic
+	// for the prefix ourselves
+	Router bool
+	// Downstream is the interface targets are probed on in
+	// NSResponderReachable mode
+	Downstream Conn
+	// DownstreamLinkLayerAddress is included in the probes, if set
+	DownstreamLinkLayerAddress net.HardwareAddr
+	// Addresses are the targets answered for in NSResponderStatic mode
+	Addresses []net.IP
+}
+
+func (r NSResponderRule) String() string {
+	return fmt.Sprintf("%s %s", r.Prefix, r.Mode)
+}
+
+// matches returns true if this rule answers for given target, not taking
+// reachability into account
+func (r NSResponderRule) matches(target net.IP) bool {
+	if !r.Prefix.Contains(target) {
+		return false
+	}
+
+	if r.Mode != NSResponderStatic {
+		return true
+	}
+
+	for _, a := range r.Addresses {
+		if a.Equal(target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NSResponder answers neighbor solicitations for addresses in prefixes routed
+// to us, in the way ndppd does, so a router on the upstream link delivers
+// traffic for them to us. Rules are tried in order and the first one with a
+// matching prefix decides about the answer.
+type NSResponder struct {
+	conn  Conn
+	lla   net.HardwareAddr
+	rules []NSResponderRule
+
+	mu       sync.Mutex
+	sessions map[string]*nsResponderSession
+}
+
+type nsResponderState uint8
+
+const (
+	nsResponderWaiting nsResponderState = iota
+	nsResponderValid
+	nsResponderInvalid
+)
+
+// nsResponderSession tracks the reachability of a target probed for a
+// NSResponderReachable rule
+type nsResponderSession struct {
+	rule    int
+	state   nsResponderState
+	expires time.Time
+	// waiting holds the sources of solicitations to answer once the target
+	// answers
+	waiting []net.IP
+}
+
+// nsResponderSend is a message to send on a Conn
+type nsResponderSend struct {
+	conn Conn
+	dst  net.IP
+	m    ICMP
+}
+
+// NewNSResponder returns an NSResponder answering solicitations received on
+// given Conn with given link-layer address, according to given rules. Conns
+// need to send neighbor discovery with hop limit 255, like the ones returned
+// by ListenICMP, or the answers are dropped.
+func NewNSResponder(conn Conn, lla net.HardwareAddr, rules ...NSResponderRule) (*NSResponder, error) {
+	if conn == nil || len(lla) == 0 {
+		return nil, errors.New("a conn and link-layer address are needed to answer with")
+	}
+
+	for _, rule := range rules {
+		if rule.Prefix == nil {
+			return nil, errors.New("rule without prefix")
+		}
+
+		if rule.Mode > NSResponderStatic {
+			return nil, fmt.Errorf("rule %s has unknown mode %d", rule.Prefix, rule.Mode)
+		}
+
+		if rule.Mode == NSResponderReachable && rule.Downstream == nil {
+			return nil, fmt.Errorf("rule %s needs a downstream conn", rule)
+		}
+	}
+
+	return &NSResponder{
+		conn:     conn,
+		lla:      lla,
+		rules:    rules,
+		sessions: make(map[string]*nsResponderSession),
+	}, nil
+}
+
+// Serve answers solicitations read from the Conn and handles answers to our
+// probes read from the downstream Conns, until reading or writing fails on
+// any of them. Closing the Conns stops it.
+func (r *NSResponder) Serve() error {
+	conns := []Conn{r.conn}
+	for _, rule := range r.rules {
+		if rule.Mode == NSResponderReachable && !containsConn(conns, rule.Downstream) {
+			conns = append(conns, rule.Downstream)
+		}
+	}
+
+	errs := make(chan error, len(conns))
+	for i, conn := range conns {
+		go func(upstream bool, conn Conn) {
+			for {
+				m, src, hopLimit, err := conn.ReadFrom()
+				if err == nil {
+					if upstream {
+						err = r.HandleMessage(src, m, hopLimit, time.Now())
+					} else {
+						err = r.HandleProbeReply(m, hopLimit, time.Now())
+					}
+				}
+
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i == 0, conn)
+	}
+
+	return <-errs
+}
+
+func containsConn(conns []Conn, conn Conn) bool {
+	for _, c := range conns {
+		if c == conn {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HandleMessage answers given message received from given source address
+// with given hop limit if it is a neighbor solicitation for a target matching
+// the rules, or probes the target first when the rule asks for that.
+// Solicitations not received with hop limit 255 and other messages are
+// ignored.
+func (r *NSResponder) HandleMessage(src net.IP, m ICMP, hopLimit int, now time.Time) error {
+	ns, ok := m.(*ICMPNeighborSolicitation)
+	if !ok || !validHopLimit(m, hopLimit) || ns.TargetAddress.To16() == nil || ns.TargetAddress.IsMulticast() {
+		return nil
+	}
+
+	r.mu.Lock()
+	sends := r.solicit(src, ns.TargetAddress, now)
+	r.mu.Unlock()
+
+	return r.send(sends)
+}
+
+// HandleProbeReply answers the solicitations waiting for the target of given
+// message, if it is a neighbor advertisement received on a downstream
+// interface with hop limit 255. Other messages are ignored.
+func (r *NSResponder) HandleProbeReply(m ICMP, hopLimit int, now time.Time) error {
+	na, ok := m.(*ICMPNeighborAdvertisement)
+	if !ok || !validHopLimit(m, hopLimit) {
+		return nil
+	}
+
+	r.mu.Lock()
+	sends := r.reachable(na.TargetAddress, now)
+	r.mu.Unlock()
+
+	return r.send(sends)
+}
+
+// Reachable returns true if given target answered a probe recently
+func (r *NSResponder) Reachable(target net.IP, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.session(target, now)
+
+	return s != nil && s.state == nsResponderValid
+}
+
+// Expire removes the probe results that timed out at given time
+func (r *NSResponder) Expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for target := range r.sessions {
+		r.session(net.ParseIP(target), now)
+	}
+}
+
+func (r *NSResponder) send(sends []nsResponderSend) error {
+	for _, s := range sends {
+		if err := s.conn.WriteTo(s.m, s.dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *NSResponder) solicit(src, target net.IP, now time.Time) []nsResponderSend {
+	for i, rule := range r.rules {
+		if !rule.Prefix.Contains(target) {
+			continue
+		}
+
+		if !rule.matches(target) {
+			return nil
+		}
+
+		if rule.Mode != NSResponderReachable {
+			return []nsResponderSend{r.answer(rule, src, target)}
+		}
+
+		s := r.session(target, now)
+		if s == nil {
+			// probe the target, the answer is sent when it responds
+			r.sessions[target.String()] = &nsResponderSession{
+				rule:    i,
+				state:   nsResponderWaiting,
+				expires: now.Add(nsResponderProbeTimeout),
+				waiting: []net.IP{src},
+			}
+
+			return []nsResponderSend{r.probe(rule, target)}
+		}
+
+		switch s.state {
+		case nsResponderWaiting:
+			s.waiting = append(s.waiting, src)
+		case nsResponderValid:
+			return []nsResponderSend{r.answer(rule, src, target)}
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func (r *NSResponder) reachable(target net.IP, now time.Time) []nsResponderSend {
+	s := r.session(target, now)
+	if s == nil {
+		return nil
+	}
+
+	rule := r.rules[s.rule]
+	sends := []nsResponderSend{}
+	for _, src := range s.waiting {
+		sends = append(sends, r.answer(rule, src, target))
+	}
+
+	s.state = nsResponderValid
+	s.expires = now.Add(nsResponderValidTimeout)
+	s.waiting = nil
+
+	return sends
+}
+
+// session returns the current session for given target, moving it along
+// when it timed out
+func (r *NSResponder) session(target net.IP, now time.Time) *nsResponderSession {
+	key := target.String()
+	s, ok := r.sessions[key]
+	if !ok || now.Before(s.expires) {
+		return s
+	}
+
+	// targets that didn't answer aren't probed for a while
+	if s.state == nsResponderWaiting {
+		s.state = nsResponderInvalid
+		s.expires = s.expires.Add(nsResponderProbeTimeout)
+		s.waiting = nil
+		if now.Before(s.expires) {
+			return s
+		}
+	}
+
+	delete(r.sessions, key)
+
+	return nil
+}
+
+// answer returns the advertisement for given target to send to given source
+// of the solicitation
+func (r *NSResponder) answer(rule NSResponderRule, src, target net.IP) nsResponderSend {
+	// proxies shouldn't override the cache entries of the target itself as
+	// described at https://tools.ietf.org/html/rfc4861#section-7.2.8
+	na := &ICMPNeighborAdvertisement{
+		Router:        rule.Router,
+		Solicited:     true,
+		TargetAddress: target,
+	}
+	na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: r.lla})
+
+	// answers to duplicate address detection go to all nodes
+	dst := src
+	if src.IsUnspecified() {
+		na.Solicited = false
+		dst = allNodesMulticast
+	}
+
+	return nsResponderSend{r.conn, dst, na}
+}
+
+// probe returns our own solicitation for given target on the downstream
+// interface of given rule
+func (r *NSResponder) probe(rule NSResponderRule, target net.IP) nsResponderSend {
+	ns := &ICMPNeighborSolicitation{TargetAddress: target}
+	if len(rule.DownstreamLinkLayerAddress) > 0 {
+		ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: rule.DownstreamLinkLayerAddress})
+	}
+
+	return nsResponderSend{rule.Downstream, SolicitedNodeMulticast(target), ns}
+}
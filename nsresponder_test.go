@@ -0,0 +1,213 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNSResponderModeString(t *testing.T) {
+	tests := []struct {
+		m NSResponderMode
+		s string
+	}{
+		{NSResponderAlways, "always"},
+		{NSResponderReachable, "reachable"},
+		{NSResponderStatic, "static"},
+		{NSResponderMode(3), "<nil>"},
+	}
+
+	for _, test := range tests {
+		if test.m.String() != test.s {
+			t.Errorf("unexpected string for mode %d: %s", test.m, test.m)
+		}
+	}
+}
+
+func TestNSResponder(t *testing.T) {
+	upstream, downstream := &recordingConn{}, &recordingConn{}
+	lla := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	_, static, _ := net.ParseCIDR("2001:db8:0:1::/64")
+	_, reachable, _ := net.ParseCIDR("2001:db8:0:2::/64")
+	_, always, _ := net.ParseCIDR("2001:db8::/48")
+	responder, err := NewNSResponder(upstream, lla,
+		NSResponderRule{Prefix: static, Mode: NSResponderStatic, Addresses: []net.IP{net.ParseIP("2001:db8:0:1::1")}},
+		NSResponderRule{Prefix: reachable, Mode: NSResponderReachable, Router: true, Downstream: downstream, DownstreamLinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 2}},
+		NSResponderRule{Prefix: always, Mode: NSResponderAlways},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := net.ParseIP("fe80::1")
+	now := time.Unix(0, 0)
+	solicit := func(target string) []sentMessage {
+		if err := responder.HandleMessage(router, &ICMPNeighborSolicitation{TargetAddress: net.ParseIP(target)}, ndHopLimit, now); err != nil {
+			t.Fatal(err)
+		}
+
+		return upstream.flush()
+	}
+
+	answered := func(sent []sentMessage, target string) bool {
+		if len(sent) != 1 || !sent[0].dst.Equal(router) {
+			return false
+		}
+
+		na := sent[0].message.(*ICMPNeighborAdvertisement)
+		if !na.TargetAddress.Equal(net.ParseIP(target)) || !na.Solicited || na.Override {
+			return false
+		}
+
+		tlla, err := na.GetOption(ICMPOptionTypeTargetLinkLayerAddress)
+
+		return err == nil && bytes.Equal((*tlla).(*ICMPOptionTargetLinkLayerAddress).LinkLayerAddress, lla)
+	}
+
+	// static addresses
+	if sent := solicit("2001:db8:0:1::1"); !answered(sent, "2001:db8:0:1::1") {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// the first matching rule decides
+	if sent := solicit("2001:db8:0:1::2"); len(sent) != 0 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// always
+	if sent := solicit("2001:db8:0:3::1"); !answered(sent, "2001:db8:0:3::1") {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// solicitations forwarded from off-link are ignored
+	if err = responder.HandleMessage(router, &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8:0:3::1")}, 64, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := upstream.flush(); len(sent) != 0 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// outside of any prefix
+	if sent := solicit("2001:db9::1"); len(sent) != 0 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// reachable targets are probed first
+	target := "2001:db8:0:2::1"
+	if sent := solicit(target); len(sent) != 0 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	probes := downstream.flush()
+	if len(probes) != 1 || !probes[0].dst.Equal(SolicitedNodeMulticast(net.ParseIP(target))) {
+		t.Fatalf("unexpected probes %v", probes)
+	}
+
+	if !probes[0].message.(*ICMPNeighborSolicitation).HasOption(ICMPOptionTypeSourceLinkLayerAddress) {
+		t.Errorf("expected source link-layer address in probe %s", probes[0].message)
+	}
+
+	// retransmissions don't probe again
+	if sent := solicit(target); len(sent) != 0 || len(downstream.flush()) != 0 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// answers forwarded from off-link are ignored
+	if err = responder.HandleProbeReply(&ICMPNeighborAdvertisement{Solicited: true, TargetAddress: net.ParseIP(target)}, 64, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := upstream.flush(); len(sent) != 0 || responder.Reachable(net.ParseIP(target), now) {
+		t.Errorf("unexpected answers %v", sent)
+	}
+
+	// the answer to the probe answers both solicitations
+	if err = responder.HandleProbeReply(&ICMPNeighborAdvertisement{Solicited: true, TargetAddress: net.ParseIP(target)}, ndHopLimit, now); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := upstream.flush()
+	if len(sent) != 2 || !answered(sent[:1], target) || !sent[0].message.(*ICMPNeighborAdvertisement).Router {
+		t.Errorf("unexpected answers %v", sent)
+	}
+
+	if !responder.Reachable(net.ParseIP(target), now) {
+		t.Errorf("expected %s to be reachable", target)
+	}
+
+	// now it is answered right away
+	if sent := solicit(target); !answered(sent, target) {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// until it needs to be probed again
+	now = now.Add(nsResponderValidTimeout)
+	if sent := solicit(target); len(sent) != 0 || len(downstream.flush()) != 1 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// unreachable targets aren't probed for a while
+	now = now.Add(nsResponderProbeTimeout)
+	if sent := solicit(target); len(sent) != 0 || len(downstream.flush()) != 0 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	if responder.Reachable(net.ParseIP(target), now) {
+		t.Errorf("expected %s to be unreachable", target)
+	}
+
+	// unsolicited replies don't make targets reachable
+	now = now.Add(nsResponderProbeTimeout)
+	responder.Expire(now)
+	if err = responder.HandleProbeReply(&ICMPNeighborAdvertisement{TargetAddress: net.ParseIP(target)}, ndHopLimit, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if responder.Reachable(net.ParseIP(target), now) {
+		t.Errorf("expected %s to be unknown", target)
+	}
+
+	if sent := solicit(target); len(sent) != 0 || len(downstream.flush()) != 1 {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// duplicate address detection is answered to all nodes
+	if err = responder.HandleMessage(net.IPv6unspecified, &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8::1")}, ndHopLimit, now); err != nil {
+		t.Fatal(err)
+	}
+
+	sent = upstream.flush()
+	if len(sent) != 1 || !sent[0].dst.Equal(allNodesMulticast) || sent[0].message.(*ICMPNeighborAdvertisement).Solicited {
+		t.Errorf("unexpected answer %v", sent)
+	}
+
+	// other messages are ignored
+	if err = responder.HandleMessage(router, &ICMPRouterSolicitation{}, ndHopLimit, now); err != nil || len(upstream.flush()) != 0 {
+		t.Error("unexpected answer to router solicitation")
+	}
+}
+
+func TestNewNSResponder(t *testing.T) {
+	conn := &recordingConn{}
+	lla := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	tests := []struct {
+		conn  Conn
+		lla   net.HardwareAddr
+		rules []NSResponderRule
+	}{
+		{nil, lla, nil},
+		{conn, nil, nil},
+		{conn, lla, []NSResponderRule{{Mode: NSResponderAlways}}},
+		{conn, lla, []NSResponderRule{{Prefix: prefix, Mode: NSResponderMode(3)}}},
+		{conn, lla, []NSResponderRule{{Prefix: prefix, Mode: NSResponderReachable}}},
+	}
+
+	for _, test := range tests {
+		if _, err := NewNSResponder(test.conn, test.lla, test.rules...); err == nil {
+			t.Errorf("expected error for %v", test.rules)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	errNotIPv6         = errors.New("not an IPv6 packet")
+	errPacketTruncated = errors.New("packet truncated")
+	errFragmentedND    = errors.New("fragmented neighbor discovery message")
+)
+
+// Packet is a decoded IPv6 packet as received from the wire, including the
+// ICMPv6 message it carries. Extension headers are walked to find the
+// message, as they can be used to hide neighbor discovery from RA Guard as
+// described at https://tools.ietf.org/html/rfc7113
+type Packet struct {
+	InvokingPacket
+	// Fragmented is true when the packet has a fragment header, even when it
+	// is an atomic fragment
+	Fragmented bool
+	// FragmentOffset is the offset of this fragment in octets
+	FragmentOffset uint16
+	MoreFragments  bool
+	// IncompleteHeaderChain flags a first fragment that doesn't hold the
+	// upper-layer header, which should be dropped as described at
+	// https://tools.ietf.org/html/rfc7112#section-5
+	IncompleteHeaderChain bool
+	// Message is the ICMPv6 message in the packet, nil for other protocols
+	// and for fragments of a message
+	Message ICMP
+}
+
+func (p Packet) String() string {
+	s := p.InvokingPacket.String()
+	if p.Fragmented {
+		s += fmt.Sprintf(", fragment offset %d", p.FragmentOffset)
+		if p.MoreFragments {
+			s += ", more fragments"
+		}
+	}
+
+	if p.IncompleteHeaderChain {
+		s += ", incomplete header chain"
+	}
+
+	if p.Message != nil {
+		s += fmt.Sprintf("\n%s", p.Message)
+	}
+
+	return s
+}
+
+// ParsePacket decodes given IPv6 packet, walking its extension header chain
+// up to the upper-layer header and parsing the ICMPv6 message found there
+// with ParseMessage. Neighbor discovery messages in fragmented packets are
+// rejected as described at https://tools.ietf.org/html/rfc6980#section-5,
+// although for fragments other than the first that can't be known.
+func ParsePacket(b []byte) (*Packet, error) {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return nil, errNotIPv6
+	}
+
+	// ignore anything after the payload, like link-layer padding, jumbograms
+	// are not supported
+	l := 40 + int(binary.BigEndian.Uint16(b[4:6]))
+	if len(b) < l {
+		return nil, errPacketTruncated
+	}
+
+	invoking := ParseInvokingPacket(b[:l])
+	p := &Packet{InvokingPacket: *invoking}
+
+	// find the fragment header
+	offset := 40
+	for _, h := range p.ExtensionHeaders {
+		if h.Type == protocolFragment {
+			field := binary.BigEndian.Uint16(b[offset+2 : offset+4])
+			p.Fragmented = true
+			p.FragmentOffset = field & 0xfff8
+			p.MoreFragments = field&0x0001 != 0
+			break
+		}
+
+		offset += h.Length
+	}
+
+	if !p.Complete {
+		// the upper-layer header is in another fragment
+		if p.Fragmented && p.FragmentOffset > 0 {
+			return p, nil
+		}
+
+		if p.Fragmented {
+			p.IncompleteHeaderChain = true
+			return p, nil
+		}
+
+		return nil, errPacketTruncated
+	}
+
+	if p.Protocol != protocolICMPv6 {
+		return p, nil
+	}
+
+	if p.Fragmented && len(p.Payload) > 0 && fragmentForbidden(ipv6.ICMPType(p.Payload[0])) {
+		return nil, errFragmentedND
+	}
+
+	// the rest of the message is in other fragments
+	if p.MoreFragments {
+		return p, nil
+	}
+
+	m, err := ParseMessage(p.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Message = m
+
+	return p, nil
+}
+
+// fragmentForbidden returns true for neighbor discovery messages that are
+// never fragmented, the certification path advertisement is left out as it
+// might not fit in a single packet
+func fragmentForbidden(t ipv6.ICMPType) bool {
+	switch t {
+	case ipv6.ICMPTypeRouterSolicitation, ipv6.ICMPTypeRouterAdvertisement,
+		ipv6.ICMPTypeNeighborSolicitation, ipv6.ICMPTypeNeighborAdvertisement,
+		ipv6.ICMPTypeRedirect, ipv6.ICMPTypeCertificationPathSolicitation:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,146 @@
+package ndp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePacket(t *testing.T) {
+	ra, err := (&ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	echo, err := (&ICMPEchoRequest{Identifier: 1, SequenceNumber: 2}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plain router advertisement, with link-layer padding
+	pkt := testIPv6Header("fe80::1", "ff02::1", protocolICMPv6, len(ra))
+	pkt = append(pkt, ra...)
+	p, err := ParsePacket(append(pkt, 0, 0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.Message.(*ICMPRouterAdvertisement); !ok || p.Fragmented || len(p.ExtensionHeaders) != 0 {
+		t.Errorf("unexpected packet %s", p)
+	}
+
+	// hidden behind hop-by-hop and destination options
+	pkt = testIPv6Header("fe80::1", "ff02::1", protocolHopByHop, 24+len(ra))
+	pkt = append(pkt, protocolDestination, 0, 1, 4, 0, 0, 0, 0)
+	pkt = append(pkt, protocolICMPv6, 1, 1, 12, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	pkt = append(pkt, ra...)
+	p, err = ParsePacket(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.Message.(*ICMPRouterAdvertisement); !ok || len(p.ExtensionHeaders) != 2 {
+		t.Errorf("unexpected packet %s", p)
+	}
+
+	descfix := "fe80::1 > ff02::1, ext 0 (8), ext 60 (16), router advertisement\n" + p.Message.String()
+	if strings.Compare(p.String(), descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, p)
+	}
+
+	// neighbor discovery is never fragmented, not even in atomic fragments
+	for _, more := range []byte{0, 1} {
+		pkt = testIPv6Header("fe80::1", "ff02::1", protocolFragment, 8+len(ra))
+		pkt = append(pkt, protocolICMPv6, 0, 0, more, 0, 0, 0, 1)
+		pkt = append(pkt, ra...)
+		if _, err = ParsePacket(pkt); err != errFragmentedND {
+			t.Errorf("expected %s, got %v", errFragmentedND, err)
+		}
+	}
+
+	// other messages can be
+	pkt = testIPv6Header("2001:db8::1", "2001:db8::2", protocolFragment, 8+len(echo))
+	pkt = append(pkt, protocolICMPv6, 0, 0, 0, 0, 0, 0, 1)
+	pkt = append(pkt, echo...)
+	p, err = ParsePacket(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.Message.(*ICMPEchoRequest); !ok || !p.Fragmented || p.MoreFragments {
+		t.Errorf("unexpected packet %s", p)
+	}
+
+	// but only parsed when whole
+	pkt[40+3] = 1
+	p, err = ParsePacket(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Message != nil || !p.MoreFragments || p.IncompleteHeaderChain {
+		t.Errorf("unexpected packet %s", p)
+	}
+
+	descfix = "2001:db8::1 > 2001:db8::2, ext 44 (8), echo request id 1, seq 2, fragment offset 0, more fragments"
+	if strings.Compare(p.String(), descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, p)
+	}
+
+	// first fragment without upper-layer header
+	pkt = testIPv6Header("fe80::1", "ff02::1", protocolFragment, 16)
+	pkt = append(pkt, protocolDestination, 0, 0, 1, 0, 0, 0, 1)
+	pkt = append(pkt, protocolICMPv6, 2, 1, 4, 0, 0, 0, 0)
+	p, err = ParsePacket(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.IncompleteHeaderChain || p.Message != nil {
+		t.Errorf("unexpected packet %s", p)
+	}
+
+	// other fragments don't have it either
+	pkt = testIPv6Header("fe80::1", "ff02::1", protocolFragment, 16)
+	pkt = append(pkt, protocolICMPv6, 0, 0, 16, 0, 0, 0, 1)
+	pkt = append(pkt, 0, 0, 0, 0, 0, 0, 0, 0)
+	p, err = ParsePacket(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.IncompleteHeaderChain || p.Message != nil || p.FragmentOffset != 16 {
+		t.Errorf("unexpected packet %s", p)
+	}
+
+	// other protocols
+	pkt = testIPv6Header("2001:db8::1", "2001:db8::2", protocolUDP, 8)
+	pkt = append(pkt, 0x30, 0x39, 0, 53, 0, 8, 0, 0)
+	p, err = ParsePacket(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Message != nil || p.Protocol != protocolUDP {
+		t.Errorf("unexpected packet %s", p)
+	}
+
+	// truncated
+	pkt = testIPv6Header("fe80::1", "ff02::1", protocolHopByHop, 8)
+	pkt = append(pkt, protocolICMPv6, 1, 0, 0, 0, 0, 0, 0)
+	if _, err = ParsePacket(pkt); err != errPacketTruncated {
+		t.Errorf("expected %s, got %v", errPacketTruncated, err)
+	}
+
+	if _, err = ParsePacket(pkt[:47]); err != errPacketTruncated {
+		t.Errorf("expected %s, got %v", errPacketTruncated, err)
+	}
+
+	pkt = testIPv6Header("fe80::1", "ff02::1", protocolICMPv6, 2)
+	if _, err = ParsePacket(append(pkt, 134, 0)); err != errMessageTooShort {
+		t.Errorf("expected %s, got %v", errMessageTooShort, err)
+	}
+
+	if _, err = ParsePacket(pkt[:39]); err != errNotIPv6 {
+		t.Errorf("expected %s, got %v", errNotIPv6, err)
+	}
+}
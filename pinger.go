@@ -0,0 +1,233 @@
+package ndp
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// PingStatistics holds the results of Pinger.Ping
+type PingStatistics struct {
+	Address  net.IP
+	Sent     int
+	Received int
+	RTTs     []time.Duration
+}
+
+// Loss returns the percentage of Echo Requests that went unanswered
+func (s PingStatistics) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+
+	return float64(s.Sent-s.Received) / float64(s.Sent) * 100
+}
+
+// MinRTT returns the shortest round-trip time measured
+func (s PingStatistics) MinRTT() time.Duration {
+	var min time.Duration
+	for i, rtt := range s.RTTs {
+		if i == 0 || rtt < min {
+			min = rtt
+		}
+	}
+
+	return min
+}
+
+// MaxRTT returns the longest round-trip time measured
+func (s PingStatistics) MaxRTT() time.Duration {
+	var max time.Duration
+	for _, rtt := range s.RTTs {
+		if rtt > max {
+			max = rtt
+		}
+	}
+
+	return max
+}
+
+// AvgRTT returns the average round-trip time measured
+func (s PingStatistics) AvgRTT() time.Duration {
+	if len(s.RTTs) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, rtt := range s.RTTs {
+		total += rtt
+	}
+
+	return total / time.Duration(len(s.RTTs))
+}
+
+func (s PingStatistics) String() string {
+	str := fmt.Sprintf("%s: %d packets transmitted, %d received, ", s.Address, s.Sent, s.Received)
+	str += fmt.Sprintf("%.1f%% packet loss", s.Loss())
+	if len(s.RTTs) > 0 {
+		str += fmt.Sprintf(", rtt min/avg/max %s/%s/%s", s.MinRTT(), s.AvgRTT(), s.MaxRTT())
+	}
+
+	return str
+}
+
+type pendingEcho struct {
+	identifier uint16
+	dst        net.IP
+	data       []byte
+	reply      chan time.Time
+}
+
+// Pinger sends Echo Requests over a Conn and matches the Echo Replies it
+// receives to them on identifier, sequence number, source address and data
+type Pinger struct {
+	// Identifier is set in all Echo Requests sent, defaults to a random value
+	Identifier uint16
+	// Interval is the time between sending Echo Requests, defaults to 1s
+	Interval time.Duration
+	// Timeout is the time to wait for an Echo Reply, defaults to 1s
+	Timeout time.Duration
+	// Size is the number of data bytes in each Echo Request, defaults to 56
+	Size int
+
+	conn    Conn
+	mu      sync.Mutex
+	seq     uint16
+	pending map[uint16]*pendingEcho
+}
+
+// NewPinger returns a Pinger using given Conn, which it reads from until the
+// Pinger is closed
+func NewPinger(conn Conn) *Pinger {
+	p := &Pinger{
+		Identifier: uint16(rand.Uint32()),
+		Interval:   time.Second,
+		Timeout:    time.Second,
+		Size:       56,
+		conn:       conn,
+		pending:    make(map[uint16]*pendingEcho),
+	}
+
+	go p.receive()
+
+	return p
+}
+
+// Ping sends count Echo Requests to given destination and returns the
+// statistics on the replies received
+func (p *Pinger) Ping(dst net.IP, count int) (*PingStatistics, error) {
+	stats := &PingStatistics{
+		Address: dst,
+	}
+
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		rtt, err := p.ping(dst)
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Sent++
+		if rtt > 0 {
+			stats.Received++
+			stats.RTTs = append(stats.RTTs, rtt)
+		}
+
+		if i < count-1 {
+			time.Sleep(p.Interval - time.Since(start))
+		}
+	}
+
+	return stats, nil
+}
+
+// Close closes the underlying Conn
+func (p *Pinger) Close() error {
+	return p.conn.Close()
+}
+
+// send a single Echo Request and return the round-trip time of its reply or
+// 0 if there was none
+func (p *Pinger) ping(dst net.IP) (time.Duration, error) {
+	req := &ICMPEchoRequest{
+		Identifier: p.Identifier,
+		Data:       make([]byte, p.Size),
+	}
+	for i := range req.Data {
+		req.Data[i] = byte(i)
+	}
+
+	pe := &pendingEcho{
+		identifier: req.Identifier,
+		dst:        dst,
+		data:       req.Data,
+		reply:      make(chan time.Time, 1),
+	}
+
+	p.mu.Lock()
+	p.seq++
+	req.SequenceNumber = p.seq
+	p.pending[req.SequenceNumber] = pe
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, req.SequenceNumber)
+		p.mu.Unlock()
+	}()
+
+	sent := time.Now()
+	if err := p.conn.WriteTo(req, dst); err != nil {
+		return 0, err
+	}
+
+	timer := time.NewTimer(p.Timeout)
+	defer timer.Stop()
+
+	select {
+	case received := <-pe.reply:
+		rtt := received.Sub(sent)
+		// make sure a reply is never mistaken for a loss
+		if rtt <= 0 {
+			rtt = 1
+		}
+		return rtt, nil
+	case <-timer.C:
+		return 0, nil
+	}
+}
+
+func (p *Pinger) receive() {
+	for {
+		m, src, _, err := p.conn.ReadFrom()
+		if err != nil {
+			return
+		}
+
+		received := time.Now()
+		reply, ok := m.(*ICMPEchoReply)
+		if !ok {
+			continue
+		}
+
+		p.mu.Lock()
+		pe, ok := p.pending[reply.SequenceNumber]
+		p.mu.Unlock()
+		if !ok || pe.identifier != reply.Identifier || !bytes.Equal(pe.data, reply.Data) {
+			continue
+		}
+
+		// replies to multicast destinations come from unicast addresses
+		if !pe.dst.IsMulticast() && !pe.dst.Equal(src) {
+			continue
+		}
+
+		select {
+		case pe.reply <- received:
+		default:
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package ndp
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echo Echo Requests received on given Conn, except for the ones drop returns
+// true for
+func echoResponder(conn Conn, drop func(*ICMPEchoRequest) bool) {
+	for {
+		m, src, _, err := conn.ReadFrom()
+		if err != nil {
+			return
+		}
+
+		req, ok := m.(*ICMPEchoRequest)
+		if !ok || drop(req) {
+			continue
+		}
+
+		conn.WriteTo(&ICMPEchoReply{
+			Identifier:     req.Identifier,
+			SequenceNumber: req.SequenceNumber,
+			Data:           req.Data,
+		}, src)
+	}
+}
+
+func TestPinger(t *testing.T) {
+	local, remote := NewPipe(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"))
+	go echoResponder(remote, func(req *ICMPEchoRequest) bool {
+		// drop every second request
+		return req.SequenceNumber%2 == 0
+	})
+
+	pinger := NewPinger(local)
+	defer pinger.Close()
+	pinger.Interval = 0
+	pinger.Timeout = 50 * time.Millisecond
+
+	stats, err := pinger.Ping(net.ParseIP("2001:db8::2"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Sent != 4 || stats.Received != 2 {
+		t.Errorf("unexpected statistics: %s", stats)
+	}
+
+	if stats.Loss() != 50 {
+		t.Errorf("unexpected loss %.1f%%", stats.Loss())
+	}
+
+	if len(stats.RTTs) != 2 || stats.MinRTT() <= 0 || stats.MinRTT() > stats.MaxRTT() {
+		t.Errorf("unexpected round-trip times %v", stats.RTTs)
+	}
+
+	if !strings.HasPrefix(stats.String(), "2001:db8::2: 4 packets transmitted, 2 received, 50.0% packet loss, rtt min/avg/max ") {
+		t.Errorf("unexpected description '%s'", stats)
+	}
+}
+
+func TestPingerMatching(t *testing.T) {
+	local, remote := NewPipe(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"))
+	go func() {
+		for {
+			m, src, _, err := remote.ReadFrom()
+			if err != nil {
+				return
+			}
+
+			req := m.(*ICMPEchoRequest)
+			// wrong identifier
+			remote.WriteTo(&ICMPEchoReply{Identifier: req.Identifier + 1, SequenceNumber: req.SequenceNumber, Data: req.Data}, src)
+			// wrong sequence number
+			remote.WriteTo(&ICMPEchoReply{Identifier: req.Identifier, SequenceNumber: req.SequenceNumber + 1, Data: req.Data}, src)
+			// wrong data
+			remote.WriteTo(&ICMPEchoReply{Identifier: req.Identifier, SequenceNumber: req.SequenceNumber}, src)
+		}
+	}()
+
+	pinger := NewPinger(local)
+	defer pinger.Close()
+	pinger.Timeout = 50 * time.Millisecond
+
+	stats, err := pinger.Ping(net.ParseIP("2001:db8::2"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Received != 0 || stats.Loss() != 100 {
+		t.Errorf("unexpected statistics: %s", stats)
+	}
+
+	// replies from other addresses than the destination are ignored too
+	stats, err = pinger.Ping(net.ParseIP("2001:db8::3"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Received != 0 {
+		t.Errorf("unexpected statistics: %s", stats)
+	}
+}
+
+func TestPingerClosed(t *testing.T) {
+	local, _ := NewPipe(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"))
+	pinger := NewPinger(local)
+	pinger.Close()
+
+	_, err := pinger.Ping(net.ParseIP("2001:db8::2"), 1)
+	if err != net.ErrClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
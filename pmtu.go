@@ -0,0 +1,185 @@
+package ndp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// MinimumMTU is the minimum link MTU of IPv6 as described at
+// https://tools.ietf.org/html/rfc8200#section-5
+const MinimumMTU = 1280
+
+// PMTUDefaultTimeout is the time after which a reduced Path MTU is raised
+// again, as recommended at https://tools.ietf.org/html/rfc8201#section-4
+const PMTUDefaultTimeout = 10 * time.Minute
+
+var (
+	errInvokingPacketUnavailable = errors.New("invoking packet unavailable")
+	errInvokingPacketNotOurs     = errors.New("invoking packet does not belong to one of our flows")
+)
+
+type pmtuEntry struct {
+	mtu     uint32
+	expires time.Time
+}
+
+// PMTUCache keeps track of the Path MTU per destination as described at
+// https://tools.ietf.org/html/rfc8201, based on the MTU option in Router
+// Advertisements and received Packet Too Big messages
+type PMTUCache struct {
+	// Timeout is the time after which a reduced Path MTU is raised to the
+	// link MTU again, defaults to PMTUDefaultTimeout
+	Timeout time.Duration
+	// ValidateFlow is called for the invoking packet of every Packet Too
+	// Big message that passed the other checks, when set. It should return
+	// false if the packet can't belong to one of our flows.
+	ValidateFlow func(*InvokingPacket) bool
+
+	mu             sync.Mutex
+	interfaceMTU   uint32
+	linkMTU        uint32
+	localAddresses []net.IP
+	entries        map[string]*pmtuEntry
+}
+
+// NewPMTUCache returns a PMTUCache for an interface with given MTU, only
+// accepting Packet Too Big messages for packets sent from given local
+// addresses
+func NewPMTUCache(interfaceMTU uint32, localAddresses ...net.IP) *PMTUCache {
+	if interfaceMTU < MinimumMTU {
+		interfaceMTU = MinimumMTU
+	}
+
+	return &PMTUCache{
+		Timeout:        PMTUDefaultTimeout,
+		interfaceMTU:   interfaceMTU,
+		linkMTU:        interfaceMTU,
+		localAddresses: localAddresses,
+		entries:        make(map[string]*pmtuEntry),
+	}
+}
+
+// LinkMTU returns the MTU currently used for the link
+func (c *PMTUCache) LinkMTU() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.linkMTU
+}
+
+// PMTU returns the Path MTU for given destination
+func (c *PMTUCache) PMTU(dst net.IP, now time.Time) uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[dst.String()]
+	if !ok || !now.Before(e.expires) || e.mtu > c.linkMTU {
+		return c.linkMTU
+	}
+
+	return e.mtu
+}
+
+// HandleMessage processes a received ICMPRouterAdvertisement or
+// ICMPPacketTooBig and returns an error if it was rejected, other messages
+// are ignored
+func (c *PMTUCache) HandleMessage(m ICMP, now time.Time) error {
+	switch p := m.(type) {
+	case *ICMPRouterAdvertisement:
+		c.handleRouterAdvertisement(p)
+	case *ICMPPacketTooBig:
+		return c.handlePacketTooBig(p, now)
+	}
+
+	return nil
+}
+
+// Expire removes all entries that aged out at given time, which raises their
+// Path MTU to the link MTU again
+func (c *PMTUCache) Expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *PMTUCache) handleRouterAdvertisement(p *ICMPRouterAdvertisement) {
+	o, err := p.GetOption(ICMPOptionTypeMTU)
+	if err != nil {
+		return
+	}
+
+	opt, ok := (*o).(*ICMPOptionMTU)
+	if !ok {
+		return
+	}
+
+	// ignore MTUs we can't support as described at
+	// https://tools.ietf.org/html/rfc4861#section-6.3.4
+	if opt.MTU < MinimumMTU || opt.MTU > c.interfaceMTU {
+		return
+	}
+
+	c.mu.Lock()
+	c.linkMTU = opt.MTU
+	c.mu.Unlock()
+}
+
+func (c *PMTUCache) handlePacketTooBig(p *ICMPPacketTooBig, now time.Time) error {
+	inv := p.Invoking()
+	if inv == nil {
+		return errInvokingPacketUnavailable
+	}
+
+	if !c.isLocal(inv.Source) {
+		return errInvokingPacketNotOurs
+	}
+
+	if c.ValidateFlow != nil && !c.ValidateFlow(inv) {
+		return errInvokingPacketNotOurs
+	}
+
+	// never go below the minimum link MTU as described at
+	// https://tools.ietf.org/html/rfc8201#section-4
+	mtu := p.MTU
+	if mtu < MinimumMTU {
+		mtu = MinimumMTU
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := inv.Destination.String()
+	current := c.linkMTU
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) && e.mtu < current {
+		current = e.mtu
+	}
+
+	// a Packet Too Big message never increases the Path MTU
+	if mtu >= current {
+		return nil
+	}
+
+	c.entries[key] = &pmtuEntry{
+		mtu:     mtu,
+		expires: now.Add(c.Timeout),
+	}
+
+	return nil
+}
+
+func (c *PMTUCache) isLocal(ip net.IP) bool {
+	for _, a := range c.localAddresses {
+		if a.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
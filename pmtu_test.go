@@ -0,0 +1,130 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPMTUCache(t *testing.T) {
+	local := net.ParseIP("2001:db8::1")
+	remote := net.ParseIP("2001:db8:1::1")
+	cache := NewPMTUCache(9000, local)
+	now := time.Unix(0, 0)
+
+	if cache.PMTU(remote, now) != 9000 {
+		t.Errorf("expected interface MTU, got %d", cache.PMTU(remote, now))
+	}
+
+	// link MTU from router advertisement
+	ra := &ICMPRouterAdvertisement{}
+	ra.AddOption(&ICMPOptionMTU{MTU: 1500})
+	ra, err := reparseRouterAdvertisement(ra)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cache.HandleMessage(ra, now); err != nil {
+		t.Error(err)
+	}
+	if cache.LinkMTU() != 1500 || cache.PMTU(remote, now) != 1500 {
+		t.Errorf("expected link MTU of 1500, got %d", cache.LinkMTU())
+	}
+
+	// MTUs larger than the interface MTU are ignored
+	ra.Options = ICMPOptions{&ICMPOptionMTU{MTU: 9216}}
+	if err = cache.HandleMessage(ra, now); err != nil {
+		t.Error(err)
+	}
+	if cache.LinkMTU() != 1500 {
+		t.Errorf("expected link MTU of 1500, got %d", cache.LinkMTU())
+	}
+
+	// packet too big for one of our flows
+	pkt := testIPv6Header("2001:db8::1", "2001:db8:1::1", protocolUDP, 8)
+	pkt = append(pkt, 0x30, 0x39, 0, 53, 0, 8, 0, 0)
+	if err = cache.HandleMessage(&ICMPPacketTooBig{MTU: 1400, InvokingPacket: pkt}, now); err != nil {
+		t.Error(err)
+	}
+	if cache.PMTU(remote, now) != 1400 {
+		t.Errorf("expected PMTU of 1400, got %d", cache.PMTU(remote, now))
+	}
+
+	// other destinations are not affected
+	if cache.PMTU(net.ParseIP("2001:db8:2::1"), now) != 1500 {
+		t.Error("unexpected PMTU for other destination")
+	}
+
+	// never increased by packet too big
+	if err = cache.HandleMessage(&ICMPPacketTooBig{MTU: 1450, InvokingPacket: pkt}, now); err != nil {
+		t.Error(err)
+	}
+	if cache.PMTU(remote, now) != 1400 {
+		t.Errorf("expected PMTU of 1400, got %d", cache.PMTU(remote, now))
+	}
+
+	// never below minimum MTU
+	if err = cache.HandleMessage(&ICMPPacketTooBig{MTU: 576, InvokingPacket: pkt}, now); err != nil {
+		t.Error(err)
+	}
+	if cache.PMTU(remote, now) != MinimumMTU {
+		t.Errorf("expected PMTU of %d, got %d", MinimumMTU, cache.PMTU(remote, now))
+	}
+
+	// aged back up to link MTU
+	now = now.Add(PMTUDefaultTimeout)
+	if cache.PMTU(remote, now) != 1500 {
+		t.Errorf("expected PMTU of 1500 after timeout, got %d", cache.PMTU(remote, now))
+	}
+	cache.Expire(now)
+	if len(cache.entries) != 0 {
+		t.Error("expected expired entries to be removed")
+	}
+}
+
+func TestPMTUCacheValidation(t *testing.T) {
+	cache := NewPMTUCache(1500, net.ParseIP("2001:db8::1"))
+	now := time.Unix(0, 0)
+
+	// no invoking packet
+	if err := cache.HandleMessage(&ICMPPacketTooBig{MTU: 1400}, now); err != errInvokingPacketUnavailable {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// not sent by us
+	pkt := testIPv6Header("2001:db8::2", "2001:db8:1::1", protocolUDP, 8)
+	pkt = append(pkt, 0x30, 0x39, 0, 53, 0, 8, 0, 0)
+	if err := cache.HandleMessage(&ICMPPacketTooBig{MTU: 1400, InvokingPacket: pkt}, now); err != errInvokingPacketNotOurs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// rejected by flow validation
+	pkt = testIPv6Header("2001:db8::1", "2001:db8:1::1", protocolUDP, 8)
+	pkt = append(pkt, 0x30, 0x39, 0, 53, 0, 8, 0, 0)
+	cache.ValidateFlow = func(p *InvokingPacket) bool {
+		return p.HasPorts && p.DestinationPort == 443
+	}
+	if err := cache.HandleMessage(&ICMPPacketTooBig{MTU: 1400, InvokingPacket: pkt}, now); err != errInvokingPacketNotOurs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if cache.PMTU(net.ParseIP("2001:db8:1::1"), now) != 1500 {
+		t.Error("PMTU changed by rejected packet too big")
+	}
+}
+
+// marshal and parse given ICMPRouterAdvertisement, so its options are as
+// they would be when received
+func reparseRouterAdvertisement(ra *ICMPRouterAdvertisement) (*ICMPRouterAdvertisement, error) {
+	b, err := ra.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ParseMessage(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.(*ICMPRouterAdvertisement), nil
+}
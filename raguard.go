@@ -0,0 +1,270 @@
+package ndp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// RAGuardReason tells why RAGuard allowed or dropped a message
+type RAGuardReason uint8
+
+// reasons currently defined
+const (
+	RAGuardAllowed RAGuardReason = iota
+	RAGuardHostPort
+	RAGuardSource
+	RAGuardLinkLayerAddress
+	RAGuardHopLimit
+	RAGuardManagedAddress
+	RAGuardOtherStateful
+	RAGuardRouterPreference
+	RAGuardOption
+	RAGuardPrefix
+)
+
+func (r RAGuardReason) String() string {
+	switch r {
+	case RAGuardAllowed:
+		return "allowed"
+	case RAGuardHostPort:
+		return "host port"
+	case RAGuardSource:
+		return "source address"
+	case RAGuardLinkLayerAddress:
+		return "link-layer address"
+	case RAGuardHopLimit:
+		return "hop limit"
+	case RAGuardManagedAddress:
+		return "managed address flag"
+	case RAGuardOtherStateful:
+		return "other stateful flag"
+	case RAGuardRouterPreference:
+		return "router preference"
+	case RAGuardOption:
+		return "option"
+	case RAGuardPrefix:
+		return "prefix"
+	}
+
+	return "<nil>"
+}
+
+// RAGuardVerdict is the decision of RAGuard about a message
+type RAGuardVerdict struct {
+	Allow  bool
+	Reason RAGuardReason
+	// Detail describes what in the message caused it to be dropped
+	Detail string
+}
+
+func (v RAGuardVerdict) String() string {
+	if v.Allow {
+		return "allow"
+	}
+
+	return fmt.Sprintf("drop: %s: %s", v.Reason, v.Detail)
+}
+
+func raGuardDrop(reason RAGuardReason, format string, a ...interface{}) RAGuardVerdict {
+	return RAGuardVerdict{
+		Reason: reason,
+		Detail: fmt.Sprintf(format, a...),
+	}
+}
+
+// RAGuardPolicy describes which router advertisements are allowed on a port,
+// nil fields don't restrict anything
+type RAGuardPolicy struct {
+	// Sources are the link-local addresses routers advertise from
+	Sources []net.IP
+	// LinkLayerAddresses are the addresses routers advertise from, checked
+	// against both the frame and the source link-layer address option
+	LinkLayerAddresses []net.HardwareAddr
+	// Prefixes are the prefixes advertised in prefix information options
+	// need to be within
+	Prefixes []*net.IPNet
+	// MaxHopLimit is the highest hop limit advertised, 0 doesn't restrict.
+	// Hop limit 0 in a router advertisement means unspecified and is always
+	// allowed.
+	MaxHopLimit uint8
+	// ManagedAddress is the value the M flag needs to have
+	ManagedAddress *bool
+	// OtherStateful is the value the O flag needs to have
+	OtherStateful *bool
+	// RouterPreferences are the router preferences advertised
+	RouterPreferences []RouterPreferenceField
+	// Options are the option types advertisements can include
+	Options []ICMPOptionType
+}
+
+// Check returns the verdict about given router advertisement received from
+// given source address and link-layer address, which is nil when not known
+func (p RAGuardPolicy) Check(src net.IP, lla net.HardwareAddr, ra *ICMPRouterAdvertisement) RAGuardVerdict {
+	// routers advertise from link-local addresses as described at
+	// https://tools.ietf.org/html/rfc4861#section-6.1.2
+	if !src.IsLinkLocalUnicast() || src.To4() != nil {
+		return raGuardDrop(RAGuardSource, "%s is not link-local", src)
+	}
+
+	if p.Sources != nil && !containsIP(p.Sources, src) {
+		return raGuardDrop(RAGuardSource, "%s not allowed", src)
+	}
+
+	if p.LinkLayerAddresses != nil {
+		if lla != nil && !containsHardwareAddr(p.LinkLayerAddresses, lla) {
+			return raGuardDrop(RAGuardLinkLayerAddress, "%s not allowed", lla)
+		}
+
+		for _, o := range ra.Options {
+			if slla, ok := o.(*ICMPOptionSourceLinkLayerAddress); ok && !containsHardwareAddr(p.LinkLayerAddresses, slla.LinkLayerAddress) {
+				return raGuardDrop(RAGuardLinkLayerAddress, "%s in option not allowed", slla.LinkLayerAddress)
+			}
+		}
+	}
+
+	if p.MaxHopLimit > 0 && ra.HopLimit > p.MaxHopLimit {
+		return raGuardDrop(RAGuardHopLimit, "%d above %d", ra.HopLimit, p.MaxHopLimit)
+	}
+
+	if p.ManagedAddress != nil && ra.ManagedAddress != *p.ManagedAddress {
+		return raGuardDrop(RAGuardManagedAddress, "%t not allowed", ra.ManagedAddress)
+	}
+
+	if p.OtherStateful != nil && ra.OtherStateful != *p.OtherStateful {
+		return raGuardDrop(RAGuardOtherStateful, "%t not allowed", ra.OtherStateful)
+	}
+
+	if p.RouterPreferences != nil && !containsRouterPreference(p.RouterPreferences, ra.RouterPreference) {
+		return raGuardDrop(RAGuardRouterPreference, "%s not allowed", ra.RouterPreference)
+	}
+
+	for _, o := range ra.Options {
+		if p.Options != nil && !containsOptionType(p.Options, o.Type()) {
+			return raGuardDrop(RAGuardOption, "%s (%d) not allowed", o.Type(), o.Type())
+		}
+
+		pio, ok := o.(*ICMPOptionPrefixInformation)
+		if ok && p.Prefixes != nil && !prefixWithin(p.Prefixes, pio.Prefix, int(pio.PrefixLength)) {
+			return raGuardDrop(RAGuardPrefix, "%s/%d not allowed", pio.Prefix, pio.PrefixLength)
+		}
+	}
+
+	return RAGuardVerdict{Allow: true}
+}
+
+// RAGuard implements stateless RA Guard as described at
+// https://tools.ietf.org/html/rfc6105#section-3, to be used by a switch to
+// block router advertisements from rogue routers. Ports are either facing
+// routers and have a policy, or facing hosts and drop all router
+// advertisements. Other messages are always allowed.
+type RAGuard struct {
+	mu     sync.Mutex
+	policy *RAGuardPolicy
+	ports  map[string]*RAGuardPolicy
+}
+
+// NewRAGuard returns an RAGuard using given policy for ports that have none
+// set, nil makes those ports facing hosts
+func NewRAGuard(policy *RAGuardPolicy) *RAGuard {
+	return &RAGuard{
+		policy: policy,
+		ports:  make(map[string]*RAGuardPolicy),
+	}
+}
+
+// SetPort sets the policy for given port, nil makes it facing hosts
+func (g *RAGuard) SetPort(port string, policy *RAGuardPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ports[port] = policy
+}
+
+// RemovePort makes given port use the default policy again
+func (g *RAGuard) RemovePort(port string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.ports, port)
+}
+
+// Check returns the verdict about given message received on given port from
+// given source address and link-layer address, which is nil when not known
+func (g *RAGuard) Check(port string, src net.IP, lla net.HardwareAddr, m ICMP) RAGuardVerdict {
+	ra, ok := m.(*ICMPRouterAdvertisement)
+	if !ok {
+		return RAGuardVerdict{Allow: true}
+	}
+
+	g.mu.Lock()
+	policy, ok := g.ports[port]
+	if !ok {
+		policy = g.policy
+	}
+	g.mu.Unlock()
+
+	if policy == nil {
+		return raGuardDrop(RAGuardHostPort, "%s faces hosts", port)
+	}
+
+	return policy.Check(src, lla, ra)
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsHardwareAddr(addrs []net.HardwareAddr, addr net.HardwareAddr) bool {
+	for _, a := range addrs {
+		if bytes.Equal(a, addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsRouterPreference(prefs []RouterPreferenceField, pref RouterPreferenceField) bool {
+	for _, p := range prefs {
+		if p == pref {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsOptionType(types []ICMPOptionType, typ ICMPOptionType) bool {
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prefixWithin returns true if given prefix with given length is within any
+// of given networks
+func prefixWithin(networks []*net.IPNet, prefix net.IP, length int) bool {
+	if prefix.To16() == nil {
+		return false
+	}
+
+	for _, n := range networks {
+		ones, _ := n.Mask.Size()
+		if length >= ones && n.Contains(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
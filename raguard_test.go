@@ -0,0 +1,138 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRAGuardReasonString(t *testing.T) {
+	tests := []struct {
+		r RAGuardReason
+		s string
+	}{
+		{RAGuardAllowed, "allowed"},
+		{RAGuardHostPort, "host port"},
+		{RAGuardSource, "source address"},
+		{RAGuardLinkLayerAddress, "link-layer address"},
+		{RAGuardHopLimit, "hop limit"},
+		{RAGuardManagedAddress, "managed address flag"},
+		{RAGuardOtherStateful, "other stateful flag"},
+		{RAGuardRouterPreference, "router preference"},
+		{RAGuardOption, "option"},
+		{RAGuardPrefix, "prefix"},
+		{RAGuardReason(10), "<nil>"},
+	}
+
+	for _, test := range tests {
+		if test.r.String() != test.s {
+			t.Errorf("unexpected string for reason %d: %s", test.r, test.r)
+		}
+	}
+}
+
+func TestRAGuardPolicy(t *testing.T) {
+	_, allowed, _ := net.ParseCIDR("2001:db8::/48")
+	off := false
+	policy := RAGuardPolicy{
+		Sources:            []net.IP{net.ParseIP("fe80::1")},
+		LinkLayerAddresses: []net.HardwareAddr{{2, 0, 0, 0, 0, 1}},
+		Prefixes:           []*net.IPNet{allowed},
+		MaxHopLimit:        64,
+		ManagedAddress:     &off,
+		OtherStateful:      &off,
+		RouterPreferences:  []RouterPreferenceField{RouterPreferenceMedium, RouterPreferenceLow},
+		Options:            []ICMPOptionType{ICMPOptionTypeSourceLinkLayerAddress, ICMPOptionTypePrefixInformation},
+	}
+
+	router := net.ParseIP("fe80::1")
+	lla := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	advertisement := func(prefix string, length uint8) *ICMPRouterAdvertisement {
+		ra := &ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}
+		ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+		ra.AddOption(&ICMPOptionPrefixInformation{Prefix: net.ParseIP(prefix), PrefixLength: length, ValidLifetime: 3600})
+		return ra
+	}
+
+	tests := []struct {
+		src    net.IP
+		lla    net.HardwareAddr
+		ra     *ICMPRouterAdvertisement
+		reason RAGuardReason
+		detail string
+	}{
+		{router, lla, advertisement("2001:db8:0:1::", 64), RAGuardAllowed, ""},
+		// the link-layer address of the frame might not be known
+		{router, nil, advertisement("2001:db8::", 48), RAGuardAllowed, ""},
+		{net.ParseIP("2001:db8::1"), lla, advertisement("2001:db8::", 64), RAGuardSource, "2001:db8::1 is not link-local"},
+		{net.ParseIP("fe80::2"), lla, advertisement("2001:db8::", 64), RAGuardSource, "fe80::2 not allowed"},
+		{router, net.HardwareAddr{2, 0, 0, 0, 0, 2}, advertisement("2001:db8::", 64), RAGuardLinkLayerAddress, "02:00:00:00:00:02 not allowed"},
+		{router, lla, &ICMPRouterAdvertisement{optionContainer: optionContainer{Options: ICMPOptions{&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 2}}}}}, RAGuardLinkLayerAddress, "02:00:00:00:00:02 in option not allowed"},
+		{router, lla, &ICMPRouterAdvertisement{HopLimit: 255}, RAGuardHopLimit, "255 above 64"},
+		// unspecified hop limit
+		{router, lla, &ICMPRouterAdvertisement{}, RAGuardAllowed, ""},
+		{router, lla, &ICMPRouterAdvertisement{ManagedAddress: true}, RAGuardManagedAddress, "true not allowed"},
+		{router, lla, &ICMPRouterAdvertisement{OtherStateful: true}, RAGuardOtherStateful, "true not allowed"},
+		{router, lla, &ICMPRouterAdvertisement{RouterPreference: RouterPreferenceHigh}, RAGuardRouterPreference, "high not allowed"},
+		{router, lla, &ICMPRouterAdvertisement{optionContainer: optionContainer{Options: ICMPOptions{&ICMPOptionMTU{MTU: 1500}}}}, RAGuardOption, "mtu (5) not allowed"},
+		{router, lla, advertisement("2001:db9::", 64), RAGuardPrefix, "2001:db9::/64 not allowed"},
+		// shorter prefixes aren't within the allowed one
+		{router, lla, advertisement("2001:db8::", 32), RAGuardPrefix, "2001:db8::/32 not allowed"},
+	}
+
+	for _, test := range tests {
+		verdict := policy.Check(test.src, test.lla, test.ra)
+		if verdict.Allow != (test.reason == RAGuardAllowed) || verdict.Reason != test.reason || verdict.Detail != test.detail {
+			t.Errorf("unexpected verdict for %s: %s", test.ra, verdict)
+		}
+	}
+
+	// an empty policy allows everything from link-local addresses
+	ra := advertisement("2001:db9::", 64)
+	ra.HopLimit = 255
+	ra.ManagedAddress = true
+	ra.AddOption(&ICMPOptionMTU{MTU: 1500})
+	if verdict := (RAGuardPolicy{}).Check(router, nil, ra); !verdict.Allow {
+		t.Errorf("unexpected verdict %s", verdict)
+	}
+
+	verdict := RAGuardVerdict{Reason: RAGuardHopLimit, Detail: "255 above 64"}
+	if verdict.String() != "drop: hop limit: 255 above 64" {
+		t.Errorf("unexpected string %s", verdict)
+	}
+}
+
+func TestRAGuard(t *testing.T) {
+	guard := NewRAGuard(nil)
+	guard.SetPort("uplink", &RAGuardPolicy{Sources: []net.IP{net.ParseIP("fe80::1")}})
+
+	router := net.ParseIP("fe80::1")
+	ra := &ICMPRouterAdvertisement{}
+	if verdict := guard.Check("uplink", router, nil, ra); !verdict.Allow {
+		t.Errorf("unexpected verdict %s", verdict)
+	}
+
+	if verdict := guard.Check("uplink", net.ParseIP("fe80::2"), nil, ra); verdict.Allow || verdict.Reason != RAGuardSource {
+		t.Errorf("unexpected verdict %s", verdict)
+	}
+
+	// ports without policy face hosts
+	verdict := guard.Check("eth1", router, nil, ra)
+	if verdict.Allow || verdict.Reason != RAGuardHostPort || verdict.String() != "drop: host port: eth1 faces hosts" {
+		t.Errorf("unexpected verdict %s", verdict)
+	}
+
+	// other messages pass
+	if verdict := guard.Check("eth1", router, nil, &ICMPRouterSolicitation{}); !verdict.Allow {
+		t.Errorf("unexpected verdict %s", verdict)
+	}
+
+	guard.SetPort("eth1", &RAGuardPolicy{})
+	if verdict := guard.Check("eth1", router, nil, ra); !verdict.Allow {
+		t.Errorf("unexpected verdict %s", verdict)
+	}
+
+	guard.RemovePort("uplink")
+	if verdict := guard.Check("uplink", router, nil, ra); verdict.Allow {
+		t.Errorf("unexpected verdict %s", verdict)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+
+package ndp
+
+// routerAlert returns an error, since the Router Alert option can't be
+// added to messages on this platform
+func routerAlert() ([]byte, error) {
+	return nil, errRouterAlertUnsupported
+}
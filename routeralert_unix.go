@@ -0,0 +1,22 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package ndp
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// routerAlert returns the control message making the kernel add the Router
+// Alert option for MLD to a message
+func routerAlert() ([]byte, error) {
+	b := make([]byte, unix.CmsgSpace(len(routerAlertMLD)))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = unix.IPPROTO_IPV6
+	h.Type = unix.IPV6_HOPOPTS
+	h.SetLen(unix.CmsgLen(len(routerAlertMLD)))
+	copy(b[unix.CmsgLen(0):], routerAlertMLD)
+
+	return b, nil
+}
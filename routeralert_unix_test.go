@@ -0,0 +1,35 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package ndp
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRouterAlert(t *testing.T) {
+	b, err := routerAlert()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 1 {
+		t.Fatalf("parsed %d control messages instead of 1", len(msgs))
+	}
+
+	if msgs[0].Header.Level != unix.IPPROTO_IPV6 || msgs[0].Header.Type != unix.IPV6_HOPOPTS {
+		t.Errorf("unexpected control message level %d type %d", msgs[0].Header.Level, msgs[0].Header.Type)
+	}
+
+	fixture := []byte{0, 0, 5, 2, 0, 0, 1, 0}
+	if bytes.Compare(msgs[0].Data, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, msgs[0].Data)
+	}
+}
@@ -0,0 +1,475 @@
+package ndp
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// oidIPAddressBlocks is the object identifier of the IP address delegation
+// extension as described at https://tools.ietf.org/html/rfc3779#section-2
+var oidIPAddressBlocks = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 7}
+
+// ipAddressFamilyIPv6 is the address family identifier for IPv6 in the IP address
+// delegation extension
+var ipAddressFamilyIPv6 = []byte{0, 2}
+
+var (
+	errNoCertificates         = errors.New("no certificates in certification path")
+	errNoRSAKey               = errors.New("router certificate has no rsa public key")
+	errNotRouterAdvertisement = errors.New("message is not a router advertisement")
+)
+
+type ipAddressFamily struct {
+	AddressFamily []byte
+	Choice        asn1.RawValue
+}
+
+type ipAddressRange struct {
+	Min asn1.BitString
+	Max asn1.BitString
+}
+
+// addressRange holds an inclusive range of IPv6 addresses
+type addressRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+// allAddresses is the range a trust anchor without IP address delegation
+// extension is authorized for
+var allAddresses = []addressRange{{
+	first: netip.IPv6Unspecified(),
+	last:  netip.AddrFrom16([16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}),
+}}
+
+// IPAddressBlocksExtension returns the critical IP address delegation
+// extension as described at https://tools.ietf.org/html/rfc3779#section-2
+// for given IPv6 prefixes, to be added to a router certificate's
+// ExtraExtensions. A nil slice makes the certificate inherit the prefixes of
+// its issuer.
+func IPAddressBlocksExtension(prefixes []*net.IPNet) (pkix.Extension, error) {
+	family := ipAddressFamily{AddressFamily: ipAddressFamilyIPv6}
+
+	if prefixes == nil {
+		family.Choice = asn1.NullRawValue
+	} else {
+		sorted := make([]*net.IPNet, len(prefixes))
+		copy(sorted, prefixes)
+		sort.Slice(sorted, func(i, j int) bool {
+			return bytes.Compare(sorted[i].IP.To16(), sorted[j].IP.To16()) < 0
+		})
+
+		var elems []byte
+		for _, p := range sorted {
+			ones, bits := p.Mask.Size()
+			if p.IP.To4() != nil || p.IP.To16() == nil || bits != 128 {
+				return pkix.Extension{}, fmt.Errorf("invalid IPv6 prefix %s", p)
+			}
+
+			e, err := asn1.Marshal(asn1.BitString{
+				Bytes:     p.IP.Mask(p.Mask)[:((ones + 7) / 8)],
+				BitLength: ones,
+			})
+			if err != nil {
+				return pkix.Extension{}, err
+			}
+
+			elems = append(elems, e...)
+		}
+
+		family.Choice = asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSequence,
+			IsCompound: true,
+			Bytes:      elems,
+		}
+	}
+
+	value, err := asn1.Marshal([]ipAddressFamily{family})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{
+		Id:       oidIPAddressBlocks,
+		Critical: true,
+		Value:    value,
+	}, nil
+}
+
+// parseIPAddressBlocks returns the IPv6 ranges in given IP address delegation
+// extension, or inherit when they should be taken from the issuer
+func parseIPAddressBlocks(value []byte) (ranges []addressRange, inherit bool, err error) {
+	var families []ipAddressFamily
+	rest, err := asn1.Unmarshal(value, &families)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(rest) > 0 {
+		return nil, false, errors.New("trailing data after ip address blocks")
+	}
+
+	for _, f := range families {
+		if len(f.AddressFamily) < 2 || !bytes.Equal(f.AddressFamily[:2], ipAddressFamilyIPv6) {
+			continue
+		}
+
+		if f.Choice.Class == asn1.ClassUniversal && f.Choice.Tag == asn1.TagNull {
+			return nil, true, nil
+		}
+
+		if f.Choice.Class != asn1.ClassUniversal || f.Choice.Tag != asn1.TagSequence {
+			return nil, false, errors.New("invalid ip address choice")
+		}
+
+		elems := f.Choice.Bytes
+		for len(elems) > 0 {
+			var v asn1.RawValue
+			elems, err = asn1.Unmarshal(elems, &v)
+			if err != nil {
+				return nil, false, err
+			}
+
+			var lo, hi asn1.BitString
+			switch v.Tag {
+			case asn1.TagBitString:
+				if _, err := asn1.Unmarshal(v.FullBytes, &lo); err != nil {
+					return nil, false, err
+				}
+				hi = lo
+
+			case asn1.TagSequence:
+				var r ipAddressRange
+				if _, err := asn1.Unmarshal(v.FullBytes, &r); err != nil {
+					return nil, false, err
+				}
+				lo, hi = r.Min, r.Max
+
+			default:
+				return nil, false, fmt.Errorf("invalid ip address or range with tag %d", v.Tag)
+			}
+
+			first, err := bitsToAddr(lo, false)
+			if err != nil {
+				return nil, false, err
+			}
+
+			last, err := bitsToAddr(hi, true)
+			if err != nil {
+				return nil, false, err
+			}
+
+			ranges = append(ranges, addressRange{first: first, last: last})
+		}
+	}
+
+	return mergeRanges(ranges), false, nil
+}
+
+// bitsToAddr returns the address for given bit string, filling the missing
+// bits with zeroes or, when fill is set, ones
+func bitsToAddr(bs asn1.BitString, fill bool) (netip.Addr, error) {
+	if bs.BitLength > 128 || len(bs.Bytes) > 16 {
+		return netip.Addr{}, fmt.Errorf("ip address of %d bits too long", bs.BitLength)
+	}
+
+	var a [16]byte
+	copy(a[:], bs.Bytes)
+	for i := bs.BitLength; i < 128; i++ {
+		if fill {
+			a[i/8] |= 0x80 >> uint(i%8)
+		} else {
+			a[i/8] &^= 0x80 >> uint(i%8)
+		}
+	}
+
+	return netip.AddrFrom16(a), nil
+}
+
+// mergeRanges returns given ranges sorted, with overlapping and adjacent
+// ranges merged
+func mergeRanges(ranges []addressRange) []addressRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].first.Less(ranges[j].first)
+	})
+
+	merged := []addressRange{}
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			prev := &merged[n-1]
+			next := prev.last.Next()
+			if !next.IsValid() || !next.Less(r.first) {
+				if prev.last.Less(r.last) {
+					prev.last = r.last
+				}
+				continue
+			}
+		}
+
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// rangesContain returns true if given range lies within given merged ranges
+func rangesContain(ranges []addressRange, r addressRange) bool {
+	for _, c := range ranges {
+		if !r.first.Less(c.first) && !c.last.Less(r.last) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RouterAuthorization describes a router whose certification path was
+// validated and the prefixes it is authorized to advertise
+type RouterAuthorization struct {
+	Certificate *x509.Certificate
+	ranges      []addressRange
+}
+
+// AuthorizesPrefix returns true if the router is authorized to advertise
+// given prefix
+func (a *RouterAuthorization) AuthorizesPrefix(prefix net.IP, length uint8) bool {
+	if prefix.To16() == nil || length > 128 {
+		return false
+	}
+
+	p, ok := netip.AddrFromSlice(prefix.To16())
+	if !ok {
+		return false
+	}
+
+	pfx, err := p.Prefix(int(length))
+	if err != nil {
+		return false
+	}
+
+	last := pfx.Addr().As16()
+	for i := int(length); i < 128; i++ {
+		last[i/8] |= 0x80 >> uint(i%8)
+	}
+
+	return rangesContain(a.ranges, addressRange{first: pfx.Addr(), last: netip.AddrFrom16(last)})
+}
+
+// RouterValidator checks whether routers are authorized as described at
+// https://tools.ietf.org/html/rfc3971#section-6.3, based on their
+// certification path to one of the configured trust anchors and the IP
+// address delegation extensions of the certificates in that path
+type RouterValidator struct {
+	anchors []*x509.Certificate
+}
+
+// NewRouterValidator returns a RouterValidator accepting routers certified
+// by given trust anchors. Trust anchors without IP address delegation
+// extension may authorize any prefix.
+func NewRouterValidator(anchors ...*x509.Certificate) *RouterValidator {
+	return &RouterValidator{anchors: anchors}
+}
+
+// Validate checks given certification path, starting with the router
+// certificate, at given time. It returns the authorization of the router or
+// error when the router is not authorized.
+func (v *RouterValidator) Validate(certs []*x509.Certificate, now time.Time) (*RouterAuthorization, error) {
+	if len(certs) == 0 {
+		return nil, errNoCertificates
+	}
+
+	roots := x509.NewCertPool()
+	for _, c := range v.anchors {
+		roots.AddCert(handleIPAddressBlocks(c))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(handleIPAddressBlocks(c))
+	}
+
+	chains, err := handleIPAddressBlocks(certs[0]).Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// any chain with valid delegations will do
+	for _, chain := range chains {
+		ranges, e := delegatedRanges(chain)
+		if e != nil {
+			err = e
+			continue
+		}
+
+		return &RouterAuthorization{Certificate: certs[0], ranges: ranges}, nil
+	}
+
+	return nil, err
+}
+
+// ValidateRouterAdvertisement checks whether the ICMPRouterAdvertisement
+// received as given bytes, sent from src to dst, was signed by the router of
+// given certification path and whether that router is authorized for all
+// prefixes in its ICMPOptionPrefixInformation options
+func (v *RouterValidator) ValidateRouterAdvertisement(b []byte, src, dst net.IP, certs []*x509.Certificate, now time.Time) (*RouterAuthorization, error) {
+	m, err := ParseMessage(b)
+	if err != nil {
+		return nil, err
+	}
+
+	ra, ok := m.(*ICMPRouterAdvertisement)
+	if !ok {
+		return nil, errNotRouterAdvertisement
+	}
+
+	auth, err := v.Validate(certs, now)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := auth.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errNoRSAKey
+	}
+
+	if err := VerifyMessage(b, src, dst, pub); err != nil {
+		return nil, err
+	}
+
+	for _, o := range ra.Options {
+		pi, ok := o.(*ICMPOptionPrefixInformation)
+		if !ok {
+			continue
+		}
+
+		if !auth.AuthorizesPrefix(pi.Prefix, pi.PrefixLength) {
+			return nil, fmt.Errorf("router not authorized for prefix %s/%d", pi.Prefix, pi.PrefixLength)
+		}
+	}
+
+	return auth, nil
+}
+
+// CertificationPath returns the certificates in the ICMPOptionCertificate
+// options of given ICMPCertificationPathAdvertisements, with the router
+// certificate first, ready to be passed to RouterValidator
+func CertificationPath(cpas ...*ICMPCertificationPathAdvertisement) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for _, cpa := range cpas {
+		for _, o := range cpa.Options {
+			co, ok := o.(*ICMPOptionCertificate)
+			if !ok {
+				continue
+			}
+
+			c, err := co.ParseCertificate()
+			if err != nil {
+				return nil, err
+			}
+
+			certs = append(certs, c)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, errNoCertificates
+	}
+
+	// the router certificate issued none of the others
+	for i, c := range certs {
+		issuer := false
+		for j, o := range certs {
+			if i != j && bytes.Equal(c.RawSubject, o.RawIssuer) {
+				issuer = true
+				break
+			}
+		}
+
+		if !issuer {
+			certs[0], certs[i] = certs[i], certs[0]
+			break
+		}
+	}
+
+	return certs, nil
+}
+
+// handleIPAddressBlocks returns a copy of given certificate with the IP
+// address delegation extension marked as handled, since x509 would refuse
+// it being critical while we check it ourselves
+func handleIPAddressBlocks(c *x509.Certificate) *x509.Certificate {
+	cp := *c
+	cp.UnhandledCriticalExtensions = nil
+	for _, oid := range c.UnhandledCriticalExtensions {
+		if !oid.Equal(oidIPAddressBlocks) {
+			cp.UnhandledCriticalExtensions = append(cp.UnhandledCriticalExtensions, oid)
+		}
+	}
+
+	return &cp
+}
+
+// delegatedRanges returns the ranges the first certificate of given chain,
+// which ends with a trust anchor, is authorized for. Each certificate should
+// stay within the ranges of its issuer as described at
+// https://tools.ietf.org/html/rfc3779#section-2.3
+func delegatedRanges(chain []*x509.Certificate) ([]addressRange, error) {
+	var ranges []addressRange
+	for i := len(chain) - 1; i >= 0; i-- {
+		var ext *pkix.Extension
+		for j, e := range chain[i].Extensions {
+			if e.Id.Equal(oidIPAddressBlocks) {
+				ext = &chain[i].Extensions[j]
+				break
+			}
+		}
+
+		if ext == nil {
+			if i == len(chain)-1 {
+				ranges = allAddresses
+			} else {
+				ranges = nil
+			}
+			continue
+		}
+
+		r, inherit, err := parseIPAddressBlocks(ext.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip address blocks in certificate %s: %s", chain[i].Subject, err)
+		}
+
+		if inherit {
+			if i == len(chain)-1 {
+				ranges = allAddresses
+			}
+			continue
+		}
+
+		if i < len(chain)-1 {
+			for _, c := range r {
+				if !rangesContain(ranges, c) {
+					return nil, fmt.Errorf("certificate %s exceeds ip address blocks of its issuer", chain[i].Subject)
+				}
+			}
+		}
+
+		ranges = r
+	}
+
+	return ranges, nil
+}
@@ -0,0 +1,326 @@
+package ndp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+type testIssuer struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func testPrefixes(t *testing.T, prefixes ...string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, p := range prefixes {
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets
+}
+
+// testIssue returns a certificate for given public key, issued by given
+// issuer or self-signed when issuer is nil, with an IP address delegation
+// extension for given prefixes unless noExt is set
+func testIssue(t *testing.T, cn string, issuer *testIssuer, key crypto.Signer, pub crypto.PublicKey, prefixes []*net.IPNet, noExt bool) *testIssuer {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Unix(1700000000, 0),
+		NotAfter:              time.Unix(1700000000, 0).Add(24 * time.Hour),
+		IsCA:                  issuer == nil || key != nil,
+		BasicConstraintsValid: true,
+	}
+
+	if !noExt {
+		ext, err := IPAddressBlocksExtension(prefixes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template.ExtraExtensions = []pkix.Extension{ext}
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.cert, issuer.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testIssuer{cert: cert, key: key}
+}
+
+func testCAKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestRouterValidator(t *testing.T) {
+	now := time.Unix(1700000000, 0).Add(time.Hour)
+
+	rootKey := testCAKey(t)
+	root := testIssue(t, "root", nil, rootKey, &rootKey.PublicKey, testPrefixes(t, "2001:db8::/32"), false)
+	interKey := testCAKey(t)
+	inter := testIssue(t, "intermediate", root, interKey, &interKey.PublicKey, testPrefixes(t, "2001:db8:1::/48"), false)
+
+	routerKey := testRSAKey()
+	router := testIssue(t, "router", inter, nil, &routerKey.PublicKey, testPrefixes(t, "2001:db8:1:2::/64", "2001:db8:1:1::/64"), false)
+
+	v := NewRouterValidator(root.cert)
+	auth, err := v.Validate([]*x509.Certificate{router.cert, inter.cert}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prefix     string
+		length     uint8
+		authorized bool
+	}{
+		{"2001:db8:1:1::", 64, true},
+		{"2001:db8:1:2::", 64, true},
+		{"2001:db8:1:2:1::", 80, true},
+		{"2001:db8:1::", 62, false},
+		{"2001:db8:1:0::", 63, false},
+		{"2001:db8:1:3::", 64, false},
+		{"2001:db8:1::", 48, false},
+		{"fe80::", 64, false},
+	}
+
+	for _, test := range tests {
+		if auth.AuthorizesPrefix(net.ParseIP(test.prefix), test.length) != test.authorized {
+			t.Errorf("expected authorization %t for %s/%d", test.authorized, test.prefix, test.length)
+		}
+	}
+
+	// missing intermediate
+	if _, err := v.Validate([]*x509.Certificate{router.cert}, now); err == nil {
+		t.Error("expected error for incomplete certification path")
+	}
+
+	// expired
+	if _, err := v.Validate([]*x509.Certificate{router.cert, inter.cert}, now.Add(48*time.Hour)); err == nil {
+		t.Error("expected error for expired certificates")
+	}
+
+	// untrusted
+	otherKey := testCAKey(t)
+	other := testIssue(t, "other", nil, otherKey, &otherKey.PublicKey, nil, true)
+	if _, err := NewRouterValidator(other.cert).Validate([]*x509.Certificate{router.cert, inter.cert}, now); err == nil {
+		t.Error("expected error for untrusted root")
+	}
+
+	// router exceeding its issuer
+	greedy := testIssue(t, "greedy", inter, nil, &routerKey.PublicKey, testPrefixes(t, "2001:db8:2::/64"), false)
+	if _, err := v.Validate([]*x509.Certificate{greedy.cert, inter.cert}, now); err == nil {
+		t.Error("expected error for router exceeding its issuer")
+	}
+
+	// routers inherit prefixes when told so
+	inheriting := testIssue(t, "inheriting", inter, nil, &routerKey.PublicKey, nil, false)
+	auth, err = v.Validate([]*x509.Certificate{inheriting.cert, inter.cert}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !auth.AuthorizesPrefix(net.ParseIP("2001:db8:1:ff::"), 64) {
+		t.Error("inheriting router not authorized for prefix of its issuer")
+	}
+
+	// but aren't authorized for any prefix without extension
+	bare := testIssue(t, "bare", inter, nil, &routerKey.PublicKey, nil, true)
+	auth, err = v.Validate([]*x509.Certificate{bare.cert, inter.cert}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth.AuthorizesPrefix(net.ParseIP("2001:db8:1:1::"), 64) {
+		t.Error("router without extension authorized for prefix")
+	}
+
+	// trust anchors without extension may authorize anything
+	unrestricted := testIssue(t, "router", other, nil, &routerKey.PublicKey, testPrefixes(t, "2001:db8:9::/48"), false)
+	auth, err = NewRouterValidator(other.cert).Validate([]*x509.Certificate{unrestricted.cert}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !auth.AuthorizesPrefix(net.ParseIP("2001:db8:9:1::"), 64) {
+		t.Error("router not authorized for its own prefix")
+	}
+}
+
+func TestRouterValidatorRouterAdvertisement(t *testing.T) {
+	now := time.Unix(1700000000, 0).Add(time.Hour)
+	src := net.ParseIP("fe80::1")
+	dst := net.ParseIP("ff02::1")
+
+	rootKey := testCAKey(t)
+	root := testIssue(t, "root", nil, rootKey, &rootKey.PublicKey, testPrefixes(t, "2001:db8::/32"), false)
+	routerKey := testRSAKey()
+	router := testIssue(t, "router", root, nil, &routerKey.PublicKey, testPrefixes(t, "2001:db8:1::/48"), false)
+
+	// the certification path arrives in an advertisement
+	cpa := &ICMPCertificationPathAdvertisement{AllComponents: 2}
+	cpa.AddOption(CertificateOption(root.cert))
+	cpa.AddOption(CertificateOption(router.cert))
+	m, err := cpa.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := CertificationPath(parsed.(*ICMPCertificationPathAdvertisement))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !certs[0].Equal(router.cert) {
+		t.Errorf("expected router certificate first, not %s", certs[0].Subject)
+	}
+
+	newRA := func(prefix string) *ICMPRouterAdvertisement {
+		ra := &ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}
+		ra.AddOption(&ICMPOptionPrefixInformation{
+			PrefixLength:      64,
+			OnLink:            true,
+			Auto:              true,
+			ValidLifetime:     86400,
+			PreferredLifetime: 14400,
+			Prefix:            net.ParseIP(prefix),
+		})
+		ra.AddOption(&ICMPOptionTimestamp{Timestamp: now})
+		if err := SignMessage(ra, src, dst, routerKey); err != nil {
+			t.Fatal(err)
+		}
+
+		return ra
+	}
+
+	marshal := func(m ICMP) []byte {
+		b, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return b
+	}
+
+	v := NewRouterValidator(root.cert)
+	if _, err := v.ValidateRouterAdvertisement(marshal(newRA("2001:db8:1:1::")), src, dst, certs, now); err != nil {
+		t.Errorf("authorized router advertisement rejected: %s", err)
+	}
+
+	if _, err := v.ValidateRouterAdvertisement(marshal(newRA("2001:db8:2:1::")), src, dst, certs, now); err == nil {
+		t.Error("expected error for unauthorized prefix")
+	}
+
+	// the received bytes are verified, including the reserved bits of the
+	// prefix information option the parser ignores
+	b := marshal(newRA("2001:db8:1:1::"))
+	b[16+3] |= 0x01
+	if _, err := v.ValidateRouterAdvertisement(b, src, dst, certs, now); err == nil {
+		t.Error("expected error for router advertisement tampered with")
+	}
+
+	// signed by someone else
+	ra := newRA("2001:db8:1:1::")
+	ra.Options = ra.Options[:2]
+	impostor, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SignMessage(ra, src, dst, impostor); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.ValidateRouterAdvertisement(marshal(ra), src, dst, certs, now); err == nil {
+		t.Error("expected error for router advertisement signed by another key")
+	}
+
+	if _, err := v.ValidateRouterAdvertisement(marshal(&ICMPRouterSolicitation{}), src, dst, certs, now); err != errNotRouterAdvertisement {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := CertificationPath(&ICMPCertificationPathAdvertisement{}); err != errNoCertificates {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIPAddressBlocksExtension(t *testing.T) {
+	ext, err := IPAddressBlocksExtension(testPrefixes(t, "2001:db8:1::/48", "2001:db8:2::/48", "2001:db8:4::/47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ext.Critical || !ext.Id.Equal(oidIPAddressBlocks) {
+		t.Errorf("unexpected extension %v", ext)
+	}
+
+	ranges, inherit, err := parseIPAddressBlocks(ext.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inherit {
+		t.Error("unexpected inherit")
+	}
+
+	// adjacent prefixes are merged
+	if len(ranges) != 2 {
+		t.Fatalf("expected 2 ranges, got %d", len(ranges))
+	}
+
+	if ranges[0].first.String() != "2001:db8:1::" || ranges[0].last.String() != "2001:db8:2:ffff:ffff:ffff:ffff:ffff" {
+		t.Errorf("unexpected range %s-%s", ranges[0].first, ranges[0].last)
+	}
+
+	if ranges[1].first.String() != "2001:db8:4::" || ranges[1].last.String() != "2001:db8:5:ffff:ffff:ffff:ffff:ffff" {
+		t.Errorf("unexpected range %s-%s", ranges[1].first, ranges[1].last)
+	}
+
+	ext, err = IPAddressBlocksExtension(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, inherit, err = parseIPAddressBlocks(ext.Value); err != nil || !inherit {
+		t.Errorf("expected inherit, got %t (%v)", inherit, err)
+	}
+
+	if _, err := IPAddressBlocksExtension(testPrefixes(t, "192.0.2.0/24")); err == nil {
+		t.Error("expected error for IPv4 prefix")
+	}
+}
@@ -0,0 +1,595 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+// codes of the Router Renumbering message as described at
+// https://tools.ietf.org/html/rfc2894#section-3.1
+const (
+	routerRenumberingCodeCommand             = 0
+	routerRenumberingCodeResult              = 1
+	routerRenumberingCodeSequenceNumberReset = 255
+)
+
+// RouterRenumberingHeader implements the header shared by all Router
+// Renumbering messages as described at
+// https://tools.ietf.org/html/rfc2894#section-3.1
+type RouterRenumberingHeader struct {
+	SequenceNumber      uint32
+	SegmentNumber       uint8
+	TestCommand         bool
+	ResultRequested     bool
+	AllInterfaces       bool
+	SiteSpecific        bool
+	ProcessedPreviously bool
+	MaxDelay            uint16
+}
+
+func (h RouterRenumberingHeader) String() string {
+	s := fmt.Sprintf("seq %d, segment %d, ", h.SequenceNumber, h.SegmentNumber)
+	f := []string{}
+	if h.TestCommand {
+		f = append(f, "test")
+	}
+	if h.ResultRequested {
+		f = append(f, "result requested")
+	}
+	if h.AllInterfaces {
+		f = append(f, "all interfaces")
+	}
+	if h.SiteSpecific {
+		f = append(f, "site specific")
+	}
+	if h.ProcessedPreviously {
+		f = append(f, "processed previously")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("max delay %dms", h.MaxDelay)
+
+	return s
+}
+
+func (h RouterRenumberingHeader) marshal(code uint8) []byte {
+	b := make([]byte, 16)
+	// message header
+	b[0] = uint8(ipv6.ICMPTypeRouterRenumbering)
+	b[1] = code
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint32(b[4:8], h.SequenceNumber)
+	b[8] = h.SegmentNumber
+	if h.TestCommand {
+		b[9] ^= 0x80
+	}
+	if h.ResultRequested {
+		b[9] ^= 0x40
+	}
+	if h.AllInterfaces {
+		b[9] ^= 0x20
+	}
+	if h.SiteSpecific {
+		b[9] ^= 0x10
+	}
+	if h.ProcessedPreviously {
+		b[9] ^= 0x08
+	}
+	binary.BigEndian.PutUint16(b[10:12], h.MaxDelay)
+	// b[12:16] = reserved
+
+	return b
+}
+
+// RouterRenumberingOpCode implements the operations of a Match-Prefix Part as
+// described at https://tools.ietf.org/html/rfc2894#section-3.2.1
+type RouterRenumberingOpCode uint8
+
+// operations currently defined
+const (
+	_ RouterRenumberingOpCode = iota
+	RouterRenumberingOpAdd
+	RouterRenumberingOpChange
+	RouterRenumberingOpSetGlobal
+)
+
+func (op RouterRenumberingOpCode) String() string {
+	switch op {
+	case RouterRenumberingOpAdd:
+		return "add"
+	case RouterRenumberingOpChange:
+		return "change"
+	case RouterRenumberingOpSetGlobal:
+		return "set-global"
+	default:
+		return "<nil>"
+	}
+}
+
+// UsePrefixPart implements the Use-Prefix Part as described at
+// https://tools.ietf.org/html/rfc2894#section-3.2.2
+type UsePrefixPart struct {
+	UseLen             uint8
+	KeepLen            uint8
+	MaskOnLink         bool
+	MaskAuto           bool
+	OnLink             bool
+	Auto               bool
+	ValidLifetime      uint32
+	PreferredLifetime  uint32
+	DecrementValid     bool
+	DecrementPreferred bool
+	UsePrefix          net.IP
+}
+
+func (u UsePrefixPart) String() string {
+	s := fmt.Sprintf("use %s/%d keep %d, ", u.UsePrefix, u.UseLen, u.KeepLen)
+	f := []string{}
+	if u.MaskOnLink {
+		f = append(f, fmt.Sprintf("onlink=%t", u.OnLink))
+	}
+	if u.MaskAuto {
+		f = append(f, fmt.Sprintf("auto=%t", u.Auto))
+	}
+	if u.DecrementValid {
+		f = append(f, "decr valid")
+	}
+	if u.DecrementPreferred {
+		f = append(f, "decr pref")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("valid time %ds, ", u.ValidLifetime)
+	s += fmt.Sprintf("pref. time %ds", u.PreferredLifetime)
+
+	return s
+}
+
+func (u UsePrefixPart) marshal() []byte {
+	b := make([]byte, 16)
+	b[0] = u.UseLen
+	b[1] = u.KeepLen
+	if u.MaskOnLink {
+		b[2] ^= 0x80
+	}
+	if u.MaskAuto {
+		b[2] ^= 0x40
+	}
+	if u.OnLink {
+		b[3] ^= 0x80
+	}
+	if u.Auto {
+		b[3] ^= 0x40
+	}
+	binary.BigEndian.PutUint32(b[4:8], u.ValidLifetime)
+	binary.BigEndian.PutUint32(b[8:12], u.PreferredLifetime)
+	if u.DecrementValid {
+		b[12] ^= 0x80
+	}
+	if u.DecrementPreferred {
+		b[12] ^= 0x40
+	}
+	b = append(b, prefixBytes(u.UsePrefix)...)
+
+	return b
+}
+
+// MatchPrefixPart implements the Match-Prefix Part and the Use-Prefix Parts
+// following it, which together form a Prefix Control Operation as described
+// at https://tools.ietf.org/html/rfc2894#section-3.2
+type MatchPrefixPart struct {
+	OpCode      RouterRenumberingOpCode
+	Ordinal     uint8
+	MatchLen    uint8
+	MinLen      uint8
+	MaxLen      uint8
+	MatchPrefix net.IP
+	UsePrefixes []UsePrefixPart
+}
+
+func (m MatchPrefixPart) String() string {
+	s := fmt.Sprintf("%s ordinal %d, ", m.OpCode, m.Ordinal)
+	s += fmt.Sprintf("match %s/%d, ", m.MatchPrefix, m.MatchLen)
+	s += fmt.Sprintf("len %d-%d", m.MinLen, m.MaxLen)
+	for _, u := range m.UsePrefixes {
+		s += fmt.Sprintf("\n        %s", u)
+	}
+
+	return s
+}
+
+func (m MatchPrefixPart) marshal() []byte {
+	b := make([]byte, 8)
+	b[0] = uint8(m.OpCode)
+	b[1] = uint8(3 + 4*len(m.UsePrefixes))
+	b[2] = m.Ordinal
+	b[3] = m.MatchLen
+	b[4] = m.MinLen
+	b[5] = m.MaxLen
+	// b[6:8] = reserved
+	b = append(b, prefixBytes(m.MatchPrefix)...)
+	for _, u := range m.UsePrefixes {
+		b = append(b, u.marshal()...)
+	}
+
+	return b
+}
+
+// matches returns true if given prefix is matched by this MatchPrefixPart,
+// an invalid match length or prefix never matches
+func (m MatchPrefixPart) matches(p *ICMPOptionPrefixInformation) bool {
+	if m.MatchLen > 128 || p.Prefix.To16() == nil {
+		return false
+	}
+
+	if p.PrefixLength < m.MinLen || p.PrefixLength > m.MaxLen {
+		return false
+	}
+
+	// SET-GLOBAL matches all global prefixes
+	if m.OpCode == RouterRenumberingOpSetGlobal {
+		return p.Prefix.IsGlobalUnicast()
+	}
+
+	return prefixEqual(p.Prefix, m.MatchPrefix, int(m.MatchLen))
+}
+
+// MatchResult implements the Match Result as described at
+// https://tools.ietf.org/html/rfc2894#section-3.3
+type MatchResult struct {
+	Bounded        bool
+	Forbidden      bool
+	Ordinal        uint8
+	MatchedLen     uint8
+	InterfaceIndex uint32
+	MatchedPrefix  net.IP
+}
+
+func (r MatchResult) String() string {
+	s := fmt.Sprintf("ordinal %d, ", r.Ordinal)
+	s += fmt.Sprintf("matched %s/%d on interface %d", r.MatchedPrefix, r.MatchedLen, r.InterfaceIndex)
+	f := []string{}
+	if r.Bounded {
+		f = append(f, "bounded")
+	}
+	if r.Forbidden {
+		f = append(f, "forbidden")
+	}
+	s += fmt.Sprintf(", Flags %s", f)
+
+	return s
+}
+
+func (r MatchResult) marshal() []byte {
+	b := make([]byte, 8)
+	if r.Bounded {
+		b[1] ^= 0x02
+	}
+	if r.Forbidden {
+		b[1] ^= 0x01
+	}
+	b[2] = r.Ordinal
+	b[3] = r.MatchedLen
+	binary.BigEndian.PutUint32(b[4:8], r.InterfaceIndex)
+	b = append(b, prefixBytes(r.MatchedPrefix)...)
+
+	return b
+}
+
+// ICMPRouterRenumberingCommand implements the Router Renumbering Command and
+// Sequence Number Reset messages as described at
+// https://tools.ietf.org/html/rfc2894#section-3
+type ICMPRouterRenumberingCommand struct {
+	RouterRenumberingHeader
+	SequenceNumberReset bool
+	Operations          []MatchPrefixPart
+}
+
+func (p ICMPRouterRenumberingCommand) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	if p.SequenceNumberReset {
+		s += "sequence number reset, "
+	} else {
+		s += "command, "
+	}
+	s += fmt.Sprintf("%s\n", p.RouterRenumberingHeader)
+	for _, o := range p.Operations {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeRouterRenumbering
+func (p ICMPRouterRenumberingCommand) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRouterRenumbering
+}
+
+// Marshal returns byte slice representing this ICMPRouterRenumberingCommand
+func (p ICMPRouterRenumberingCommand) Marshal() ([]byte, error) {
+	code := uint8(routerRenumberingCodeCommand)
+	if p.SequenceNumberReset {
+		code = routerRenumberingCodeSequenceNumberReset
+	}
+
+	b := p.RouterRenumberingHeader.marshal(code)
+	for _, o := range p.Operations {
+		if len(o.UsePrefixes) > 63 {
+			return nil, fmt.Errorf("too many use-prefix parts for ordinal %d: %d", o.Ordinal, len(o.UsePrefixes))
+		}
+
+		if o.MatchLen > 128 {
+			return nil, fmt.Errorf("invalid match length %d for ordinal %d", o.MatchLen, o.Ordinal)
+		}
+
+		b = append(b, o.marshal()...)
+	}
+
+	return b, nil
+}
+
+// Apply performs the Prefix Control Operations of this command on given
+// prefixes and returns the resulting prefixes with a MatchResult for every
+// prefix that matched. When TestCommand is set, given prefixes are returned
+// as is. Given prefixes are never modified. Operations with a match length
+// above 128 match nothing, and prefixes without valid address are never
+// matched.
+func (p ICMPRouterRenumberingCommand) Apply(prefixes []*ICMPOptionPrefixInformation) ([]*ICMPOptionPrefixInformation, []MatchResult) {
+	current := prefixes
+	results := []MatchResult{}
+
+	for _, o := range p.Operations {
+		next := []*ICMPOptionPrefixInformation{}
+		created := []*ICMPOptionPrefixInformation{}
+
+		for _, pi := range current {
+			if !o.matches(pi) {
+				next = append(next, pi)
+				continue
+			}
+
+			result := MatchResult{
+				Ordinal:       o.Ordinal,
+				MatchedLen:    pi.PrefixLength,
+				MatchedPrefix: pi.Prefix,
+			}
+
+			for _, u := range o.UsePrefixes {
+				// nothing to create, only used to delete matched prefix
+				if u.UseLen == 0 && u.KeepLen == 0 {
+					continue
+				}
+
+				if int(u.UseLen)+int(u.KeepLen) > 128 {
+					result.Bounded = true
+					continue
+				}
+
+				np := u.apply(pi)
+				if np.Prefix.IsLinkLocalUnicast() || np.Prefix.IsMulticast() || np.Prefix.IsLoopback() || np.Prefix.IsUnspecified() {
+					result.Forbidden = true
+					continue
+				}
+
+				created = append(created, np)
+			}
+
+			results = append(results, result)
+
+			// only ADD keeps the matched prefix
+			if o.OpCode == RouterRenumberingOpAdd {
+				next = append(next, pi)
+			}
+		}
+
+		// new prefixes replace existing ones with the same prefix
+		for _, np := range created {
+			replaced := false
+			for i, pi := range next {
+				if pi.PrefixLength == np.PrefixLength && pi.Prefix.Equal(np.Prefix) {
+					next[i] = np
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				next = append(next, np)
+			}
+		}
+
+		current = next
+	}
+
+	if p.TestCommand {
+		return prefixes, results
+	}
+
+	return current, results
+}
+
+// apply creates the new prefix for given matched prefix
+func (u UsePrefixPart) apply(matched *ICMPOptionPrefixInformation) *ICMPOptionPrefixInformation {
+	prefix := make(net.IP, net.IPv6len)
+	copy(prefix, maskPrefix(u.UsePrefix, int(u.UseLen)))
+
+	// keep bits from the matched prefix
+	for i := int(u.UseLen); i < int(u.UseLen)+int(u.KeepLen); i++ {
+		if matched.Prefix.To16()[i/8]&(0x80>>uint(i%8)) > 0 {
+			prefix[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+
+	np := &ICMPOptionPrefixInformation{
+		PrefixLength:      u.UseLen + u.KeepLen,
+		OnLink:            matched.OnLink,
+		Auto:              matched.Auto,
+		ValidLifetime:     u.ValidLifetime,
+		PreferredLifetime: u.PreferredLifetime,
+		Prefix:            prefix,
+	}
+	if u.MaskOnLink {
+		np.OnLink = u.OnLink
+	}
+	if u.MaskAuto {
+		np.Auto = u.Auto
+	}
+
+	return np
+}
+
+// ICMPRouterRenumberingResult implements the Router Renumbering Result message
+// as described at https://tools.ietf.org/html/rfc2894#section-3.3
+type ICMPRouterRenumberingResult struct {
+	RouterRenumberingHeader
+	Results []MatchResult
+}
+
+func (p ICMPRouterRenumberingResult) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("result, %s\n", p.RouterRenumberingHeader)
+	for _, r := range p.Results {
+		s += fmt.Sprintf("    %s\n", r)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeRouterRenumbering
+func (p ICMPRouterRenumberingResult) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRouterRenumbering
+}
+
+// Marshal returns byte slice representing this ICMPRouterRenumberingResult
+func (p ICMPRouterRenumberingResult) Marshal() ([]byte, error) {
+	b := p.RouterRenumberingHeader.marshal(routerRenumberingCodeResult)
+	for _, r := range p.Results {
+		b = append(b, r.marshal()...)
+	}
+
+	return b, nil
+}
+
+func parseRouterRenumbering(b []byte) (ICMP, error) {
+	if len(b) < 16 {
+		return nil, errMessageTooShort
+	}
+
+	h := RouterRenumberingHeader{
+		SequenceNumber:      binary.BigEndian.Uint32(b[4:8]),
+		SegmentNumber:       b[8],
+		TestCommand:         (b[9]&0x80 > 0),
+		ResultRequested:     (b[9]&0x40 > 0),
+		AllInterfaces:       (b[9]&0x20 > 0),
+		SiteSpecific:        (b[9]&0x10 > 0),
+		ProcessedPreviously: (b[9]&0x08 > 0),
+		MaxDelay:            binary.BigEndian.Uint16(b[10:12]),
+	}
+
+	switch b[1] {
+	case routerRenumberingCodeCommand, routerRenumberingCodeSequenceNumberReset:
+		message := &ICMPRouterRenumberingCommand{
+			RouterRenumberingHeader: h,
+			SequenceNumberReset:     b[1] == routerRenumberingCodeSequenceNumberReset,
+		}
+
+		b = b[16:]
+		for len(b) > 0 {
+			if len(b) < 24 {
+				return nil, errMessageTooShort
+			}
+
+			opLength := int(b[1])
+			if opLength < 3 || (opLength-3)%4 != 0 {
+				return nil, fmt.Errorf("invalid prefix control operation length %d", opLength)
+			}
+			if len(b) < opLength*8 {
+				return nil, errMessageTooShort
+			}
+
+			if b[3] > 128 {
+				return nil, fmt.Errorf("invalid match length %d", b[3])
+			}
+
+			o := MatchPrefixPart{
+				OpCode:      RouterRenumberingOpCode(b[0]),
+				Ordinal:     b[2],
+				MatchLen:    b[3],
+				MinLen:      b[4],
+				MaxLen:      b[5],
+				MatchPrefix: net.IP(b[8:24]),
+			}
+
+			for i := 24; i < opLength*8; i += 32 {
+				u := b[i:(i + 32)]
+				o.UsePrefixes = append(o.UsePrefixes, UsePrefixPart{
+					UseLen:             u[0],
+					KeepLen:            u[1],
+					MaskOnLink:         (u[2]&0x80 > 0),
+					MaskAuto:           (u[2]&0x40 > 0),
+					OnLink:             (u[3]&0x80 > 0),
+					Auto:               (u[3]&0x40 > 0),
+					ValidLifetime:      binary.BigEndian.Uint32(u[4:8]),
+					PreferredLifetime:  binary.BigEndian.Uint32(u[8:12]),
+					DecrementValid:     (u[12]&0x80 > 0),
+					DecrementPreferred: (u[12]&0x40 > 0),
+					UsePrefix:          net.IP(u[16:32]),
+				})
+			}
+
+			message.Operations = append(message.Operations, o)
+			b = b[opLength*8:]
+		}
+
+		return message, nil
+
+	case routerRenumberingCodeResult:
+		message := &ICMPRouterRenumberingResult{
+			RouterRenumberingHeader: h,
+		}
+
+		b = b[16:]
+		if len(b)%24 != 0 {
+			return nil, errMessageTooShort
+		}
+
+		for i := 0; i < len(b); i += 24 {
+			message.Results = append(message.Results, MatchResult{
+				Bounded:        (b[i+1]&0x02 > 0),
+				Forbidden:      (b[i+1]&0x01 > 0),
+				Ordinal:        b[i+2],
+				MatchedLen:     b[i+3],
+				InterfaceIndex: binary.BigEndian.Uint32(b[(i + 4):(i + 8)]),
+				MatchedPrefix:  net.IP(b[(i + 8):(i + 24)]),
+			})
+		}
+
+		return message, nil
+
+	default:
+		return nil, fmt.Errorf("router renumbering message with code %d not supported", b[1])
+	}
+}
+
+// return 16 bytes for given prefix, which may be nil
+func prefixBytes(ip net.IP) net.IP {
+	if ip = ip.To16(); ip == nil {
+		return make(net.IP, net.IPv6len)
+	}
+
+	return ip
+}
+
+// return given address with all but the first bits bits set to 0
+func maskPrefix(ip net.IP, bits int) net.IP {
+	return prefixBytes(ip).Mask(net.CIDRMask(bits, 128))
+}
+
+// return true if the first bits bits of given addresses are equal
+func prefixEqual(a, b net.IP, bits int) bool {
+	return bytes.Equal(maskPrefix(a, bits), maskPrefix(b, bits))
+}
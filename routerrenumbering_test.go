@@ -0,0 +1,292 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPRouterRenumberingCommand(t *testing.T) {
+	icmp := &ICMPRouterRenumberingCommand{
+		RouterRenumberingHeader: RouterRenumberingHeader{
+			SequenceNumber:  1,
+			TestCommand:     true,
+			ResultRequested: true,
+			MaxDelay:        100,
+		},
+		Operations: []MatchPrefixPart{
+			{
+				OpCode:      RouterRenumberingOpChange,
+				Ordinal:     1,
+				MatchLen:    32,
+				MinLen:      48,
+				MaxLen:      64,
+				MatchPrefix: net.ParseIP("2001:db8::"),
+				UsePrefixes: []UsePrefixPart{
+					{
+						UseLen:            32,
+						KeepLen:           32,
+						MaskAuto:          true,
+						ValidLifetime:     3600,
+						PreferredLifetime: 1800,
+						DecrementValid:    true,
+						UsePrefix:         net.ParseIP("2001:db9::"),
+					},
+				},
+			},
+		},
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeRouterRenumbering {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeRouterRenumbering)
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{138, 0, 0, 0, 0, 0, 0, 1, 0, 192, 0, 100, 0, 0, 0, 0,
+		2, 7, 1, 32, 48, 64, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		32, 32, 64, 0, 0, 0, 14, 16, 0, 0, 7, 8, 128, 0, 0, 0, 32, 1, 13, 185, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "router renumbering, length 72, command, seq 1, segment 0, Flags [test result requested], max delay 100ms\n    change ordinal 1, match 2001:db8::/32, len 48-64\n        use 2001:db9::/32 keep 32, Flags [auto=false decr valid], valid time 3600s, pref. time 1800s"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// truncated use-prefix part
+	_, err = ParseMessage(fixture[:60])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	// match lengths above 128 would match everything
+	invalid := append([]byte{}, fixture...)
+	invalid[19] = 200
+	if _, err = ParseMessage(invalid); err == nil {
+		t.Error("expected error parsing invalid match length")
+	}
+
+	icmp.Operations[0].MatchLen = 200
+	if _, err = icmp.Marshal(); err == nil {
+		t.Error("expected error marshalling invalid match length")
+	}
+	icmp.Operations[0].MatchLen = 32
+
+	// sequence number reset
+	icmp = &ICMPRouterRenumberingCommand{SequenceNumberReset: true}
+	marshal, err = icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{138, 255, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	parsedICMP, err = ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !parsedICMP.(*ICMPRouterRenumberingCommand).SequenceNumberReset {
+		t.Error("expected sequence number reset")
+	}
+
+	// unknown code
+	fixture[1] = 2
+	_, err = ParseMessage(fixture)
+	if err == nil || strings.Compare(err.Error(), "router renumbering message with code 2 not supported") != 0 {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPRouterRenumberingResult(t *testing.T) {
+	icmp := &ICMPRouterRenumberingResult{
+		RouterRenumberingHeader: RouterRenumberingHeader{
+			SequenceNumber: 1,
+			SegmentNumber:  2,
+		},
+		Results: []MatchResult{
+			{
+				Forbidden:      true,
+				Ordinal:        1,
+				MatchedLen:     64,
+				InterfaceIndex: 3,
+				MatchedPrefix:  net.ParseIP("2001:db8::"),
+			},
+		},
+	}
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{138, 1, 0, 0, 0, 0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0,
+		0, 1, 1, 64, 0, 0, 0, 3, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "router renumbering, length 40, result, seq 1, segment 2, Flags [], max delay 0ms\n    ordinal 1, matched 2001:db8::/64 on interface 3, Flags [forbidden]"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	_, err = ParseMessage(fixture[:30])
+	if err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestICMPRouterRenumberingCommandApply(t *testing.T) {
+	prefixes := []*ICMPOptionPrefixInformation{
+		{PrefixLength: 64, OnLink: true, Auto: true, ValidLifetime: 86400, PreferredLifetime: 14400, Prefix: net.ParseIP("2001:db8:0:1::")},
+		{PrefixLength: 64, OnLink: true, Auto: true, ValidLifetime: 86400, PreferredLifetime: 14400, Prefix: net.ParseIP("2001:db8:0:2::")},
+		{PrefixLength: 64, OnLink: true, Auto: true, ValidLifetime: 86400, PreferredLifetime: 14400, Prefix: net.ParseIP("fd00::")},
+	}
+
+	// move 2001:db8::/48 into 2001:db9::/48, keeping the subnet ids
+	cmd := &ICMPRouterRenumberingCommand{
+		Operations: []MatchPrefixPart{
+			{
+				OpCode:      RouterRenumberingOpChange,
+				Ordinal:     1,
+				MatchLen:    48,
+				MinLen:      48,
+				MaxLen:      64,
+				MatchPrefix: net.ParseIP("2001:db8::"),
+				UsePrefixes: []UsePrefixPart{
+					{
+						UseLen:            48,
+						KeepLen:           16,
+						MaskAuto:          true,
+						Auto:              false,
+						ValidLifetime:     3600,
+						PreferredLifetime: 1800,
+						UsePrefix:         net.ParseIP("2001:db9::"),
+					},
+				},
+			},
+		},
+	}
+
+	result, matches := cmd.Apply(prefixes)
+	if len(matches) != 2 {
+		t.Errorf("expected 2 match results, got %d", len(matches))
+	}
+
+	expected := []string{"fd00::/64", "2001:db9:0:1::/64", "2001:db9:0:2::/64"}
+	if len(result) != len(expected) {
+		t.Fatalf("expected %d prefixes, got %d", len(expected), len(result))
+	}
+	for i, pi := range result {
+		got := (&net.IPNet{IP: pi.Prefix, Mask: net.CIDRMask(int(pi.PrefixLength), 128)}).String()
+		if got != expected[i] {
+			t.Errorf("expected prefix %s, got %s", expected[i], got)
+		}
+	}
+
+	if !result[1].OnLink || result[1].Auto || result[1].ValidLifetime != 3600 {
+		t.Errorf("unexpected flags or lifetimes for %s", result[1])
+	}
+
+	// original prefixes are untouched
+	if !prefixes[0].Prefix.Equal(net.ParseIP("2001:db8:0:1::")) || !prefixes[0].Auto {
+		t.Error("original prefix was modified")
+	}
+
+	// ADD keeps matched prefixes
+	cmd.Operations[0].OpCode = RouterRenumberingOpAdd
+	result, _ = cmd.Apply(prefixes)
+	if len(result) != 5 {
+		t.Errorf("expected 5 prefixes, got %d", len(result))
+	}
+
+	// test commands only report
+	cmd.TestCommand = true
+	result, matches = cmd.Apply(prefixes)
+	if len(result) != 3 || len(matches) != 2 {
+		t.Errorf("test command changed prefixes: %d prefixes, %d matches", len(result), len(matches))
+	}
+	cmd.TestCommand = false
+
+	// SET-GLOBAL replaces all global prefixes, including unique local ones
+	cmd.Operations[0].OpCode = RouterRenumberingOpSetGlobal
+	cmd.Operations[0].MatchLen = 0
+	cmd.Operations[0].MatchPrefix = nil
+	cmd.Operations[0].UsePrefixes[0].KeepLen = 0
+	cmd.Operations[0].UsePrefixes[0].UseLen = 64
+	result, matches = cmd.Apply(prefixes)
+	if len(result) != 1 || len(matches) != 3 {
+		t.Errorf("unexpected SET-GLOBAL result: %d prefixes, %d matches", len(result), len(matches))
+	}
+
+	// out of bounds and forbidden prefixes are reported
+	cmd.Operations[0].OpCode = RouterRenumberingOpChange
+	cmd.Operations[0].MatchLen = 48
+	cmd.Operations[0].MatchPrefix = net.ParseIP("2001:db8::")
+	cmd.Operations[0].UsePrefixes = []UsePrefixPart{
+		{UseLen: 120, KeepLen: 16, UsePrefix: net.ParseIP("2001:db9::")},
+		{UseLen: 64, UsePrefix: net.ParseIP("fe80::")},
+	}
+	result, matches = cmd.Apply(prefixes)
+	if len(result) != 1 || len(matches) != 2 || !matches[0].Bounded || !matches[0].Forbidden {
+		t.Errorf("unexpected result: %d prefixes, matches %v", len(result), matches)
+	}
+
+	// invalid match lengths match nothing
+	cmd.Operations[0].MatchLen = 200
+	cmd.Operations[0].MinLen = 0
+	cmd.Operations[0].MaxLen = 128
+	if result, matches = cmd.Apply(prefixes); len(result) != 3 || len(matches) != 0 {
+		t.Errorf("unexpected result: %d prefixes, matches %v", len(result), matches)
+	}
+
+	// neither do prefixes without address
+	cmd.Operations[0].MatchLen = 0
+	cmd.Operations[0].UsePrefixes = []UsePrefixPart{{UseLen: 48, KeepLen: 16, UsePrefix: net.ParseIP("2001:db9::")}}
+	result, matches = cmd.Apply([]*ICMPOptionPrefixInformation{{PrefixLength: 64}})
+	if len(result) != 1 || len(matches) != 0 {
+		t.Errorf("unexpected result: %d prefixes, matches %v", len(result), matches)
+	}
+}
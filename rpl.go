@@ -0,0 +1,604 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+var errRPLEncrypted = errors.New("encrypted rpl control messages not supported")
+
+// codes of RPL control messages as described at
+// https://tools.ietf.org/html/rfc6550#section-6
+const (
+	rplCodeDODAGInformationSolicitation uint8 = iota
+	rplCodeDODAGInformationObject
+	rplCodeDestinationAdvertisementObject
+	rplCodeDestinationAdvertisementObjectAck
+	// secure variants have the high bit set
+	rplCodeSecure uint8 = 0x80
+)
+
+// RPLSecurity implements the security section of secure RPL control
+// messages as described at https://tools.ietf.org/html/rfc6550#section-6.1
+// Signature keys (KeyIdentifierMode 3) are expected without key identifier.
+type RPLSecurity struct {
+	// CounterIsTime tells whether Counter is a timestamp
+	CounterIsTime     bool
+	Algorithm         uint8
+	KeyIdentifierMode uint8
+	Level             uint8
+	Counter           uint32
+	KeyIdentifier     []byte
+	// MIC is the message authentication code or signature following the
+	// options
+	MIC []byte
+}
+
+func (s RPLSecurity) String() string {
+	return fmt.Sprintf("algorithm %d, kim %d, level %d, counter %d", s.Algorithm, s.KeyIdentifierMode, s.Level, s.Counter)
+}
+
+// Encrypted returns whether the base and options of the message are
+// encrypted at this security level
+func (s RPLSecurity) Encrypted() bool {
+	return s.Level&0x01 > 0
+}
+
+// keyIdentifierLength returns the length of the key identifier for the key
+// identifier mode
+func (s RPLSecurity) keyIdentifierLength() (int, error) {
+	switch s.KeyIdentifierMode {
+	case 0:
+		// key index
+		return 1, nil
+	case 1, 3:
+		return 0, nil
+	case 2:
+		// key source and key index
+		return 9, nil
+	default:
+		return 0, fmt.Errorf("invalid key identifier mode %d", s.KeyIdentifierMode)
+	}
+}
+
+// micLength returns the length of the MIC for the security level
+func (s RPLSecurity) micLength() (int, error) {
+	if s.Level > 3 {
+		return 0, fmt.Errorf("invalid security level %d", s.Level)
+	}
+
+	// signatures of 3072 and 2048 bits
+	if s.KeyIdentifierMode == 3 {
+		if s.Level < 2 {
+			return 384, nil
+		}
+
+		return 256, nil
+	}
+
+	// 32 and 64 bits MACs
+	if s.Level < 2 {
+		return 4, nil
+	}
+
+	return 8, nil
+}
+
+func (s RPLSecurity) marshal() ([]byte, error) {
+	kl, err := s.keyIdentifierLength()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.KeyIdentifier) != kl {
+		return nil, fmt.Errorf("key identifier of %d bytes should be %d bytes", len(s.KeyIdentifier), kl)
+	}
+
+	ml, err := s.micLength()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.MIC) != ml {
+		return nil, fmt.Errorf("mic of %d bytes should be %d bytes", len(s.MIC), ml)
+	}
+
+	b := make([]byte, 8)
+	if s.CounterIsTime {
+		b[0] ^= 0x80
+	}
+	b[1] = s.Algorithm
+	b[2] = s.KeyIdentifierMode<<6 | s.Level
+	// b[3] = flags, always 0
+	binary.BigEndian.PutUint32(b[4:8], s.Counter)
+	b = append(b, s.KeyIdentifier...)
+
+	return b, nil
+}
+
+// parseRPLSecurity returns the security section at the start of given bytes
+// and the remaining bytes without the MIC
+func parseRPLSecurity(b []byte) (*RPLSecurity, []byte, error) {
+	if len(b) < 8 {
+		return nil, nil, errMessageTooShort
+	}
+
+	s := &RPLSecurity{
+		CounterIsTime:     (b[0]&0x80 > 0),
+		Algorithm:         b[1],
+		KeyIdentifierMode: b[2] >> 6,
+		Level:             b[2] & 0x07,
+		Counter:           binary.BigEndian.Uint32(b[4:8]),
+	}
+
+	kl, err := s.keyIdentifierLength()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ml, err := s.micLength()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(b) < 8+kl+ml {
+		return nil, nil, errMessageTooShort
+	}
+
+	s.KeyIdentifier = b[8:(8 + kl)]
+	s.MIC = b[(len(b) - ml):]
+
+	return s, b[(8 + kl):(len(b) - ml)], nil
+}
+
+// rplOptionContainer holds the RPL control message options, which live in
+// their own option space
+type rplOptionContainer struct {
+	Options RPLOptions
+}
+
+// AddOption adds given RPLOption to options of ICMP
+func (oc *rplOptionContainer) AddOption(o RPLOption) {
+	oc.Options = append(oc.Options, o)
+}
+
+// HasOption returns true if ICMP contains option of type RPLOptionType
+func (oc rplOptionContainer) HasOption(t RPLOptionType) bool {
+	for _, o := range oc.Options {
+		if o.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOption returns RPLOption of type RPLOptionType or error if ICMP has no
+// such option
+func (oc rplOptionContainer) GetOption(t RPLOptionType) (*RPLOption, error) {
+	for _, o := range oc.Options {
+		if o.Type() == t {
+			return &o, nil
+		}
+	}
+
+	return nil, fmt.Errorf("option %d not found", t)
+}
+
+// marshalRPL returns the RPL control message of given code, base and
+// options, secured when sec is set
+func marshalRPL(code uint8, sec *RPLSecurity, base []byte, options RPLOptions) ([]byte, error) {
+	b := make([]byte, 4)
+	// message header
+	b[0] = uint8(ipv6.ICMPTypeRPLControl)
+	b[1] = code
+	// b[2:3] = checksum, calculated separately
+
+	if sec != nil {
+		b[1] ^= rplCodeSecure
+		sm, err := sec.marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, sm...)
+	}
+
+	b = append(b, base...)
+	om, err := options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+	if sec != nil {
+		b = append(b, sec.MIC...)
+	}
+
+	return b, nil
+}
+
+// rplString returns the common start of the description of RPL control
+// messages
+func rplString(p ICMP, name string, sec *RPLSecurity) string {
+	m, _ := p.Marshal()
+	if sec != nil {
+		name = "secure " + name
+	}
+
+	return fmt.Sprintf("%s, length %d, %s, ", p.Type(), len(m), name)
+}
+
+// rplOptionsString returns the description of options of RPL control
+// messages
+func rplOptionsString(s string, sec *RPLSecurity, options RPLOptions) string {
+	if sec != nil {
+		s += fmt.Sprintf(", %s", sec)
+	}
+	s += "\n"
+	for _, o := range options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// ICMPDODAGInformationSolicitation implements the DODAG Information
+// Solicitation message as described at
+// https://tools.ietf.org/html/rfc6550#section-6.2
+type ICMPDODAGInformationSolicitation struct {
+	rplOptionContainer
+	Security *RPLSecurity
+}
+
+func (p ICMPDODAGInformationSolicitation) String() string {
+	s := strings.TrimSuffix(rplString(p, "dis", p.Security), ", ")
+
+	return rplOptionsString(s, p.Security, p.Options)
+}
+
+// Type returns ipv6.ICMPTypeRPLControl
+func (p ICMPDODAGInformationSolicitation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRPLControl
+}
+
+// Marshal returns byte slice representing this
+// ICMPDODAGInformationSolicitation
+func (p ICMPDODAGInformationSolicitation) Marshal() ([]byte, error) {
+	// flags and reserved, always 0
+	return marshalRPL(rplCodeDODAGInformationSolicitation, p.Security, make([]byte, 2), p.Options)
+}
+
+// RPLModeOfOperation describes the downward routing of a DODAG
+type RPLModeOfOperation uint8
+
+// modes currently defined
+const (
+	RPLModeOfOperationNoDownwardRoutes RPLModeOfOperation = iota
+	RPLModeOfOperationNonStoring
+	RPLModeOfOperationStoring
+	RPLModeOfOperationStoringMulticast
+)
+
+func (m RPLModeOfOperation) String() string {
+	switch m {
+	case RPLModeOfOperationNoDownwardRoutes:
+		return "no downward routes"
+	case RPLModeOfOperationNonStoring:
+		return "non-storing"
+	case RPLModeOfOperationStoring:
+		return "storing"
+	case RPLModeOfOperationStoringMulticast:
+		return "storing with multicast"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPDODAGInformationObject implements the DODAG Information Object
+// message as described at https://tools.ietf.org/html/rfc6550#section-6.3
+type ICMPDODAGInformationObject struct {
+	rplOptionContainer
+	Security        *RPLSecurity
+	InstanceID      uint8
+	Version         uint8
+	Rank            uint16
+	Grounded        bool
+	ModeOfOperation RPLModeOfOperation
+	// Preference of the DODAG root, 0 being the least preferred and 7 the
+	// most
+	Preference uint8
+	DTSN       uint8
+	DODAGID    net.IP
+}
+
+func (p ICMPDODAGInformationObject) String() string {
+	s := rplString(p, "dio", p.Security)
+	s += fmt.Sprintf("instance %d, version %d, rank %d, ", p.InstanceID, p.Version, p.Rank)
+	f := []string{}
+	if p.Grounded {
+		f = append(f, "grounded")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("mop %s, pref %d, dtsn %d, ", p.ModeOfOperation, p.Preference, p.DTSN)
+	s += fmt.Sprintf("dodag %s", p.DODAGID)
+
+	return rplOptionsString(s, p.Security, p.Options)
+}
+
+// Type returns ipv6.ICMPTypeRPLControl
+func (p ICMPDODAGInformationObject) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRPLControl
+}
+
+// Marshal returns byte slice representing this ICMPDODAGInformationObject
+func (p ICMPDODAGInformationObject) Marshal() ([]byte, error) {
+	if p.ModeOfOperation > 7 {
+		return nil, fmt.Errorf("invalid mode of operation %d", p.ModeOfOperation)
+	}
+
+	if p.Preference > 7 {
+		return nil, fmt.Errorf("invalid preference %d", p.Preference)
+	}
+
+	if p.DODAGID.To16() == nil {
+		return nil, fmt.Errorf("invalid dodag id %s", p.DODAGID)
+	}
+
+	b := make([]byte, 8)
+	b[0] = p.InstanceID
+	b[1] = p.Version
+	binary.BigEndian.PutUint16(b[2:4], p.Rank)
+	if p.Grounded {
+		b[4] ^= 0x80
+	}
+	b[4] ^= uint8(p.ModeOfOperation)<<3 | p.Preference
+	b[5] = p.DTSN
+	// b[6:8] = flags and reserved, always 0
+	b = append(b, p.DODAGID.To16()...)
+
+	return marshalRPL(rplCodeDODAGInformationObject, p.Security, b, p.Options)
+}
+
+// ICMPDestinationAdvertisementObject implements the Destination
+// Advertisement Object message as described at
+// https://tools.ietf.org/html/rfc6550#section-6.4
+type ICMPDestinationAdvertisementObject struct {
+	rplOptionContainer
+	Security     *RPLSecurity
+	InstanceID   uint8
+	AckRequested bool
+	Sequence     uint8
+	// DODAGID is optional and only included when set
+	DODAGID net.IP
+}
+
+func (p ICMPDestinationAdvertisementObject) String() string {
+	s := rplString(p, "dao", p.Security)
+	s += fmt.Sprintf("instance %d, ", p.InstanceID)
+	f := []string{}
+	if p.AckRequested {
+		f = append(f, "ack")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("seq %d", p.Sequence)
+	if p.DODAGID != nil {
+		s += fmt.Sprintf(", dodag %s", p.DODAGID)
+	}
+
+	return rplOptionsString(s, p.Security, p.Options)
+}
+
+// Type returns ipv6.ICMPTypeRPLControl
+func (p ICMPDestinationAdvertisementObject) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRPLControl
+}
+
+// Marshal returns byte slice representing this
+// ICMPDestinationAdvertisementObject
+func (p ICMPDestinationAdvertisementObject) Marshal() ([]byte, error) {
+	b := make([]byte, 4)
+	b[0] = p.InstanceID
+	if p.AckRequested {
+		b[1] ^= 0x80
+	}
+	// b[2] = reserved
+	b[3] = p.Sequence
+	if p.DODAGID != nil {
+		if p.DODAGID.To16() == nil {
+			return nil, fmt.Errorf("invalid dodag id %s", p.DODAGID)
+		}
+
+		b[1] ^= 0x40
+		b = append(b, p.DODAGID.To16()...)
+	}
+
+	return marshalRPL(rplCodeDestinationAdvertisementObject, p.Security, b, p.Options)
+}
+
+// ICMPDestinationAdvertisementObjectAck implements the Destination
+// Advertisement Object Acknowledgement message as described at
+// https://tools.ietf.org/html/rfc6550#section-6.5
+type ICMPDestinationAdvertisementObjectAck struct {
+	rplOptionContainer
+	Security   *RPLSecurity
+	InstanceID uint8
+	Sequence   uint8
+	// Status of 0 means unqualified acceptance, 128 and up rejection
+	Status uint8
+	// DODAGID is optional and only included when set
+	DODAGID net.IP
+}
+
+func (p ICMPDestinationAdvertisementObjectAck) String() string {
+	s := rplString(p, "dao-ack", p.Security)
+	s += fmt.Sprintf("instance %d, seq %d, status %d", p.InstanceID, p.Sequence, p.Status)
+	if p.DODAGID != nil {
+		s += fmt.Sprintf(", dodag %s", p.DODAGID)
+	}
+
+	return rplOptionsString(s, p.Security, p.Options)
+}
+
+// Type returns ipv6.ICMPTypeRPLControl
+func (p ICMPDestinationAdvertisementObjectAck) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRPLControl
+}
+
+// Marshal returns byte slice representing this
+// ICMPDestinationAdvertisementObjectAck
+func (p ICMPDestinationAdvertisementObjectAck) Marshal() ([]byte, error) {
+	b := make([]byte, 4)
+	b[0] = p.InstanceID
+	// b[1] = flags and reserved
+	b[2] = p.Sequence
+	b[3] = p.Status
+	if p.DODAGID != nil {
+		if p.DODAGID.To16() == nil {
+			return nil, fmt.Errorf("invalid dodag id %s", p.DODAGID)
+		}
+
+		b[1] ^= 0x80
+		b = append(b, p.DODAGID.To16()...)
+	}
+
+	return marshalRPL(rplCodeDestinationAdvertisementObjectAck, p.Security, b, p.Options)
+}
+
+func parseRPL(b []byte) (ICMP, error) {
+	if len(b) < 4 {
+		return nil, errMessageTooShort
+	}
+
+	var sec *RPLSecurity
+	body := b[4:]
+	if b[1]&rplCodeSecure > 0 {
+		var err error
+		sec, body, err = parseRPLSecurity(body)
+		if err != nil {
+			return nil, err
+		}
+
+		// without the key, there is nothing to parse
+		if sec.Encrypted() {
+			return nil, errRPLEncrypted
+		}
+	}
+
+	// parseOptions parses the options following the base of given size
+	parseOptions := func(base int) (RPLOptions, error) {
+		if len(body) > base {
+			return parseRPLOptions(body[base:])
+		}
+
+		return nil, nil
+	}
+
+	switch b[1] &^ rplCodeSecure {
+	case rplCodeDODAGInformationSolicitation:
+		if len(body) < 2 {
+			return nil, errMessageTooShort
+		}
+
+		p := &ICMPDODAGInformationSolicitation{Security: sec}
+		options, err := parseOptions(2)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Options = options
+		return p, nil
+
+	case rplCodeDODAGInformationObject:
+		if len(body) < 24 {
+			return nil, errMessageTooShort
+		}
+
+		p := &ICMPDODAGInformationObject{
+			Security:        sec,
+			InstanceID:      body[0],
+			Version:         body[1],
+			Rank:            binary.BigEndian.Uint16(body[2:4]),
+			Grounded:        (body[4]&0x80 > 0),
+			ModeOfOperation: RPLModeOfOperation((body[4] >> 3) & 0x07),
+			Preference:      body[4] & 0x07,
+			DTSN:            body[5],
+			DODAGID:         net.IP(body[8:24]),
+		}
+
+		options, err := parseOptions(24)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Options = options
+		return p, nil
+
+	case rplCodeDestinationAdvertisementObject:
+		if len(body) < 4 {
+			return nil, errMessageTooShort
+		}
+
+		p := &ICMPDestinationAdvertisementObject{
+			Security:     sec,
+			InstanceID:   body[0],
+			AckRequested: (body[1]&0x80 > 0),
+			Sequence:     body[3],
+		}
+
+		base := 4
+		if body[1]&0x40 > 0 {
+			if len(body) < 20 {
+				return nil, errMessageTooShort
+			}
+
+			p.DODAGID = net.IP(body[4:20])
+			base = 20
+		}
+
+		options, err := parseOptions(base)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Options = options
+		return p, nil
+
+	case rplCodeDestinationAdvertisementObjectAck:
+		if len(body) < 4 {
+			return nil, errMessageTooShort
+		}
+
+		p := &ICMPDestinationAdvertisementObjectAck{
+			Security:   sec,
+			InstanceID: body[0],
+			Sequence:   body[2],
+			Status:     body[3],
+		}
+
+		base := 4
+		if body[1]&0x80 > 0 {
+			if len(body) < 20 {
+				return nil, errMessageTooShort
+			}
+
+			p.DODAGID = net.IP(body[4:20])
+			base = 20
+		}
+
+		options, err := parseOptions(base)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Options = options
+		return p, nil
+
+	default:
+		return nil, fmt.Errorf("rpl control message with code %d not supported", b[1])
+	}
+}
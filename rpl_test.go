@@ -0,0 +1,195 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRPLControl(t *testing.T) {
+	dio := &ICMPDODAGInformationObject{
+		InstanceID:      1,
+		Version:         2,
+		Rank:            256,
+		Grounded:        true,
+		ModeOfOperation: RPLModeOfOperationStoring,
+		Preference:      3,
+		DTSN:            4,
+		DODAGID:         net.ParseIP("2001:db8::1"),
+	}
+	dio.AddOption(&RPLOptionDODAGConfiguration{
+		DIOIntervalDoublings:  20,
+		DIOIntervalMin:        3,
+		DIORedundancyConstant: 10,
+		MinHopRankIncrease:    256,
+		ObjectiveCodePoint:    1,
+		DefaultLifetime:       255,
+		LifetimeUnit:          65535,
+	})
+
+	dao := &ICMPDestinationAdvertisementObject{
+		InstanceID:   1,
+		AckRequested: true,
+		Sequence:     5,
+		DODAGID:      net.ParseIP("2001:db8::1"),
+	}
+	dao.AddOption(&RPLOptionTarget{PrefixLength: 128, Prefix: net.ParseIP("2001:db8::2")})
+	dao.AddOption(&RPLOptionTransitInformation{PathSequence: 7, PathLifetime: 255})
+
+	tests := []struct {
+		icmp    ICMP
+		fixture []byte
+		descfix string
+	}{
+		{
+			&ICMPDODAGInformationSolicitation{},
+			[]byte{155, 0, 0, 0, 0, 0},
+			"rpl control message, length 6, dis",
+		},
+		{
+			dio,
+			[]byte{155, 1, 0, 0, 1, 2, 1, 0, 147, 4, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 4, 14, 0, 20, 3, 10, 0, 0, 1, 0, 0, 1, 0, 255, 255, 255},
+			"rpl control message, length 44, dio, instance 1, version 2, rank 256, Flags [grounded], mop storing, pref 3, dtsn 4, dodag 2001:db8::1\n" +
+				"    dodag configuration option (4), length 14: Flags [], pcs 0, dio int doublings 20, min 3, redundancy 10, max rank inc 0, min hop rank inc 256, ocp 1, lifetime 255 x 65535s",
+		},
+		{
+			dao,
+			[]byte{155, 2, 0, 0, 1, 192, 0, 5, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 5, 18, 0, 128, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 6, 4, 0, 0, 7, 255},
+			"rpl control message, length 50, dao, instance 1, Flags [ack], seq 5, dodag 2001:db8::1\n" +
+				"    rpl target option (5), length 18: 2001:db8::2/128\n" +
+				"    transit information option (6), length 4: Flags [], path control 0, seq 7, lifetime 255",
+		},
+		{
+			&ICMPDestinationAdvertisementObjectAck{InstanceID: 1, Sequence: 5},
+			[]byte{155, 3, 0, 0, 1, 0, 5, 0},
+			"rpl control message, length 8, dao-ack, instance 1, seq 5, status 0",
+		},
+		{
+			&ICMPDODAGInformationSolicitation{
+				Security: &RPLSecurity{
+					Counter:       1,
+					KeyIdentifier: []byte{7},
+					MIC:           []byte{1, 2, 3, 4},
+				},
+			},
+			[]byte{155, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 7, 0, 0, 1, 2, 3, 4},
+			"rpl control message, length 19, secure dis, algorithm 0, kim 0, level 0, counter 1",
+		},
+		{
+			&ICMPDestinationAdvertisementObjectAck{
+				Security: &RPLSecurity{
+					CounterIsTime:     true,
+					KeyIdentifierMode: 2,
+					Level:             2,
+					Counter:           2,
+					KeyIdentifier:     []byte{1, 2, 3, 4, 5, 6, 7, 8, 9},
+					MIC:               []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				},
+				InstanceID: 1,
+				Sequence:   5,
+				Status:     128,
+				DODAGID:    net.ParseIP("2001:db8::1"),
+			},
+			[]byte{155, 131, 0, 0, 128, 0, 130, 0, 0, 0, 0, 2, 1, 2, 3, 4, 5, 6, 7, 8, 9, 1, 128, 5, 128, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 3, 4, 5, 6, 7, 8},
+			"rpl control message, length 49, secure dao-ack, instance 1, seq 5, status 128, dodag 2001:db8::1, algorithm 0, kim 2, level 2, counter 2",
+		},
+	}
+
+	for _, test := range tests {
+		if test.icmp.Type() != 155 {
+			t.Errorf("wrong type: %d instead of 155", test.icmp.Type())
+		}
+
+		marshal, err := test.icmp.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.icmp.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		parsedICMP, err := ParseMessage(test.fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsedMarshal, err := parsedICMP.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(parsedMarshal, marshal) != 0 {
+			t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+		}
+
+		_, err = ParseMessage(test.fixture[:5])
+		if err != errMessageTooShort {
+			t.Errorf("unexpected error message: %s", err)
+		}
+	}
+}
+
+func TestRPLControlInvalid(t *testing.T) {
+	// encrypted secure dis
+	_, err := ParseMessage([]byte{155, 128, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 7, 0, 0, 1, 2, 3, 4})
+	if err != errRPLEncrypted {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	// consistency check
+	if _, err = ParseMessage([]byte{155, 10, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Error("expected error parsing unsupported code")
+	}
+
+	// dao with dodag flag but without dodag id
+	if _, err = ParseMessage([]byte{155, 2, 0, 0, 1, 64, 0, 5}); err != errMessageTooShort {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	messages := []ICMP{
+		&ICMPDODAGInformationSolicitation{Security: &RPLSecurity{MIC: []byte{1, 2, 3, 4}}},
+		&ICMPDODAGInformationSolicitation{Security: &RPLSecurity{KeyIdentifier: []byte{1}}},
+		&ICMPDODAGInformationSolicitation{Security: &RPLSecurity{Level: 4}},
+		&ICMPDODAGInformationObject{},
+		&ICMPDODAGInformationObject{Preference: 8, DODAGID: net.ParseIP("2001:db8::1")},
+	}
+
+	for _, m := range messages {
+		if _, err := m.Marshal(); err == nil {
+			t.Errorf("expected error marshalling %s", m)
+		}
+	}
+}
+
+func TestRPLSecurityLengths(t *testing.T) {
+	tests := []struct {
+		sec RPLSecurity
+		key int
+		mic int
+	}{
+		{RPLSecurity{KeyIdentifierMode: 0, Level: 0}, 1, 4},
+		{RPLSecurity{KeyIdentifierMode: 1, Level: 2}, 0, 8},
+		{RPLSecurity{KeyIdentifierMode: 2, Level: 3}, 9, 8},
+		{RPLSecurity{KeyIdentifierMode: 3, Level: 0}, 0, 384},
+		{RPLSecurity{KeyIdentifierMode: 3, Level: 2}, 0, 256},
+	}
+
+	for _, test := range tests {
+		key, err := test.sec.keyIdentifierLength()
+		if err != nil || key != test.key {
+			t.Errorf("unexpected key identifier length %d for kim %d", key, test.sec.KeyIdentifierMode)
+		}
+
+		mic, err := test.sec.micLength()
+		if err != nil || mic != test.mic {
+			t.Errorf("unexpected mic length %d for kim %d level %d", mic, test.sec.KeyIdentifierMode, test.sec.Level)
+		}
+	}
+}
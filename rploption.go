@@ -0,0 +1,709 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// RPLOption describes the options of RPL control messages, which unlike
+// ICMPOption have their length in bytes
+type RPLOption interface {
+	String() string
+	// Len returns the option length in bytes, not counting type and length
+	Len() uint8
+	Marshal() ([]byte, error)
+	Type() RPLOptionType
+}
+
+// RPLOptions is a type wrapper for a slice of RPLOptions
+type RPLOptions []RPLOption
+
+// Marshal is a helper function of RPLOptions and returns marshalled results
+// for all RPLOptions or error when there is one
+func (opts RPLOptions) Marshal() ([]byte, error) {
+	var b []byte
+	for _, o := range opts {
+		m, err := o.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, m...)
+	}
+
+	return b, nil
+}
+
+// RPLOptionType describes RPL control message option types
+type RPLOptionType uint8
+
+// RPL control message option types as described in RFC6550
+const (
+	RPLOptionTypePad1 RPLOptionType = iota
+	RPLOptionTypePadN
+	RPLOptionTypeDAGMetricContainer
+	RPLOptionTypeRouteInformation
+	RPLOptionTypeDODAGConfiguration
+	RPLOptionTypeTarget
+	RPLOptionTypeTransitInformation
+	RPLOptionTypeSolicitedInformation
+	RPLOptionTypePrefixInformation
+)
+
+func (typ RPLOptionType) String() string {
+	switch typ {
+	case RPLOptionTypePad1:
+		return "pad1"
+	case RPLOptionTypePadN:
+		return "padn"
+	case RPLOptionTypeDAGMetricContainer:
+		return "dag metric container"
+	case RPLOptionTypeRouteInformation:
+		return "route information"
+	case RPLOptionTypeDODAGConfiguration:
+		return "dodag configuration"
+	case RPLOptionTypeTarget:
+		return "rpl target"
+	case RPLOptionTypeTransitInformation:
+		return "transit information"
+	case RPLOptionTypeSolicitedInformation:
+		return "solicited information"
+	case RPLOptionTypePrefixInformation:
+		return "prefix information"
+	default:
+		return "<nil>"
+	}
+}
+
+// rplOptionString returns the common start of the description of RPL
+// control message options
+func rplOptionString(o RPLOption) string {
+	return fmt.Sprintf("%s option (%d), length %d", o.Type(), o.Type(), o.Len())
+}
+
+// rplPrefixBytes returns the bytes of prefix needed to contain given prefix
+// length
+func rplPrefixBytes(prefix net.IP, length uint8) ([]byte, error) {
+	if length > 128 {
+		return nil, fmt.Errorf("invalid prefix length %d", length)
+	}
+
+	p := prefix.To16()
+	if p == nil {
+		return nil, fmt.Errorf("invalid prefix %s", prefix)
+	}
+
+	return p.Mask(net.CIDRMask(int(length), 128))[:((int(length) + 7) / 8)], nil
+}
+
+// rplPrefix returns the prefix of given length from the truncated prefix in
+// given bytes
+func rplPrefix(b []byte, length uint8) (net.IP, error) {
+	if length > 128 || len(b) < (int(length)+7)/8 || len(b) > net.IPv6len {
+		return nil, fmt.Errorf("invalid prefix of %d bytes for length %d", len(b), length)
+	}
+
+	prefix := make(net.IP, net.IPv6len)
+	copy(prefix, b)
+
+	return prefix, nil
+}
+
+// RPLOptionUnknown is an RPL control message option not known to this
+// package
+type RPLOptionUnknown struct {
+	optionType RPLOptionType
+	body       []byte
+}
+
+func (o RPLOptionUnknown) String() string {
+	return fmt.Sprintf("unknown option (%d), length %d", o.optionType, o.Len())
+}
+
+// Type returns apparent type of this option
+func (o RPLOptionUnknown) Type() RPLOptionType {
+	return o.optionType
+}
+
+// Len returns known length for this option
+func (o RPLOptionUnknown) Len() uint8 {
+	return uint8(len(o.body))
+}
+
+// Marshal returns byte slice representing this RPLOptionUnknown
+func (o RPLOptionUnknown) Marshal() ([]byte, error) {
+	b := []byte{uint8(o.optionType), o.Len()}
+
+	return append(b, o.body...), nil
+}
+
+// RPLOptionPad1 implements the Pad1 option as described at
+// https://tools.ietf.org/html/rfc6550#section-6.7.2
+type RPLOptionPad1 struct{}
+
+func (o RPLOptionPad1) String() string {
+	return fmt.Sprintf("%s option (%d)", o.Type(), o.Type())
+}
+
+// Type returns RPLOptionTypePad1
+func (o RPLOptionPad1) Type() RPLOptionType {
+	return RPLOptionTypePad1
+}
+
+// Len returns 0, Pad1 options have no length
+func (o RPLOptionPad1) Len() uint8 {
+	return 0
+}
+
+// Marshal returns byte slice representing this RPLOptionPad1
+func (o RPLOptionPad1) Marshal() ([]byte, error) {
+	return []byte{byte(o.Type())}, nil
+}
+
+// RPLOptionPadN implements the PadN option as described at
+// https://tools.ietf.org/html/rfc6550#section-6.7.3
+type RPLOptionPadN struct {
+	// N is the number of padding bytes following the option header, up to 5
+	N uint8
+}
+
+func (o RPLOptionPadN) String() string {
+	return rplOptionString(o)
+}
+
+// Type returns RPLOptionTypePadN
+func (o RPLOptionPadN) Type() RPLOptionType {
+	return RPLOptionTypePadN
+}
+
+// Len returns the length in bytes of RPLOptionPadN
+func (o RPLOptionPadN) Len() uint8 {
+	return o.N
+}
+
+// Marshal returns byte slice representing this RPLOptionPadN
+func (o RPLOptionPadN) Marshal() ([]byte, error) {
+	if o.N > 5 {
+		return nil, fmt.Errorf("padn of %d bytes should be at most 5 bytes", o.N)
+	}
+
+	b := make([]byte, 2+int(o.N))
+	b[0] = byte(o.Type())
+	b[1] = o.Len()
+
+	return b, nil
+}
+
+// RPLOptionDAGMetricContainer implements the DAG Metric Container option as
+// described at https://tools.ietf.org/html/rfc6550#section-6.7.4
+// The metric data is described in RFC6551 and kept as is.
+type RPLOptionDAGMetricContainer struct {
+	Data []byte
+}
+
+func (o RPLOptionDAGMetricContainer) String() string {
+	return rplOptionString(o) + fmt.Sprintf(": %x", o.Data)
+}
+
+// Type returns RPLOptionTypeDAGMetricContainer
+func (o RPLOptionDAGMetricContainer) Type() RPLOptionType {
+	return RPLOptionTypeDAGMetricContainer
+}
+
+// Len returns the length in bytes of RPLOptionDAGMetricContainer
+func (o RPLOptionDAGMetricContainer) Len() uint8 {
+	return uint8(len(o.Data))
+}
+
+// Marshal returns byte slice representing this RPLOptionDAGMetricContainer
+func (o RPLOptionDAGMetricContainer) Marshal() ([]byte, error) {
+	if len(o.Data) > 255 {
+		return nil, fmt.Errorf("metric data of %d bytes too long", len(o.Data))
+	}
+
+	b := []byte{byte(o.Type()), o.Len()}
+
+	return append(b, o.Data...), nil
+}
+
+// RPLOptionRouteInformation implements the Route Information option as
+// described at https://tools.ietf.org/html/rfc6550#section-6.7.5
+type RPLOptionRouteInformation struct {
+	PrefixLength  uint8
+	Preference    RouterPreferenceField
+	RouteLifetime uint32
+	Prefix        net.IP
+}
+
+func (o RPLOptionRouteInformation) String() string {
+	s := rplOptionString(o)
+	s += fmt.Sprintf(": %s/%d, ", o.Prefix, o.PrefixLength)
+	s += fmt.Sprintf("pref %s, ", o.Preference)
+	s += fmt.Sprintf("lifetime %ds", o.RouteLifetime)
+
+	return s
+}
+
+// Type returns RPLOptionTypeRouteInformation
+func (o RPLOptionRouteInformation) Type() RPLOptionType {
+	return RPLOptionTypeRouteInformation
+}
+
+// Len returns the length in bytes of RPLOptionRouteInformation
+func (o RPLOptionRouteInformation) Len() uint8 {
+	return 6 + (o.PrefixLength+7)/8
+}
+
+// Marshal returns byte slice representing this RPLOptionRouteInformation
+func (o RPLOptionRouteInformation) Marshal() ([]byte, error) {
+	prefix, err := rplPrefixBytes(o.Prefix, o.PrefixLength)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 8)
+	b[0] = byte(o.Type())
+	b[1] = o.Len()
+	b[2] = o.PrefixLength
+	b[3] = byte(o.Preference) << 3
+	binary.BigEndian.PutUint32(b[4:8], o.RouteLifetime)
+
+	return append(b, prefix...), nil
+}
+
+// RPLOptionDODAGConfiguration implements the DODAG Configuration option as
+// described at https://tools.ietf.org/html/rfc6550#section-6.7.6
+type RPLOptionDODAGConfiguration struct {
+	Authentication        bool
+	PathControlSize       uint8
+	DIOIntervalDoublings  uint8
+	DIOIntervalMin        uint8
+	DIORedundancyConstant uint8
+	MaxRankIncrease       uint16
+	MinHopRankIncrease    uint16
+	ObjectiveCodePoint    uint16
+	DefaultLifetime       uint8
+	LifetimeUnit          uint16
+}
+
+func (o RPLOptionDODAGConfiguration) String() string {
+	s := rplOptionString(o) + ": "
+	f := []string{}
+	if o.Authentication {
+		f = append(f, "authentication")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("pcs %d, ", o.PathControlSize)
+	s += fmt.Sprintf("dio int doublings %d, min %d, redundancy %d, ", o.DIOIntervalDoublings, o.DIOIntervalMin, o.DIORedundancyConstant)
+	s += fmt.Sprintf("max rank inc %d, min hop rank inc %d, ", o.MaxRankIncrease, o.MinHopRankIncrease)
+	s += fmt.Sprintf("ocp %d, ", o.ObjectiveCodePoint)
+	s += fmt.Sprintf("lifetime %d x %ds", o.DefaultLifetime, o.LifetimeUnit)
+
+	return s
+}
+
+// Type returns RPLOptionTypeDODAGConfiguration
+func (o RPLOptionDODAGConfiguration) Type() RPLOptionType {
+	return RPLOptionTypeDODAGConfiguration
+}
+
+// Len returns the length in bytes of RPLOptionDODAGConfiguration
+func (o RPLOptionDODAGConfiguration) Len() uint8 {
+	// DODAG configuration options are always 14
+	return 14
+}
+
+// Marshal returns byte slice representing this RPLOptionDODAGConfiguration
+func (o RPLOptionDODAGConfiguration) Marshal() ([]byte, error) {
+	if o.PathControlSize > 7 {
+		return nil, fmt.Errorf("invalid path control size %d", o.PathControlSize)
+	}
+
+	b := make([]byte, 16)
+	b[0] = byte(o.Type())
+	b[1] = o.Len()
+	b[2] = o.PathControlSize
+	if o.Authentication {
+		b[2] ^= 0x08
+	}
+	b[3] = o.DIOIntervalDoublings
+	b[4] = o.DIOIntervalMin
+	b[5] = o.DIORedundancyConstant
+	binary.BigEndian.PutUint16(b[6:8], o.MaxRankIncrease)
+	binary.BigEndian.PutUint16(b[8:10], o.MinHopRankIncrease)
+	binary.BigEndian.PutUint16(b[10:12], o.ObjectiveCodePoint)
+	// b[12] = reserved
+	b[13] = o.DefaultLifetime
+	binary.BigEndian.PutUint16(b[14:16], o.LifetimeUnit)
+
+	return b, nil
+}
+
+// RPLOptionTarget implements the RPL Target option as described at
+// https://tools.ietf.org/html/rfc6550#section-6.7.7
+type RPLOptionTarget struct {
+	PrefixLength uint8
+	Prefix       net.IP
+}
+
+func (o RPLOptionTarget) String() string {
+	return rplOptionString(o) + fmt.Sprintf(": %s/%d", o.Prefix, o.PrefixLength)
+}
+
+// Type returns RPLOptionTypeTarget
+func (o RPLOptionTarget) Type() RPLOptionType {
+	return RPLOptionTypeTarget
+}
+
+// Len returns the length in bytes of RPLOptionTarget
+func (o RPLOptionTarget) Len() uint8 {
+	return 2 + (o.PrefixLength+7)/8
+}
+
+// Marshal returns byte slice representing this RPLOptionTarget
+func (o RPLOptionTarget) Marshal() ([]byte, error) {
+	prefix, err := rplPrefixBytes(o.Prefix, o.PrefixLength)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 4)
+	b[0] = byte(o.Type())
+	b[1] = o.Len()
+	// b[2] = flags, always 0
+	b[3] = o.PrefixLength
+
+	return append(b, prefix...), nil
+}
+
+// RPLOptionTransitInformation implements the Transit Information option as
+// described at https://tools.ietf.org/html/rfc6550#section-6.7.8
+type RPLOptionTransitInformation struct {
+	External     bool
+	PathControl  uint8
+	PathSequence uint8
+	PathLifetime uint8
+	// ParentAddress is optional and only included when set, as in
+	// non-storing mode
+	ParentAddress net.IP
+}
+
+func (o RPLOptionTransitInformation) String() string {
+	s := rplOptionString(o) + ": "
+	f := []string{}
+	if o.External {
+		f = append(f, "external")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("path control %d, seq %d, lifetime %d", o.PathControl, o.PathSequence, o.PathLifetime)
+	if o.ParentAddress != nil {
+		s += fmt.Sprintf(", parent %s", o.ParentAddress)
+	}
+
+	return s
+}
+
+// Type returns RPLOptionTypeTransitInformation
+func (o RPLOptionTransitInformation) Type() RPLOptionType {
+	return RPLOptionTypeTransitInformation
+}
+
+// Len returns the length in bytes of RPLOptionTransitInformation
+func (o RPLOptionTransitInformation) Len() uint8 {
+	if o.ParentAddress != nil {
+		return 20
+	}
+
+	return 4
+}
+
+// Marshal returns byte slice representing this RPLOptionTransitInformation
+func (o RPLOptionTransitInformation) Marshal() ([]byte, error) {
+	b := make([]byte, 6)
+	b[0] = byte(o.Type())
+	b[1] = o.Len()
+	if o.External {
+		b[2] ^= 0x80
+	}
+	b[3] = o.PathControl
+	b[4] = o.PathSequence
+	b[5] = o.PathLifetime
+	if o.ParentAddress != nil {
+		if o.ParentAddress.To16() == nil {
+			return nil, fmt.Errorf("invalid parent address %s", o.ParentAddress)
+		}
+
+		b = append(b, o.ParentAddress.To16()...)
+	}
+
+	return b, nil
+}
+
+// RPLOptionSolicitedInformation implements the Solicited Information option
+// as described at https://tools.ietf.org/html/rfc6550#section-6.7.9
+type RPLOptionSolicitedInformation struct {
+	InstanceID uint8
+	// the predicates tell which of the fields DIOs should match
+	VersionPredicate    bool
+	InstanceIDPredicate bool
+	DODAGIDPredicate    bool
+	DODAGID             net.IP
+	Version             uint8
+}
+
+func (o RPLOptionSolicitedInformation) String() string {
+	s := rplOptionString(o) + ": "
+	s += fmt.Sprintf("instance %d, ", o.InstanceID)
+	f := []string{}
+	if o.VersionPredicate {
+		f = append(f, "version")
+	}
+	if o.InstanceIDPredicate {
+		f = append(f, "instance")
+	}
+	if o.DODAGIDPredicate {
+		f = append(f, "dodag")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("dodag %s, version %d", o.DODAGID, o.Version)
+
+	return s
+}
+
+// Type returns RPLOptionTypeSolicitedInformation
+func (o RPLOptionSolicitedInformation) Type() RPLOptionType {
+	return RPLOptionTypeSolicitedInformation
+}
+
+// Len returns the length in bytes of RPLOptionSolicitedInformation
+func (o RPLOptionSolicitedInformation) Len() uint8 {
+	// Solicited information options are always 19
+	return 19
+}
+
+// Marshal returns byte slice representing this RPLOptionSolicitedInformation
+func (o RPLOptionSolicitedInformation) Marshal() ([]byte, error) {
+	dodag := o.DODAGID.To16()
+	if dodag == nil {
+		dodag = net.IPv6unspecified
+	}
+
+	b := make([]byte, 4)
+	b[0] = byte(o.Type())
+	b[1] = o.Len()
+	b[2] = o.InstanceID
+	if o.VersionPredicate {
+		b[3] ^= 0x80
+	}
+	if o.InstanceIDPredicate {
+		b[3] ^= 0x40
+	}
+	if o.DODAGIDPredicate {
+		b[3] ^= 0x20
+	}
+	b = append(b, dodag...)
+	b = append(b, o.Version)
+
+	return b, nil
+}
+
+// RPLOptionPrefixInformation implements the Prefix Information option as
+// described at https://tools.ietf.org/html/rfc6550#section-6.7.10
+// Its fields are those of the prefix information option of neighbor
+// discovery.
+type RPLOptionPrefixInformation struct {
+	ICMPOptionPrefixInformation
+}
+
+func (o RPLOptionPrefixInformation) String() string {
+	s := rplOptionString(o)
+	s += fmt.Sprintf(": %s/%d, ", o.Prefix, o.PrefixLength)
+	f := []string{}
+	if o.OnLink {
+		f = append(f, "onlink")
+	}
+	if o.Auto {
+		f = append(f, "auto")
+	}
+	if o.RouterAddress {
+		f = append(f, "router")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("valid time %ds, ", o.ValidLifetime)
+	s += fmt.Sprintf("pref. time %ds", o.PreferredLifetime)
+
+	return s
+}
+
+// Type returns RPLOptionTypePrefixInformation
+func (o RPLOptionPrefixInformation) Type() RPLOptionType {
+	return RPLOptionTypePrefixInformation
+}
+
+// Len returns the length in bytes of RPLOptionPrefixInformation
+func (o RPLOptionPrefixInformation) Len() uint8 {
+	// Prefix information options are always 30
+	return 30
+}
+
+// Marshal returns byte slice representing this RPLOptionPrefixInformation
+func (o RPLOptionPrefixInformation) Marshal() ([]byte, error) {
+	b, err := o.ICMPOptionPrefixInformation.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	// same body with RPL option header
+	b[0] = byte(o.Type())
+	b[1] = o.Len()
+
+	return b, nil
+}
+
+// parseRPLOptions returns a slice of RPLOptions for given bytes or error if
+// it couldn't parse them
+func parseRPLOptions(b []byte) (RPLOptions, error) {
+	var options RPLOptions
+
+	for len(b) > 0 {
+		optionType := RPLOptionType(b[0])
+		if optionType == RPLOptionTypePad1 {
+			options = append(options, &RPLOptionPad1{})
+			b = b[1:]
+			continue
+		}
+
+		if len(b) < 2 {
+			return nil, fmt.Errorf("too few bytes received: %d while at least 2 expected", len(b))
+		}
+
+		optionLength := int(b[1])
+		if len(b) < 2+optionLength {
+			return nil, fmt.Errorf("too few bytes received: %d while at least %d expected", len(b), 2+optionLength)
+		}
+
+		body := b[2:(2 + optionLength)]
+		var currentOption RPLOption
+
+		switch optionType {
+		case RPLOptionTypePadN:
+			if optionLength > 5 {
+				return nil, fmt.Errorf("option %s (%d) too long: %d", optionType, optionType, optionLength)
+			}
+
+			currentOption = &RPLOptionPadN{N: uint8(optionLength)}
+
+		case RPLOptionTypeDAGMetricContainer:
+			currentOption = &RPLOptionDAGMetricContainer{Data: body}
+
+		case RPLOptionTypeRouteInformation:
+			if optionLength < 6 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d", optionType, optionType, optionLength)
+			}
+
+			prefix, err := rplPrefix(body[6:], body[0])
+			if err != nil {
+				return nil, err
+			}
+
+			currentOption = &RPLOptionRouteInformation{
+				PrefixLength:  body[0],
+				Preference:    RouterPreferenceField((body[1] >> 3) & 0x03),
+				RouteLifetime: binary.BigEndian.Uint32(body[2:6]),
+				Prefix:        prefix,
+			}
+
+		case RPLOptionTypeDODAGConfiguration:
+			if optionLength != 14 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d should be 14", optionType, optionType, optionLength)
+			}
+
+			currentOption = &RPLOptionDODAGConfiguration{
+				Authentication:        (body[0]&0x08 > 0),
+				PathControlSize:       body[0] & 0x07,
+				DIOIntervalDoublings:  body[1],
+				DIOIntervalMin:        body[2],
+				DIORedundancyConstant: body[3],
+				MaxRankIncrease:       binary.BigEndian.Uint16(body[4:6]),
+				MinHopRankIncrease:    binary.BigEndian.Uint16(body[6:8]),
+				ObjectiveCodePoint:    binary.BigEndian.Uint16(body[8:10]),
+				DefaultLifetime:       body[11],
+				LifetimeUnit:          binary.BigEndian.Uint16(body[12:14]),
+			}
+
+		case RPLOptionTypeTarget:
+			if optionLength < 2 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d", optionType, optionType, optionLength)
+			}
+
+			prefix, err := rplPrefix(body[2:], body[1])
+			if err != nil {
+				return nil, err
+			}
+
+			currentOption = &RPLOptionTarget{
+				PrefixLength: body[1],
+				Prefix:       prefix,
+			}
+
+		case RPLOptionTypeTransitInformation:
+			if optionLength != 4 && optionLength != 20 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d", optionType, optionType, optionLength)
+			}
+
+			o := &RPLOptionTransitInformation{
+				External:     (body[0]&0x80 > 0),
+				PathControl:  body[1],
+				PathSequence: body[2],
+				PathLifetime: body[3],
+			}
+			if optionLength == 20 {
+				o.ParentAddress = net.IP(body[4:20])
+			}
+			currentOption = o
+
+		case RPLOptionTypeSolicitedInformation:
+			if optionLength != 19 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d should be 19", optionType, optionType, optionLength)
+			}
+
+			currentOption = &RPLOptionSolicitedInformation{
+				InstanceID:          body[0],
+				VersionPredicate:    (body[1]&0x80 > 0),
+				InstanceIDPredicate: (body[1]&0x40 > 0),
+				DODAGIDPredicate:    (body[1]&0x20 > 0),
+				DODAGID:             net.IP(body[2:18]),
+				Version:             body[18],
+			}
+
+		case RPLOptionTypePrefixInformation:
+			if optionLength != 30 {
+				return nil, fmt.Errorf("option %s (%d) has invalid length: %d should be 30", optionType, optionType, optionLength)
+			}
+
+			currentOption = &RPLOptionPrefixInformation{
+				ICMPOptionPrefixInformation{
+					PrefixLength:      body[0],
+					OnLink:            (body[1]&0x80 > 0),
+					Auto:              (body[1]&0x40 > 0),
+					RouterAddress:     (body[1]&0x20 > 0),
+					ValidLifetime:     binary.BigEndian.Uint32(body[2:6]),
+					PreferredLifetime: binary.BigEndian.Uint32(body[6:10]),
+					Prefix:            net.IP(body[14:30]),
+				},
+			}
+
+		default:
+			currentOption = &RPLOptionUnknown{
+				optionType: optionType,
+				body:       body,
+			}
+		}
+
+		options = append(options, currentOption)
+		b = b[(2 + optionLength):]
+	}
+
+	return options, nil
+}
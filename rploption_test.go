@@ -0,0 +1,246 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRPLOptions(t *testing.T) {
+	tests := []struct {
+		option  RPLOption
+		typ     RPLOptionType
+		fixture []byte
+		descfix string
+	}{
+		{
+			&RPLOptionPad1{},
+			RPLOptionTypePad1,
+			[]byte{0},
+			"pad1 option (0)",
+		},
+		{
+			&RPLOptionPadN{N: 2},
+			RPLOptionTypePadN,
+			[]byte{1, 2, 0, 0},
+			"padn option (1), length 2",
+		},
+		{
+			&RPLOptionDAGMetricContainer{Data: []byte{1, 2, 3}},
+			RPLOptionTypeDAGMetricContainer,
+			[]byte{2, 3, 1, 2, 3},
+			"dag metric container option (2), length 3: 010203",
+		},
+		{
+			&RPLOptionRouteInformation{
+				PrefixLength:  48,
+				Preference:    RouterPreferenceHigh,
+				RouteLifetime: 3600,
+				Prefix:        net.ParseIP("2001:db8:1::"),
+			},
+			RPLOptionTypeRouteInformation,
+			[]byte{3, 12, 48, 8, 0, 0, 14, 16, 32, 1, 13, 184, 0, 1},
+			"route information option (3), length 12: 2001:db8:1::/48, pref high, lifetime 3600s",
+		},
+		{
+			&RPLOptionDODAGConfiguration{
+				Authentication:        true,
+				PathControlSize:       2,
+				DIOIntervalDoublings:  20,
+				DIOIntervalMin:        3,
+				DIORedundancyConstant: 10,
+				MaxRankIncrease:       1792,
+				MinHopRankIncrease:    256,
+				ObjectiveCodePoint:    1,
+				DefaultLifetime:       255,
+				LifetimeUnit:          65535,
+			},
+			RPLOptionTypeDODAGConfiguration,
+			[]byte{4, 14, 10, 20, 3, 10, 7, 0, 1, 0, 0, 1, 0, 255, 255, 255},
+			"dodag configuration option (4), length 14: Flags [authentication], pcs 2, dio int doublings 20, min 3, redundancy 10, max rank inc 1792, min hop rank inc 256, ocp 1, lifetime 255 x 65535s",
+		},
+		{
+			&RPLOptionTarget{
+				PrefixLength: 64,
+				Prefix:       net.ParseIP("2001:db8::"),
+			},
+			RPLOptionTypeTarget,
+			[]byte{5, 10, 0, 64, 32, 1, 13, 184, 0, 0, 0, 0},
+			"rpl target option (5), length 10: 2001:db8::/64",
+		},
+		{
+			&RPLOptionTransitInformation{
+				External:      true,
+				PathSequence:  1,
+				PathLifetime:  30,
+				ParentAddress: net.ParseIP("2001:db8::1"),
+			},
+			RPLOptionTypeTransitInformation,
+			[]byte{6, 20, 128, 0, 1, 30, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			"transit information option (6), length 20: Flags [external], path control 0, seq 1, lifetime 30, parent 2001:db8::1",
+		},
+		{
+			&RPLOptionSolicitedInformation{
+				InstanceID:          1,
+				VersionPredicate:    true,
+				InstanceIDPredicate: true,
+				DODAGID:             net.ParseIP("2001:db8::1"),
+				Version:             3,
+			},
+			RPLOptionTypeSolicitedInformation,
+			[]byte{7, 19, 1, 192, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 3},
+			"solicited information option (7), length 19: instance 1, Flags [version instance], dodag 2001:db8::1, version 3",
+		},
+		{
+			&RPLOptionPrefixInformation{
+				ICMPOptionPrefixInformation{
+					PrefixLength:      64,
+					OnLink:            true,
+					Auto:              true,
+					ValidLifetime:     86400,
+					PreferredLifetime: 14400,
+					Prefix:            net.ParseIP("2001:db8::"),
+				},
+			},
+			RPLOptionTypePrefixInformation,
+			[]byte{8, 30, 64, 192, 0, 1, 81, 128, 0, 0, 56, 64, 0, 0, 0, 0, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			"prefix information option (8), length 30: 2001:db8::/64, Flags [onlink auto], valid time 86400s, pref. time 14400s",
+		},
+	}
+
+	var all []byte
+	for _, test := range tests {
+		if test.option.Type() != test.typ {
+			t.Errorf("wrong type: %d instead of %d", test.option.Type(), test.typ)
+		}
+
+		marshal, err := test.option.Marshal()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if bytes.Compare(marshal, test.fixture) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.fixture, marshal)
+		}
+
+		desc := test.option.String()
+		if strings.Compare(desc, test.descfix) != 0 {
+			t.Errorf("fixture of '%s' did not match '%s'", test.descfix, desc)
+		}
+
+		all = append(all, test.fixture...)
+	}
+
+	// options are parsed back to back, unaligned
+	options, err := parseRPLOptions(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(options) != len(tests) {
+		t.Fatalf("expected %d options, got %d", len(tests), len(options))
+	}
+
+	for i, o := range options {
+		if o.Type() != tests[i].typ {
+			t.Errorf("wrong type: %d instead of %d", o.Type(), tests[i].typ)
+		}
+	}
+
+	parsedMarshal, err := options.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, all) != 0 {
+		t.Errorf("marshal of %v did not match %v", all, parsedMarshal)
+	}
+}
+
+func TestRPLOptionUnknown(t *testing.T) {
+	fixture := []byte{42, 2, 1, 2}
+	options, err := parseRPLOptions(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := options[0].(*RPLOptionUnknown); !ok {
+		t.Fatalf("expected unknown option, got %T", options[0])
+	}
+
+	if desc := options[0].String(); desc != "unknown option (42), length 2" {
+		t.Errorf("unexpected description '%s'", desc)
+	}
+
+	marshal, err := options.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("marshal of %v did not match %v", fixture, marshal)
+	}
+}
+
+func TestRPLOptionsInvalid(t *testing.T) {
+	fixtures := [][]byte{
+		// truncated header
+		{1},
+		// exceeding message
+		{2, 4, 1, 2},
+		// padn too long
+		{1, 6, 0, 0, 0, 0, 0, 0},
+		// dodag configuration too short
+		{4, 13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		// route information with prefix longer than 128 bits
+		{3, 6, 129, 0, 0, 0, 0, 0},
+		// target with truncated prefix
+		{5, 3, 0, 64, 32},
+		// transit information with partial parent address
+		{6, 8, 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+
+	for _, fixture := range fixtures {
+		if _, err := parseRPLOptions(fixture); err == nil {
+			t.Errorf("expected error parsing %v", fixture)
+		}
+	}
+
+	options := []RPLOption{
+		&RPLOptionPadN{N: 6},
+		&RPLOptionRouteInformation{PrefixLength: 129, Prefix: net.ParseIP("2001:db8::")},
+		&RPLOptionTarget{PrefixLength: 64},
+		&RPLOptionDODAGConfiguration{PathControlSize: 8},
+	}
+
+	for _, option := range options {
+		if _, err := option.Marshal(); err == nil {
+			t.Errorf("expected error marshalling %T", option)
+		}
+	}
+}
+
+func TestRPLOptionTypeString(t *testing.T) {
+	tests := []struct {
+		typ  RPLOptionType
+		desc string
+	}{
+		{RPLOptionTypePad1, "pad1"},
+		{RPLOptionTypePadN, "padn"},
+		{RPLOptionTypeDAGMetricContainer, "dag metric container"},
+		{RPLOptionTypeRouteInformation, "route information"},
+		{RPLOptionTypeDODAGConfiguration, "dodag configuration"},
+		{RPLOptionTypeTarget, "rpl target"},
+		{RPLOptionTypeTransitInformation, "transit information"},
+		{RPLOptionTypeSolicitedInformation, "solicited information"},
+		{RPLOptionTypePrefixInformation, "prefix information"},
+		{RPLOptionType(42), "<nil>"},
+	}
+
+	for _, test := range tests {
+		if desc := test.typ.String(); desc != test.desc {
+			t.Errorf("unexpected description %s for %d", desc, test.typ)
+		}
+	}
+}
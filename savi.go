@@ -0,0 +1,335 @@
+package ndp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// saviTentativeLifetime is how long a binding stays tentative or testing
+	// waiting for a defending advertisement, TENT_LT described at
+	// https://tools.ietf.org/html/rfc6620#section-4
+	saviTentativeLifetime = 500 * time.Millisecond
+	// saviDefaultLifetime is how long a valid binding lasts without traffic
+	// before it is tested again, DEFAULT_LT described at
+	// https://tools.ietf.org/html/rfc6620#section-4
+	saviDefaultLifetime = 5 * time.Minute
+)
+
+// SAVIState is the state of a binding in SAVITable
+type SAVIState uint8
+
+// states currently defined
+const (
+	SAVITentative SAVIState = iota
+	SAVIValid
+	SAVITesting
+)
+
+func (s SAVIState) String() string {
+	switch s {
+	case SAVITentative:
+		return "tentative"
+	case SAVIValid:
+		return "valid"
+	case SAVITesting:
+		return "testing"
+	}
+
+	return "<nil>"
+}
+
+// SAVIBinding binds an address to the anchor it is allowed on
+type SAVIBinding struct {
+	Address net.IP
+	// Anchor identifies where traffic enters, like a port or link-layer
+	// address
+	Anchor  string
+	State   SAVIState
+	Expires time.Time
+}
+
+func (b SAVIBinding) String() string {
+	return fmt.Sprintf("%s on %s, %s", b.Address, b.Anchor, b.State)
+}
+
+// SAVIProbe is a neighbor solicitation to send to verify whether an address
+// is in use
+type SAVIProbe struct {
+	// Anchor is the anchor to send the probe on or, when Flood is set, the
+	// only anchor not to send it on
+	Anchor      string
+	Flood       bool
+	Destination net.IP
+	Message     ICMP
+}
+
+// SAVITable implements First-Come, First-Served Source Address Validation
+// as described at https://tools.ietf.org/html/rfc6620, to be used by a switch
+// to drop traffic from spoofed source addresses. Addresses are bound to the
+// anchor they were first claimed on, by duplicate address detection or by
+// traffic using them, and claims on other anchors are resolved by probing
+// whether the address is still in use on the anchor it is bound to.
+// Probes are sent by the caller, using the unspecified source address.
+type SAVITable struct {
+	maxBindings int
+
+	mu       sync.Mutex
+	bindings map[string]*SAVIBinding
+}
+
+// NewSAVITable returns an empty SAVITable that allows given number of
+// bindings per anchor, 0 doesn't limit the number
+func NewSAVITable(maxBindings int) *SAVITable {
+	return &SAVITable{
+		maxBindings: maxBindings,
+		bindings:    make(map[string]*SAVIBinding),
+	}
+}
+
+// HandleMessage returns whether given message received on given anchor from
+// given source address is allowed and the probes to send, learning address
+// ownership from duplicate address detection and neighbor advertisements
+func (t *SAVITable) HandleMessage(anchor string, src net.IP, m ICMP, now time.Time) (bool, []SAVIProbe) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch p := m.(type) {
+	case *ICMPNeighborSolicitation:
+		if src.IsUnspecified() {
+			return t.handleDAD(anchor, p.TargetAddress, now), nil
+		}
+	case *ICMPNeighborAdvertisement:
+		allowed, probes := t.handleAdvertisement(anchor, p.TargetAddress, now)
+		if !allowed || src.Equal(p.TargetAddress) {
+			return allowed, probes
+		}
+	case *ICMPRouterSolicitation:
+		// router solicitations are sent before having an address
+		if src.IsUnspecified() {
+			return true, nil
+		}
+	}
+
+	return t.check(anchor, src, now)
+}
+
+// CheckSource returns whether traffic received on given anchor from given
+// source address is allowed and the probes to send
+func (t *SAVITable) CheckSource(anchor string, src net.IP, now time.Time) (bool, []SAVIProbe) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.check(anchor, src, now)
+}
+
+// Lookup returns the binding of given address
+func (t *SAVITable) Lookup(addr net.IP) (SAVIBinding, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.bindings[addr.String()]
+	if !ok {
+		return SAVIBinding{}, false
+	}
+
+	return *b, true
+}
+
+// Bindings returns all bindings sorted by address
+func (t *SAVITable) Bindings() []SAVIBinding {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bindings := []SAVIBinding{}
+	for _, b := range t.bindings {
+		bindings = append(bindings, *b)
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		return bytes.Compare(bindings[i].Address.To16(), bindings[j].Address.To16()) < 0
+	})
+
+	return bindings
+}
+
+// RemoveAnchor removes all bindings on given anchor, like when its port
+// goes down
+func (t *SAVITable) RemoveAnchor(anchor string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, b := range t.bindings {
+		if b.Anchor == anchor {
+			delete(t.bindings, key)
+		}
+	}
+}
+
+// Expire moves bindings along whose lifetime ended at given time and
+// returns the probes to send for them
+func (t *SAVITable) Expire(now time.Time) []SAVIProbe {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	probes := []SAVIProbe{}
+	for _, b := range t.bindings {
+		probes = append(probes, t.advance(b, now)...)
+	}
+
+	return probes
+}
+
+func (t *SAVITable) handleDAD(anchor string, target net.IP, now time.Time) bool {
+	if b := t.lookup(target, now); b == nil {
+		t.claim(anchor, target, now)
+	}
+
+	// solicitations for bound addresses are allowed as well, so the owner
+	// defends its address
+	return true
+}
+
+func (t *SAVITable) handleAdvertisement(anchor string, target net.IP, now time.Time) (bool, []SAVIProbe) {
+	b := t.lookup(target, now)
+	switch {
+	case b == nil:
+		return t.check(anchor, target, now)
+	case b.Anchor == anchor:
+		// the owner confirms its address
+		if b.State != SAVITentative {
+			b.State = SAVIValid
+			b.Expires = now.Add(saviDefaultLifetime)
+		}
+
+		return true, nil
+	case b.State == SAVITentative:
+		// the address was already in use, so the duplicate address
+		// detection fails
+		delete(t.bindings, target.String())
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// check returns whether given source address is allowed on given anchor,
+// claiming it when it is not bound yet
+func (t *SAVITable) check(anchor string, src net.IP, now time.Time) (bool, []SAVIProbe) {
+	if src.To16() == nil || src.IsUnspecified() || src.IsMulticast() {
+		return false, nil
+	}
+
+	b := t.lookup(src, now)
+	if b == nil {
+		// traffic without duplicate address detection first, verify nobody
+		// else uses the address
+		if !t.claim(anchor, src, now) {
+			return false, nil
+		}
+
+		return false, []SAVIProbe{{
+			Anchor:      anchor,
+			Flood:       true,
+			Destination: SolicitedNodeMulticast(src),
+			Message:     &ICMPNeighborSolicitation{TargetAddress: src},
+		}}
+	}
+
+	if b.Anchor != anchor {
+		if b.State != SAVIValid {
+			return false, nil
+		}
+
+		// verify the owner still uses the address
+		return false, []SAVIProbe{t.test(b, now)}
+	}
+
+	switch b.State {
+	case SAVIValid:
+		b.Expires = now.Add(saviDefaultLifetime)
+		return true, nil
+	case SAVITesting:
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// claim binds given address to given anchor tentatively, unless the anchor
+// has too many bindings
+func (t *SAVITable) claim(anchor string, addr net.IP, now time.Time) bool {
+	if t.maxBindings > 0 {
+		count := 0
+		for _, b := range t.bindings {
+			if b.Anchor == anchor {
+				count++
+			}
+		}
+
+		if count >= t.maxBindings {
+			return false
+		}
+	}
+
+	t.bindings[addr.String()] = &SAVIBinding{
+		Address: addr,
+		Anchor:  anchor,
+		State:   SAVITentative,
+		Expires: now.Add(saviTentativeLifetime),
+	}
+
+	return true
+}
+
+// lookup returns the current binding of given address, valid bindings are
+// only tested by Expire
+func (t *SAVITable) lookup(addr net.IP, now time.Time) *SAVIBinding {
+	b, ok := t.bindings[addr.String()]
+	if !ok {
+		return nil
+	}
+
+	if b.State != SAVIValid {
+		t.advance(b, now)
+	}
+
+	return t.bindings[addr.String()]
+}
+
+// advance moves given binding along when its lifetime ended, returning the
+// probes to send
+func (t *SAVITable) advance(b *SAVIBinding, now time.Time) []SAVIProbe {
+	if now.Before(b.Expires) {
+		return nil
+	}
+
+	switch b.State {
+	case SAVITentative:
+		// nobody defended the address
+		b.State = SAVIValid
+		b.Expires = now.Add(saviDefaultLifetime)
+	case SAVIValid:
+		return []SAVIProbe{t.test(b, now)}
+	case SAVITesting:
+		delete(t.bindings, b.Address.String())
+	}
+
+	return nil
+}
+
+// test moves given binding to testing and returns the probe for it
+func (t *SAVITable) test(b *SAVIBinding, now time.Time) SAVIProbe {
+	b.State = SAVITesting
+	b.Expires = now.Add(saviTentativeLifetime)
+
+	return SAVIProbe{
+		Anchor:      b.Anchor,
+		Destination: SolicitedNodeMulticast(b.Address),
+		Message:     &ICMPNeighborSolicitation{TargetAddress: b.Address},
+	}
+}
@@ -0,0 +1,157 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSAVIStateString(t *testing.T) {
+	tests := []struct {
+		s   SAVIState
+		str string
+	}{
+		{SAVITentative, "tentative"},
+		{SAVIValid, "valid"},
+		{SAVITesting, "testing"},
+		{SAVIState(3), "<nil>"},
+	}
+
+	for _, test := range tests {
+		if test.s.String() != test.str {
+			t.Errorf("unexpected string for state %d: %s", test.s, test.s)
+		}
+	}
+}
+
+func TestSAVITable(t *testing.T) {
+	table := NewSAVITable(2)
+	now := time.Unix(0, 0)
+	addr := net.ParseIP("2001:db8::10")
+
+	state := func(addr net.IP) SAVIState {
+		b, ok := table.Lookup(addr)
+		if !ok {
+			return SAVIState(255)
+		}
+
+		return b.State
+	}
+
+	// duplicate address detection claims the address
+	if ok, probes := table.HandleMessage("port1", net.IPv6unspecified, &ICMPNeighborSolicitation{TargetAddress: addr}, now); !ok || len(probes) != 0 {
+		t.Errorf("unexpected verdict %t with probes %v", ok, probes)
+	}
+
+	if state(addr) != SAVITentative {
+		t.Errorf("unexpected state %s", state(addr))
+	}
+
+	// not usable until the detection is done
+	if ok, _ := table.CheckSource("port1", addr, now); ok {
+		t.Error("expected tentative address to be dropped")
+	}
+
+	// others doing the same don't take over
+	if ok, _ := table.HandleMessage("port2", net.IPv6unspecified, &ICMPNeighborSolicitation{TargetAddress: addr}, now); !ok {
+		t.Error("expected duplicate address detection to be allowed")
+	}
+
+	now = now.Add(saviTentativeLifetime)
+	if ok, _ := table.CheckSource("port1", addr, now); !ok || state(addr) != SAVIValid {
+		t.Errorf("expected valid binding, got %s", state(addr))
+	}
+
+	// spoofing from another port tests the owner
+	ok, probes := table.CheckSource("port2", addr, now)
+	if ok || len(probes) != 1 || probes[0].Anchor != "port1" || probes[0].Flood || !probes[0].Destination.Equal(SolicitedNodeMulticast(addr)) {
+		t.Fatalf("unexpected verdict %t with probes %v", ok, probes)
+	}
+
+	if state(addr) != SAVITesting {
+		t.Errorf("unexpected state %s", state(addr))
+	}
+
+	// only once
+	if ok, probes := table.CheckSource("port2", addr, now); ok || len(probes) != 0 {
+		t.Errorf("unexpected verdict %t with probes %v", ok, probes)
+	}
+
+	// the owner is still allowed while testing, and defends its address
+	if ok, _ := table.CheckSource("port1", addr, now); !ok {
+		t.Error("expected owner to be allowed")
+	}
+
+	if ok, _ := table.HandleMessage("port2", addr, &ICMPNeighborAdvertisement{TargetAddress: addr}, now); ok {
+		t.Error("expected spoofed advertisement to be dropped")
+	}
+
+	if ok, _ := table.HandleMessage("port1", addr, &ICMPNeighborAdvertisement{TargetAddress: addr}, now); !ok || state(addr) != SAVIValid {
+		t.Errorf("expected valid binding, got %s", state(addr))
+	}
+
+	// bindings are tested when their lifetime ends
+	now = now.Add(saviDefaultLifetime)
+	probes = table.Expire(now)
+	if len(probes) != 1 || probes[0].Anchor != "port1" || state(addr) != SAVITesting {
+		t.Fatalf("unexpected probes %v", probes)
+	}
+
+	// and removed when nobody answers
+	now = now.Add(saviTentativeLifetime)
+	if probes = table.Expire(now); len(probes) != 0 {
+		t.Errorf("unexpected probes %v", probes)
+	}
+
+	if _, ok := table.Lookup(addr); ok {
+		t.Error("expected binding to be removed")
+	}
+
+	// traffic without duplicate address detection is verified first
+	ok, probes = table.CheckSource("port2", addr, now)
+	if ok || len(probes) != 1 || probes[0].Anchor != "port2" || !probes[0].Flood {
+		t.Fatalf("unexpected verdict %t with probes %v", ok, probes)
+	}
+
+	if probes[0].Message.(*ICMPNeighborSolicitation).TargetAddress.String() != addr.String() {
+		t.Errorf("unexpected probe %s", probes[0].Message)
+	}
+
+	// an existing owner defends the address
+	if ok, _ := table.HandleMessage("port1", addr, &ICMPNeighborAdvertisement{TargetAddress: addr}, now); !ok {
+		t.Error("expected defending advertisement to be allowed")
+	}
+
+	if _, ok := table.Lookup(addr); ok {
+		t.Error("expected binding to be removed")
+	}
+
+	// router solicitations are allowed without address, other messages
+	// are checked
+	if ok, _ := table.HandleMessage("port1", net.IPv6unspecified, &ICMPRouterSolicitation{}, now); !ok {
+		t.Error("expected router solicitation to be allowed")
+	}
+
+	if ok, _ := table.HandleMessage("port1", net.IPv6unspecified, &ICMPEchoRequest{}, now); ok {
+		t.Error("expected unspecified source to be dropped")
+	}
+
+	// the number of bindings per anchor is limited
+	for _, a := range []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"} {
+		table.HandleMessage("port3", net.IPv6unspecified, &ICMPNeighborSolicitation{TargetAddress: net.ParseIP(a)}, now)
+	}
+
+	if _, ok := table.Lookup(net.ParseIP("2001:db8::3")); ok {
+		t.Error("expected binding over limit to be refused")
+	}
+
+	bindings := table.Bindings()
+	if len(bindings) != 2 || bindings[0].String() != "2001:db8::1 on port3, tentative" {
+		t.Errorf("unexpected bindings %v", bindings)
+	}
+
+	table.RemoveAnchor("port3")
+	if bindings = table.Bindings(); len(bindings) != 0 {
+		t.Errorf("unexpected bindings %v", bindings)
+	}
+}
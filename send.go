@@ -0,0 +1,439 @@
+package ndp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	errSignatureMissing  = errors.New("message has no rsa signature option")
+	errKeyHashMismatch   = errors.New("key hash of rsa signature option does not match public key")
+	errAlreadySigned     = errors.New("message already has an rsa signature option")
+	errOptionsNotAllowed = errors.New("message can't carry options")
+)
+
+// cgaMessageTypeTag is the CGA Message Type tag for SEND as described at
+// https://tools.ietf.org/html/rfc3971#section-5.2
+var cgaMessageTypeTag = []byte{0x08, 0x6f, 0xca, 0x5e, 0x10, 0xb2, 0x00, 0xc9, 0x9c, 0x8c, 0xe0, 0x01, 0x64, 0x27, 0x7c, 0x08}
+
+// optionMessage is implemented by all messages embedding optionContainer
+type optionMessage interface {
+	ICMP
+	container() *optionContainer
+}
+
+// CGAParameters implements the CGA Parameters data structure as described at
+// https://tools.ietf.org/html/rfc3972#section-3
+type CGAParameters struct {
+	Modifier       [16]byte
+	SubnetPrefix   net.IP
+	CollisionCount uint8
+	// PublicKey holds the DER-encoded SubjectPublicKeyInfo of the owner of
+	// the address
+	PublicKey       []byte
+	ExtensionFields []byte
+}
+
+// Marshal returns byte slice representing these CGAParameters
+func (c CGAParameters) Marshal() ([]byte, error) {
+	prefix := c.SubnetPrefix.To16()
+	if prefix == nil {
+		return nil, fmt.Errorf("invalid subnet prefix %s", c.SubnetPrefix)
+	}
+
+	if len(c.PublicKey) == 0 {
+		return nil, errors.New("cga parameters lack a public key")
+	}
+
+	b := make([]byte, 25)
+	copy(b[0:16], c.Modifier[:])
+	copy(b[16:24], prefix[:8])
+	b[24] = c.CollisionCount
+	b = append(b, c.PublicKey...)
+	b = append(b, c.ExtensionFields...)
+
+	return b, nil
+}
+
+// RSAPublicKey returns the public key of these CGAParameters or error if it
+// isn't an RSA key
+func (c CGAParameters) RSAPublicKey() (*rsa.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(c.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key of type %T is not supported", key)
+	}
+
+	return pub, nil
+}
+
+// ParseCGAParameters returns CGAParameters for given bytes or error if it
+// couldn't parse them
+func ParseCGAParameters(b []byte) (*CGAParameters, error) {
+	if len(b) < 25 {
+		return nil, errors.New("cga parameters too short")
+	}
+
+	// the public key is the only variable length field that tells its length
+	var key asn1.RawValue
+	rest, err := asn1.Unmarshal(b[25:], &key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key in cga parameters: %s", err)
+	}
+
+	if key.Class != asn1.ClassUniversal || key.Tag != asn1.TagSequence {
+		return nil, errors.New("invalid public key in cga parameters")
+	}
+
+	c := &CGAParameters{
+		SubnetPrefix:   make(net.IP, net.IPv6len),
+		CollisionCount: b[24],
+		PublicKey:      key.FullBytes,
+	}
+	copy(c.Modifier[:], b[0:16])
+	copy(c.SubnetPrefix, b[16:24])
+
+	if len(rest) > 0 {
+		c.ExtensionFields = rest
+	}
+
+	return c, nil
+}
+
+// ICMPOptionCGA implements the CGA option as described at
+// https://tools.ietf.org/html/rfc3971#section-5.1
+type ICMPOptionCGA struct {
+	Parameters CGAParameters
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionCGA) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf(": modifier %x, ", o.Parameters.Modifier)
+	s += fmt.Sprintf("prefix %s, ", o.Parameters.SubnetPrefix)
+	s += fmt.Sprintf("collision count %d, ", o.Parameters.CollisionCount)
+	s += fmt.Sprintf("public key %d bytes", len(o.Parameters.PublicKey))
+
+	return s
+}
+
+// Type returns ICMPOptionTypeCGA
+func (o ICMPOptionCGA) Type() ICMPOptionType {
+	return ICMPOptionTypeCGA
+}
+
+// Len returns the length in bytes of ICMPOptionCGA
+func (o ICMPOptionCGA) Len() uint8 {
+	l := 4 + 25 + len(o.Parameters.PublicKey) + len(o.Parameters.ExtensionFields)
+	return uint8((l + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionCGA
+func (o ICMPOptionCGA) Marshal() ([]byte, error) {
+	p, err := o.Parameters.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	l := (4 + len(p) + 7) / 8
+	if l > 255 {
+		return nil, fmt.Errorf("cga parameters of %d bytes too large", len(p))
+	}
+
+	b := make([]byte, 4, l*8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(l)
+	// option fields
+	b[2] = byte(l*8 - 4 - len(p))
+	// b[3] = reserved
+	b = append(b, p...)
+	// padding
+	b = b[:l*8]
+
+	return b, nil
+}
+
+func parseCGAOption(b []byte) (*ICMPOptionCGA, error) {
+	if len(b) < 32 {
+		return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 4", ICMPOptionTypeCGA, ICMPOptionTypeCGA, len(b)/8)
+	}
+
+	padLength := int(b[2])
+	if 4+padLength > len(b) {
+		return nil, fmt.Errorf("option %s (%d) has invalid pad length %d", ICMPOptionTypeCGA, ICMPOptionTypeCGA, padLength)
+	}
+
+	p, err := ParseCGAParameters(b[4:(len(b) - padLength)])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ICMPOptionCGA{Parameters: *p}, nil
+}
+
+// ICMPOptionRSASignature implements the RSA Signature option as described at
+// https://tools.ietf.org/html/rfc3971#section-5.2
+type ICMPOptionRSASignature struct {
+	// KeyHash holds the leftmost 128 bits of the SHA-1 hash of the public key
+	KeyHash [16]byte
+	// Signature holds the RSASSA-PKCS1-v1_5 signature. When parsed it
+	// includes the option padding since the option doesn't tell the length
+	// of the signature, which is the size of the key.
+	Signature []byte
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionRSASignature) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf(": key hash %x", o.KeyHash)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeRSASignature
+func (o ICMPOptionRSASignature) Type() ICMPOptionType {
+	return ICMPOptionTypeRSASignature
+}
+
+// Len returns the length in bytes of ICMPOptionRSASignature
+func (o ICMPOptionRSASignature) Len() uint8 {
+	return uint8((20 + len(o.Signature) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionRSASignature
+func (o ICMPOptionRSASignature) Marshal() ([]byte, error) {
+	l := (20 + len(o.Signature) + 7) / 8
+	if l > 255 {
+		return nil, fmt.Errorf("signature of %d bytes too large", len(o.Signature))
+	}
+
+	b := make([]byte, l*8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(l)
+	// option fields
+	// b[2:4] = reserved
+	copy(b[4:20], o.KeyHash[:])
+	copy(b[20:], o.Signature)
+
+	return b, nil
+}
+
+// ICMPOptionTimestamp implements the Timestamp option as described at
+// https://tools.ietf.org/html/rfc3971#section-5.3.1
+type ICMPOptionTimestamp struct {
+	Timestamp time.Time
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionTimestamp) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": %s", o.Timestamp.UTC().Format(time.RFC3339Nano))
+
+	return s
+}
+
+// Type returns ICMPOptionTypeTimestamp
+func (o ICMPOptionTimestamp) Type() ICMPOptionType {
+	return ICMPOptionTypeTimestamp
+}
+
+// Len returns the length in bytes of ICMPOptionTimestamp
+func (o ICMPOptionTimestamp) Len() uint8 {
+	// Timestamp options are always 2
+	return 2
+}
+
+// Marshal returns byte slice representing this ICMPOptionTimestamp
+func (o ICMPOptionTimestamp) Marshal() ([]byte, error) {
+	if o.Timestamp.Unix() < 0 || o.Timestamp.Unix() >= 1<<48 {
+		return nil, fmt.Errorf("timestamp %s out of range", o.Timestamp)
+	}
+
+	// option header
+	b := make([]byte, 16)
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	// b[2:8] = reserved
+	binary.BigEndian.PutUint64(b[8:16], encTimestamp(o.Timestamp))
+
+	return b, nil
+}
+
+// encTimestamp returns given time as 48.16 fixed point number of seconds
+// since the epoch
+func encTimestamp(t time.Time) uint64 {
+	frac := uint64(t.Nanosecond()) * 0x10000 / uint64(time.Second)
+	return uint64(t.Unix())<<16 | frac
+}
+
+// decTimestamp returns time for given 48.16 fixed point number of seconds
+// since the epoch
+func decTimestamp(v uint64) time.Time {
+	nsec := (v & 0xffff) * uint64(time.Second) / 0x10000
+	return time.Unix(int64(v>>16), int64(nsec))
+}
+
+// KeyHash returns the leftmost 128 bits of the SHA-1 hash of the
+// DER-encoded given public key, as used in ICMPOptionRSASignature
+func KeyHash(pub *rsa.PublicKey) ([16]byte, error) {
+	var h [16]byte
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return h, err
+	}
+
+	sum := sha1.Sum(der)
+	copy(h[:], sum[:16])
+
+	return h, nil
+}
+
+// sendDigest returns the SHA-1 hash the RSA signature is calculated over as
+// described at https://tools.ietf.org/html/rfc3971#section-5.2, for given
+// message bytes up to the RSA Signature option. The checksum is taken as 0,
+// since it is calculated over the signature itself.
+func sendDigest(src, dst net.IP, b []byte) []byte {
+	h := sha1.New()
+	h.Write(cgaMessageTypeTag)
+	h.Write(src.To16())
+	h.Write(dst.To16())
+	h.Write(b[0:2])
+	h.Write([]byte{0, 0})
+	h.Write(b[4:])
+
+	return h.Sum(nil)
+}
+
+// SignMessage protects given message sent from src to dst by adding an
+// ICMPOptionRSASignature signed by given key. Options like ICMPOptionCGA,
+// ICMPOptionTimestamp and ICMPOptionNonce should be added before signing,
+// since options following the signature aren't protected.
+func SignMessage(m ICMP, src, dst net.IP, key *rsa.PrivateKey) error {
+	om, ok := m.(optionMessage)
+	if !ok {
+		return errOptionsNotAllowed
+	}
+
+	if om.container().HasOption(ICMPOptionTypeRSASignature) {
+		return errAlreadySigned
+	}
+
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	keyHash, err := KeyHash(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, sendDigest(src, dst, b))
+	if err != nil {
+		return err
+	}
+
+	om.container().AddOption(&ICMPOptionRSASignature{
+		KeyHash:   keyHash,
+		Signature: sig,
+	})
+
+	return nil
+}
+
+// VerifyMessage checks the ICMPOptionRSASignature of the message received
+// as given bytes, sent from src to dst, against given public key, typically
+// taken from the message's ICMPOptionCGA. The signature is checked over the
+// received bytes rather than over the parsed message, since parsing ignores
+// reserved bits and padding the sender did sign. It returns nil when the
+// signature is valid.
+func VerifyMessage(b []byte, src, dst net.IP, pub *rsa.PublicKey) error {
+	m, err := ParseMessage(b)
+	if err != nil {
+		return err
+	}
+
+	om, ok := m.(optionMessage)
+	if !ok {
+		return errOptionsNotAllowed
+	}
+
+	offset, err := optionsOffset(om)
+	if err != nil {
+		return err
+	}
+
+	// only the header and the options preceding the signature are signed,
+	// options were checked for their lengths while parsing
+	var sig *ICMPOptionRSASignature
+	for offset+8 <= len(b) {
+		if ICMPOptionType(b[offset]) == ICMPOptionTypeRSASignature {
+			options, err := parseOptions(b[offset:])
+			if err != nil {
+				return err
+			}
+
+			sig = options[0].(*ICMPOptionRSASignature)
+			break
+		}
+
+		offset += int(b[offset+1]) * 8
+	}
+
+	if sig == nil {
+		return errSignatureMissing
+	}
+
+	keyHash, err := KeyHash(pub)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(keyHash[:], sig.KeyHash[:]) {
+		return errKeyHashMismatch
+	}
+
+	// strip padding off the signature
+	s := sig.Signature
+	if len(s) > pub.Size() {
+		s = s[:pub.Size()]
+	}
+
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA1, sendDigest(src, dst, b[:offset]), s)
+}
+
+// optionsOffset returns where the options of given message start, which is
+// the length of the message marshalled without its options
+func optionsOffset(om optionMessage) (int, error) {
+	c := om.container()
+	options := c.Options
+	c.Options = nil
+	b, err := om.Marshal()
+	c.Options = options
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
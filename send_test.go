@@ -0,0 +1,394 @@
+package ndp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testRSAKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+})
+
+func testCGAParameters(t *testing.T, key *rsa.PrivateKey) CGAParameters {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return CGAParameters{
+		Modifier:     [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SubnetPrefix: net.ParseIP("fe80::"),
+		PublicKey:    der,
+	}
+}
+
+func TestICMPOptionCGA(t *testing.T) {
+	option := &ICMPOptionCGA{
+		Parameters: testCGAParameters(t, testRSAKey()),
+	}
+
+	if option.Type() != ICMPOptionTypeCGA {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeCGA)
+	}
+
+	// 2048 bit keys make the option exceed 255 bytes
+	if option.Len() != 41 {
+		t.Errorf("wrong length, %d != 41", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(marshal) != 328 {
+		t.Errorf("unexpected length %d of marshalled option", len(marshal))
+	}
+
+	if marshal[0] != 11 || marshal[1] != 41 || marshal[2] != 5 {
+		t.Errorf("unexpected option header %v", marshal[0:4])
+	}
+
+	descfix := "cga option (11), length 328 (41): modifier 0102030405060708090a0b0c0d0e0f10, prefix fe80::, collision count 0, public key 294 bytes"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(options) != 1 {
+		t.Fatalf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionCGA)
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	pub, err := parsed.Parameters.RSAPublicKey()
+	if err != nil {
+		t.Error(err)
+	} else if !pub.Equal(&testRSAKey().PublicKey) {
+		t.Error("parsed public key did not match")
+	}
+
+	// extension fields follow the public key
+	option.Parameters.ExtensionFields = []byte{0, 1, 0, 2, 42, 42}
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(options[0].(*ICMPOptionCGA).Parameters.ExtensionFields, option.Parameters.ExtensionFields) {
+		t.Errorf("extension fields %v did not match %v", options[0].(*ICMPOptionCGA).Parameters.ExtensionFields, option.Parameters.ExtensionFields)
+	}
+
+	// public key should be valid DER
+	marshal[29] = 0xff
+	if _, err := parseOptions(marshal); err == nil {
+		t.Error("expected error for invalid public key")
+	}
+}
+
+func TestICMPOptionRSASignature(t *testing.T) {
+	option := &ICMPOptionRSASignature{
+		KeyHash:   [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Signature: []byte{1, 2, 3, 4},
+	}
+
+	if option.Type() != ICMPOptionTypeRSASignature {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeRSASignature)
+	}
+
+	if option.Len() != 3 {
+		t.Errorf("wrong length, %d != 3", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{12, 3, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 1, 2, 3, 4}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "rsa signature option (12), length 24 (3): key hash 0102030405060708090a0b0c0d0e0f10"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsedMarshal, err := options[0].Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPOptionTimestamp(t *testing.T) {
+	option := &ICMPOptionTimestamp{
+		Timestamp: time.Unix(1700000000, 500000000),
+	}
+
+	if option.Type() != ICMPOptionTypeTimestamp {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeTimestamp)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{13, 2, 0, 0, 0, 0, 0, 0, 0, 0, 101, 83, 241, 0, 128, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "timestamp option (13), length 16 (2): 2023-11-14T22:13:20.5Z"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionTimestamp)
+	if !parsed.Timestamp.Equal(option.Timestamp) {
+		t.Errorf("parsed timestamp %s did not match %s", parsed.Timestamp, option.Timestamp)
+	}
+
+	// precision is 1/65536th of a second
+	ts := time.Unix(1700000000, 123456789)
+	if d := decTimestamp(encTimestamp(ts)).Sub(ts); d > 0 || d <= -(time.Second/0x10000) {
+		t.Errorf("unexpected rounding of %s", d)
+	}
+
+	if _, err := (&ICMPOptionTimestamp{}).Marshal(); err == nil {
+		t.Error("expected error for timestamp before the epoch")
+	}
+}
+
+func TestSignVerifyMessage(t *testing.T) {
+	key := testRSAKey()
+	src := net.ParseIP("fe80::1")
+	dst := net.ParseIP("ff02::1")
+
+	ra := &ICMPRouterAdvertisement{
+		HopLimit:       64,
+		RouterLifeTime: 1800,
+	}
+	ra.AddOption(&ICMPOptionSourceLinkLayerAddress{
+		LinkLayerAddress: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	})
+	ra.AddOption(&ICMPOptionCGA{Parameters: testCGAParameters(t, key)})
+	ra.AddOption(&ICMPOptionTimestamp{Timestamp: time.Unix(1700000000, 0)})
+	ra.AddOption(&ICMPOptionNonce{Nonce: 123456})
+
+	unsigned, err := ra.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyMessage(unsigned, src, dst, &key.PublicKey); err != errSignatureMissing {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := SignMessage(ra, src, dst, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SignMessage(ra, src, dst, key); err != errAlreadySigned {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	marshal, err := ra.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signed messages easily exceed 255 bytes
+	if desc := ra.String(); !strings.HasPrefix(desc, fmt.Sprintf("%s, length %d\n", ra.Type(), len(marshal))) {
+		t.Errorf("unexpected length in '%s'", desc)
+	}
+
+	// the receiving end takes the key from the cga option
+	parsedICMP, err := ParseMessage(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := parsedICMP.(*ICMPRouterAdvertisement).GetOption(ICMPOptionTypeCGA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := (*o).(*ICMPOptionCGA).Parameters.RSAPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyMessage(marshal, src, dst, pub); err != nil {
+		t.Errorf("valid signature did not verify: %s", err)
+	}
+
+	// options after the signature aren't protected
+	mtu := append(append([]byte{}, marshal...), 5, 1, 0, 0, 0, 0, 5, 220)
+	if err := VerifyMessage(mtu, src, dst, pub); err != nil {
+		t.Errorf("valid signature did not verify: %s", err)
+	}
+
+	// addresses are protected
+	if err := VerifyMessage(marshal, net.ParseIP("fe80::2"), dst, pub); err == nil {
+		t.Error("expected error for spoofed source address")
+	}
+
+	// header is protected
+	tampered := append([]byte{}, marshal...)
+	tampered[4] = 255
+	if err := VerifyMessage(tampered, src, dst, pub); err == nil {
+		t.Error("expected error for tampered message")
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyMessage(marshal, src, dst, &other.PublicKey); err != errKeyHashMismatch {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// neighbor discovery is protected the same way
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}
+	ns.AddOption(&ICMPOptionNonce{Nonce: 42})
+	if err := SignMessage(ns, src, SolicitedNodeMulticast(ns.TargetAddress), other); err != nil {
+		t.Fatal(err)
+	}
+
+	marshal, err = ns.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyMessage(marshal, src, SolicitedNodeMulticast(ns.TargetAddress), &other.PublicKey); err != nil {
+		t.Errorf("valid signature did not verify: %s", err)
+	}
+
+	if err := SignMessage(&ICMPEchoRequest{}, src, dst, key); err != errOptionsNotAllowed {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := VerifyMessage([]byte{128, 0, 0, 0, 0, 0, 0, 0}, src, dst, pub); err != errOptionsNotAllowed {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := VerifyMessage(nil, src, dst, pub); err != errMessageTooShort {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyMessagePeerSigned(t *testing.T) {
+	key := testRSAKey()
+	src := net.ParseIP("fe80::1")
+	dst := SolicitedNodeMulticast(net.ParseIP("fe80::2"))
+
+	// neighbor solicitation signed by another implementation, with its
+	// reserved bits set, which don't survive parsing
+	b := []byte{135, 0, 0, 0, 0xde, 0xad, 0xbe, 0xef}
+	b = append(b, net.ParseIP("fe80::2")...)
+	b = append(b, 14, 1, 1, 2, 3, 4, 5, 6)
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, sendDigest(src, dst, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyHash, err := KeyHash(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	option, err := (&ICMPOptionRSASignature{KeyHash: keyHash, Signature: sig}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b = append(b, option...)
+
+	parsed, err := ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	marshal, err := parsed.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(marshal, b) {
+		t.Fatal("expected fixture not to survive parsing")
+	}
+
+	if err := VerifyMessage(b, src, dst, &key.PublicKey); err != nil {
+		t.Errorf("valid signature did not verify: %s", err)
+	}
+
+	// flipping a reserved bit breaks the signature
+	b[4] ^= 1
+	if err := VerifyMessage(b, src, dst, &key.PublicKey); err == nil {
+		t.Error("expected error for tampered reserved bits")
+	}
+}